@@ -0,0 +1,17 @@
+//go:build purego || appengine || tinygo
+
+package iptrie
+
+import (
+	"encoding/binary"
+	"net/netip"
+)
+
+// addr128 returns the 128-bit form of addr, which for an IPv4 address is its IPv4-mapped IPv6 form.
+//
+// This is the portable implementation, used when building with the purego or appengine tag, or with TinyGo, which makes
+// no assumption about the layout of netip.Addr.
+func addr128(addr netip.Addr) uint128 {
+	b := addr.As16()
+	return uint128{binary.BigEndian.Uint64(b[:8]), binary.BigEndian.Uint64(b[8:])}
+}
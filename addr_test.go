@@ -0,0 +1,16 @@
+package iptrie
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddr128(t *testing.T) {
+	for _, s := range []string{"0.0.0.0", "10.1.2.3", "255.255.255.255", "::", "::ffff:192.0.2.1", "2001:db8::1", "fe80::1%eth0"} {
+		addr := netip.MustParseAddr(s)
+		u := addr128(addr)
+		assert.Equal(t, addr.As16(), addrFrom128(u).As16(), s)
+	}
+}
@@ -0,0 +1,28 @@
+//go:build !purego && !appengine && !tinygo
+
+package iptrie
+
+import (
+	"net/netip"
+	"unsafe"
+)
+
+// addr128 returns the 128-bit form of addr, which for an IPv4 address is its IPv4-mapped IPv6 form.
+//
+// This reads the underlying data of the netip.Addr directly, avoiding the byte swapping of As16. Build with the purego
+// or appengine tag to use the portable implementation in addr_safe.go instead. TinyGo always uses the portable
+// implementation.
+func addr128(addr netip.Addr) uint128 {
+	return *(*uint128)(unsafe.Pointer(&addr))
+}
+
+func init() {
+	// Accessing the underlying data of a `netip.Addr` relies upon the data being
+	// in a known format, which is not guaranteed to be stable. So this init()
+	// function is to detect if it ever changes.
+	ip := netip.AddrFrom16([16]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15})
+	i128 := addr128(ip)
+	if i128.hi != 0x0001020304050607 || i128.lo != 0x08090a0b0c0d0e0f {
+		panic("netip.Addr format mismatch")
+	}
+}
@@ -0,0 +1,22 @@
+package iptrie
+
+// arenaSlabSize is the number of nodes allocated at once by a nodeArena.
+const arenaSlabSize = 4096
+
+// nodeArena allocates nodes out of large contiguous slabs, rather than individually on the heap. It's used when many
+// nodes are created at once, such as by BuildFromSorted and when decoding the binary format.
+//
+// Nodes are never returned to the arena. The memory of a slab is only reclaimed once all nodes within it are no longer
+// referenced.
+type nodeArena struct {
+	slab []node
+}
+
+func (na *nodeArena) alloc() *node {
+	if len(na.slab) == 0 {
+		na.slab = make([]node, arenaSlabSize)
+	}
+	n := &na.slab[0]
+	na.slab = na.slab[1:]
+	return n
+}
@@ -0,0 +1,100 @@
+package iptrie
+
+import (
+	"net/netip"
+	"sync/atomic"
+)
+
+// AtomicTrie holds a Trie which is replaced as a whole, such as when a list of networks is reloaded, allowing lookups
+// to continue against the previous trie while the next one is built. Unlike RCUTrie, which supports modifying
+// individual entries, the trie is never modified once stored, and is swapped with a single atomic pointer store.
+//
+// An AtomicTrie is safe for concurrent use. The tries stored in it must not be modified after being stored.
+type AtomicTrie struct {
+	trie atomic.Pointer[Trie]
+}
+
+// NewAtomicTrie creates an AtomicTrie holding pt. If pt is nil, it holds a new empty Trie.
+func NewAtomicTrie(pt *Trie) *AtomicTrie {
+	if pt == nil {
+		pt = NewTrie()
+	}
+	at := &AtomicTrie{}
+	at.trie.Store(pt)
+	return at
+}
+
+// Load returns the current trie. This can be used to perform multiple lookups against a consistent view.
+//
+// The returned Trie must not be modified.
+func (at *AtomicTrie) Load() *Trie {
+	return at.trie.Load()
+}
+
+// Store replaces the current trie with pt.
+func (at *AtomicTrie) Store(pt *Trie) {
+	at.trie.Store(pt)
+}
+
+// Swap replaces the current trie with pt, returning the previous one.
+func (at *AtomicTrie) Swap(pt *Trie) *Trie {
+	return at.trie.Swap(pt)
+}
+
+// Find returns the value from the most specific network (largest prefix) containing the given address.
+func (at *AtomicTrie) Find(ip netip.Addr) any {
+	return at.Load().Find(ip)
+}
+
+// FindLargest returns the value from the largest network (smallest prefix) containing the given address.
+func (at *AtomicTrie) FindLargest(ip netip.Addr) any {
+	return at.Load().FindLargest(ip)
+}
+
+// Contains indicates whether the trie contains the given ip.
+func (at *AtomicTrie) Contains(ip netip.Addr) bool {
+	return at.Load().Contains(ip)
+}
+
+// ContainingNetworks returns the list of networks containing the given ip in ascending prefix order (largest network to
+// smallest).
+//
+// Note: Inserted addresses are normalized to IPv6, so the returned list will be IPv6 only, unless SetDenormalize was
+// used on the trie.
+func (at *AtomicTrie) ContainingNetworks(ip netip.Addr) []netip.Prefix {
+	return at.Load().ContainingNetworks(ip)
+}
+
+// ContainingNetworksAppend is like ContainingNetworks, but appends the networks to dst and returns the extended slice.
+func (at *AtomicTrie) ContainingNetworksAppend(dst []netip.Prefix, ip netip.Addr) []netip.Prefix {
+	return at.Load().ContainingNetworksAppend(dst, ip)
+}
+
+// CoveredNetworks returns the list of networks contained within the given network.
+//
+// Note: Inserted addresses are normalized to IPv6, so the returned list will be IPv6 only, unless SetDenormalize was
+// used on the trie.
+func (at *AtomicTrie) CoveredNetworks(network netip.Prefix) []netip.Prefix {
+	return at.Load().CoveredNetworks(network)
+}
+
+// CoveredNetworksAppend is like CoveredNetworks, but appends the networks to dst and returns the extended slice.
+func (at *AtomicTrie) CoveredNetworksAppend(dst []netip.Prefix, network netip.Prefix) []netip.Prefix {
+	return at.Load().CoveredNetworksAppend(dst, network)
+}
+
+// IsFullyCovered indicates whether every address within the given network is covered by an entry in the trie.
+func (at *AtomicTrie) IsFullyCovered(network netip.Prefix) bool {
+	return at.Load().IsFullyCovered(network)
+}
+
+// Walk calls fn for each entry of the current trie in depth order, until fn returns false. A trie stored during the
+// walk is not seen by it.
+func (at *AtomicTrie) Walk(fn func(network netip.Prefix, value any) bool) {
+	at.Load().Walk(fn)
+}
+
+// String returns string representation of trie.
+func (at *AtomicTrie) String() string {
+	return at.Load().String()
+}
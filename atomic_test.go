@@ -0,0 +1,49 @@
+package iptrie
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAtomicTrie(t *testing.T) {
+	at := NewAtomicTrie(nil)
+	assert.False(t, at.Load().Contains(netip.MustParseAddr("10.0.0.1")))
+
+	first := NewTrie()
+	first.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	at.Store(first)
+	assert.Same(t, first, at.Load())
+
+	second := NewTrie()
+	assert.Same(t, first, at.Swap(second))
+	assert.Same(t, second, at.Load())
+}
+
+func TestAtomicTrieReads(t *testing.T) {
+	pt := NewTrie()
+	pt.SetDenormalize(true)
+	pt.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	pt.Insert(netip.MustParsePrefix("10.1.0.0/16"), "b")
+	at := NewAtomicTrie(pt)
+
+	assert.Equal(t, "b", at.Find(netip.MustParseAddr("10.1.0.1")))
+	assert.Equal(t, "a", at.FindLargest(netip.MustParseAddr("10.1.0.1")))
+	assert.True(t, at.Contains(netip.MustParseAddr("10.2.0.1")))
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("10.1.0.0/16")},
+		at.ContainingNetworks(netip.MustParseAddr("10.1.0.1")))
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("10.1.0.0/16")},
+		at.CoveredNetworks(netip.MustParsePrefix("10.1.0.0/15")))
+	assert.False(t, at.IsFullyCovered(netip.MustParsePrefix("10.0.0.0/7")))
+	assert.Equal(t, pt.String(), at.String())
+
+	at.Store(NewTrie())
+	assert.Nil(t, at.Find(netip.MustParseAddr("10.1.0.1")))
+	var walked int
+	at.Walk(func(network netip.Prefix, value any) bool {
+		walked++
+		return true
+	})
+	assert.Zero(t, walked)
+}
@@ -3,6 +3,8 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"encoding/csv"
+	"flag"
 	"fmt"
 	"go/ast"
 	"go/doc"
@@ -21,7 +23,24 @@ import (
 	"golang.org/x/text/message"
 )
 
+var markdownPath = flag.String("results.md", "", "Write the comparison tables as Markdown to the given path.")
+var csvPath = flag.String("results.csv", "", "Write the comparison results as CSV to the given path.")
+
 func TestMain(m *testing.M) {
+	flag.Parse()
+	// Allocations are included in the results, so report them unless explicitly disabled.
+	benchmemSet := false
+	flag.Visit(func(f *flag.Flag) { benchmemSet = benchmemSet || f.Name == "test.benchmem" })
+	if !benchmemSet {
+		flag.Set("test.benchmem", "true")
+	}
+	if *bgpSource != "" {
+		if err := useBGPPrefixes(*bgpSource); err != nil {
+			fmt.Fprintf(os.Stderr, "loading BGP prefixes: %s\n", err)
+			os.Exit(1)
+		}
+	}
+
 	r, w, err := os.Pipe()
 	if err != nil {
 		os.Exit(m.Run())
@@ -40,11 +59,36 @@ func TestMain(m *testing.M) {
 	os.Stdout = stdoutOrig
 	w.Close()
 	wg.Wait()
-	fmt.Printf("\n%s\n", parseToTable(buf))
+
+	results := parseResults(buf)
+	tables := results.tables()
+	fmt.Printf("\n%s\n", tables)
+	if *markdownPath != "" {
+		if err := os.WriteFile(*markdownPath, []byte(tables), 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "writing Markdown results: %s\n", err)
+			code = 1
+		}
+	}
+	if *csvPath != "" {
+		if err := results.writeCSVFile(*csvPath); err != nil {
+			fmt.Fprintf(os.Stderr, "writing CSV results: %s\n", err)
+			code = 1
+		}
+	}
 	os.Exit(code)
 }
 
-func parseToTable(buf *bytes.Buffer) string {
+// benchResults holds the results of each test for each package, indexed by test name and then package name.
+type benchResults struct {
+	opsPerSec        map[string]map[string]float64
+	bytesPerRoute    map[string]map[string]float64
+	allocsPerElement map[string]map[string]float64
+	// latencyNs is indexed by test name with the percentile appended.
+	latencyNs map[string]map[string]float64
+}
+
+// parseResults parses the output of the benchmarks.
+func parseResults(buf *bytes.Buffer) benchResults {
 	scnr := bufio.NewScanner(buf)
 
 	scnr.Scan() // drop goos
@@ -52,7 +96,12 @@ func parseToTable(buf *bytes.Buffer) string {
 	scnr.Scan() // drop pkg
 	scnr.Scan() // drop cpu
 
-	testTimes := map[string]map[string]int{}
+	results := benchResults{
+		opsPerSec:        map[string]map[string]float64{},
+		bytesPerRoute:    map[string]map[string]float64{},
+		allocsPerElement: map[string]map[string]float64{},
+		latencyNs:        map[string]map[string]float64{},
+	}
 	for scnr.Scan() {
 		line := strings.TrimRight(scnr.Text(), "\n")
 
@@ -66,74 +115,195 @@ func parseToTable(buf *bytes.Buffer) string {
 		}
 
 		nameParts := strings.SplitN(cols[0], "/", 2)
+		if len(nameParts) < 2 {
+			continue
+		}
 		testName := strings.TrimPrefix(nameParts[0], "Benchmark")
 		testName = strings.ReplaceAll(testName, "_", " ")
 		pkgName := strings.SplitN(nameParts[1], "-", 2)[0]
 
-		if _, ok := testTimes[testName]; !ok {
-			testTimes[testName] = map[string]int{}
-		}
-
 		var nsop float64
-		for i := 2; i < len(cols); i += 2 {
+		batchSize := 1
+		bytesPerRoute := -1.0
+		allocsPerOp := -1.0
+		var latency []string
+		for i := 2; i+1 < len(cols); i += 2 {
 			switch cols[i+1] {
 			case "ns/op":
 				nsop, _ = strconv.ParseFloat(cols[i], 64)
 			case "batch_size":
-				batch_size, _ := strconv.Atoi(cols[i])
-				nsop /= float64(batch_size)
+				batchSize, _ = strconv.Atoi(cols[i])
+			case "bytes/route":
+				bytesPerRoute, _ = strconv.ParseFloat(cols[i], 64)
+			case "allocs/op":
+				allocsPerOp, _ = strconv.ParseFloat(cols[i], 64)
+			case "p50-ns", "p99-ns":
+				latency = append(latency, cols[i], cols[i+1])
 			}
 		}
 
-		testTimes[testName][pkgName], _ = strconv.Atoi(cols[2])
+		// Memory and latency tests get their own tables, as their timings would only duplicate the other tests, or
+		// include the overhead of the measurement.
+		if bytesPerRoute >= 0 {
+			setResult(results.bytesPerRoute, testName, pkgName, bytesPerRoute)
+			continue
+		}
+		if len(latency) > 0 {
+			for i := 0; i < len(latency); i += 2 {
+				ns, _ := strconv.ParseFloat(latency[i], 64)
+				setResult(results.latencyNs, testName+" "+strings.TrimSuffix(latency[i+1], "-ns"), pkgName, ns)
+			}
+			continue
+		}
+		if allocsPerOp >= 0 {
+			setResult(results.allocsPerElement, testName, pkgName, allocsPerOp/float64(batchSize))
+		}
+		if nsop > 0 {
+			setResult(results.opsPerSec, testName, pkgName, float64(1e9)/nsop*float64(batchSize))
+		}
 	}
+	return results
+}
 
-	pkgNames := []string{}
-	for _, times := range testTimes {
-		for k, _ := range times {
-			pkgNames = append(pkgNames, k)
-		}
-		break
+func setResult(results map[string]map[string]float64, testName, pkgName string, value float64) {
+	if _, ok := results[testName]; !ok {
+		results[testName] = map[string]float64{}
 	}
-	sort.Strings(pkgNames)
+	results[testName][pkgName] = value
+}
 
-	opsPerBatch := 10000
+// tables renders the results as Markdown tables.
+func (br benchResults) tables() string {
 	tblBuf := bytes.NewBuffer(nil)
-	tbl := tablewriter.NewWriter(tblBuf)
+	p := message.NewPrinter(language.English)
+
+	// Operations per second, with the percentage compared to the fastest result in parentheses.
+	renderTable(tblBuf, "*(OPs/Sec)*", br.opsPerSec, false, func(value, best float64) string {
+		return p.Sprintf("%.0f (%.1f%%)", value, value/best*100)
+	})
+
+	// The remaining tables are smaller is better, with the percentage compared to the smallest result in parentheses.
+	for _, tbl := range []struct {
+		header  string
+		results map[string]map[string]float64
+		format  string
+	}{
+		{"*(Bytes/Route)*", br.bytesPerRoute, "%.1f"},
+		{"*(Allocs/Element)*", br.allocsPerElement, "%.3f"},
+		{"*(Latency ns)*", br.latencyNs, "%.0f"},
+	} {
+		if len(tbl.results) == 0 {
+			continue
+		}
+		tblBuf.WriteString("\n")
+		renderTable(tblBuf, tbl.header, tbl.results, true, func(value, best float64) string {
+			if best <= 0 {
+				return p.Sprintf(tbl.format, value)
+			}
+			return p.Sprintf(tbl.format+" (%.1f%%)", value, value/best*100)
+		})
+	}
+
+	return tblBuf.String()
+}
+
+// renderTable writes a table with a row for each test, and a column for each package. Each cell is formatted along
+// with the best result of its row, which is the smallest if lowerIsBetter, and otherwise the largest.
+func renderTable(w io.Writer, header string, results map[string]map[string]float64, lowerIsBetter bool,
+	format func(value, best float64) string) {
+	// Not every test supports every package, so collect the names from all of them.
+	pkgNames := resultPkgNames(results)
+
+	tbl := tablewriter.NewWriter(w)
 	tbl.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
 	tbl.SetCenterSeparator("|")
 	tbl.SetAutoFormatHeaders(false)
-	tbl.SetHeader(append([]string{"*(OPs/Sec)*"}, pkgNames...))
-	var testNames []string
-	for testName, _ := range testTimes {
-		testNames = append(testNames, testName)
-	}
-	sort.Strings(testNames)
-	p := message.NewPrinter(language.English)
-	for _, testName := range testNames {
-		times := testTimes[testName]
+	tbl.SetHeader(append([]string{header}, pkgNames...))
+	for _, testName := range resultTestNames(results) {
+		values := results[testName]
 		row := []string{testName}
-		minNsOp := 0
-		for _, nsop := range times {
-			if minNsOp == 0 || nsop < minNsOp {
-				minNsOp = nsop
+		best := 0.0
+		first := true
+		for _, value := range values {
+			if first || (lowerIsBetter && value < best) || (!lowerIsBetter && value > best) {
+				best = value
+				first = false
 			}
 		}
-		opPerSecMax := float64(1e9) / float64(minNsOp) * float64(opsPerBatch)
 		for _, pkgName := range pkgNames {
-			if _, ok := times[pkgName]; !ok {
+			value, ok := values[pkgName]
+			if !ok {
 				row = append(row, "N/A")
 				continue
 			}
-			nsop := times[pkgName]
-			opPerSec := float64(1e9) / float64(nsop) * float64(opsPerBatch)
-			diff := opPerSec / opPerSecMax * 100
-			row = append(row, p.Sprintf("%.0f (%.1f%%)", opPerSec, diff))
+			row = append(row, format(value, best))
 		}
 		tbl.Append(row)
 	}
 	tbl.Render()
-	return tblBuf.String()
+}
+
+// writeCSVFile writes the results to the file at path as CSV, with a row per test, package, and metric.
+func (br benchResults) writeCSVFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := br.writeCSV(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (br benchResults) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"test", "package", "metric", "value"})
+	for _, metric := range []struct {
+		name    string
+		results map[string]map[string]float64
+	}{
+		{"ops/sec", br.opsPerSec},
+		{"bytes/route", br.bytesPerRoute},
+		{"allocs/element", br.allocsPerElement},
+		{"ns", br.latencyNs},
+	} {
+		for _, testName := range resultTestNames(metric.results) {
+			for _, pkgName := range resultPkgNames(metric.results) {
+				value, ok := metric.results[testName][pkgName]
+				if !ok {
+					continue
+				}
+				cw.Write([]string{testName, pkgName, metric.name, strconv.FormatFloat(value, 'f', -1, 64)})
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func resultTestNames(results map[string]map[string]float64) []string {
+	var testNames []string
+	for testName := range results {
+		testNames = append(testNames, testName)
+	}
+	sort.Strings(testNames)
+	return testNames
+}
+
+func resultPkgNames(results map[string]map[string]float64) []string {
+	pkgNameSet := map[string]bool{}
+	for _, values := range results {
+		for k := range values {
+			pkgNameSet[k] = true
+		}
+	}
+	pkgNames := []string{}
+	for k := range pkgNameSet {
+		pkgNames = append(pkgNames, k)
+	}
+	sort.Strings(pkgNames)
+	return pkgNames
 }
 
 func getFuncDesc(fName string) string {
@@ -8,12 +8,19 @@ import (
 	"math/rand"
 	"net"
 	"net/netip"
+	"runtime"
 	"sort"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/asergeyev/nradix"
+	"github.com/gaissmai/bart"
 	infoblox "github.com/infobloxopen/go-trees/iptree"
+	"github.com/kentik/patricia"
+	patriciatree "github.com/kentik/patricia/generics_tree"
 	"github.com/phemmer/go-iptrie"
 	"github.com/yl2chen/cidranger"
 )
@@ -32,6 +39,50 @@ var LoadNetsSorted []string
 var LookupIPs []string
 var LookupResults []any
 
+// LookupIPsByHitRate contains addresses to look up where exactly the given percentage is matched by LoadNets.
+var LookupIPsByHitRate map[int][]string
+var CoverNets []string
+
+// randHost returns a random address within the given network.
+func randHost(network string) string {
+	pfx := netip.MustParsePrefix(network)
+	// Since we populated the list with IPv4 addresses, hostSize is guaranteed to be < 32
+	hostSize := 32 - pfx.Bits()
+	host := rng.Intn(1 << hostSize)
+
+	pfxBytes := pfx.Masked().Addr().As4()
+	pfxInt := binary.BigEndian.Uint32(pfxBytes[:])
+	hostBytes := binary.BigEndian.AppendUint32(nil, pfxInt|uint32(host))
+	return netip.AddrFrom4([4]byte(hostBytes)).String()
+}
+
+// makeLookupIPs returns count addresses, of which hitRate percent are within a network from LoadNets, and the remainder
+// are not within any.
+func makeLookupIPs(count int, hitRate int) []string {
+	trie := iptrie.NewTrie()
+	for _, network := range LoadNets {
+		trie.Insert(netip.MustParsePrefix(network), nil)
+	}
+
+	ips := make([]string, 0, count)
+	hits := count * hitRate / 100
+	for len(ips) < hits {
+		ips = append(ips, randHost(LoadNets[rng.Intn(len(LoadNets))]))
+	}
+	for attempts := 0; len(ips) < count; attempts++ {
+		if attempts == count*1000 {
+			panic("unable to find addresses outside of LoadNets")
+		}
+		ip := randIP(24)
+		if trie.Contains(netip.AddrFrom4([4]byte(ip))) {
+			continue
+		}
+		ips = append(ips, ip.String())
+	}
+	rng.Shuffle(len(ips), func(i, j int) { ips[i], ips[j] = ips[j], ips[i] })
+	return ips
+}
+
 type netSorter []string
 
 func (ns netSorter) Len() int {
@@ -47,10 +98,19 @@ func (ns netSorter) Swap(i, j int) {
 func init() {
 	for len(LoadNets) < 100000 {
 		ip := randIP(24)
+		// Leave the reserved 240.0.0.0/4 out of the tree, as in a real BGP table, so that lookups have somewhere to miss.
+		if ip[0] >= 240 {
+			continue
+		}
 		mask := strconv.Itoa(rand.Intn(25) + 8)
 		LoadNets = append(LoadNets, ip.String()+"/"+mask)
 	}
 
+	deriveData()
+}
+
+// deriveData constructs the test data derived from LoadNets.
+func deriveData() {
 	LoadNetsSorted = make([]string, len(LoadNets))
 	copy(LoadNetsSorted, LoadNets)
 	sort.Sort(netSorter(LoadNetsSorted))
@@ -59,15 +119,7 @@ func init() {
 	LookupIPs = make([]string, 10000)
 	take := len(LookupIPs) / 10
 	for i := 0; i < take; i++ {
-		pfx := netip.MustParsePrefix(LoadNets[i])
-		// Since we populated the list with IPv4 addresses, hostSize is guaranteed to be < 32
-		hostSize := 32 - pfx.Bits()
-		host := rng.Intn(1 << hostSize)
-
-		pfxBytes := pfx.Masked().Addr().As4()
-		pfxInt := binary.BigEndian.Uint32(pfxBytes[:])
-		hostBytes := binary.BigEndian.AppendUint32(nil, pfxInt|uint32(host))
-		LookupIPs[i] = netip.AddrFrom4([4]byte(hostBytes)).String()
+		LookupIPs[i] = randHost(LoadNets[i])
 	}
 	for i := take; i < len(LookupIPs); i++ {
 		ip := randIP(24)
@@ -75,6 +127,18 @@ func init() {
 	}
 
 	LookupResults = make([]any, len(LookupIPs))
+
+	LookupIPsByHitRate = map[int][]string{}
+	for _, hitRate := range []int{0, 50, 100} {
+		LookupIPsByHitRate[hitRate] = makeLookupIPs(len(LookupIPs), hitRate)
+	}
+
+	// Construct CoverNets with prefix lengths short enough that each typically covers a number of LoadNets.
+	CoverNets = make([]string, 1000)
+	for i := range CoverNets {
+		pfx := netip.PrefixFrom(netip.AddrFrom4([4]byte(randIP(24))), rng.Intn(9)+8).Masked()
+		CoverNets[i] = pfx.String()
+	}
 }
 
 type pkg interface {
@@ -88,8 +152,41 @@ type pkg interface {
 	Check([]any, *[]bool)
 }
 
+// containingPkg is implemented by the packages which can list all networks containing an address.
+type containingPkg interface {
+	pkg
+	// ContainingNetworks stores the number of networks containing each address.
+	ContainingNetworks([]any, *[]int)
+}
+
+// coveredPkg is implemented by the packages which can list all networks within a network.
+type coveredPkg interface {
+	pkg
+	ConvertNetNative(string) any
+	// CoveredNetworks stores the number of networks covered by each network.
+	CoveredNetworks([]any, *[]int)
+}
+
+// removerPkg is implemented by the packages which support removing networks.
+type removerPkg interface {
+	pkg
+	RemoveNets([]string)
+}
+
+// concurrentPkg is implemented by packages which support lookups concurrently with modifications.
+type concurrentPkg interface {
+	pkg
+	// ConcurrentInit creates a new tree loaded with the given networks, for use with the other concurrent methods.
+	ConcurrentInit(nets []string)
+	// ConcurrentLookup is the same as Lookup, but may be called concurrently with itself and ConcurrentLoadNets.
+	ConcurrentLookup(lookup []any, results *[]any)
+	// ConcurrentLoadNets is the same as LoadNets, but may be called concurrently with itself and ConcurrentLookup.
+	ConcurrentLoadNets(nets []string)
+}
+
 type IPTrie struct {
 	trie *iptrie.Trie
+	rcu  *iptrie.RCUTrie
 }
 
 func (ipt *IPTrie) Name() string {
@@ -117,6 +214,41 @@ func (ipt *IPTrie) Check(lookup []any, results *[]bool) {
 		(*results)[i] = ipt.trie.Contains(ip.(netip.Addr))
 	}
 }
+func (ipt *IPTrie) ContainingNetworks(lookup []any, results *[]int) {
+	for i, ip := range lookup {
+		(*results)[i] = len(ipt.trie.ContainingNetworks(ip.(netip.Addr)))
+	}
+}
+func (ipt *IPTrie) RemoveNets(nets []string) {
+	for _, ipStr := range nets {
+		ipt.trie.Remove(netip.MustParsePrefix(ipStr))
+	}
+}
+func (ipt *IPTrie) ConvertNetNative(netStr string) any {
+	return netip.MustParsePrefix(netStr)
+}
+func (ipt *IPTrie) CoveredNetworks(lookup []any, results *[]int) {
+	for i, network := range lookup {
+		(*results)[i] = len(ipt.trie.CoveredNetworks(network.(netip.Prefix)))
+	}
+}
+func (ipt *IPTrie) ConcurrentInit(nets []string) {
+	ipt.rcu = iptrie.NewRCUTrie()
+	ipt.ConcurrentLoadNets(nets)
+}
+func (ipt *IPTrie) ConcurrentLookup(lookup []any, results *[]any) {
+	trie := ipt.rcu.Load()
+	for i, ip := range lookup {
+		(*results)[i] = trie.Find(ip.(netip.Addr))
+	}
+}
+func (ipt *IPTrie) ConcurrentLoadNets(nets []string) {
+	txn := ipt.rcu.Txn()
+	for _, ipStr := range nets {
+		txn.Insert(netip.MustParsePrefix(ipStr), ipStr)
+	}
+	txn.Commit()
+}
 
 type RangerEntry struct {
 	net.IPNet
@@ -149,6 +281,10 @@ func (r *Ranger) ConvertIPNative(ipStr string) any {
 func (r *Ranger) Lookup(lookup []any, results *[]any) {
 	for i, ip := range lookup {
 		nets, _ := r.ranger.ContainingNetworks(ip.(net.IP))
+		if len(nets) == 0 {
+			(*results)[i] = nil
+			continue
+		}
 		(*results)[i] = nets[len(nets)-1].(RangerEntry).data
 	}
 }
@@ -157,9 +293,32 @@ func (r *Ranger) Check(lookup []any, results *[]bool) {
 		(*results)[i], _ = r.ranger.Contains(ip.(net.IP))
 	}
 }
+func (r *Ranger) ContainingNetworks(lookup []any, results *[]int) {
+	for i, ip := range lookup {
+		nets, _ := r.ranger.ContainingNetworks(ip.(net.IP))
+		(*results)[i] = len(nets)
+	}
+}
+func (r *Ranger) RemoveNets(nets []string) {
+	for _, ipStr := range nets {
+		_, ipnet, _ := net.ParseCIDR(ipStr)
+		r.ranger.Remove(*ipnet)
+	}
+}
+func (r *Ranger) ConvertNetNative(netStr string) any {
+	_, ipnet, _ := net.ParseCIDR(netStr)
+	return *ipnet
+}
+func (r *Ranger) CoveredNetworks(lookup []any, results *[]int) {
+	for i, network := range lookup {
+		nets, _ := r.ranger.CoveredNetworks(network.(net.IPNet))
+		(*results)[i] = len(nets)
+	}
+}
 
 type Infoblox struct {
-	ipt *infoblox.Tree
+	ipt    *infoblox.Tree
+	shared atomic.Pointer[infoblox.Tree]
 }
 
 func (ib *Infoblox) Name() string {
@@ -174,6 +333,13 @@ func (ib *Infoblox) LoadNets(nets []string) {
 		ib.ipt.InplaceInsertNet(ipnet, ipStr)
 	}
 }
+func (ib *Infoblox) RemoveNets(nets []string) {
+	for _, ipStr := range nets {
+		_, ipnet, _ := net.ParseCIDR(ipStr)
+		// Deletion is not in place, returning a new tree sharing the unmodified nodes.
+		ib.ipt, _ = ib.ipt.DeleteByNet(ipnet)
+	}
+}
 func (ib *Infoblox) ConvertIPNative(ipStr string) any {
 	return net.ParseIP(ipStr)
 }
@@ -187,6 +353,33 @@ func (ib *Infoblox) Check(lookup []any, results *[]bool) {
 		_, (*results)[i] = ib.ipt.GetByIP(ip.(net.IP))
 	}
 }
+func (ib *Infoblox) ConcurrentInit(nets []string) {
+	ib.shared.Store(infoblox.NewTree())
+	ib.ConcurrentLoadNets(nets)
+}
+func (ib *Infoblox) ConcurrentLookup(lookup []any, results *[]any) {
+	ipt := ib.shared.Load()
+	for i, ip := range lookup {
+		(*results)[i], _ = ipt.GetByIP(ip.(net.IP))
+	}
+}
+func (ib *Infoblox) ConcurrentLoadNets(nets []string) {
+	ipnets := make([]*net.IPNet, len(nets))
+	for i, ipStr := range nets {
+		_, ipnets[i], _ = net.ParseCIDR(ipStr)
+	}
+	// The tree is immutable, so insert into a new version and publish it, retrying if another writer got there first.
+	for {
+		old := ib.shared.Load()
+		ipt := old
+		for i, ipnet := range ipnets {
+			ipt = ipt.InsertNet(ipnet, nets[i])
+		}
+		if ib.shared.CompareAndSwap(old, ipt) {
+			return
+		}
+	}
+}
 
 type NRadix struct {
 	tree *nradix.Tree
@@ -200,7 +393,13 @@ func (nr *NRadix) Init() {
 }
 func (nr *NRadix) LoadNets(nets []string) {
 	for _, ipStr := range nets {
-		nr.tree.AddCIDR(ipStr, ipStr)
+		// SetCIDR replaces the value of a network already present, as the other packages do, rather than keeping the first.
+		nr.tree.SetCIDR(ipStr, ipStr)
+	}
+}
+func (nr *NRadix) RemoveNets(nets []string) {
+	for _, ipStr := range nets {
+		nr.tree.DeleteCIDR(ipStr)
 	}
 }
 func (nr *NRadix) ConvertIPNative(ipStr string) any {
@@ -218,11 +417,120 @@ func (nr *NRadix) Check(lookup []any, results *[]bool) {
 	}
 }
 
+type Bart struct {
+	table *bart.Table[any]
+}
+
+func (bt *Bart) Name() string {
+	return "Bart"
+}
+func (bt *Bart) Init() {
+	bt.table = &bart.Table[any]{}
+}
+func (bt *Bart) LoadNets(nets []string) {
+	for _, ipStr := range nets {
+		bt.table.Insert(netip.MustParsePrefix(ipStr).Masked(), ipStr)
+	}
+}
+func (bt *Bart) ConvertIPNative(ipStr string) any {
+	return netip.MustParseAddr(ipStr)
+}
+func (bt *Bart) Lookup(lookup []any, results *[]any) {
+	for i, ip := range lookup {
+		(*results)[i], _ = bt.table.Lookup(ip.(netip.Addr))
+	}
+}
+func (bt *Bart) Check(lookup []any, results *[]bool) {
+	for i, ip := range lookup {
+		_, (*results)[i] = bt.table.Lookup(ip.(netip.Addr))
+	}
+}
+func (bt *Bart) ContainingNetworks(lookup []any, results *[]int) {
+	for i, ip := range lookup {
+		addr := ip.(netip.Addr)
+		count := 0
+		bt.table.EachLookupPrefix(netip.PrefixFrom(addr, addr.BitLen()), func(netip.Prefix, any) bool {
+			count++
+			return true
+		})
+		(*results)[i] = count
+	}
+}
+func (bt *Bart) RemoveNets(nets []string) {
+	for _, ipStr := range nets {
+		bt.table.Delete(netip.MustParsePrefix(ipStr).Masked())
+	}
+}
+func (bt *Bart) ConvertNetNative(netStr string) any {
+	return netip.MustParsePrefix(netStr)
+}
+func (bt *Bart) CoveredNetworks(lookup []any, results *[]int) {
+	for i, network := range lookup {
+		count := 0
+		bt.table.EachSubnet(network.(netip.Prefix), func(netip.Prefix, any) bool {
+			count++
+			return true
+		})
+		(*results)[i] = count
+	}
+}
+
+// Patricia uses the IPv4 tree of kentik/patricia, as the benchmark networks are all IPv4. Its native key type is a
+// uint32 address and prefix length.
+type Patricia struct {
+	tree *patriciatree.TreeV4[any]
+}
+
+func (pt *Patricia) Name() string {
+	return "Patricia"
+}
+func (pt *Patricia) Init() {
+	pt.tree = patriciatree.NewTreeV4[any]()
+}
+func (pt *Patricia) LoadNets(nets []string) {
+	for _, ipStr := range nets {
+		pfx := netip.MustParsePrefix(ipStr).Masked()
+		addr := pfx.Addr().As4()
+		// Set rather than Add, as Add appends to the tags of an existing network rather than replacing them.
+		pt.tree.Set(patricia.NewIPv4Address(binary.BigEndian.Uint32(addr[:]), uint(pfx.Bits())), ipStr)
+	}
+}
+func (pt *Patricia) RemoveNets(nets []string) {
+	for _, ipStr := range nets {
+		pfx := netip.MustParsePrefix(ipStr).Masked()
+		addr := pfx.Addr().As4()
+		pt.tree.Delete(patricia.NewIPv4Address(binary.BigEndian.Uint32(addr[:]), uint(pfx.Bits())),
+			func(a, b any) bool { return true }, nil)
+	}
+}
+func (pt *Patricia) ConvertIPNative(ipStr string) any {
+	addr := netip.MustParseAddr(ipStr).As4()
+	return patricia.NewIPv4Address(binary.BigEndian.Uint32(addr[:]), 32)
+}
+func (pt *Patricia) Lookup(lookup []any, results *[]any) {
+	for i, ip := range lookup {
+		_, (*results)[i] = pt.tree.FindDeepestTag(ip.(patricia.IPv4Address))
+	}
+}
+func (pt *Patricia) Check(lookup []any, results *[]bool) {
+	for i, ip := range lookup {
+		(*results)[i], _ = pt.tree.FindDeepestTag(ip.(patricia.IPv4Address))
+	}
+}
+func (pt *Patricia) ContainingNetworks(lookup []any, results *[]int) {
+	for i, ip := range lookup {
+		// Each network holds a single tag, as they're inserted with Set.
+		(*results)[i] = len(pt.tree.FindTags(ip.(patricia.IPv4Address)))
+	}
+}
+
 var pkgs = []pkg{
 	&IPTrie{},
 	&Ranger{},
 	&Infoblox{},
 	&NRadix{},
+	&Bart{},
+	&Patricia{},
 }
 
 func BenchmarkLoadNets_Random(b *testing.B) {
@@ -274,26 +582,117 @@ func BenchmarkRead_Check(b *testing.B) {
 
 			b.StopTimer()
 
-			buf := bytes.NewBuffer(nil)
-			fmt.Fprintf(buf, "%+v", results)
-			cksum := crc64.Checksum(buf.Bytes(), crc64.MakeTable(crc64.ISO))
-			if cksum != checksum {
-				if checksum == 0 {
-					checksum = cksum
-				} else {
-					b.Errorf("output mismatch")
+			checkResults(b, &checksum, results)
+		})
+	}
+}
+
+func BenchmarkRead_Lookup(b *testing.B) {
+	benchmarkLookup(b, LookupIPs)
+}
+
+// BenchmarkRead_Lookup_Hit_0 is the same as 'Read Lookup', but with none of the addresses matching, to measure the miss
+// path.
+func BenchmarkRead_Lookup_Hit_0(b *testing.B) {
+	benchmarkLookup(b, LookupIPsByHitRate[0])
+}
+
+// BenchmarkRead_Lookup_Hit_50 is the same as 'Read Lookup', but with exactly half of the addresses matching.
+func BenchmarkRead_Lookup_Hit_50(b *testing.B) {
+	benchmarkLookup(b, LookupIPsByHitRate[50])
+}
+
+// BenchmarkRead_Lookup_Hit_100 is the same as 'Read Lookup', but with all of the addresses matching.
+func BenchmarkRead_Lookup_Hit_100(b *testing.B) {
+	benchmarkLookup(b, LookupIPsByHitRate[100])
+}
+
+// BenchmarkRead_Lookup_Latency performs the same lookups as 'Read Lookup', but times each lookup individually and
+// reports the 50th and 99th percentile latencies. The timings include the overhead of reading the clock.
+func BenchmarkRead_Lookup_Latency(b *testing.B) {
+	for _, pkg := range pkgs {
+		b.Run(pkg.Name(), func(b *testing.B) {
+			b.StopTimer()
+
+			b.ReportMetric(float64(len(LookupIPs)), "batch_size")
+			pkg.Init()
+			pkg.LoadNets(LoadNets)
+			lookup := make([]any, len(LookupIPs))
+			for i, ipStr := range LookupIPs {
+				lookup[i] = pkg.ConvertIPNative(ipStr)
+			}
+			results := make([]any, 1)
+			var hist latencyHistogram
+
+			b.StartTimer()
+
+			for n := 0; n < b.N; n++ {
+				for i := range lookup {
+					start := time.Now()
+					pkg.Lookup(lookup[i:i+1], &results)
+					hist.record(time.Since(start))
 				}
 			}
+
+			b.StopTimer()
+
+			b.ReportMetric(float64(hist.percentile(50)), "p50-ns")
+			b.ReportMetric(float64(hist.percentile(99)), "p99-ns")
 		})
 	}
 }
 
-func BenchmarkRead_Lookup(b *testing.B) {
+func benchmarkLookup(b *testing.B, lookupIPs []string) {
 	var checksum uint64
 	for _, pkg := range pkgs {
 		b.Run(pkg.Name(), func(b *testing.B) {
 			b.StopTimer()
 
+			b.ReportMetric(float64(len(lookupIPs)), "batch_size")
+			pkg.Init()
+			pkg.LoadNets(LoadNets)
+			lookup := make([]any, len(lookupIPs))
+			for i, ipStr := range lookupIPs {
+				lookup[i] = pkg.ConvertIPNative(ipStr)
+			}
+			results := make([]any, len(lookupIPs))
+
+			b.StartTimer()
+
+			for n := 0; n < b.N; n++ {
+				pkg.Lookup(lookup, &results)
+			}
+
+			b.StopTimer()
+
+			checkResults(b, &checksum, results)
+		})
+	}
+}
+
+// checkResults fails the benchmark if results differ from those of the previous package run, as recorded in checksum.
+func checkResults(b *testing.B, checksum *uint64, results any) {
+	buf := bytes.NewBuffer(nil)
+	fmt.Fprintf(buf, "%+v", results)
+	cksum := crc64.Checksum(buf.Bytes(), crc64.MakeTable(crc64.ISO))
+	if cksum != *checksum {
+		if *checksum == 0 {
+			*checksum = cksum
+		} else {
+			b.Errorf("output mismatch")
+		}
+	}
+}
+
+func BenchmarkRead_Containing(b *testing.B) {
+	var checksum uint64
+	for _, pkg := range pkgs {
+		pkg, ok := pkg.(containingPkg)
+		if !ok {
+			continue
+		}
+		b.Run(pkg.Name(), func(b *testing.B) {
+			b.StopTimer()
 			b.ReportMetric(float64(len(LookupIPs)), "batch_size")
 			pkg.Init()
 			pkg.LoadNets(LoadNets)
@@ -301,26 +700,196 @@ func BenchmarkRead_Lookup(b *testing.B) {
 			for i, ipStr := range LookupIPs {
 				lookup[i] = pkg.ConvertIPNative(ipStr)
 			}
-			results := make([]any, len(LookupIPs))
+			results := make([]int, len(LookupIPs))
+			b.StartTimer()
 
+			for n := 0; n < b.N; n++ {
+				pkg.ContainingNetworks(lookup, &results)
+			}
+
+			b.StopTimer()
+			checkResults(b, &checksum, results)
+		})
+	}
+}
+
+func BenchmarkRead_Covered(b *testing.B) {
+	var checksum uint64
+	for _, pkg := range pkgs {
+		pkg, ok := pkg.(coveredPkg)
+		if !ok {
+			continue
+		}
+		b.Run(pkg.Name(), func(b *testing.B) {
+			b.StopTimer()
+			b.ReportMetric(float64(len(CoverNets)), "batch_size")
+			pkg.Init()
+			pkg.LoadNets(LoadNets)
+			lookup := make([]any, len(CoverNets))
+			for i, netStr := range CoverNets {
+				lookup[i] = pkg.ConvertNetNative(netStr)
+			}
+			results := make([]int, len(CoverNets))
 			b.StartTimer()
 
 			for n := 0; n < b.N; n++ {
-				pkg.Lookup(lookup, &results)
+				pkg.CoveredNetworks(lookup, &results)
+			}
+
+			b.StopTimer()
+			checkResults(b, &checksum, results)
+		})
+	}
+}
+
+// BenchmarkMemory loads all the networks from LoadNets into an empty tree, and reports the heap used by the tree per
+// network loaded.
+func BenchmarkMemory(b *testing.B) {
+	for _, pkg := range pkgs {
+		b.Run(pkg.Name(), func(b *testing.B) {
+			var used int64
+			for n := 0; n < b.N; n++ {
+				b.StopTimer()
+				pkg.Init()
+				before := heapAlloc()
+				b.StartTimer()
+				pkg.LoadNets(LoadNets)
+				b.StopTimer()
+				used = heapAlloc() - before
+				b.StartTimer()
+			}
+			b.ReportMetric(float64(used)/float64(len(LoadNets)), "bytes/route")
+		})
+	}
+}
+
+// BenchmarkConcurrent_Read_0_Writers performs the same lookups as 'Read Lookup' from multiple goroutines in parallel,
+// using the concurrency safe mode of the package.
+func BenchmarkConcurrent_Read_0_Writers(b *testing.B) {
+	benchmarkConcurrentRead(b, 0)
+}
+
+// BenchmarkConcurrent_Read_1_Writers is the same as 'Concurrent Read 0 Writers', but with a goroutine continually
+// inserting networks while the lookups are performed.
+func BenchmarkConcurrent_Read_1_Writers(b *testing.B) {
+	benchmarkConcurrentRead(b, 1)
+}
+
+// BenchmarkConcurrent_Read_N_Writers is the same as 'Concurrent Read 1 Writers', but with as many writers as
+// GOMAXPROCS.
+func BenchmarkConcurrent_Read_N_Writers(b *testing.B) {
+	benchmarkConcurrentRead(b, runtime.GOMAXPROCS(0))
+}
+
+func benchmarkConcurrentRead(b *testing.B, writers int) {
+	var checksum uint64
+	for _, pkg := range pkgs {
+		pkg, ok := pkg.(concurrentPkg)
+		if !ok {
+			continue
+		}
+		b.Run(pkg.Name(), func(b *testing.B) {
+			b.StopTimer()
+
+			b.ReportMetric(float64(len(LookupIPs)), "batch_size")
+			pkg.Init()
+			pkg.ConcurrentInit(LoadNets)
+			lookup := make([]any, len(LookupIPs))
+			for i, ipStr := range LookupIPs {
+				lookup[i] = pkg.ConvertIPNative(ipStr)
 			}
 
+			// Validate the results before the writers start, as overlapping entries in LoadNets may change which value is
+			// found while the writers are running.
+			results := make([]any, len(LookupIPs))
+			pkg.ConcurrentLookup(lookup, &results)
+			checkResults(b, &checksum, results)
+
+			// The writers re-insert the networks already in the tree, so that the size of the tree does not change.
+			var writes atomic.Int64
+			stop := make(chan struct{})
+			var wg sync.WaitGroup
+			for w := 0; w < writers; w++ {
+				wg.Add(1)
+				go func(pos int) {
+					defer wg.Done()
+					for {
+						select {
+						case <-stop:
+							return
+						default:
+						}
+						start := pos % (len(LoadNets) - churnChunk)
+						pkg.ConcurrentLoadNets(LoadNets[start : start+churnChunk])
+						writes.Add(churnChunk)
+						pos += churnChunk
+					}
+				}(w * len(LoadNets) / writers)
+			}
+
+			b.StartTimer()
+
+			b.RunParallel(func(pb *testing.PB) {
+				results := make([]any, len(LookupIPs))
+				for pb.Next() {
+					pkg.ConcurrentLookup(lookup, &results)
+				}
+			})
+
+			b.StopTimer()
+			close(stop)
+			wg.Wait()
+			b.ReportMetric(float64(writes.Load())/float64(b.N), "writes/op")
+		})
+	}
+}
+
+// heapAlloc returns the bytes of live heap objects.
+func heapAlloc() int64 {
+	runtime.GC()
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return int64(ms.HeapAlloc)
+}
+
+// churnChunk is the number of networks removed and then inserted at a time by the Churn benchmark.
+const churnChunk = 100
+
+// BenchmarkChurn simulates the turnover of a ban list, where the oldest networks are continually removed as new ones
+// are inserted. The tree holds a sliding window over LoadNets of half its size, and each operation removes one network
+// from the start of the window and inserts one at the end, in chunks of churnChunk. The heap used by the tree once the
+// benchmark completes is reported, to show whether memory is reclaimed at a steady state.
+func BenchmarkChurn(b *testing.B) {
+	window := len(LoadNets) / 2
+	for _, pkg := range pkgs {
+		pkg, ok := pkg.(removerPkg)
+		if !ok {
+			continue
+		}
+		b.Run(pkg.Name(), func(b *testing.B) {
 			b.StopTimer()
+			batchSize := 10000
+			b.ReportMetric(float64(batchSize), "batch_size")
+			pkg.Init()
+			pkg.LoadNets(LoadNets[:window])
+			pos := 0
+			b.StartTimer()
 
-			buf := bytes.NewBuffer(nil)
-			fmt.Fprintf(buf, "%+v", results)
-			cksum := crc64.Checksum(buf.Bytes(), crc64.MakeTable(crc64.ISO))
-			if cksum != checksum {
-				if checksum == 0 {
-					checksum = cksum
-				} else {
-					b.Errorf("output mismatch")
+			for n := 0; n < b.N; n++ {
+				for i := 0; i < batchSize; i += churnChunk {
+					start, end := pos%len(LoadNets), (pos+window)%len(LoadNets)
+					pkg.RemoveNets(LoadNets[start : start+churnChunk])
+					pkg.LoadNets(LoadNets[end : end+churnChunk])
+					pos += churnChunk
 				}
 			}
+
+			b.StopTimer()
+			// The trees of the packages benchmarked previously remain allocated, so measure the tree as the heap freed by
+			// replacing it with an empty one.
+			used := heapAlloc()
+			pkg.Init()
+			b.ReportMetric(float64(used-heapAlloc()), "heap_bytes")
 		})
 	}
 }
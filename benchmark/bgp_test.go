@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var bgpSource = flag.String("bgp", "", "Load the networks from a prefix list derived from a BGP table (such as a"+
+	" RouteViews pfx2as file) instead of generating them randomly. May be a file path or an http(s) URL, and may be"+
+	" gzip compressed.")
+
+// useBGPPrefixes replaces LoadNets with the IPv4 prefixes from the list at src, and reconstructs the data derived from
+// it.
+func useBGPPrefixes(src string) error {
+	nets, err := loadBGPPrefixes(src)
+	if err != nil {
+		return err
+	}
+
+	// The lists are usually sorted, and the 'Random' tests expect otherwise. The slices taken by the churn tests also
+	// need the number of networks to be a multiple of the chunk size over the window.
+	rng.Shuffle(len(nets), func(i, j int) { nets[i], nets[j] = nets[j], nets[i] })
+	nets = nets[:len(nets)-len(nets)%(churnChunk*2)]
+	if len(nets) == 0 {
+		return fmt.Errorf("no IPv4 prefixes found in %s", src)
+	}
+
+	LoadNets = nets
+	deriveData()
+	return nil
+}
+
+// loadBGPPrefixes reads the IPv4 prefixes from the list at src. The list may either contain one prefix per line in CIDR
+// notation, or have the address and prefix length as separate whitespace separated fields, as in the RouteViews pfx2as
+// format. Any further fields are ignored.
+func loadBGPPrefixes(src string) ([]string, error) {
+	if strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://") {
+		var err error
+		if src, err = downloadBGPPrefixes(src); err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := os.Open(src)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(src, ".gz") {
+		gzr, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", src, err)
+		}
+		defer gzr.Close()
+		r = gzr
+	}
+
+	var nets []string
+	scnr := bufio.NewScanner(r)
+	for lineNum := 1; scnr.Scan(); lineNum++ {
+		fields := strings.Fields(scnr.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		pfxStr := fields[0]
+		if !strings.Contains(pfxStr, "/") && len(fields) > 1 {
+			pfxStr += "/" + fields[1]
+		}
+		pfx, err := netip.ParsePrefix(pfxStr)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", src, lineNum, err)
+		}
+		if !pfx.Addr().Is4() {
+			continue
+		}
+		nets = append(nets, pfx.Masked().String())
+	}
+	if err := scnr.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", src, err)
+	}
+	return nets, nil
+}
+
+// downloadBGPPrefixes downloads the list at url into the user's cache directory, unless already present, and returns
+// the path to it.
+func downloadBGPPrefixes(url string) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	dir = filepath.Join(dir, "go-iptrie-benchmark")
+	dst := filepath.Join(dir, path.Base(url))
+	if _, err := os.Stat(dst); err == nil {
+		return dst, nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	resp, err := http.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s: %s", url, resp.Status)
+	}
+
+	// Download to a temporary file so an interrupted download isn't mistaken for a complete one.
+	tmp, err := os.CreateTemp(dir, path.Base(url)+".*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, resp.Body); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("%s: %w", url, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return "", err
+	}
+	return dst, nil
+}
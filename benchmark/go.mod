@@ -4,11 +4,19 @@ go 1.21.1
 
 require (
 	github.com/asergeyev/nradix v0.0.0-20220715161825-e451993e425c
+	github.com/gaissmai/bart v0.11.1
 	github.com/infobloxopen/go-trees v0.0.0-20221216143356-66ceba885ebc
+	github.com/kentik/patricia v1.2.1
 	github.com/olekukonko/tablewriter v0.0.5
 	github.com/phemmer/go-iptrie v0.0.0-20240325175840-205f57d1fc56
 	github.com/yl2chen/cidranger v1.0.2
 	golang.org/x/text v0.14.0
 )
 
-require github.com/mattn/go-runewidth v0.0.9 // indirect
+require (
+	github.com/bits-and-blooms/bitset v1.13.0 // indirect
+	github.com/mattn/go-runewidth v0.0.9 // indirect
+)
+
+// Benchmark the version of the package in this repository.
+replace github.com/phemmer/go-iptrie => ../
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"math/bits"
+	"time"
+)
+
+// latencySubBuckets is the number of buckets each power of 2 is divided into, giving a precision of 1/16th (6.25%).
+const latencySubBuckets = 16
+
+// latencyHistogram records durations into log-linear buckets, so that percentiles can be computed without storing
+// every sample.
+type latencyHistogram struct {
+	counts [64 * latencySubBuckets]uint64
+	total  uint64
+}
+
+func latencyBucket(ns uint64) int {
+	if ns < latencySubBuckets {
+		return int(ns)
+	}
+	exp := bits.Len64(ns) - 1
+	sub := (ns >> (exp - 4)) & (latencySubBuckets - 1)
+	return (exp-3)*latencySubBuckets + int(sub)
+}
+
+// latencyBucketMin returns the smallest duration, in nanoseconds, recorded in the given bucket.
+func latencyBucketMin(idx int) uint64 {
+	if idx < latencySubBuckets {
+		return uint64(idx)
+	}
+	exp := idx/latencySubBuckets + 3
+	sub := uint64(idx % latencySubBuckets)
+	return (latencySubBuckets + sub) << (exp - 4)
+}
+
+func (lh *latencyHistogram) record(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	lh.counts[latencyBucket(uint64(d))]++
+	lh.total++
+}
+
+// percentile returns the duration which p percent of the recorded durations are within, to the precision of the
+// buckets.
+func (lh *latencyHistogram) percentile(p float64) time.Duration {
+	if lh.total == 0 {
+		return 0
+	}
+	rank := uint64(p / 100 * float64(lh.total))
+	if rank >= lh.total {
+		rank = lh.total - 1
+	}
+	var seen uint64
+	for idx, count := range lh.counts {
+		seen += count
+		if seen > rank {
+			return time.Duration(latencyBucketMin(idx))
+		}
+	}
+	return time.Duration(latencyBucketMin(len(lh.counts) - 1))
+}
@@ -0,0 +1,487 @@
+package iptrie
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// The binary format is laid out as follows:
+//
+//	magic   [4]byte "IPTB"
+//	version uint8
+//	nodes   uvarint number of nodes, including implicit nodes
+//	entries uvarint number of entries
+//	nodes, in depth order starting with the root:
+//		flags uint8 (binaryFlag*)
+//		bits  uint8
+//		addr  the leading ceil(bits/8) bytes of the address
+//		value uvarint length followed by the encoded value, only present for entries without a nil value
+//	crc     uint32 CRC-32C of everything preceding it
+//
+// A node's children immediately follow it, with the child for bit 0 first. As the structure of the trie is encoded
+// directly, decoding does not need to perform any inserts. The counts allow the nodes to be allocated up front, and
+// the checksum follows the nodes so that the trie can be written in a single pass.
+//
+// Version 1 of the format lacks the counts and checksum, and is still accepted when decoding.
+const (
+	binaryMagic   = "IPTB"
+	binaryVersion = 2
+
+	// binaryMaxPrealloc is the maximum number of nodes allocated up front, so that a corrupt count can't cause an
+	// excessive allocation.
+	binaryMaxPrealloc = 1 << 20
+
+	// binaryFlagEntry indicates that the node is an entry, and not just an implicit node.
+	binaryFlagEntry = 1 << 0
+	// binaryFlagNil indicates that the entry has a nil value, which is not passed through the value codec.
+	binaryFlagNil = 1 << 1
+	// binaryFlagChild0 and binaryFlagChild1 indicate the presence of the respective children.
+	binaryFlagChild0 = 1 << 2
+	binaryFlagChild1 = 1 << 3
+)
+
+var binaryCRCTable = crc32.MakeTable(crc32.Castagnoli)
+
+// ErrInvalidBinary is returned when data is not a valid binary encoded trie.
+var ErrInvalidBinary = errors.New("iptrie: invalid binary trie data")
+
+// MarshalBinary implements encoding.BinaryMarshaler, producing a compact encoding of the trie which can be loaded with
+// UnmarshalBinary much faster than the entries can be inserted.
+//
+// The encoding is deterministic: tries with the same entries produce the same encoding, regardless of the order the
+// entries were inserted in, or how the trie was constructed.
+//
+// Values are encoded with StringCodec. See MarshalBinaryCodec for other types.
+func (pt *Trie) MarshalBinary() ([]byte, error) {
+	return pt.MarshalBinaryCodec(StringCodec)
+}
+
+// MarshalBinaryCodec is like MarshalBinary, but uses codec to encode values.
+func (pt *Trie) MarshalBinaryCodec(codec ValueCodec) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := pt.writeBinary(&buf, codec); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler, replacing the contents of the trie with data produced by
+// MarshalBinary (or WriteToCompressed). Values are decoded with StringCodec. See UnmarshalBinaryCodec for other types.
+//
+// The trie is only modified if data is successfully decoded.
+func (pt *Trie) UnmarshalBinary(data []byte) error {
+	return pt.UnmarshalBinaryCodec(data, StringCodec)
+}
+
+// UnmarshalBinaryCodec is like UnmarshalBinary, but uses codec to decode values.
+func (pt *Trie) UnmarshalBinaryCodec(data []byte, codec ValueCodec) error {
+	if !bytes.HasPrefix(data, []byte(binaryMagic)) {
+		// Possibly compressed.
+		_, err := pt.ReadFromCodec(bytes.NewReader(data), codec)
+		return err
+	}
+	r := bytes.NewReader(data)
+	root, err := pt.readBinary(r, codec)
+	if err != nil {
+		return err
+	}
+	if r.Len() != 0 {
+		return fmt.Errorf("%w: trailing data", ErrInvalidBinary)
+	}
+	pt.replaceRoot(root)
+	return nil
+}
+
+// WriteTo implements io.WriterTo, writing the trie to w in the same format as MarshalBinary, without first
+// materializing the entire encoding in memory.
+//
+// Values are encoded with StringCodec. See WriteToCodec for other types.
+func (pt *Trie) WriteTo(w io.Writer) (int64, error) {
+	return pt.WriteToCodec(w, StringCodec)
+}
+
+// WriteToCodec is like WriteTo, but uses codec to encode values.
+func (pt *Trie) WriteToCodec(w io.Writer, codec ValueCodec) (int64, error) {
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	err := pt.writeBinary(bw, codec)
+	if err == nil {
+		err = bw.Flush()
+	}
+	return cw.n, err
+}
+
+// ReadFrom implements io.ReaderFrom, replacing the contents of the trie with data produced by WriteTo, MarshalBinary or
+// WriteToCompressed, read from r until EOF. Compressed data is detected automatically. Values are decoded with
+// StringCodec. See ReadFromCodec for other types.
+//
+// The trie is only modified if the data is successfully decoded.
+func (pt *Trie) ReadFrom(r io.Reader) (int64, error) {
+	return pt.ReadFromCodec(r, StringCodec)
+}
+
+// ReadFromCodec is like ReadFrom, but uses codec to decode values.
+func (pt *Trie) ReadFromCodec(r io.Reader, codec ValueCodec) (int64, error) {
+	cr := &countingReader{r: r}
+	br, err := decompress(bufio.NewReader(cr))
+	if err != nil {
+		return cr.n, err
+	}
+	root, err := pt.readBinary(br, codec)
+	if err == nil {
+		if _, err = br.ReadByte(); err == nil {
+			err = fmt.Errorf("%w: trailing data", ErrInvalidBinary)
+		} else if err == io.EOF {
+			err = nil
+		} else {
+			// The end of compressed data is only verified once all of it has been read.
+			err = unexpectedEOF(err)
+		}
+	}
+	if err != nil {
+		return cr.n, err
+	}
+	pt.replaceRoot(root)
+	return cr.n, nil
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
+
+// writeBinary writes the header, the nodes of the trie, and the checksum to w.
+func (pt *Trie) writeBinary(w binaryWriter, codec ValueCodec) error {
+	cw := &crcWriter{w: w}
+	nodes, entries := pt.count()
+	hdr := append([]byte(binaryMagic), binaryVersion)
+	hdr = binary.AppendUvarint(hdr, uint64(nodes))
+	hdr = binary.AppendUvarint(hdr, uint64(entries))
+	if _, err := cw.Write(hdr); err != nil {
+		return err
+	}
+	if err := pt.node.encodeBinary(cw, codec); err != nil {
+		return err
+	}
+	_, err := w.Write(binary.BigEndian.AppendUint32(nil, cw.crc))
+	return err
+}
+
+// readBinary reads the header, the nodes of a trie, and the checksum from r, returning the root. It does not check for
+// trailing data.
+func (pt *Trie) readBinary(r binaryReader, codec ValueCodec) (*node, error) {
+	cr := &crcReader{r: r}
+	var hdr [len(binaryMagic) + 1]byte
+	if _, err := io.ReadFull(cr, hdr[:]); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if string(hdr[:len(binaryMagic)]) != binaryMagic {
+		return nil, ErrInvalidBinary
+	}
+	switch v := hdr[len(binaryMagic)]; v {
+	case 1:
+		return pt.decodeBinary(r, codec, 0)
+	case binaryVersion:
+	default:
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidBinary, v)
+	}
+
+	nodes, err := binary.ReadUvarint(cr)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	entries, err := binary.ReadUvarint(cr)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if nodes == 0 || entries > nodes {
+		return nil, fmt.Errorf("%w: invalid counts", ErrInvalidBinary)
+	}
+	root, err := pt.decodeBinary(cr, codec, min(nodes, binaryMaxPrealloc))
+	if err != nil {
+		return nil, err
+	}
+	if gotNodes, gotEntries := root.count(); uint64(gotNodes) != nodes || uint64(gotEntries) != entries {
+		return nil, fmt.Errorf("%w: expected %d nodes and %d entries, found %d and %d", ErrInvalidBinary,
+			nodes, entries, gotNodes, gotEntries)
+	}
+
+	crc := cr.crc
+	var sum [4]byte
+	if _, err := io.ReadFull(r, sum[:]); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if binary.BigEndian.Uint32(sum[:]) != crc {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrInvalidBinary)
+	}
+	return root, nil
+}
+
+// crcWriter is a binaryWriter which computes the checksum of everything written through it.
+type crcWriter struct {
+	w   binaryWriter
+	crc uint32
+}
+
+func (cw *crcWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.crc = crc32.Update(cw.crc, binaryCRCTable, p[:n])
+	return n, err
+}
+
+func (cw *crcWriter) WriteByte(c byte) error {
+	if err := cw.w.WriteByte(c); err != nil {
+		return err
+	}
+	cw.crc = crc32.Update(cw.crc, binaryCRCTable, []byte{c})
+	return nil
+}
+
+// crcReader is a binaryReader which computes the checksum of everything read through it.
+type crcReader struct {
+	r   binaryReader
+	crc uint32
+}
+
+func (cr *crcReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.crc = crc32.Update(cr.crc, binaryCRCTable, p[:n])
+	return n, err
+}
+
+func (cr *crcReader) ReadByte() (byte, error) {
+	c, err := cr.r.ReadByte()
+	if err == nil {
+		cr.crc = crc32.Update(cr.crc, binaryCRCTable, []byte{c})
+	}
+	return c, err
+}
+
+// binaryWriter is implemented by both bytes.Buffer and bufio.Writer.
+type binaryWriter interface {
+	io.Writer
+	io.ByteWriter
+}
+
+// binaryReader is implemented by both bytes.Reader and bufio.Reader.
+type binaryReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+func (pt *node) encodeBinary(w binaryWriter, codec ValueCodec) error {
+	var flags uint8
+	if pt.value != nil {
+		flags |= binaryFlagEntry
+		if pt.value == empty {
+			flags |= binaryFlagNil
+		}
+	}
+	if pt.children[0] != nil {
+		flags |= binaryFlagChild0
+	}
+	if pt.children[1] != nil {
+		flags |= binaryFlagChild1
+	}
+
+	var hdr [2 + 16]byte
+	hdr[0] = flags
+	hdr[1] = pt.bits
+	binary.BigEndian.PutUint64(hdr[2:], pt.addr.hi)
+	binary.BigEndian.PutUint64(hdr[10:], pt.addr.lo)
+	if _, err := w.Write(hdr[:2+(int(pt.bits)+7)/8]); err != nil {
+		return err
+	}
+
+	if flags&binaryFlagEntry != 0 && flags&binaryFlagNil == 0 {
+		value, err := codec.Encode(pt.value)
+		if err != nil {
+			return fmt.Errorf("encoding value for %s: %w", pt.network(), err)
+		}
+		var lenBuf [binary.MaxVarintLen64]byte
+		if _, err := w.Write(lenBuf[:binary.PutUvarint(lenBuf[:], uint64(len(value)))]); err != nil {
+			return err
+		}
+		if _, err := w.Write(value); err != nil {
+			return err
+		}
+	}
+
+	for _, child := range pt.children {
+		if child == nil {
+			continue
+		}
+		if err := child.encodeBinary(w, codec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeBinary reads the nodes of a trie from r, returning the root. The nodes are allocated for use within pt, with
+// space for prealloc nodes allocated up front.
+func (pt *Trie) decodeBinary(r binaryReader, codec ValueCodec, prealloc uint64) (*node, error) {
+	d := binaryDecoder{r: r, codec: codec, owner: pt.owner}
+	if prealloc > 1 {
+		// The root is not allocated from the arena.
+		d.arena.slab = make([]node, prealloc-1)
+	}
+	root := &node{}
+	if err := d.decode(root, nil); err != nil {
+		return nil, err
+	}
+	if root.bits != 0 {
+		return nil, fmt.Errorf("%w: root is not ::/0", ErrInvalidBinary)
+	}
+	return root, nil
+}
+
+// entriesRoot returns a root node holding the given entries, allocated for use within pt, for replacing its contents
+// with replaceRoot. As when decoding the binary format, the entries are stored as they are, without the settings or
+// side effects (such as hooks and the change log) of inserting them.
+func (pt *Trie) entriesRoot(entries []pfxEntry) *node {
+	root := pt.owner.newNode()
+	for _, entry := range entries {
+		addr, bits := prefix128(normalizePrefix(entry.Prefix))
+		root.insert(addr, bits, emptyize(entry.Value))
+	}
+	return root
+}
+
+// replaceRoot replaces the contents of pt with those of the given root node. The metadata of entry tracking is
+// discarded, as it describes the previous entries.
+func (pt *Trie) replaceRoot(root *node) {
+	if pt.stats != nil {
+		// The previous metadata may still be shared with snapshots of the previous entries, so it's replaced rather
+		// than cleared.
+		pt.stats = &entryStats{m: map[entryKey]*entryCounters{}}
+	}
+	pt.children = root.children
+	pt.addr = root.addr
+	pt.bits = root.bits
+	pt.value = root.value
+	pt.refreshV4()
+}
+
+type binaryDecoder struct {
+	r     binaryReader
+	codec ValueCodec
+	owner *owner
+	arena nodeArena
+}
+
+func (d *binaryDecoder) newNode() *node {
+	n := d.arena.alloc()
+	n.owner = d.owner
+	return n
+}
+
+// decode reads a node and its descendants into n. If parent is not nil, the node must be a valid child of it.
+func (d *binaryDecoder) decode(n *node, parent *node) error {
+	var hdr [2 + 16]byte
+	if _, err := io.ReadFull(d.r, hdr[:2]); err != nil {
+		return unexpectedEOF(err)
+	}
+	flags, bits := hdr[0], hdr[1]
+	if bits > 128 || flags&^(binaryFlagEntry|binaryFlagNil|binaryFlagChild0|binaryFlagChild1) != 0 {
+		return ErrInvalidBinary
+	}
+	if _, err := io.ReadFull(d.r, hdr[2:2+(int(bits)+7)/8]); err != nil {
+		return unexpectedEOF(err)
+	}
+	n.addr = uint128{binary.BigEndian.Uint64(hdr[2:]), binary.BigEndian.Uint64(hdr[10:])}
+	n.bits = bits
+	if n.addr.bitsClearedFrom(bits) != n.addr {
+		return fmt.Errorf("%w: %s is not masked", ErrInvalidBinary, n.network())
+	}
+	if parent != nil {
+		if bits <= parent.bits || !parent.contains(n.addr) || parent.children[parent.discriminatorBit(n.addr)] != n {
+			return fmt.Errorf("%w: %s is not a valid child of %s", ErrInvalidBinary, n.network(), parent.network())
+		}
+	}
+
+	if flags&binaryFlagEntry != 0 {
+		if flags&binaryFlagNil != 0 {
+			n.value = empty
+		} else {
+			size, err := binary.ReadUvarint(d.r)
+			if err != nil {
+				return unexpectedEOF(err)
+			}
+			data, err := readN(d.r, size)
+			if err != nil {
+				return unexpectedEOF(err)
+			}
+			value, err := d.codec.Decode(data)
+			if err != nil {
+				return fmt.Errorf("decoding value for %s: %w", n.network(), err)
+			}
+			n.value = emptyize(value)
+		}
+	} else if flags&binaryFlagNil != 0 {
+		return ErrInvalidBinary
+	}
+
+	for bit, flag := range [2]uint8{binaryFlagChild0, binaryFlagChild1} {
+		if flags&flag == 0 {
+			continue
+		}
+		if bits == 128 {
+			return ErrInvalidBinary
+		}
+		child := d.newNode()
+		n.children[bit] = child
+		if err := d.decode(child, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readN reads exactly n bytes from r. Large reads are performed incrementally, so that a corrupt length can't cause a
+// large allocation.
+func readN(r io.Reader, n uint64) ([]byte, error) {
+	if n <= 1<<16 {
+		b := make([]byte, n)
+		_, err := io.ReadFull(r, b)
+		return b, err
+	}
+	if n > 1<<62 {
+		return nil, ErrInvalidBinary
+	}
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, r, int64(n)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// unexpectedEOF converts io.EOF into an error indicating the data is truncated.
+func unexpectedEOF(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return fmt.Errorf("%w: %w", ErrInvalidBinary, io.ErrUnexpectedEOF)
+	}
+	return err
+}
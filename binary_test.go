@@ -0,0 +1,168 @@
+package iptrie
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrieMarshalBinary(t *testing.T) {
+	trie := NewTrie()
+	trie.Insert(netip.MustParsePrefix("::/0"), "default")
+	trie.Insert(netip.MustParsePrefix("2001:db8::/32"), "v6")
+	trie.Insert(netip.MustParsePrefix("2001:db8::1/128"), "v6 host")
+	trie.Insert(netip.MustParsePrefix("192.0.2.0/24"), nil)
+	for i := 0; i < 1000; i++ {
+		ip := GenIPV4()
+		network, _ := ip.Prefix(int(ip.As4()[3]%25) + 8)
+		trie.Insert(network, fmt.Sprintf("net=%s", network))
+	}
+
+	data, err := trie.MarshalBinary()
+	require.NoError(t, err)
+
+	var loaded Trie
+	require.NoError(t, loaded.UnmarshalBinary(data))
+	assert.Equal(t, trie.String(), loaded.String())
+	assert.Equal(t, trie.appendEntries(nil), loaded.appendEntries(nil))
+	for i := 0; i < 1000; i++ {
+		ip := GenIPV4()
+		assert.Equal(t, trie.Find(ip), loaded.Find(ip), "ip=%s", ip)
+	}
+	ip := netip.MustParseAddr("192.0.2.1")
+	assert.Equal(t, trie.Find(ip), loaded.Find(ip))
+	assert.Equal(t, trie.ContainingNetworks(ip), loaded.ContainingNetworks(ip))
+	assert.Contains(t, loaded.ContainingNetworks(ip), netip.MustParsePrefix("::ffff:192.0.2.0/120"))
+
+	// The loaded trie must be usable for further modification.
+	loaded.Insert(netip.MustParsePrefix("10.0.0.0/8"), "new")
+	assert.Equal(t, "new", loaded.Find(netip.MustParseAddr("10.0.0.1")))
+	assert.Equal(t, "v6", loaded.Remove(netip.MustParsePrefix("2001:db8::/32")))
+	assert.Equal(t, "v6 host", loaded.Find(netip.MustParseAddr("2001:db8::1")))
+	assert.Equal(t, "default", loaded.Find(netip.MustParseAddr("2001:db8::2")))
+}
+
+func TestTrieMarshalBinaryCodec(t *testing.T) {
+	trie := NewTrie()
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), 1)
+	trie.Insert(netip.MustParsePrefix("10.1.0.0/16"), 2)
+
+	_, err := trie.MarshalBinary()
+	assert.Error(t, err)
+
+	codec := ValueCodecFuncs{
+		EncodeFunc: func(value any) ([]byte, error) {
+			return []byte(strconv.Itoa(value.(int))), nil
+		},
+		DecodeFunc: func(data []byte) (any, error) {
+			return strconv.Atoi(string(data))
+		},
+	}
+	data, err := trie.MarshalBinaryCodec(codec)
+	require.NoError(t, err)
+
+	loaded := NewTrie()
+	err = loaded.UnmarshalBinaryCodec(data, codec)
+	require.NoError(t, err)
+	assert.Equal(t, trie.String(), loaded.String())
+	assert.Equal(t, 2, loaded.Find(netip.MustParseAddr("10.1.0.1")))
+
+	decodeErr := errors.New("decode failure")
+	err = loaded.UnmarshalBinaryCodec(data, ValueCodecFuncs{DecodeFunc: func(data []byte) (any, error) {
+		return nil, decodeErr
+	}})
+	assert.ErrorIs(t, err, decodeErr)
+}
+
+func TestTrieUnmarshalBinaryInvalid(t *testing.T) {
+	trie := NewTrie()
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	trie.Insert(netip.MustParsePrefix("10.1.0.0/16"), "b")
+	data, err := trie.MarshalBinary()
+	require.NoError(t, err)
+
+	loaded := NewTrie()
+	loaded.Insert(netip.MustParsePrefix("192.0.2.0/24"), "orig")
+	for i := 0; i < len(data); i++ {
+		assert.ErrorIs(t, loaded.UnmarshalBinary(data[:i]), ErrInvalidBinary, "len=%d", i)
+	}
+	assert.ErrorIs(t, loaded.UnmarshalBinary(append(data, 0)), ErrInvalidBinary)
+
+	corrupt := append([]byte(nil), data...)
+	corrupt[4] = 99
+	assert.ErrorIs(t, loaded.UnmarshalBinary(corrupt), ErrInvalidBinary)
+
+	// A corrupt value is caught by the checksum.
+	corrupt = append([]byte(nil), data...)
+	corrupt[bytes.LastIndexByte(corrupt, 'b')] = 'c'
+	assert.ErrorContains(t, loaded.UnmarshalBinary(corrupt), "checksum")
+
+	// A count which doesn't match the nodes.
+	corrupt = append([]byte(nil), data...)
+	corrupt[6]++
+	assert.ErrorContains(t, loaded.UnmarshalBinary(corrupt), "entries")
+
+	// A failed unmarshal leaves the trie untouched.
+	assert.Equal(t, "orig", loaded.Find(netip.MustParseAddr("192.0.2.1")))
+	assert.Nil(t, loaded.Find(netip.MustParseAddr("10.0.0.1")))
+}
+
+func TestTrieUnmarshalBinaryVersion1(t *testing.T) {
+	// Version 1 has no counts or checksum.
+	data := []byte("IPTB\x01" +
+		"\x04\x00" + // ::/0 with child 0
+		"\x01\x68\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\xff\xff\x0a" + // ::ffff:10.0.0.0/104
+		"\x01a")
+	var loaded Trie
+	require.NoError(t, loaded.UnmarshalBinary(data))
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("::ffff:10.0.0.0/104")}, loaded.appendEntries(nil))
+	assert.Equal(t, "a", loaded.Find(netip.MustParseAddr("10.0.0.1")))
+
+	for i := 0; i < len(data); i++ {
+		assert.ErrorIs(t, loaded.UnmarshalBinary(data[:i]), ErrInvalidBinary, "len=%d", i)
+	}
+}
+
+func TestTrieWriteToReadFrom(t *testing.T) {
+	trie := NewTrie()
+	for i := 0; i < 10000; i++ {
+		network := GenLeafIPNet(GenIPV4())
+		trie.Insert(network, network.String())
+	}
+	trie.Insert(netip.MustParsePrefix("2001:db8::/32"), nil)
+
+	data, err := trie.MarshalBinary()
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	n, err := trie.WriteTo(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(data)), n)
+	assert.Equal(t, data, buf.Bytes())
+
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := trie.WriteTo(pw)
+		pw.CloseWithError(err)
+	}()
+	loaded := NewTrie()
+	n, err = loaded.ReadFrom(pr)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(data)), n)
+	assert.Equal(t, trie.String(), loaded.String())
+
+	n, err = loaded.ReadFrom(bytes.NewReader(append(data, 0)))
+	assert.ErrorIs(t, err, ErrInvalidBinary)
+	assert.Equal(t, int64(len(data)+1), n)
+	n, err = loaded.ReadFrom(bytes.NewReader(data[:len(data)/2]))
+	assert.ErrorIs(t, err, ErrInvalidBinary)
+	assert.Equal(t, int64(len(data)/2), n)
+	assert.Equal(t, trie.String(), loaded.String())
+}
@@ -0,0 +1,96 @@
+package bitstrie
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+)
+
+// Key is a key of up to 128 bits. Keys are left-aligned, so bit 0 is the most significant bit of Hi, and a key of a
+// trie with a width of less than 128 bits occupies only the leading bits.
+type Key struct {
+	Hi, Lo uint64
+}
+
+// KeyFromUint64 returns the key whose leading width bits are the low width bits of v. For example,
+// KeyFromUint64(0x001a2b, 24) is the key of the 24 bits 00:1a:2b. width must be at most 64.
+func KeyFromUint64(v uint64, width int) Key {
+	if width <= 0 {
+		return Key{}
+	}
+	return Key{Hi: v << (64 - width)}
+}
+
+// KeyFromBytes returns the key whose leading bits are those of b, which must not be longer than 16 bytes.
+func KeyFromBytes(b []byte) Key {
+	if len(b) > 16 {
+		panic("bitstrie: key longer than 128 bits")
+	}
+	var buf [16]byte
+	copy(buf[:], b)
+	return Key{binary.BigEndian.Uint64(buf[:8]), binary.BigEndian.Uint64(buf[8:])}
+}
+
+// Uint64 returns the leading width bits of k, which must be at most 64, as the low bits of an integer. It is the
+// inverse of KeyFromUint64.
+func (k Key) Uint64(width int) uint64 {
+	if width <= 0 {
+		return 0
+	}
+	return k.Hi >> (64 - width)
+}
+
+// Bytes returns the leading n bytes of k.
+func (k Key) Bytes(n int) []byte {
+	var buf [16]byte
+	binary.BigEndian.PutUint64(buf[:8], k.Hi)
+	binary.BigEndian.PutUint64(buf[8:], k.Lo)
+	return buf[:n:n]
+}
+
+// Bit returns the value (0 or 1) of the bit at position pos, counting from the most significant bit.
+func (k Key) Bit(pos int) uint8 {
+	if pos < 64 {
+		return uint8(k.Hi >> (63 - pos) & 1)
+	}
+	return uint8(k.Lo >> (127 - pos) & 1)
+}
+
+// Masked returns k with all bits after the leading n bits cleared.
+func (k Key) Masked(n int) Key {
+	return k.and(mask(n))
+}
+
+// String returns the key in hexadecimal.
+func (k Key) String() string {
+	return fmt.Sprintf("%016x%016x", k.Hi, k.Lo)
+}
+
+func (k Key) and(m Key) Key {
+	return Key{k.Hi & m.Hi, k.Lo & m.Lo}
+}
+
+func (k Key) xor(m Key) Key {
+	return Key{k.Hi ^ m.Hi, k.Lo ^ m.Lo}
+}
+
+// leadingZeros returns the number of leading zero bits of k.
+func (k Key) leadingZeros() int {
+	if k.Hi != 0 {
+		return bits.LeadingZeros64(k.Hi)
+	}
+	return 64 + bits.LeadingZeros64(k.Lo)
+}
+
+// mask returns a key with the leading n bits set.
+func mask(n int) Key {
+	switch {
+	case n <= 0:
+		return Key{}
+	case n < 64:
+		return Key{Hi: ^(^uint64(0) >> n)}
+	case n < 128:
+		return Key{Hi: ^uint64(0), Lo: ^(^uint64(0) >> (n - 64))}
+	}
+	return Key{^uint64(0), ^uint64(0)}
+}
@@ -0,0 +1,27 @@
+package bitstrie
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKey(t *testing.T) {
+	k := KeyFromUint64(0x001a2b3c4d5e, 48)
+	assert.Equal(t, Key{Hi: 0x001a2b3c4d5e0000}, k)
+	assert.Equal(t, uint64(0x001a2b3c4d5e), k.Uint64(48))
+	assert.Equal(t, []byte{0x00, 0x1a, 0x2b, 0x3c, 0x4d, 0x5e}, k.Bytes(6))
+	assert.Equal(t, k, KeyFromBytes([]byte{0x00, 0x1a, 0x2b, 0x3c, 0x4d, 0x5e}))
+	assert.Equal(t, KeyFromUint64(0x001a2b, 24), k.Masked(24))
+	assert.Equal(t, uint8(0), k.Bit(0))
+	assert.Equal(t, uint8(1), k.Bit(11))
+
+	k = KeyFromBytes([]byte{0x80, 0, 0, 0, 0, 0, 0, 0, 0x80, 0, 0, 0, 0, 0, 0, 1})
+	assert.Equal(t, uint8(1), k.Bit(0))
+	assert.Equal(t, uint8(1), k.Bit(64))
+	assert.Equal(t, uint8(1), k.Bit(127))
+	assert.Equal(t, Key{Hi: 1 << 63, Lo: 1 << 63}, k.Masked(65))
+	assert.Equal(t, k, k.Masked(128))
+	assert.Equal(t, Key{}, k.Masked(0))
+	assert.Equal(t, "80000000000000008000000000000001", k.String())
+}
@@ -0,0 +1,232 @@
+// Package bitstrie provides a path-compressed binary radix trie over fixed-width bit-string keys of up to 128 bits,
+// supporting longest-prefix-match lookups.
+//
+// It uses the same algorithm as the iptrie.Trie, generalized to keys which aren't IP addresses, such as MAC addresses
+// (48 bits) or 64-bit identifiers, where entries are prefixes of the key space.
+//
+// It is a separate implementation of the algorithm, rather than the engine beneath iptrie.Trie (and its FrozenTrie and
+// CompiledTrie forms). Those are specialized to 128-bit keys for lookup performance, and their nodes carry state this
+// package has no use for, such as copy-on-write ownership for snapshots and the placeholder for nil values, so layering
+// them on a generic engine would slow the IP lookups down. Fixes to the algorithm may need to be applied to both.
+package bitstrie
+
+// Trie is a path-compressed binary radix trie mapping prefixes of fixed-width keys to values of type V.
+//
+// A Trie is not safe for concurrent modification, but may be read concurrently when not being modified.
+type Trie[V any] struct {
+	root  node[V]
+	width int
+	len   int
+}
+
+type node[V any] struct {
+	children [2]*node[V]
+	parent   *node[V]
+	key      Key
+	bits     int
+	value    V
+	// set indicates the node is an entry, rather than an implicit node joining two branches.
+	set bool
+}
+
+// New creates a new Trie for keys of the given width in bits, which must be between 1 and 128.
+func New[V any](width int) *Trie[V] {
+	if width < 1 || width > 128 {
+		panic("bitstrie: width must be between 1 and 128")
+	}
+	return &Trie[V]{width: width}
+}
+
+// Width returns the width of the keys of the trie in bits.
+func (t *Trie[V]) Width() int {
+	return t.width
+}
+
+// Len returns the number of entries in the trie.
+func (t *Trie[V]) Len() int {
+	return t.len
+}
+
+// prefix returns key masked to bits, with bits clamped to the width of the trie.
+func (t *Trie[V]) prefix(key Key, bits int) (Key, int) {
+	if bits < 0 {
+		bits = 0
+	} else if bits > t.width {
+		bits = t.width
+	}
+	return key.Masked(bits), bits
+}
+
+// Insert inserts an entry for the prefix of key consisting of its leading bits, replacing the value of any existing
+// entry for the same prefix. Key bits after the prefix are ignored, and bits is clamped to the width of the trie.
+func (t *Trie[V]) Insert(key Key, bits int, value V) {
+	key, bits = t.prefix(key, bits)
+	n := &t.root
+	for {
+		if n.bits == bits && n.key == key {
+			if !n.set {
+				t.len++
+			}
+			n.value, n.set = value, true
+			return
+		}
+
+		bit := key.Bit(n.bits)
+		child := n.children[bit]
+		if child == nil {
+			n.setChild(bit, &node[V]{key: key, bits: bits, value: value, set: true})
+			t.len++
+			return
+		}
+
+		// Insert an implicit node where the prefix diverges from the path to the existing child.
+		if divBits := divergence(child.key, child.bits, key, bits); divBits != child.bits {
+			div := &node[V]{key: key.Masked(divBits), bits: divBits}
+			n.setChild(bit, div)
+			div.setChild(child.key.Bit(divBits), child)
+			child = div
+		}
+		n = child
+	}
+}
+
+// divergence returns the length of the longest prefix common to both prefixes.
+func divergence(a Key, aBits int, b Key, bBits int) int {
+	return min(aBits, bBits, a.xor(b).leadingZeros())
+}
+
+func (n *node[V]) setChild(bit uint8, child *node[V]) {
+	n.children[bit] = child
+	child.parent = n
+}
+
+// contains indicates whether key is within the prefix of n.
+func (n *node[V]) contains(key Key) bool {
+	return key.Masked(n.bits) == n.key
+}
+
+// get returns the node for the exact prefix, which may be an implicit node, or nil if there is none.
+func (t *Trie[V]) get(key Key, bits int) *node[V] {
+	for n := &t.root; n != nil; n = n.children[key.Bit(n.bits)] {
+		if n.bits == bits && n.key == key {
+			return n
+		}
+		if n.bits >= bits || !n.contains(key) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// Get returns the value of the entry for exactly the given prefix, and whether there is one.
+func (t *Trie[V]) Get(key Key, bits int) (value V, ok bool) {
+	if n := t.get(t.prefix(key, bits)); n != nil && n.set {
+		return n.value, true
+	}
+	return value, false
+}
+
+// Remove removes the entry for exactly the given prefix, returning its value and whether there was one.
+func (t *Trie[V]) Remove(key Key, bits int) (value V, ok bool) {
+	n := t.get(t.prefix(key, bits))
+	if n == nil || !n.set {
+		return value, false
+	}
+	value = n.value
+	var zero V
+	n.value, n.set = zero, false
+	t.len--
+	n.compress()
+	return value, true
+}
+
+// compress removes n from the trie if it's an implicit node with at most one child, attaching the child to the parent
+// of n, and then does the same for the parent.
+func (n *node[V]) compress() {
+	for n.parent != nil && !n.set && (n.children[0] == nil || n.children[1] == nil) {
+		child := n.children[0]
+		if child == nil {
+			child = n.children[1]
+		}
+		parent := n.parent
+		bit := n.key.Bit(parent.bits)
+		parent.children[bit] = child
+		if child != nil {
+			child.parent = parent
+		}
+		n = parent
+	}
+}
+
+// Find returns the value of the most specific entry (longest prefix) containing key, and whether there is one.
+func (t *Trie[V]) Find(key Key) (value V, ok bool) {
+	key = key.Masked(t.width)
+	var found *node[V]
+	for n := &t.root; n != nil && n.contains(key); {
+		if n.set {
+			found = n
+		}
+		if n.bits == t.width {
+			break
+		}
+		n = n.children[key.Bit(n.bits)]
+	}
+	if found == nil {
+		return value, false
+	}
+	return found.value, true
+}
+
+// FindLargest returns the value of the least specific entry (shortest prefix) containing key, and whether there is one.
+func (t *Trie[V]) FindLargest(key Key) (value V, ok bool) {
+	key = key.Masked(t.width)
+	for n := &t.root; n != nil && n.contains(key); {
+		if n.set {
+			return n.value, true
+		}
+		if n.bits == t.width {
+			break
+		}
+		n = n.children[key.Bit(n.bits)]
+	}
+	return value, false
+}
+
+// Contains indicates whether any entry contains key.
+func (t *Trie[V]) Contains(key Key) bool {
+	_, ok := t.FindLargest(key)
+	return ok
+}
+
+// Walk calls fn with the prefix and value of each entry, in ascending order of key, with each prefix visited before the
+// prefixes it contains. It stops when fn returns false.
+func (t *Trie[V]) Walk(fn func(key Key, bits int, value V) bool) {
+	t.root.walk(fn)
+}
+
+func (n *node[V]) walk(fn func(key Key, bits int, value V) bool) bool {
+	if n.set && !fn(n.key, n.bits, n.value) {
+		return false
+	}
+	for _, child := range n.children {
+		if child != nil && !child.walk(fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// Covered calls fn with each entry within the given prefix, including an entry for the prefix itself, in the same order
+// as Walk. It stops when fn returns false.
+func (t *Trie[V]) Covered(key Key, bits int, fn func(key Key, bits int, value V) bool) {
+	key, bits = t.prefix(key, bits)
+	for n := &t.root; n != nil; n = n.children[key.Bit(n.bits)] {
+		if bits <= n.bits && n.key.Masked(bits) == key {
+			n.walk(fn)
+			return
+		}
+		if n.bits == t.width || !n.contains(key) {
+			return
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package bitstrie
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type entry struct {
+	key  Key
+	bits int
+}
+
+func TestTrie(t *testing.T) {
+	trie := New[string](48)
+	trie.Insert(KeyFromUint64(0x001a2b, 24), 24, "vendor")
+	trie.Insert(KeyFromUint64(0x001a2b3c, 32), 32, "block")
+	trie.Insert(KeyFromUint64(0x001a2b3c4d5e, 48), 48, "host")
+	trie.Insert(KeyFromUint64(0x001a2b3c4d5e, 48), 48, "host2")
+	assert.Equal(t, 3, trie.Len())
+
+	v, ok := trie.Find(KeyFromUint64(0x001a2b3c4d5e, 48))
+	assert.True(t, ok)
+	assert.Equal(t, "host2", v)
+	v, _ = trie.Find(KeyFromUint64(0x001a2b3c0000, 48))
+	assert.Equal(t, "block", v)
+	v, _ = trie.Find(KeyFromUint64(0x001a2bff0000, 48))
+	assert.Equal(t, "vendor", v)
+	v, _ = trie.FindLargest(KeyFromUint64(0x001a2b3c4d5e, 48))
+	assert.Equal(t, "vendor", v)
+	_, ok = trie.Find(KeyFromUint64(0x001a2c000000, 48))
+	assert.False(t, ok)
+	assert.False(t, trie.Contains(KeyFromUint64(0x001a2c000000, 48)))
+
+	v, ok = trie.Get(KeyFromUint64(0x001a2b, 24), 24)
+	assert.True(t, ok)
+	assert.Equal(t, "vendor", v)
+	_, ok = trie.Get(KeyFromUint64(0x001a, 16), 16)
+	assert.False(t, ok)
+
+	var covered []string
+	trie.Covered(KeyFromUint64(0x001a2b3c, 32), 32, func(key Key, bits int, value string) bool {
+		covered = append(covered, value)
+		return true
+	})
+	assert.Equal(t, []string{"block", "host2"}, covered)
+
+	v, ok = trie.Remove(KeyFromUint64(0x001a2b3c, 32), 32)
+	assert.True(t, ok)
+	assert.Equal(t, "block", v)
+	_, ok = trie.Remove(KeyFromUint64(0x001a2b3c, 32), 32)
+	assert.False(t, ok)
+	v, _ = trie.Find(KeyFromUint64(0x001a2b3c0000, 48))
+	assert.Equal(t, "vendor", v)
+	assert.Equal(t, 2, trie.Len())
+}
+
+// TestTrieRandom compares lookups against a brute force search over random prefixes, for a number of key widths.
+func TestTrieRandom(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	randKey := func() Key { return Key{rnd.Uint64(), rnd.Uint64()} }
+	for _, width := range []int{1, 7, 48, 64, 65, 128} {
+		trie := New[int](width)
+		var entries []entry
+		for i := 0; i < 500; i++ {
+			bits := rnd.Intn(width + 1)
+			e := entry{randKey().Masked(bits), bits}
+			if _, ok := trie.Get(e.key, e.bits); ok {
+				continue
+			}
+			trie.Insert(e.key, e.bits, len(entries))
+			entries = append(entries, e)
+		}
+		require.Equal(t, len(entries), trie.Len())
+
+		// Remove every other entry, so that path compression is exercised.
+		removed := make([]bool, len(entries))
+		for i := 0; i < len(entries); i += 2 {
+			v, ok := trie.Remove(entries[i].key, entries[i].bits)
+			require.True(t, ok)
+			require.Equal(t, i, v)
+			removed[i] = true
+		}
+
+		for i := 0; i < 2000; i++ {
+			key := randKey()
+			if i%2 == 0 {
+				// Derive the key from an entry, so that lookups don't all miss for the longer widths.
+				e := entries[rnd.Intn(len(entries))]
+				key = e.key.xor(randKey().and(mask(e.bits).xor(mask(128))))
+			}
+			key = key.Masked(width)
+			best, largest := -1, -1
+			for j, e := range entries {
+				if removed[j] || key.Masked(e.bits) != e.key {
+					continue
+				}
+				if best < 0 || e.bits > entries[best].bits {
+					best = j
+				}
+				if largest < 0 || e.bits < entries[largest].bits {
+					largest = j
+				}
+			}
+			v, ok := trie.Find(key)
+			assert.Equal(t, best >= 0, ok)
+			if ok {
+				assert.Equal(t, best, v, "width %d key %s", width, key)
+			}
+			v, ok = trie.FindLargest(key)
+			assert.Equal(t, largest >= 0, ok)
+			if ok {
+				assert.Equal(t, largest, v)
+			}
+		}
+
+		var walked []int
+		var last *entry
+		trie.Walk(func(key Key, bits int, value int) bool {
+			if last != nil {
+				// Each prefix is visited before the prefixes it contains, and otherwise in ascending order.
+				contains := bits > last.bits && key.Masked(last.bits) == last.key
+				assert.True(t, contains || last.key.Hi < key.Hi || (last.key.Hi == key.Hi && last.key.Lo < key.Lo))
+			}
+			last = &entry{key, bits}
+			walked = append(walked, value)
+			return true
+		})
+		assert.Len(t, walked, trie.Len())
+	}
+}
+
+func TestNewWidth(t *testing.T) {
+	assert.Panics(t, func() { New[int](0) })
+	assert.Panics(t, func() { New[int](129) })
+}
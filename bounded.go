@@ -0,0 +1,134 @@
+package iptrie
+
+import (
+	"container/list"
+	"net/netip"
+	"sync"
+)
+
+// EvictionPolicy determines which entry a BoundedTrie evicts when it is full.
+type EvictionPolicy uint8
+
+const (
+	// EvictLRU evicts the least recently used entry, where an entry is used when it is inserted, or returned by Find.
+	EvictLRU EvictionPolicy = iota
+	// EvictFIFO evicts the entry which was inserted first. Replacing the value of an entry does not change its
+	// position.
+	EvictFIFO
+)
+
+// BoundedTrie is a Trie holding at most a fixed number of entries, evicting entries according to an EvictionPolicy to
+// make room for new ones. It is suited to caches of per-network state which must not grow without bound.
+//
+// A BoundedTrie is safe for concurrent use. As lookups update the usage of entries, they acquire an exclusive lock.
+type BoundedTrie struct {
+	mu      sync.Mutex
+	trie    *Trie
+	max     int
+	policy  EvictionPolicy
+	onEvict func(network netip.Prefix, value any)
+
+	// order holds the (normalized) network of each entry, with the next to be evicted at the front.
+	order *list.List
+	elems map[netip.Prefix]*list.Element
+}
+
+// NewBoundedTrie creates a BoundedTrie holding at most maxEntries entries.
+func NewBoundedTrie(maxEntries int, policy EvictionPolicy) *BoundedTrie {
+	if maxEntries < 1 {
+		panic("iptrie: BoundedTrie must allow at least 1 entry")
+	}
+	return &BoundedTrie{
+		trie:   NewTrie(),
+		max:    maxEntries,
+		policy: policy,
+		order:  list.New(),
+		elems:  map[netip.Prefix]*list.Element{},
+	}
+}
+
+// OnEvict sets fn to be called with each entry which is evicted. It is called with the lock held, and so must not call
+// back into the BoundedTrie.
+func (bt *BoundedTrie) OnEvict(fn func(network netip.Prefix, value any)) {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+	bt.onEvict = fn
+}
+
+// Insert inserts an entry into the trie, evicting another entry if the trie is full.
+func (bt *BoundedTrie) Insert(network netip.Prefix, value any) {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+	network = normalizePrefix(network.Masked())
+	bt.trie.Insert(network, value)
+	if elem, ok := bt.elems[network]; ok {
+		if bt.policy == EvictLRU {
+			bt.order.MoveToBack(elem)
+		}
+		return
+	}
+	bt.elems[network] = bt.order.PushBack(network)
+	for bt.order.Len() > bt.max {
+		bt.evict()
+	}
+}
+
+// evict removes the entry at the front of the eviction order. bt.mu must be held.
+func (bt *BoundedTrie) evict() {
+	network := bt.order.Remove(bt.order.Front()).(netip.Prefix)
+	delete(bt.elems, network)
+	value := unempty(bt.trie.Remove(network))
+	if bt.onEvict != nil {
+		bt.onEvict(network, value)
+	}
+}
+
+// Remove removes the entry identified by given network from trie.
+func (bt *BoundedTrie) Remove(network netip.Prefix) any {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+	network = normalizePrefix(network.Masked())
+	elem, ok := bt.elems[network]
+	if !ok {
+		return nil
+	}
+	bt.order.Remove(elem)
+	delete(bt.elems, network)
+	return unempty(bt.trie.Remove(network))
+}
+
+// Find returns the value from the most specific network (largest prefix) containing the given address, marking the
+// entry as used.
+func (bt *BoundedTrie) Find(ip netip.Addr) any {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+	n := bt.trie.findNode(lookupAddr128(ip))
+	if n == nil {
+		return nil
+	}
+	if bt.policy == EvictLRU {
+		bt.order.MoveToBack(bt.elems[n.network()])
+	}
+	return unempty(n.value)
+}
+
+// Contains indicates whether the trie contains the given ip. It does not mark any entry as used.
+func (bt *BoundedTrie) Contains(ip netip.Addr) bool {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+	return bt.trie.Contains(ip)
+}
+
+// Len returns the number of entries in the trie.
+func (bt *BoundedTrie) Len() int {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+	return bt.order.Len()
+}
+
+// Snapshot returns an immutable point-in-time view of the trie.
+func (bt *BoundedTrie) Snapshot() *Trie {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+	return bt.trie.Snapshot()
+}
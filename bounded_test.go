@@ -0,0 +1,54 @@
+package iptrie
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBoundedTrieLRU(t *testing.T) {
+	bt := NewBoundedTrie(2, EvictLRU)
+	var evicted []netip.Prefix
+	bt.OnEvict(func(network netip.Prefix, value any) {
+		evicted = append(evicted, denormalizePrefix(network))
+	})
+
+	bt.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	bt.Insert(netip.MustParsePrefix("192.0.2.0/24"), "b")
+	// Using 10.0.0.0/8 makes 192.0.2.0/24 the least recently used.
+	assert.Equal(t, "a", bt.Find(netip.MustParseAddr("10.0.0.1")))
+	bt.Insert(netip.MustParsePrefix("2001:db8::/32"), "c")
+
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")}, evicted)
+	assert.Equal(t, 2, bt.Len())
+	assert.Nil(t, bt.Find(netip.MustParseAddr("192.0.2.1")))
+	assert.Equal(t, "a", bt.Find(netip.MustParseAddr("10.0.0.1")))
+
+	// Replacing a value counts as a use.
+	bt.Insert(netip.MustParsePrefix("2001:db8::/32"), "c2")
+	bt.Insert(netip.MustParsePrefix("198.51.100.0/24"), "d")
+	assert.Equal(t, netip.MustParsePrefix("10.0.0.0/8"), evicted[1])
+	assert.Equal(t, "c2", bt.Find(netip.MustParseAddr("2001:db8::1")))
+
+	assert.Equal(t, "d", bt.Remove(netip.MustParsePrefix("198.51.100.0/24")))
+	assert.Nil(t, bt.Remove(netip.MustParsePrefix("198.51.100.0/24")))
+	assert.Equal(t, 1, bt.Len())
+}
+
+func TestBoundedTrieFIFO(t *testing.T) {
+	bt := NewBoundedTrie(2, EvictFIFO)
+	bt.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	bt.Insert(netip.MustParsePrefix("10.1.0.0/16"), "b")
+	assert.Equal(t, "b", bt.Find(netip.MustParseAddr("10.1.0.1")))
+	assert.Equal(t, "a", bt.Find(netip.MustParseAddr("10.0.0.1")))
+	bt.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a2")
+	bt.Insert(netip.MustParsePrefix("10.2.0.0/16"), "c")
+
+	// 10.0.0.0/8 was inserted first, despite being used and replaced since.
+	assert.False(t, bt.Contains(netip.MustParseAddr("10.3.0.1")))
+	assert.Equal(t, "b", bt.Find(netip.MustParseAddr("10.1.0.1")))
+	assert.Equal(t, "c", bt.Find(netip.MustParseAddr("10.2.0.1")))
+	assert.Equal(t, 2, bt.Len())
+	assert.Equal(t, 2, len(bt.Snapshot().appendEntries(nil)))
+}
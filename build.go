@@ -0,0 +1,84 @@
+package iptrie
+
+// BuildFromSorted creates a new Trie containing the given entries, which must be sorted by address, and then by prefix
+// length (shortest first), after IPv4 networks are mapped to IPv6 (so all IPv4 networks sort between ::ffff:0:0 and
+// ::ffff:ffff:ffff). This is the order in which TrieLoader.InsertAll inserts entries. When multiple entries have the
+// same network, the last one wins, the same as with successive calls to Insert.
+//
+// As the entries are sorted, the trie can be constructed in a single pass, attaching each entry to the path leading to
+// the previous one rather than searching for its location. If the entries turn out not to be sorted, it falls back to
+// sorting and inserting them with a TrieLoader.
+//
+// The nodes are allocated out of large contiguous slabs, so the memory of removed nodes is not reclaimed until every
+// node in the same slab is removed. Nodes added to the trie afterwards are allocated individually.
+func BuildFromSorted(entries []Entry) *Trie {
+	t := NewTrie()
+
+	type key struct {
+		addr uint128
+		bits uint8
+	}
+	keys := make([]key, len(entries))
+	for i, entry := range entries {
+		keys[i].addr, keys[i].bits = prefix128(normalizePrefix(entry.Network))
+		if i == 0 {
+			continue
+		}
+		if prev := keys[i-1]; keys[i].addr.less(prev.addr) || (keys[i].addr == prev.addr && keys[i].bits < prev.bits) {
+			NewTrieLoader(t).InsertAll(entries)
+			return t
+		}
+	}
+
+	arena := &nodeArena{}
+	newNode := func(addr uint128, bits uint8, value any) *node {
+		n := arena.alloc()
+		n.owner = t.owner
+		n.addr = addr
+		n.bits = bits
+		n.value = value
+		return n
+	}
+
+	// path holds the nodes from the root to the most recently added node. Every node to the left of it is complete,
+	// and every node to the right is yet to be added.
+	path := []*node{&t.node}
+	for i, entry := range entries {
+		addr, bits := keys[i].addr, keys[i].bits
+		value := emptyize(entry.Value)
+
+		// Back out of the path until reaching a node containing the network. The last node backed out of is the child
+		// of that node in the direction of the previous entry.
+		var last *node
+		top := path[len(path)-1]
+		for top.bits > bits || !netContains(top.addr, top.bits, addr) {
+			last = top
+			path = path[:len(path)-1]
+			top = path[len(path)-1]
+		}
+		if top.bits == bits && top.addr == addr {
+			top.value = value
+			continue
+		}
+
+		n := newNode(addr, bits, value)
+		bit := top.discriminatorBit(addr)
+		if last == nil || top.discriminatorBit(last.addr) != bit {
+			top.appendTrie(bit, n)
+			path = append(path, n)
+			continue
+		}
+
+		// The network shares the child with the previous entries, so join them with a node at their divergence. As the
+		// entries are sorted, the network cannot contain any node already added which it doesn't share a path with.
+		divAddr, divBits := netDivergence(last.addr, last.bits, addr, bits)
+		div := newNode(divAddr, divBits, nil)
+		top.appendTrie(bit, div)
+		div.appendTrie(div.discriminatorBit(last.addr), last)
+		div.appendTrie(div.discriminatorBit(addr), n)
+		path = append(path, div, n)
+	}
+
+	t.refreshV4()
+	return t
+}
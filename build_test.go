@@ -0,0 +1,99 @@
+package iptrie
+
+import (
+	"net/netip"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildFromSorted(t *testing.T) {
+	var entries []Entry
+	for i := 0; i < 1000; i++ {
+		ip := GenIPV4()
+		entries = append(entries, Entry{netip.PrefixFrom(ip, 8+i%25).Masked(), i})
+	}
+	entries = append(entries,
+		Entry{netip.MustParsePrefix("::/0"), "default"},
+		Entry{netip.MustParsePrefix("2001:db8::/32"), "a"},
+		Entry{netip.MustParsePrefix("2001:db8:1::/48"), "b"},
+		Entry{netip.MustParsePrefix("2001:db8::/32"), "c"},
+		Entry{netip.MustParsePrefix("10.0.0.0/8"), nil},
+	)
+	sort.SliceStable(entries, func(i, j int) bool {
+		pi, pj := normalizePrefix(entries[i].Network), normalizePrefix(entries[j].Network)
+		if pi.Addr() != pj.Addr() {
+			return pi.Addr().Less(pj.Addr())
+		}
+		return pi.Bits() < pj.Bits()
+	})
+
+	expected := NewTrie()
+	for _, entry := range entries {
+		expected.Insert(entry.Network, entry.Value)
+	}
+
+	trie := BuildFromSorted(entries)
+	assert.Equal(t, expected.String(), trie.String())
+	expectedNodes, expectedEntries := expected.Count()
+	nodes, entryCount := trie.Count()
+	assert.Equal(t, expectedNodes, nodes)
+	assert.Equal(t, expectedEntries, entryCount)
+	assert.Equal(t, "c", trie.Find(netip.MustParseAddr("2001:db8::1")))
+	for _, entry := range entries {
+		assert.Equal(t, expected.Find(entry.Network.Addr()), trie.Find(entry.Network.Addr()))
+	}
+
+	// Removals must still compress paths through the new nodes.
+	for _, entry := range entries[:500] {
+		assert.Equal(t, expected.Remove(entry.Network), trie.Remove(entry.Network))
+	}
+	assert.Equal(t, expected.String(), trie.String())
+}
+
+func TestBuildFromSorted_unsorted(t *testing.T) {
+	entries := []Entry{
+		{netip.MustParsePrefix("192.168.0.0/16"), "a"},
+		{netip.MustParsePrefix("10.0.0.0/8"), "b"},
+		{netip.MustParsePrefix("10.1.0.0/16"), "c"},
+	}
+	expected := NewTrie()
+	for _, entry := range entries {
+		expected.Insert(entry.Network, entry.Value)
+	}
+
+	trie := BuildFromSorted(entries)
+	assert.Equal(t, expected.String(), trie.String())
+	assert.Equal(t, "c", trie.Find(netip.MustParseAddr("10.1.2.3")))
+}
+
+func TestBuildFromSorted_nil(t *testing.T) {
+	sorted := []Entry{
+		{netip.MustParsePrefix("10.0.0.0/8"), nil},
+		{netip.MustParsePrefix("10.1.0.0/16"), "a"},
+		{netip.MustParsePrefix("192.0.2.1/32"), nil},
+	}
+	unsorted := []Entry{sorted[2], sorted[0], sorted[1]}
+
+	for _, entries := range [][]Entry{sorted, unsorted} {
+		trie := BuildFromSorted(entries)
+		assert.True(t, trie.Contains(netip.MustParseAddr("10.2.0.1")))
+		assert.True(t, trie.Contains(netip.MustParseAddr("192.0.2.1")))
+		_, count := trie.Count()
+		assert.Equal(t, 3, count)
+	}
+	assert.Equal(t, BuildFromSorted(sorted).String(), BuildFromSorted(unsorted).String())
+}
+
+func BenchmarkBuildFromSorted(b *testing.B) {
+	entries := make([]Entry, 100000)
+	for i := range entries {
+		entries[i] = Entry{GenLeafIPNet(GenIPV4()), i}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Network.Addr().Less(entries[j].Network.Addr()) })
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		BuildFromSorted(entries)
+	}
+}
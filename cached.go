@@ -0,0 +1,109 @@
+package iptrie
+
+import (
+	"container/list"
+	"net/netip"
+	"sync"
+)
+
+// CachedTrie wraps a Trie, memoizing the results of recent Find calls in a least-recently-used cache. This benefits
+// workloads where a small set of hot addresses dominates the lookups.
+//
+// The cache is cleared whenever the trie is modified through the CachedTrie. The wrapped trie must not be modified
+// directly, as the cache would then return stale results.
+//
+// All methods are safe for concurrent use.
+type CachedTrie struct {
+	mu      sync.Mutex
+	trie    *Trie
+	size    int
+	entries map[netip.Addr]*list.Element
+	lru     list.List
+}
+
+type cacheEntry struct {
+	ip    netip.Addr
+	value any
+}
+
+// NewCachedTrie creates a CachedTrie wrapping trie, caching the results of up to size addresses.
+func NewCachedTrie(trie *Trie, size int) *CachedTrie {
+	return &CachedTrie{
+		trie:    trie,
+		size:    size,
+		entries: make(map[netip.Addr]*list.Element, size),
+	}
+}
+
+// Insert inserts an entry into the trie.
+func (ct *CachedTrie) Insert(network netip.Prefix, value any) {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	ct.trie.Insert(network, value)
+	ct.purge()
+}
+
+// Remove removes the entry identified by given network from trie.
+func (ct *CachedTrie) Remove(network netip.Prefix) any {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	v := ct.trie.Remove(network)
+	ct.purge()
+	return v
+}
+
+// Find returns the value from the most specific network (largest prefix) containing the given address.
+func (ct *CachedTrie) Find(ip netip.Addr) any {
+	ip = normalizeAddr(ip)
+
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	if e, ok := ct.entries[ip]; ok {
+		ct.lru.MoveToFront(e)
+		return e.Value.(*cacheEntry).value
+	}
+
+	value := ct.trie.Find(ip)
+	if ct.size <= 0 {
+		return value
+	}
+	if ct.lru.Len() >= ct.size {
+		// Reuse the least recently used entry rather than allocating a new one.
+		e := ct.lru.Back()
+		ce := e.Value.(*cacheEntry)
+		delete(ct.entries, ce.ip)
+		ce.ip, ce.value = ip, value
+		ct.lru.MoveToFront(e)
+		ct.entries[ip] = e
+		return value
+	}
+	ct.entries[ip] = ct.lru.PushFront(&cacheEntry{ip: ip, value: value})
+	return value
+}
+
+// FindLargest returns the value from the largest network (smallest prefix) containing the given address. The result is
+// not cached.
+func (ct *CachedTrie) FindLargest(ip netip.Addr) any {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	return ct.trie.FindLargest(ip)
+}
+
+// Contains indicates whether the trie contains the given ip. The result is not cached.
+func (ct *CachedTrie) Contains(ip netip.Addr) bool {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	return ct.trie.Contains(ip)
+}
+
+// Len returns the number of addresses currently cached.
+func (ct *CachedTrie) Len() int {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	return ct.lru.Len()
+}
+
+func (ct *CachedTrie) purge() {
+	clear(ct.entries)
+	ct.lru.Init()
+}
@@ -0,0 +1,41 @@
+package iptrie
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCachedTrie(t *testing.T) {
+	ct := NewCachedTrie(NewTrie(), 2)
+	ct.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+
+	ip1 := netip.MustParseAddr("10.0.0.1")
+	ip2 := netip.MustParseAddr("10.0.0.2")
+	ip3 := netip.MustParseAddr("10.0.0.3")
+	assert.Equal(t, "a", ct.Find(ip1))
+	assert.Equal(t, "a", ct.Find(ip2))
+	assert.Equal(t, "a", ct.Find(ip1))
+	assert.Equal(t, 2, ct.Len())
+
+	// ip2 is the least recently used, and should be evicted.
+	assert.Equal(t, "a", ct.Find(ip3))
+	assert.Equal(t, 2, ct.Len())
+	assert.Contains(t, ct.entries, normalizeAddr(ip1))
+	assert.Contains(t, ct.entries, normalizeAddr(ip3))
+	assert.NotContains(t, ct.entries, normalizeAddr(ip2))
+
+	// Modifications invalidate the cache.
+	ct.Insert(netip.MustParsePrefix("10.0.0.0/24"), "b")
+	assert.Equal(t, 0, ct.Len())
+	assert.Equal(t, "b", ct.Find(ip1))
+	assert.Equal(t, "b", ct.Find(netip.MustParseAddr("::ffff:10.0.0.1")))
+	assert.Equal(t, 1, ct.Len())
+	ct.Remove(netip.MustParsePrefix("10.0.0.0/24"))
+	assert.Equal(t, "a", ct.Find(ip1))
+
+	assert.True(t, ct.Contains(ip1))
+	assert.Equal(t, "a", ct.FindLargest(ip1))
+	assert.Nil(t, ct.Find(netip.MustParseAddr("11.0.0.1")))
+}
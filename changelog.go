@@ -0,0 +1,231 @@
+package iptrie
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/netip"
+	"sync"
+)
+
+// ChangeOp is the type of modification described by a Change.
+type ChangeOp uint8
+
+const (
+	// ChangeInsert is the insertion of an entry, or the replacement of the value of an existing entry.
+	ChangeInsert ChangeOp = iota + 1
+	// ChangeRemove is the removal of an entry.
+	ChangeRemove
+)
+
+func (op ChangeOp) String() string {
+	switch op {
+	case ChangeInsert:
+		return "insert"
+	case ChangeRemove:
+		return "remove"
+	}
+	return fmt.Sprintf("ChangeOp(%d)", uint8(op))
+}
+
+// Change describes a single modification of a trie.
+type Change struct {
+	Op ChangeOp
+	// Network is the network of the entry, normalized to IPv6.
+	Network netip.Prefix
+	// Value is the value inserted, or the value which was removed.
+	Value any
+}
+
+// SetChangeLog sets fn to be called with each modification made through Insert and Remove, after the modification has
+// been applied. Removals of entries which don't exist are not reported. Modifications made by other means, such as
+// replacing the contents with UnmarshalBinary or UnmarshalJSON, or a TrieLoader, are not reported. Passing nil disables
+// the change log.
+//
+// Snapshots of the trie keep reporting to the same change log, as do the tries created from it with NewRCUTrieFrom and
+// NewVersionedTrieFrom. The changes made in an RCUTrie transaction are passed when it's committed, and not at all if
+// it's aborted (see Txn).
+//
+// See ChangeLogWriter for persisting the changes.
+func (pt *Trie) SetChangeLog(fn func(Change)) {
+	pt.changeLog = fn
+}
+
+// Apply applies the given change to the trie.
+func (pt *Trie) Apply(c Change) error {
+	switch c.Op {
+	case ChangeInsert:
+		// The change records the value as stored, so it must not be merged again.
+		pt.insertEntry(c.Network, c.Value, nil)
+	case ChangeRemove:
+		pt.Remove(c.Network)
+	default:
+		return fmt.Errorf("iptrie: unknown change op %s", c.Op)
+	}
+	return nil
+}
+
+// Each record of the change log format is laid out as follows:
+//
+//	op    uint8
+//	bits  uint8
+//	addr  the leading ceil(bits/8) bytes of the (normalized) address
+//	value uvarint length+1 followed by the encoded value, with a length of 0 indicating a nil value. Only present for
+//	      inserts.
+
+// ChangeLogWriter writes changes to an io.Writer, allowing a trie to be persisted incrementally, and recovered with
+// ReplayChangeLog.
+//
+// Its Log method is intended to be passed to Trie.SetChangeLog. A ChangeLogWriter is safe for concurrent use.
+type ChangeLogWriter struct {
+	mu    sync.Mutex
+	w     io.Writer
+	codec ValueCodec
+	buf   []byte
+	err   error
+}
+
+// NewChangeLogWriter creates a ChangeLogWriter which writes to w, using codec to encode values. If codec is nil,
+// StringCodec is used.
+//
+// Each change is written with a single call to w.Write.
+func NewChangeLogWriter(w io.Writer, codec ValueCodec) *ChangeLogWriter {
+	if codec == nil {
+		codec = StringCodec
+	}
+	return &ChangeLogWriter{w: w, codec: codec}
+}
+
+// Log writes the given change. As the change log callback cannot return an error, once an error occurs, it is retained
+// and returned by Err, and all further changes are discarded.
+func (clw *ChangeLogWriter) Log(c Change) {
+	clw.mu.Lock()
+	defer clw.mu.Unlock()
+	if clw.err != nil {
+		return
+	}
+	clw.err = clw.write(c)
+}
+
+// Err returns the first error encountered while writing changes.
+func (clw *ChangeLogWriter) Err() error {
+	clw.mu.Lock()
+	defer clw.mu.Unlock()
+	return clw.err
+}
+
+func (clw *ChangeLogWriter) write(c Change) error {
+	network := normalizePrefix(c.Network)
+	addr, bits := prefix128(network)
+	var addrBytes [16]byte
+	binary.BigEndian.PutUint64(addrBytes[:8], addr.hi)
+	binary.BigEndian.PutUint64(addrBytes[8:], addr.lo)
+
+	buf := append(clw.buf[:0], uint8(c.Op), bits)
+	buf = append(buf, addrBytes[:(int(bits)+7)/8]...)
+	switch c.Op {
+	case ChangeInsert:
+		if c.Value == nil {
+			buf = binary.AppendUvarint(buf, 0)
+			break
+		}
+		value, err := clw.codec.Encode(c.Value)
+		if err != nil {
+			return fmt.Errorf("encoding value for %s: %w", network, err)
+		}
+		buf = binary.AppendUvarint(buf, uint64(len(value))+1)
+		buf = append(buf, value...)
+	case ChangeRemove:
+	default:
+		return fmt.Errorf("iptrie: unknown change op %s", c.Op)
+	}
+	clw.buf = buf
+	_, err := clw.w.Write(buf)
+	return err
+}
+
+// ReadChangeLog reads the changes written by a ChangeLogWriter from r until EOF, calling fn with each one. codec is used
+// to decode values, and if nil, StringCodec is used.
+//
+// If the log ends with an incomplete change, such as from a crash during a write, an error wrapping
+// io.ErrUnexpectedEOF is returned after all complete changes have been passed to fn.
+func ReadChangeLog(r io.Reader, codec ValueCodec, fn func(Change) error) error {
+	if codec == nil {
+		codec = StringCodec
+	}
+	br, ok := r.(binaryReader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	for {
+		c, err := readChange(br, codec)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(c); err != nil {
+			return err
+		}
+	}
+}
+
+// ReplayChangeLog applies the changes written by a ChangeLogWriter to the trie, as read by ReadChangeLog.
+//
+// The changes are applied through Insert and Remove, and so are reported to the trie's change log, if set.
+func (pt *Trie) ReplayChangeLog(r io.Reader, codec ValueCodec) error {
+	return ReadChangeLog(r, codec, pt.Apply)
+}
+
+// readChange reads a single change. io.EOF is only returned if there is no data at all.
+func readChange(r binaryReader, codec ValueCodec) (Change, error) {
+	var c Change
+	var hdr [2 + 16]byte
+	if _, err := io.ReadFull(r, hdr[:2]); err != nil {
+		if err == io.EOF {
+			return c, err
+		}
+		return c, changeLogError(err)
+	}
+	c.Op = ChangeOp(hdr[0])
+	bits := hdr[1]
+	if bits > 128 {
+		return c, fmt.Errorf("reading change log: invalid prefix length %d", bits)
+	}
+	if _, err := io.ReadFull(r, hdr[2:2+(int(bits)+7)/8]); err != nil {
+		return c, changeLogError(err)
+	}
+	c.Network = netip.PrefixFrom(netip.AddrFrom16([16]byte(hdr[2:])), int(bits)).Masked()
+
+	switch c.Op {
+	case ChangeInsert:
+		size, err := binary.ReadUvarint(r)
+		if err != nil {
+			return c, changeLogError(err)
+		}
+		if size == 0 {
+			break
+		}
+		data, err := readN(r, size-1)
+		if err != nil {
+			return c, changeLogError(err)
+		}
+		if c.Value, err = codec.Decode(data); err != nil {
+			return c, fmt.Errorf("decoding value for %s: %w", c.Network, err)
+		}
+	case ChangeRemove:
+	default:
+		return c, fmt.Errorf("reading change log: unknown change op %s", c.Op)
+	}
+	return c, nil
+}
+
+// changeLogError wraps an error encountered while reading a change, which has been partially read.
+func changeLogError(err error) error {
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return fmt.Errorf("reading change log: %w", err)
+}
@@ -0,0 +1,152 @@
+package iptrie
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrieChangeLog(t *testing.T) {
+	trie := NewTrie()
+	var changes []Change
+	trie.SetChangeLog(func(c Change) {
+		changes = append(changes, c)
+	})
+
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	trie.Insert(netip.MustParsePrefix("2001:db8::/32"), nil)
+	trie.Remove(netip.MustParsePrefix("10.0.0.0/8"))
+	trie.Remove(netip.MustParsePrefix("10.0.0.0/8"))
+	trie.Remove(netip.MustParsePrefix("2001:db8::/32"))
+	assert.Equal(t, []Change{
+		{ChangeInsert, netip.MustParsePrefix("::ffff:10.0.0.0/104"), "a"},
+		{ChangeInsert, netip.MustParsePrefix("2001:db8::/32"), nil},
+		{ChangeRemove, netip.MustParsePrefix("::ffff:10.0.0.0/104"), "a"},
+		{ChangeRemove, netip.MustParsePrefix("2001:db8::/32"), nil},
+	}, changes)
+
+	changes = nil
+	trie.Snapshot()
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), "b")
+	assert.Len(t, changes, 1)
+
+	trie.SetChangeLog(nil)
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), "c")
+	assert.Len(t, changes, 1)
+}
+
+func TestChangeLogWriterReplay(t *testing.T) {
+	var log bytes.Buffer
+	clw := NewChangeLogWriter(&log, nil)
+	trie := NewTrie()
+	trie.SetChangeLog(clw.Log)
+
+	for i := 0; i < 1000; i++ {
+		network := GenLeafIPNet(GenIPV4())
+		trie.Insert(network, network.String())
+		if i%3 == 0 {
+			trie.Remove(network)
+		}
+	}
+	trie.Insert(netip.MustParsePrefix("::/0"), "default")
+	trie.Insert(netip.MustParsePrefix("2001:db8::1/128"), nil)
+	require.NoError(t, clw.Err())
+
+	replayed := NewTrie()
+	require.NoError(t, replayed.ReplayChangeLog(bytes.NewReader(log.Bytes()), nil))
+	assert.Equal(t, trie.String(), replayed.String())
+
+	// A truncated log applies all complete changes.
+	data := log.Bytes()
+	replayed = NewTrie()
+	err := replayed.ReplayChangeLog(bytes.NewReader(data[:len(data)-1]), nil)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.Equal(t, "default", replayed.Find(netip.MustParseAddr("2001:db8::2")))
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("::/0")}, replayed.ContainingNetworks(netip.MustParseAddr("2001:db8::1")))
+}
+
+func TestChangeLogWriterError(t *testing.T) {
+	var log bytes.Buffer
+	clw := NewChangeLogWriter(&log, nil)
+	trie := NewTrie()
+	trie.SetChangeLog(clw.Log)
+
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	trie.Insert(netip.MustParsePrefix("10.1.0.0/16"), 1)
+	trie.Insert(netip.MustParsePrefix("10.2.0.0/16"), "c")
+	assert.Error(t, clw.Err())
+
+	var changes []Change
+	err := ReadChangeLog(&log, nil, func(c Change) error {
+		changes = append(changes, c)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []Change{{ChangeInsert, netip.MustParsePrefix("::ffff:10.0.0.0/104"), "a"}}, changes)
+
+	errStop := errors.New("stop")
+	err = ReadChangeLog(bytes.NewReader([]byte{byte(ChangeRemove), 0, byte(ChangeRemove), 0}), nil, func(c Change) error {
+		return errStop
+	})
+	assert.ErrorIs(t, err, errStop)
+	assert.Error(t, ReadChangeLog(bytes.NewReader([]byte{99, 0}), nil, func(c Change) error { return nil }))
+}
+
+func TestTrieChangeLogUnmarshalJSON(t *testing.T) {
+	src := NewTrie()
+	src.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	src.Insert(netip.MustParsePrefix("192.0.2.1/32"), nil)
+	data, err := src.MarshalJSON()
+	require.NoError(t, err)
+
+	trie := NewTrieWithMerge(func(old, new any) any { return "merged" })
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), "old")
+	var changes []Change
+	trie.SetChangeLog(func(c Change) {
+		changes = append(changes, c)
+	})
+	require.NoError(t, trie.UnmarshalJSON(data))
+	// Replacing the contents is not reported, and the entries are stored as they were.
+	assert.Empty(t, changes)
+	assert.Equal(t, src.String(), trie.String())
+}
+
+func TestTxnChangeLogAbort(t *testing.T) {
+	trie := NewTrie()
+	var changes []Change
+	trie.SetChangeLog(func(c Change) {
+		changes = append(changes, c)
+	})
+	rt := NewRCUTrieFrom(trie)
+
+	txn := rt.Txn()
+	txn.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	txn.Remove(netip.MustParsePrefix("10.0.0.0/8"))
+	txn.Insert(netip.MustParsePrefix("10.1.0.0/16"), "b")
+	// The records are only passed once the changes are visible to readers.
+	assert.Empty(t, changes)
+	txn.Abort()
+	assert.Empty(t, changes)
+
+	txn = rt.Txn()
+	txn.Insert(netip.MustParsePrefix("10.2.0.0/16"), "c")
+	txn.Insert(netip.MustParsePrefix("10.3.0.0/16"), "d")
+	assert.Empty(t, changes)
+	txn.Commit()
+	assert.Equal(t, []Change{
+		{ChangeInsert, netip.MustParsePrefix("::ffff:10.2.0.0/112"), "c"},
+		{ChangeInsert, netip.MustParsePrefix("::ffff:10.3.0.0/112"), "d"},
+	}, changes)
+
+	// Replaying the log reproduces only the committed changes.
+	replayed := NewTrie()
+	for _, c := range changes {
+		require.NoError(t, replayed.Apply(c))
+	}
+	assert.Equal(t, rt.Load().String(), replayed.String())
+}
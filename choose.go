@@ -0,0 +1,81 @@
+package iptrie
+
+import (
+	"net/netip"
+)
+
+const (
+	// chooseRebuildReadsPerEntry is the number of lookups per entry which must occur between modifications for rebuilding
+	// an immutable backend after each modification to pay for itself.
+	chooseRebuildReadsPerEntry = 16
+	// chooseDIR24MinEntries is the number of entries from which a DIR24Table is chosen for IPv4-only tables. Below it,
+	// the trie is shallow enough that the 64MiB table isn't worth its memory.
+	chooseDIR24MinEntries = 1 << 16
+)
+
+// WorkloadHints describes how a table will be used, for ChooseBackend to select the most appropriate backend.
+type WorkloadHints struct {
+	// Reads and Writes are the expected rates of lookups and modifications, in any consistent unit, as only their ratio
+	// is considered. Writes of 0 indicates the table will not be modified once built.
+	Reads, Writes float64
+	// LowMemory prefers backends with a smaller memory footprint over faster ones.
+	LowMemory bool
+}
+
+// ChooseBackend returns the backend best suited for looking up the current contents of the trie under the given
+// workload, judged by the number of entries, whether they are all IPv4, and the ratio of reads to writes:
+//
+//   - The trie itself, when it is modified too often for rebuilding an immutable backend after each modification to pay
+//     for itself, or when it uses settings which the other backends don't support, such as SetDefault or a zone policy
+//     other than ZoneIgnore.
+//   - A DIR24Table, for large tables of only IPv4 networks, unless hints.LowMemory is set. As a DIR24Table doesn't
+//     support FindLargest, that is answered by a FrozenTrie built alongside it.
+//   - A FrozenTrie, when hints.LowMemory is set.
+//   - A CompiledTrie otherwise.
+//
+// Except for the trie itself, the backend is built from a copy of the current contents, and does not reflect later
+// modifications of the trie. Applications which modify the trie should call ChooseBackend again after doing so, which
+// is what the Reads and Writes hints account for.
+func (pt *Trie) ChooseBackend(hints WorkloadHints) Finder {
+	if pt.defaultValue != nil || pt.zonePolicy != ZoneIgnore {
+		return pt
+	}
+
+	entries, v4Only := 0, true
+	pt.node.walk(func(n *node) bool {
+		entries++
+		if n.bits < 96 || !netContains(v4Prefix, 96, n.addr) {
+			v4Only = false
+		}
+		return true
+	})
+
+	if hints.Writes > 0 && hints.Reads/hints.Writes < float64(entries*chooseRebuildReadsPerEntry) {
+		return pt
+	}
+	switch {
+	case v4Only && entries >= chooseDIR24MinEntries && !hints.LowMemory:
+		return dir24Finder{DIR24Table: pt.CompileDIR24(), largest: pt.Freeze()}
+	case hints.LowMemory:
+		return pt.Freeze()
+	default:
+		return pt.Compile()
+	}
+}
+
+// Optimize returns the backend best suited for looking up the current contents of the trie when it will not be modified
+// further. It is ChooseBackend with hints for a read-only workload.
+func (pt *Trie) Optimize() Finder {
+	return pt.ChooseBackend(WorkloadHints{Reads: 1})
+}
+
+// dir24Finder adds FindLargest to a DIR24Table, making it a Finder.
+type dir24Finder struct {
+	*DIR24Table
+	largest *FrozenTrie
+}
+
+// FindLargest returns the value from the largest network (smallest prefix) containing the given address.
+func (df dir24Finder) FindLargest(ip netip.Addr) any {
+	return df.largest.FindLargest(ip)
+}
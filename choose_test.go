@@ -0,0 +1,56 @@
+package iptrie
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChooseBackend(t *testing.T) {
+	trie := NewTrie()
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	trie.Insert(netip.MustParsePrefix("10.1.0.0/16"), "b")
+	trie.Insert(netip.MustParsePrefix("2001:db8::/32"), "v6")
+
+	assert.IsType(t, &CompiledTrie{}, trie.Optimize())
+	assert.IsType(t, &CompiledTrie{}, trie.ChooseBackend(WorkloadHints{Reads: 1000, Writes: 1}))
+	assert.IsType(t, &FrozenTrie{}, trie.ChooseBackend(WorkloadHints{Reads: 1, LowMemory: true}))
+	assert.Same(t, trie, trie.ChooseBackend(WorkloadHints{Reads: 10, Writes: 1}))
+
+	f := trie.Optimize()
+	assert.Equal(t, "b", f.Find(netip.MustParseAddr("10.1.0.1")))
+	assert.Equal(t, "a", f.FindLargest(netip.MustParseAddr("10.1.0.1")))
+	assert.True(t, f.Contains(netip.MustParseAddr("2001:db8::1")))
+
+	// Settings only Trie supports keep the trie.
+	trie.SetDefault("default")
+	assert.Same(t, trie, trie.Optimize())
+}
+
+func TestChooseBackendDIR24(t *testing.T) {
+	trie := NewTrie()
+	loader := NewTrieLoader(trie)
+	loader.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	for i := 0; i < chooseDIR24MinEntries; i++ {
+		var b [4]byte
+		binary.BigEndian.PutUint32(b[:], 0x0a000000|uint32(i)<<4)
+		loader.Insert(netip.PrefixFrom(netip.AddrFrom4(b), 28), i)
+	}
+
+	f := trie.Optimize()
+	require.IsType(t, dir24Finder{}, f)
+	assert.Equal(t, 1, f.Find(netip.MustParseAddr("10.0.0.17")))
+	assert.Equal(t, "a", f.FindLargest(netip.MustParseAddr("10.0.0.17")))
+	assert.Equal(t, "a", f.Find(netip.MustParseAddr("10.255.0.1")))
+	assert.False(t, f.Contains(netip.MustParseAddr("11.0.0.1")))
+	assert.False(t, f.Contains(netip.MustParseAddr("2001:db8::1")))
+
+	assert.IsType(t, &FrozenTrie{}, trie.ChooseBackend(WorkloadHints{LowMemory: true}))
+
+	// An IPv6 network rules out the IPv4-only table.
+	trie.Insert(netip.MustParsePrefix("2001:db8::/32"), "v6")
+	assert.IsType(t, &CompiledTrie{}, trie.Optimize())
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/phemmer/go-iptrie"
+)
+
+// buildOptions are the options of a build, set by the command line flags.
+type buildOptions struct {
+	output   string
+	format   string
+	compress string
+	hash     bool
+}
+
+// buildResult describes a database which was built.
+type buildResult struct {
+	entries int
+	// size is the number of bytes written to the output.
+	size int64
+	// hash is the digest of the entries, from Trie.Hash.
+	hash [32]byte
+}
+
+// build loads the entries of the files, and writes them to the output as described by opts.
+func build(opts buildOptions, files []string) (buildResult, error) {
+	var compression *iptrie.Compression
+	switch opts.compress {
+	case "":
+	case iptrie.CompressionGzip.Name:
+		compression = &iptrie.CompressionGzip
+	default:
+		return buildResult{}, fmt.Errorf("unsupported compression %q", opts.compress)
+	}
+	var write func(trie *iptrie.Trie, w io.Writer) error
+	switch opts.format {
+	case "mapped":
+		write = func(trie *iptrie.Trie, w io.Writer) error {
+			return trie.WriteMapped(w, nil)
+		}
+	case "binary":
+		write = func(trie *iptrie.Trie, w io.Writer) error {
+			_, err := trie.WriteTo(w)
+			return err
+		}
+	default:
+		return buildResult{}, fmt.Errorf("unsupported format %q", opts.format)
+	}
+	if compression != nil {
+		uncompressed := write
+		write = func(trie *iptrie.Trie, w io.Writer) error {
+			zw, err := compression.NewWriter(w)
+			if err != nil {
+				return err
+			}
+			if err := uncompressed(trie, zw); err != nil {
+				return err
+			}
+			return zw.Close()
+		}
+	}
+
+	trie := iptrie.NewTrie()
+	for _, file := range files {
+		if err := trie.LoadCIDRListFile(file, iptrie.ParseCIDRValue); err != nil {
+			return buildResult{}, err
+		}
+	}
+	_, entries := trie.Count()
+	res := buildResult{entries: entries, hash: trie.Hash()}
+
+	digest := sha256.New()
+	size, err := writeFileAtomic(opts.output, func(w io.Writer) error {
+		return write(trie, io.MultiWriter(w, digest))
+	})
+	if err != nil {
+		return buildResult{}, err
+	}
+	res.size = size
+
+	if opts.hash {
+		line := hex.EncodeToString(digest.Sum(nil)) + "  " + filepath.Base(opts.output) + "\n"
+		if _, err := writeFileAtomic(opts.output+".sha256", func(w io.Writer) error {
+			_, err := io.WriteString(w, line)
+			return err
+		}); err != nil {
+			return buildResult{}, err
+		}
+	}
+	return res, nil
+}
+
+// writeFileAtomic writes the named file with fn, through a temporary file in the same directory which replaces the
+// file once fn succeeds. It returns the size of the file.
+func writeFileAtomic(name string, fn func(w io.Writer) error) (int64, error) {
+	f, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(f.Name())
+
+	err = fn(f)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return 0, fmt.Errorf("writing %s: %w", name, err)
+	}
+	info, err := os.Stat(f.Name())
+	if err != nil {
+		return 0, err
+	}
+	if err := os.Chmod(f.Name(), 0o644); err != nil {
+		return 0, err
+	}
+	return info.Size(), os.Rename(f.Name(), name)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/phemmer/go-iptrie"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func TestBuildMapped(t *testing.T) {
+	dir := t.TempDir()
+	files := []string{
+		writeFile(t, dir, "a.txt", "# tenants\n10.0.0.0/8 tenant a\n10.1.0.0/16\ttenant-b\n2001:db8::/32\n"),
+		writeFile(t, dir, "b.txt", "10.1.0.0/16 override\n192.0.2.1\n"),
+	}
+	output := filepath.Join(dir, "db.iptm")
+	res, err := build(buildOptions{output: output, format: "mapped", hash: true}, files)
+	require.NoError(t, err)
+	assert.Equal(t, 4, res.entries)
+
+	mt, err := iptrie.OpenMapped(output)
+	require.NoError(t, err)
+	defer mt.Close()
+	assert.Equal(t, "override", string(mt.Find(netip.MustParseAddr("10.1.0.1"))))
+	assert.Equal(t, "tenant a", string(mt.Find(netip.MustParseAddr("10.2.0.1"))))
+	assert.True(t, mt.Contains(netip.MustParseAddr("192.0.2.1")))
+	assert.True(t, mt.Contains(netip.MustParseAddr("2001:db8::1")))
+
+	data, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.EqualValues(t, len(data), res.size)
+	sum := sha256.Sum256(data)
+	checksum, err := os.ReadFile(output + ".sha256")
+	require.NoError(t, err)
+	assert.Equal(t, hex.EncodeToString(sum[:])+"  db.iptm\n", string(checksum))
+
+	// The entries hash doesn't depend on the format.
+	res2, err := build(buildOptions{output: filepath.Join(dir, "db.bin"), format: "binary"}, files)
+	require.NoError(t, err)
+	assert.Equal(t, res.hash, res2.hash)
+	assert.NoFileExists(t, filepath.Join(dir, "db.bin.sha256"))
+}
+
+func TestBuildCompressed(t *testing.T) {
+	dir := t.TempDir()
+	files := []string{writeFile(t, dir, "a.txt", "10.0.0.0/8 a\n10.1.0.0/16 b\n")}
+
+	output := filepath.Join(dir, "db.bin.gz")
+	_, err := build(buildOptions{output: output, format: "binary", compress: "gzip"}, files)
+	require.NoError(t, err)
+	f, err := os.Open(output)
+	require.NoError(t, err)
+	defer f.Close()
+	trie := iptrie.NewTrie()
+	_, err = trie.ReadFrom(f)
+	require.NoError(t, err)
+	assert.Equal(t, "b", trie.Find(netip.MustParseAddr("10.1.0.1")))
+
+	output = filepath.Join(dir, "db.iptm.gz")
+	_, err = build(buildOptions{output: output, format: "mapped", compress: "gzip"}, files)
+	require.NoError(t, err)
+	f, err = os.Open(output)
+	require.NoError(t, err)
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	data, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	mt, err := iptrie.NewMappedTrie(data)
+	require.NoError(t, err)
+	assert.Equal(t, "a", string(mt.Find(netip.MustParseAddr("10.2.0.1"))))
+}
+
+func TestBuildErrors(t *testing.T) {
+	dir := t.TempDir()
+	output := filepath.Join(dir, "db.iptm")
+	files := []string{writeFile(t, dir, "a.txt", "10.0.0.0/8 a\nbogus\n")}
+
+	_, err := build(buildOptions{output: output, format: "mapped"}, files)
+	assert.ErrorContains(t, err, "line 2")
+	_, err = build(buildOptions{output: output, format: "csv"}, files)
+	assert.ErrorContains(t, err, `unsupported format "csv"`)
+	_, err = build(buildOptions{output: output, format: "mapped", compress: "lz4"}, files)
+	assert.ErrorContains(t, err, `unsupported compression "lz4"`)
+	assert.NoFileExists(t, output)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "temporary files must be removed")
+}
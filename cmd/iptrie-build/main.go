@@ -0,0 +1,55 @@
+// Command iptrie-build compiles lists of networks into a lookup database, so that large databases can be built once,
+// such as in CI, and shipped as artifacts which services load without parsing or inserting entries.
+//
+// Usage:
+//
+//	iptrie-build -o output [-format mapped|binary] [-compress gzip] [-hash=false] file...
+//
+// Each file holds one entry per line, in the same format as read by iptried: a network (or single address), optionally
+// followed by whitespace and a value, which is stored as a string. Where multiple files contain the same network, the
+// value from the last file is used.
+//
+// The formats are:
+//
+//	mapped  the position independent format of Trie.WriteMapped, opened in place with iptrie.OpenMapped (default)
+//	binary  the format of Trie.WriteTo, loaded with Trie.ReadFrom
+//
+// With -compress, the output is compressed. Compressed binary databases are decompressed automatically by ReadFrom,
+// while mapped databases must be decompressed before they can be opened, so compression suits shipping them.
+//
+// The output is written to a temporary file which replaces the output once complete, so readers never see a partial
+// database. Unless -hash=false is given, the SHA-256 digest of the output is written alongside it, to output.sha256,
+// in the format checked by sha256sum -c. The digest of the entries themselves (see Trie.Hash), which is the same
+// regardless of format or compression, is printed once the database is built.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+func main() {
+	var opts buildOptions
+	flag.StringVar(&opts.output, "o", "", "path to write the database to")
+	flag.StringVar(&opts.format, "format", "mapped", "format of the database: mapped or binary")
+	flag.StringVar(&opts.compress, "compress", "", "compression of the database: gzip, or none if empty")
+	flag.BoolVar(&opts.hash, "hash", true, "write the SHA-256 digest of the database to output.sha256")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(),
+			"Usage: %s -o output [-format mapped|binary] [-compress gzip] [-hash=false] file...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if opts.output == "" || flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	res, err := build(opts, flag.Args())
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("wrote %d entries (%d bytes) to %s, entries hash %x", res.entries, res.size, opts.output, res.hash)
+}
@@ -0,0 +1,68 @@
+// Command iptried is a lookup service for networks loaded from files, serving queries over HTTP with JSON responses.
+// It is a reference deployment of the iptrie package.
+//
+// Usage:
+//
+//	iptried [-listen addr] file...
+//
+// Each file holds one entry per line, in the format read by iptrie.Trie.LoadCIDRList: a network (or single address),
+// optionally followed by whitespace and a value, which is served as a string. Where multiple files contain the same
+// network, the value from the last file is used.
+//
+// The following endpoints are served:
+//
+//	GET /lookup?ip=<addr>      the most specific entry containing the address, and all networks containing it
+//	GET /covered?cidr=<network> the entries contained within the network
+//
+// On SIGHUP the files are read again, and the new entries replace the old ones atomically. If any file fails to load,
+// the error is logged and the previous entries continue to be served.
+//
+// Only HTTP is served, keeping the command free of dependencies outside the standard library. A gRPC service can be
+// built on the same pattern, protected by the interceptors of the iptriegrpc module.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func main() {
+	listen := flag.String("listen", ":8080", "address to serve HTTP on")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-listen addr] file...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	srv := newServer(flag.Args())
+	if err := srv.reload(); err != nil {
+		log.Fatal(err)
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := srv.reload(); err != nil {
+				log.Printf("reload failed: %s", err)
+				continue
+			}
+			log.Printf("reloaded %d entries", srv.entries())
+		}
+	}()
+
+	log.Printf("serving %d entries on %s", srv.entries(), *listen)
+	if err := http.ListenAndServe(*listen, srv.handler()); !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/netip"
+
+	"github.com/phemmer/go-iptrie"
+)
+
+// server serves lookups against the entries loaded from its files.
+type server struct {
+	files []string
+	trie  *iptrie.AtomicTrie
+}
+
+// newServer creates a server for the given files, which serves no entries until reload is called.
+func newServer(files []string) *server {
+	return &server{
+		files: files,
+		trie:  iptrie.NewAtomicTrie(nil),
+	}
+}
+
+// reload builds a new trie from the files, and then replaces the current trie with it. If an error occurs, the current
+// trie is left in place.
+func (s *server) reload() error {
+	trie := iptrie.NewTrie()
+	trie.SetDenormalize(true)
+	for _, file := range s.files {
+		if err := trie.LoadCIDRListFile(file, iptrie.ParseCIDRValue); err != nil {
+			return err
+		}
+	}
+	s.trie.Store(trie)
+	return nil
+}
+
+// entries returns the number of entries currently being served.
+func (s *server) entries() int {
+	_, entries := s.trie.Load().Count()
+	return entries
+}
+
+// handler returns the HTTP handler serving the endpoints.
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/lookup", s.lookup)
+	mux.HandleFunc("/covered", s.covered)
+	return mux
+}
+
+// lookupResponse is the response to /lookup.
+type lookupResponse struct {
+	IP    netip.Addr `json:"ip"`
+	Found bool       `json:"found"`
+	// Network and Value are those of the most specific entry containing IP.
+	Network  *netip.Prefix  `json:"network,omitempty"`
+	Value    any            `json:"value,omitempty"`
+	Networks []netip.Prefix `json:"networks"`
+}
+
+func (s *server) lookup(w http.ResponseWriter, r *http.Request) {
+	ip, err := netip.ParseAddr(r.URL.Query().Get("ip"))
+	if err != nil {
+		writeError(w, fmt.Errorf("invalid ip: %w", err))
+		return
+	}
+	trie := s.trie.Load()
+	resp := lookupResponse{
+		IP:       ip,
+		Networks: trie.ContainingNetworks(ip),
+	}
+	if resp.Networks == nil {
+		resp.Networks = []netip.Prefix{}
+	}
+	if n := len(resp.Networks); n > 0 {
+		resp.Found = true
+		resp.Network = &resp.Networks[n-1]
+		resp.Value = trie.Find(ip)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// coveredResponse is the response to /covered.
+type coveredResponse struct {
+	CIDR     netip.Prefix   `json:"cidr"`
+	Networks []netip.Prefix `json:"networks"`
+}
+
+func (s *server) covered(w http.ResponseWriter, r *http.Request) {
+	network, err := netip.ParsePrefix(r.URL.Query().Get("cidr"))
+	if err != nil {
+		writeError(w, fmt.Errorf("invalid cidr: %w", err))
+		return
+	}
+	resp := coveredResponse{
+		CIDR:     network,
+		Networks: s.trie.Load().CoveredNetworks(network),
+	}
+	if resp.Networks == nil {
+		resp.Networks = []netip.Prefix{}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// writeError writes err as a JSON error response for a bad request.
+func writeError(w http.ResponseWriter, err error) {
+	writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
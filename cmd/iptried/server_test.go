@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func get(t *testing.T, h http.Handler, url string) (int, map[string]any) {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", url, nil))
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	return rec.Code, body
+}
+
+func TestServer(t *testing.T) {
+	dir := t.TempDir()
+	srv := newServer([]string{
+		writeFile(t, dir, "a.txt", "# tenants\n10.0.0.0/8 tenant a\n10.1.0.0/16\ttenant-b\n2001:db8::/32\n"),
+		writeFile(t, dir, "b.txt", "10.1.0.0/16 override\n192.0.2.1\n"),
+	})
+	require.NoError(t, srv.reload())
+	assert.Equal(t, 4, srv.entries())
+	h := srv.handler()
+
+	code, body := get(t, h, "/lookup?ip=10.1.2.3")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, map[string]any{
+		"ip":       "10.1.2.3",
+		"found":    true,
+		"network":  "10.1.0.0/16",
+		"value":    "override",
+		"networks": []any{"10.0.0.0/8", "10.1.0.0/16"},
+	}, body)
+
+	_, body = get(t, h, "/lookup?ip=10.2.0.1")
+	assert.Equal(t, "tenant a", body["value"])
+
+	_, body = get(t, h, "/lookup?ip=2001:db8::1")
+	assert.Equal(t, map[string]any{
+		"ip":       "2001:db8::1",
+		"found":    true,
+		"network":  "2001:db8::/32",
+		"networks": []any{"2001:db8::/32"},
+	}, body)
+
+	_, body = get(t, h, "/lookup?ip=198.51.100.1")
+	assert.Equal(t, map[string]any{
+		"ip":       "198.51.100.1",
+		"found":    false,
+		"networks": []any{},
+	}, body)
+
+	code, body = get(t, h, "/covered?cidr=0.0.0.0/0")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []any{"10.0.0.0/8", "10.1.0.0/16", "192.0.2.1/32"}, body["networks"])
+
+	code, body = get(t, h, "/lookup?ip=bogus")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Contains(t, body["error"], "invalid ip")
+	code, _ = get(t, h, "/covered?cidr=10.0.0.0")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestServerReload(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "a.txt", "10.0.0.0/8 a\n")
+	srv := newServer([]string{path})
+	require.NoError(t, srv.reload())
+	h := srv.handler()
+
+	writeFile(t, dir, "a.txt", "192.0.2.0/24 b\n")
+	require.NoError(t, srv.reload())
+	_, body := get(t, h, "/lookup?ip=10.0.0.1")
+	assert.Equal(t, false, body["found"])
+	_, body = get(t, h, "/lookup?ip=192.0.2.1")
+	assert.Equal(t, "b", body["value"])
+
+	// A failed reload leaves the previous entries in place.
+	writeFile(t, dir, "a.txt", "198.51.100.0/24 c\nbogus\n")
+	err := srv.reload()
+	assert.ErrorContains(t, err, "a.txt: line 2:")
+	_, body = get(t, h, "/lookup?ip=192.0.2.1")
+	assert.Equal(t, "b", body["value"])
+	_, body = get(t, h, "/lookup?ip=198.51.100.1")
+	assert.Equal(t, false, body["found"])
+}
@@ -0,0 +1,90 @@
+package iptrie
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ValueCodec converts values to and from bytes, allowing the values of a trie to be persisted by the various
+// serialization methods.
+//
+// nil values are handled by the serialization methods themselves, and are not passed to a ValueCodec.
+type ValueCodec interface {
+	// Encode returns the encoded form of value.
+	Encode(value any) ([]byte, error)
+	// Decode returns the value encoded in data. data must not be retained, as it may be reused.
+	Decode(data []byte) (any, error)
+}
+
+var (
+	// StringCodec encodes values of type string or []byte, and decodes them as strings.
+	StringCodec ValueCodec = stringCodec{}
+	// BytesCodec encodes values of type []byte or string, and decodes them as []byte.
+	BytesCodec ValueCodec = bytesCodec{}
+	// JSONCodec encodes values as JSON, and decodes them in the same manner as json.Unmarshal decodes into an
+	// interface value. Use JSONCodecOf to decode into a specific type.
+	JSONCodec ValueCodec = jsonCodec[any]{}
+)
+
+// JSONCodecOf returns a ValueCodec which encodes values as JSON, and decodes them as values of type T.
+func JSONCodecOf[T any]() ValueCodec {
+	return jsonCodec[T]{}
+}
+
+// ValueCodecFuncs is a ValueCodec which uses the given functions.
+type ValueCodecFuncs struct {
+	EncodeFunc func(value any) ([]byte, error)
+	DecodeFunc func(data []byte) (any, error)
+}
+
+func (vcf ValueCodecFuncs) Encode(value any) ([]byte, error) {
+	return vcf.EncodeFunc(value)
+}
+
+func (vcf ValueCodecFuncs) Decode(data []byte) (any, error) {
+	return vcf.DecodeFunc(data)
+}
+
+type stringCodec struct{}
+
+func (stringCodec) Encode(value any) ([]byte, error) {
+	return encodeBytes(value)
+}
+
+func (stringCodec) Decode(data []byte) (any, error) {
+	return string(data), nil
+}
+
+type bytesCodec struct{}
+
+func (bytesCodec) Encode(value any) ([]byte, error) {
+	return encodeBytes(value)
+}
+
+func (bytesCodec) Decode(data []byte) (any, error) {
+	return append([]byte(nil), data...), nil
+}
+
+type jsonCodec[T any] struct{}
+
+func (jsonCodec[T]) Encode(value any) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+func (jsonCodec[T]) Decode(data []byte) (any, error) {
+	var v T
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func encodeBytes(value any) ([]byte, error) {
+	switch v := value.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	}
+	return nil, fmt.Errorf("unsupported value type %T", value)
+}
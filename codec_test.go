@@ -0,0 +1,49 @@
+package iptrie
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValueCodecs(t *testing.T) {
+	type point struct {
+		X, Y int
+	}
+	cases := []struct {
+		name    string
+		codec   ValueCodec
+		value   any
+		decoded any
+	}{
+		{"string", StringCodec, "a", "a"},
+		{"string from bytes", StringCodec, []byte("a"), "a"},
+		{"bytes", BytesCodec, []byte("a"), []byte("a")},
+		{"bytes from string", BytesCodec, "a", []byte("a")},
+		{"json", JSONCodec, map[string]any{"a": 1}, map[string]any{"a": 1.0}},
+		{"json of", JSONCodecOf[point](), point{1, 2}, point{1, 2}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			trie := NewTrie()
+			trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), tc.value)
+			trie.Insert(netip.MustParsePrefix("10.1.0.0/16"), nil)
+
+			data, err := trie.MarshalBinaryCodec(tc.codec)
+			require.NoError(t, err)
+			loaded := NewTrie()
+			require.NoError(t, loaded.UnmarshalBinaryCodec(data, tc.codec))
+			assert.Equal(t, tc.decoded, loaded.Find(netip.MustParseAddr("10.0.0.1")))
+			assert.True(t, loaded.Contains(netip.MustParseAddr("10.1.0.1")))
+		})
+	}
+
+	_, err := StringCodec.Encode(1)
+	assert.Error(t, err)
+	_, err = BytesCodec.Encode(1)
+	assert.Error(t, err)
+	_, err = JSONCodecOf[point]().Decode([]byte(`"a"`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,55 @@
+package iptrie
+
+// Compact re-applies path compression across the whole trie, removing any implicit nodes which have fewer than 2
+// children. Such nodes serve no purpose other than to lengthen lookups, and may accumulate after large numbers of
+// removals.
+//
+// Nodes shared with snapshots are copied only where they need to be modified.
+func (pt *Trie) Compact() {
+	marked := map[*node]bool{}
+	pt.node.markCompaction(marked, true)
+	if len(marked) == 0 {
+		return
+	}
+	// Move to a new generation, so that a TrieLoader holding nodes which are about to be removed starts over from the
+	// root. The nodes being modified are copied as a result, as is the path to each node modified afterwards.
+	pt.owner = pt.owner.fork()
+	pt.node.compactChildren(marked)
+	pt.refreshV4()
+}
+
+// markCompaction records every node which either needs to be removed by compaction, or has a descendant that does. It
+// returns whether pt was recorded.
+func (pt *node) markCompaction(marked map[*node]bool, root bool) bool {
+	needed := !root && pt.value == nil && pt.childrenCount() <= 1
+	for _, child := range pt.children {
+		if child != nil && child.markCompaction(marked, false) {
+			needed = true
+		}
+	}
+	if needed {
+		marked[pt] = true
+	}
+	return needed
+}
+
+func (pt *node) compactChildren(marked map[*node]bool) {
+	for bit := range pt.children {
+		if !marked[pt.children[bit]] {
+			continue
+		}
+		child := pt.ownChild(uint8(bit))
+		child.compactChildren(marked)
+		if child.value != nil || child.childrenCount() > 1 {
+			continue
+		}
+
+		// Replace the child with its lone child, if any.
+		pt.children[bit] = nil
+		for grandBit, grandchild := range child.children {
+			if grandchild != nil {
+				pt.children[bit] = child.ownChild(uint8(grandBit))
+			}
+		}
+	}
+}
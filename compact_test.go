@@ -0,0 +1,66 @@
+package iptrie
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrieCompact(t *testing.T) {
+	trie := NewTrie()
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	trie.Insert(netip.MustParsePrefix("10.1.1.0/24"), "b")
+	trie.Insert(netip.MustParsePrefix("10.2.0.0/16"), "c")
+	// Simulate implicit chains left behind, which wouldn't otherwise be created.
+	for _, network := range []string{"10.1.0.0/16", "10.1.0.0/20", "10.1.0.0/23", "172.16.0.0/12", "172.16.0.0/16"} {
+		addr, bits := prefix128(normalizePrefix(netip.MustParsePrefix(network)))
+		trie.insert(addr, bits, nil)
+	}
+	assert.Equal(t, `::/0
+├ ::ffff:0.0.0.0/96
+├ ├ ::ffff:10.0.0.0/104 • a
+├ ├ ├ ::ffff:10.0.0.0/110
+├ ├ ├ ├ ::ffff:10.1.0.0/112
+├ ├ ├ ├ ├ ::ffff:10.1.0.0/116
+├ ├ ├ ├ ├ ├ ::ffff:10.1.0.0/119
+├ ├ ├ ├ ├ ├ ├ ::ffff:10.1.1.0/120 • b
+├ ├ ├ ├ ::ffff:10.2.0.0/112 • c
+├ ├ ::ffff:172.16.0.0/108
+├ ├ ├ ::ffff:172.16.0.0/112`, trie.String())
+
+	snap := trie.Snapshot()
+	snapStr := snap.String()
+
+	trie.Compact()
+	assert.Equal(t, `::/0
+├ ::ffff:10.0.0.0/104 • a
+├ ├ ::ffff:10.0.0.0/110
+├ ├ ├ ::ffff:10.1.1.0/120 • b
+├ ├ ├ ::ffff:10.2.0.0/112 • c`, trie.String())
+	assert.Equal(t, snapStr, snap.String())
+	assert.Equal(t, "b", trie.Find(netip.MustParseAddr("10.1.1.1")))
+	assert.Equal(t, "a", trie.Find(netip.MustParseAddr("10.1.2.1")))
+
+	// Subsequent removals must still compress paths correctly.
+	trie.Remove(netip.MustParsePrefix("10.2.0.0/16"))
+	assert.Equal(t, `::/0
+├ ::ffff:10.0.0.0/104 • a
+├ ├ ::ffff:10.1.1.0/120 • b`, trie.String())
+}
+
+func TestTrieCompactLoader(t *testing.T) {
+	trie := NewTrie()
+	for _, network := range []string{"10.1.0.0/16", "10.1.0.0/20", "10.1.0.0/23"} {
+		addr, bits := prefix128(normalizePrefix(netip.MustParsePrefix(network)))
+		trie.insert(addr, bits, nil)
+	}
+	loader := NewTrieLoader(trie)
+	loader.Insert(netip.MustParsePrefix("10.1.1.0/24"), "a")
+	trie.Compact()
+
+	// The loader's cached path runs through the removed nodes, so must not be used.
+	loader.Insert(netip.MustParsePrefix("10.1.0.0/24"), "b")
+	assert.Equal(t, "a", trie.Find(netip.MustParseAddr("10.1.1.1")))
+	assert.Equal(t, "b", trie.Find(netip.MustParseAddr("10.1.0.1")))
+}
@@ -0,0 +1,326 @@
+package iptrie
+
+import (
+	"math/bits"
+	"net/netip"
+)
+
+// Finder is the interface shared by the different lookup backends, allowing callers to switch between them.
+type Finder interface {
+	// Find returns the value from the most specific network (largest prefix) containing the given address.
+	Find(ip netip.Addr) any
+	// FindLargest returns the value from the largest network (smallest prefix) containing the given address.
+	FindLargest(ip netip.Addr) any
+	// Contains indicates whether the given address is contained within any network.
+	Contains(ip netip.Addr) bool
+}
+
+// Table is a Finder which can also enumerate its networks, implemented by the backends which retain them. Applications
+// using Table rather than a concrete type can switch between a mutable Trie, its frozen form, and other backends
+// without other code changes.
+type Table interface {
+	Finder
+	// ContainingNetworks returns the list of networks containing the given address in ascending prefix order (largest
+	// network to smallest).
+	ContainingNetworks(ip netip.Addr) []netip.Prefix
+	// CoveredNetworks returns the list of networks contained within the given network.
+	CoveredNetworks(network netip.Prefix) []netip.Prefix
+	// Walk calls fn for each entry in depth order, until fn returns false.
+	Walk(fn func(network netip.Prefix, value any) bool)
+}
+
+var (
+	_ Finder = (*Trie)(nil)
+	_ Finder = (*Trie4)(nil)
+	_ Finder = (*RCUTrie)(nil)
+	_ Finder = (*AtomicTrie)(nil)
+	_ Finder = (*FrozenTrie)(nil)
+	_ Finder = (*CompiledTrie)(nil)
+	_ Finder = (*MultibitTrie)(nil)
+
+	_ Table = (*Trie)(nil)
+	_ Table = (*Trie4)(nil)
+	_ Table = (*RCUTrie)(nil)
+	_ Table = (*AtomicTrie)(nil)
+	_ Table = (*FrozenTrie)(nil)
+	_ Table = (*MultibitTrie)(nil)
+)
+
+// FindAs is like Finder.Find, but returns the value as type T. ok is false if no network contains the address, or the
+// value is not a T.
+//
+// Values are stored as interfaces, so this only performs a type assertion, and like Find, does not allocate.
+func FindAs[T any](f Finder, ip netip.Addr) (value T, ok bool) {
+	value, ok = f.Find(ip).(T)
+	return value, ok
+}
+
+// CompiledTrie is an immutable lookup table built from a Trie, using level-compressed multibit nodes in the style of
+// ART (Allotment Routing Table) and BART.
+//
+// Each node covers 8 bits of the address, so a lookup visits at most 16 nodes (4 for IPv4) regardless of how the
+// networks are distributed. Within a node, the networks are stored as a bitmap indexed by their position in a complete
+// binary tree of the node's 8 bits, allowing the most specific match within the node to be found with a few bitwise
+// operations. Children and values are stored in sparse slices indexed by bitmap popcount.
+//
+// Construction is considerably slower than for a Trie, making it best suited for tables which are built once and then
+// queried heavily.
+//
+// A CompiledTrie is safe for concurrent use.
+type CompiledTrie struct {
+	root *artNode
+
+	// v4 is the node for the IPv4 (::ffff:0:0/96) portion of the table, or nil if there is none. As every IPv4
+	// address shares the same first 12 bytes, IPv4 lookups can start here, using v4First and v4Last as the least and
+	// most specific values matched along the way.
+	v4      *artNode
+	v4First any
+	v4Last  any
+}
+
+type artNode struct {
+	// prefixes is a bitmap of the networks within the node, indexed by their ART index (see artIndex).
+	prefixes bitset512
+	// values contains the value of each network in prefixes, in index order.
+	values []any
+
+	// children is a bitmap of the child nodes, indexed by octet.
+	children bitset256
+	// nodes contains each child in children, in octet order.
+	nodes []*artNode
+}
+
+// Compile builds a CompiledTrie from the current contents of the trie. The trie itself is unaffected, and may continue
+// to be modified.
+func (pt *Trie) Compile() *CompiledTrie {
+	ct := &CompiledTrie{root: &artNode{}}
+	pt.node.compileInto(ct)
+
+	// Walk the path shared by all IPv4 addresses to find the v4 starting node, mirroring findLargest and Find for the
+	// values matched along the way.
+	b := addrFrom128(v4Prefix).As16()
+	n := ct.root
+	for depth := 0; depth < 12 && n != nil; depth++ {
+		if idx, ok := n.prefixes.intersectionBottom(&artHostAncestors[b[depth]]); ok && ct.v4First == nil {
+			ct.v4First = n.values[n.prefixes.rank(idx)]
+		}
+		if value, ok := n.lpm(b[depth], false); ok {
+			ct.v4Last = value
+		}
+		n = n.child(b[depth])
+	}
+	ct.v4 = n
+	return ct
+}
+
+func (pt *node) compileInto(ct *CompiledTrie) {
+	if pt.value != nil {
+		ct.insert(pt.addr, pt.bits, pt.value)
+	}
+	for _, child := range pt.children {
+		if child != nil {
+			child.compileInto(ct)
+		}
+	}
+}
+
+func (ct *CompiledTrie) insert(addr uint128, pfxLen uint8, value any) {
+	b := addrFrom128(addr).As16()
+	// Networks whose length is a multiple of 8 are stored in the node above, as the most specific index of that node,
+	// rather than as the least specific index of the node below.
+	depth := 0
+	if pfxLen > 0 {
+		depth = int(pfxLen-1) / 8
+	}
+	n := ct.root
+	for i := 0; i < depth; i++ {
+		n = n.addChild(b[i])
+	}
+	n.addPrefix(artIndex(b[depth], pfxLen-uint8(depth*8)), value)
+}
+
+// Find returns the value from the most specific network (largest prefix) containing the given address.
+func (ct *CompiledTrie) Find(ip netip.Addr) any {
+	n, depth, value := ct.root, 0, any(nil)
+	if ip.Is4() {
+		n, depth, value = ct.v4, 12, ct.v4Last
+	}
+	b := normalizeAddr(ip).As16()
+	for ; n != nil; depth++ {
+		if v, ok := n.lpm(b[depth], depth == 15); ok {
+			value = v
+		}
+		if depth == 15 {
+			break
+		}
+		n = n.child(b[depth])
+	}
+	return unempty(value)
+}
+
+// FindLargest returns the value from the largest network (smallest prefix) containing the given address.
+func (ct *CompiledTrie) FindLargest(ip netip.Addr) any {
+	return unempty(ct.findLargest(ip))
+}
+
+// Contains indicates whether the trie contains the given ip.
+func (ct *CompiledTrie) Contains(ip netip.Addr) bool {
+	return ct.findLargest(ip) != nil
+}
+
+func (ct *CompiledTrie) findLargest(ip netip.Addr) any {
+	n, depth := ct.root, 0
+	if ip.Is4() {
+		if ct.v4First != nil {
+			return ct.v4First
+		}
+		n, depth = ct.v4, 12
+	}
+	b := normalizeAddr(ip).As16()
+	for ; n != nil; depth++ {
+		if idx, ok := n.prefixes.intersectionBottom(&artHostAncestors[b[depth]]); ok {
+			return n.values[n.prefixes.rank(idx)]
+		}
+		if depth == 15 {
+			break
+		}
+		n = n.child(b[depth])
+	}
+	return nil
+}
+
+// lpm returns the value of the most specific network within the node containing the given octet. As with Trie.Find,
+// placeholders for nil values are skipped in favor of less specific networks, except on a full address match, which
+// last indicates is possible, as the octet is the last of the address.
+func (n *artNode) lpm(octet byte, last bool) (any, bool) {
+	idx, ok := n.prefixes.intersectionTop(&artHostAncestors[octet])
+	if !ok {
+		return nil, false
+	}
+	value := n.values[n.prefixes.rank(idx)]
+	if value != empty || last && idx >= 256 {
+		return value, true
+	}
+
+	ancestors := artHostAncestors[octet]
+	for value == empty {
+		ancestors.clear(idx)
+		if idx, ok = n.prefixes.intersectionTop(&ancestors); !ok {
+			return nil, false
+		}
+		value = n.values[n.prefixes.rank(idx)]
+	}
+	return value, true
+}
+
+func (n *artNode) child(octet byte) *artNode {
+	if !n.children.test(uint(octet)) {
+		return nil
+	}
+	return n.nodes[n.children.rank(uint(octet))]
+}
+
+func (n *artNode) addChild(octet byte) *artNode {
+	if child := n.child(octet); child != nil {
+		return child
+	}
+	n.children.set(uint(octet))
+	i := n.children.rank(uint(octet))
+	n.nodes = append(n.nodes, nil)
+	copy(n.nodes[i+1:], n.nodes[i:])
+	n.nodes[i] = &artNode{}
+	return n.nodes[i]
+}
+
+func (n *artNode) addPrefix(idx uint, value any) {
+	i := n.prefixes.rank(idx)
+	if n.prefixes.test(idx) {
+		n.values[i] = value
+		return
+	}
+	n.prefixes.set(idx)
+	n.values = append(n.values, nil)
+	copy(n.values[i+1:], n.values[i:])
+	n.values[i] = value
+}
+
+// artIndex returns the index of the network of the given length (0-8) starting at octet, within a complete binary tree
+// where index 1 is the root (/0), and the children of index i are 2i and 2i+1.
+func artIndex(octet byte, pfxLen uint8) uint {
+	return 1<<pfxLen + uint(octet)>>(8-pfxLen)
+}
+
+// artHostAncestors contains, for each octet, the set of all ART indexes of the networks which contain that octet.
+var artHostAncestors [256]bitset512
+
+func init() {
+	for octet := range artHostAncestors {
+		for idx := artIndex(byte(octet), 8); idx > 0; idx >>= 1 {
+			artHostAncestors[octet].set(idx)
+		}
+	}
+}
+
+// bitset512 is a fixed size set of bits, where bit i is stored in word i/64.
+type bitset512 [8]uint64
+
+func (b *bitset512) set(i uint) {
+	b[i/64] |= 1 << (i % 64)
+}
+
+func (b *bitset512) clear(i uint) {
+	b[i/64] &^= 1 << (i % 64)
+}
+
+func (b *bitset512) test(i uint) bool {
+	return b[i/64]&(1<<(i%64)) != 0
+}
+
+// rank returns the number of bits set below i.
+func (b *bitset512) rank(i uint) int {
+	var n int
+	for w := uint(0); w < i/64; w++ {
+		n += bits.OnesCount64(b[w])
+	}
+	return n + bits.OnesCount64(b[i/64]&(1<<(i%64)-1))
+}
+
+// intersectionTop returns the highest bit set in both b and c.
+func (b *bitset512) intersectionTop(c *bitset512) (uint, bool) {
+	for w := len(b) - 1; w >= 0; w-- {
+		if x := b[w] & c[w]; x != 0 {
+			return uint(w)*64 + uint(63-bits.LeadingZeros64(x)), true
+		}
+	}
+	return 0, false
+}
+
+// intersectionBottom returns the lowest bit set in both b and c.
+func (b *bitset512) intersectionBottom(c *bitset512) (uint, bool) {
+	for w := range b {
+		if x := b[w] & c[w]; x != 0 {
+			return uint(w)*64 + uint(bits.TrailingZeros64(x)), true
+		}
+	}
+	return 0, false
+}
+
+// bitset256 is a fixed size set of bits, where bit i is stored in word i/64.
+type bitset256 [4]uint64
+
+func (b *bitset256) set(i uint) {
+	b[i/64] |= 1 << (i % 64)
+}
+
+func (b *bitset256) test(i uint) bool {
+	return b[i/64]&(1<<(i%64)) != 0
+}
+
+// rank returns the number of bits set below i.
+func (b *bitset256) rank(i uint) int {
+	var n int
+	for w := uint(0); w < i/64; w++ {
+		n += bits.OnesCount64(b[w])
+	}
+	return n + bits.OnesCount64(b[i/64]&(1<<(i%64)-1))
+}
@@ -0,0 +1,180 @@
+package iptrie
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompiledTrie(t *testing.T) {
+	trie := NewTrie()
+	trie.Insert(netip.MustParsePrefix("2001:db8::/32"), "v6")
+	trie.Insert(netip.MustParsePrefix("2001:db8::/33"), "v6 half")
+	trie.Insert(netip.MustParsePrefix("2001:db8::1/128"), "v6 host")
+	for i := 0; i < 1000; i++ {
+		ip := GenIPV4()
+		network, _ := ip.Prefix(int(ip.As4()[3]%25) + 8)
+		trie.Insert(network, i)
+	}
+	trie.Insert(netip.MustParsePrefix("192.0.2.1/32"), "v4 host")
+
+	var ct Finder = trie.Compile()
+	ips := []netip.Addr{
+		netip.MustParseAddr("2001:db8::1"), netip.MustParseAddr("2001:db8::2"), netip.MustParseAddr("2001:db8:8000::1"),
+		netip.MustParseAddr("::1"), netip.MustParseAddr("192.0.2.1"), netip.MustParseAddr("::ffff:192.0.2.1"),
+	}
+	for i := 0; i < 1000; i++ {
+		ips = append(ips, GenIPV4())
+	}
+	for _, ip := range ips {
+		assert.Equal(t, trie.Find(ip), ct.Find(ip), "ip=%s", ip)
+		assert.Equal(t, trie.FindLargest(ip), ct.FindLargest(ip), "ip=%s", ip)
+		assert.Equal(t, trie.Contains(ip), ct.Contains(ip), "ip=%s", ip)
+	}
+
+	// Networks above the IPv4 portion must still match IPv4 addresses.
+	trie.Insert(netip.MustParsePrefix("::/0"), "default")
+	trie.Insert(netip.MustParsePrefix("::ffff:0:0/95"), "v4 super")
+	ct = trie.Compile()
+	assert.Equal(t, "default", ct.FindLargest(netip.MustParseAddr("11.0.0.1")))
+	assertMatchesTrie(t, trie, ct, append(ips, netip.MustParseAddr("192.0.2.2")))
+	assert.Equal(t, "v4 host", ct.Find(netip.MustParseAddr("192.0.2.1")))
+	assert.Equal(t, "default", ct.Find(netip.MustParseAddr("2001:db9::1")))
+}
+
+func TestCompiledTrieMatchesTrie(t *testing.T) {
+	for _, v4Only := range []bool{true, false} {
+		trie, ips := genMatchTrie(v4Only)
+		assertMatchesTrie(t, trie, trie.Compile(), ips)
+	}
+
+	trie := NewTrie()
+	trie.Insert(netip.MustParsePrefix("::/0"), "zero")
+	trie.Insert(netip.MustParsePrefix("::/5"), "five")
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	trie.Insert(netip.MustParsePrefix("10.1.0.0/16"), nil)
+	trie.Insert(netip.MustParsePrefix("10.2.0.1/32"), nil)
+	ct := trie.Compile()
+	assert.Equal(t, "zero", ct.FindLargest(netip.MustParseAddr("10.2.0.1")))
+	assert.Equal(t, "a", ct.Find(netip.MustParseAddr("10.1.0.1")))
+	assert.Nil(t, ct.Find(netip.MustParseAddr("10.2.0.1")))
+	assertMatchesTrie(t, trie, ct, []netip.Addr{netip.MustParseAddr("10.1.0.1"), netip.MustParseAddr("10.2.0.1"),
+		netip.MustParseAddr("::1"), netip.MustParseAddr("2001:db8::1")})
+}
+
+func TestCompiledTrieEmpty(t *testing.T) {
+	ct := NewTrie().Compile()
+	assert.Nil(t, ct.Find(netip.MustParseAddr("10.0.0.1")))
+	assert.False(t, ct.Contains(netip.MustParseAddr("10.0.0.1")))
+	assert.False(t, ct.Contains(netip.MustParseAddr("::1")))
+}
+
+func TestFindAs(t *testing.T) {
+	trie := NewTrie()
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	trie.Insert(netip.MustParsePrefix("10.1.0.0/16"), 1)
+
+	s, ok := FindAs[string](trie, netip.MustParseAddr("10.0.0.1"))
+	assert.True(t, ok)
+	assert.Equal(t, "a", s)
+
+	_, ok = FindAs[string](trie, netip.MustParseAddr("10.1.0.1"))
+	assert.False(t, ok)
+	i, ok := FindAs[int](trie, netip.MustParseAddr("10.1.0.1"))
+	assert.True(t, ok)
+	assert.Equal(t, 1, i)
+
+	_, ok = FindAs[string](trie, netip.MustParseAddr("192.0.2.1"))
+	assert.False(t, ok)
+}
+
+// TestFindAllocs guards the guarantee that lookups do not allocate.
+func TestFindAllocs(t *testing.T) {
+	trie := NewTrie()
+	trie4 := NewTrie4()
+	rt := NewRCUTrie()
+	trie.Insert(netip.MustParsePrefix("2001:db8::/32"), "v6")
+	for i := 0; i < 1000; i++ {
+		network := GenLeafIPNet(GenIPV4())
+		trie.Insert(network, i)
+		trie4.Insert(network, i)
+		rt.Insert(network, i)
+	}
+
+	finders := map[string]Finder{
+		"Trie":         trie,
+		"Trie4":        trie4,
+		"RCUTrie":      rt,
+		"AtomicTrie":   NewAtomicTrie(trie.Snapshot()),
+		"FrozenTrie":   trie.Freeze(),
+		"CompiledTrie": trie.Compile(),
+	}
+	ips := []netip.Addr{GenIPV4(), netip.MustParseAddr("2001:db8::1"), netip.MustParseAddr("::ffff:10.0.0.1")}
+	for name, f := range finders {
+		t.Run(name, func(t *testing.T) {
+			allocs := testing.AllocsPerRun(100, func() {
+				for _, ip := range ips {
+					f.Find(ip)
+					f.FindLargest(ip)
+					f.Contains(ip)
+					FindAs[string](f, ip)
+				}
+			})
+			assert.Zero(t, allocs)
+		})
+	}
+}
+
+func TestTable(t *testing.T) {
+	trie := NewTrie()
+	trie4 := NewTrie4()
+	rt := NewRCUTrie()
+	for i, network := range []string{"10.0.0.0/8", "10.1.0.0/16", "10.1.2.0/24", "192.0.2.0/24", "192.0.2.1/32"} {
+		trie.Insert(netip.MustParsePrefix(network), i)
+		trie4.Insert(netip.MustParsePrefix(network), i)
+		rt.Insert(netip.MustParsePrefix(network), i)
+	}
+
+	tables := map[string]Table{
+		"Trie":       trie,
+		"Trie4":      trie4,
+		"RCUTrie":    rt,
+		"AtomicTrie": NewAtomicTrie(trie.Snapshot()),
+		"FrozenTrie": trie.Freeze(),
+	}
+	unmap := func(networks []netip.Prefix) []netip.Prefix {
+		for i, network := range networks {
+			networks[i] = denormalizePrefix(network)
+		}
+		return networks
+	}
+	for name, table := range tables {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, 2, table.Find(netip.MustParseAddr("10.1.2.3")))
+			assert.Equal(t, 0, table.FindLargest(netip.MustParseAddr("10.1.2.3")))
+			assert.False(t, table.Contains(netip.MustParseAddr("198.51.100.1")))
+			assert.Equal(t,
+				[]netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("10.1.0.0/16")},
+				unmap(table.ContainingNetworks(netip.MustParseAddr("10.1.3.1"))))
+			assert.Equal(t,
+				[]netip.Prefix{netip.MustParsePrefix("192.0.2.0/24"), netip.MustParsePrefix("192.0.2.1/32")},
+				unmap(table.CoveredNetworks(netip.MustParsePrefix("192.0.0.0/16"))))
+
+			var networks []netip.Prefix
+			var values []any
+			table.Walk(func(network netip.Prefix, value any) bool {
+				networks = append(networks, network)
+				values = append(values, value)
+				return len(values) < 4
+			})
+			assert.Equal(t, []netip.Prefix{
+				netip.MustParsePrefix("10.0.0.0/8"),
+				netip.MustParsePrefix("10.1.0.0/16"),
+				netip.MustParsePrefix("10.1.2.0/24"),
+				netip.MustParsePrefix("192.0.2.0/24"),
+			}, unmap(networks))
+			assert.Equal(t, []any{0, 1, 2, 3}, values)
+		})
+	}
+}
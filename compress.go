@@ -0,0 +1,106 @@
+package iptrie
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"sync"
+)
+
+// Compression is a compression format which can be applied to the binary format with WriteToCompressed.
+type Compression struct {
+	// Name identifies the format, such as "gzip".
+	Name string
+	// Magic is the sequence of bytes which every compressed stream of the format starts with, used to detect the format
+	// when reading.
+	Magic string
+	// NewWriter returns a writer which compresses the data written to it to w. The writer is closed once all data has
+	// been written, which must flush any remaining data without closing w.
+	NewWriter func(w io.Writer) (io.WriteCloser, error)
+	// NewReader returns a reader which decompresses the data read from r.
+	NewReader func(r io.Reader) (io.Reader, error)
+}
+
+// CompressionGzip is the gzip compression format.
+var CompressionGzip = Compression{
+	Name:  "gzip",
+	Magic: "\x1f\x8b",
+	NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+		return gzip.NewWriterLevel(w, gzip.BestCompression)
+	},
+	NewReader: func(r io.Reader) (io.Reader, error) {
+		return gzip.NewReader(r)
+	},
+}
+
+var (
+	compressionsMu sync.RWMutex
+	compressions   = []Compression{CompressionGzip}
+)
+
+// RegisterCompression registers a compression format to be detected when reading the binary format, such as by
+// ReadFrom and UnmarshalBinary. Gzip is registered by default.
+//
+// This allows the use of formats which are not supported by the standard library, such as zstd:
+//
+//	iptrie.RegisterCompression(iptrie.Compression{
+//		Name:  "zstd",
+//		Magic: "\x28\xb5\x2f\xfd",
+//		NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+//			return zstd.NewWriter(w)
+//		},
+//		NewReader: func(r io.Reader) (io.Reader, error) {
+//			return zstd.NewReader(r)
+//		},
+//	})
+func RegisterCompression(c Compression) {
+	compressionsMu.Lock()
+	defer compressionsMu.Unlock()
+	compressions = append(compressions, c)
+}
+
+// WriteToCompressed is like WriteTo, but compresses the data with the given format. The data can be read with ReadFrom
+// or UnmarshalBinary, provided the format is registered with RegisterCompression. codec is used to encode values, and if
+// nil, StringCodec is used.
+//
+// The returned count is the number of compressed bytes written to w.
+func (pt *Trie) WriteToCompressed(w io.Writer, codec ValueCodec, c Compression) (int64, error) {
+	if codec == nil {
+		codec = StringCodec
+	}
+	cw := &countingWriter{w: w}
+	zw, err := c.NewWriter(cw)
+	if err != nil {
+		return 0, err
+	}
+	bw := bufio.NewWriter(zw)
+	err = pt.writeBinary(bw, codec)
+	if err == nil {
+		err = bw.Flush()
+	}
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	return cw.n, err
+}
+
+// decompress detects whether the data read from br is compressed with a registered format, and if so, returns a reader
+// of the decompressed data. Otherwise br is returned.
+func decompress(br *bufio.Reader) (binaryReader, error) {
+	compressionsMu.RLock()
+	defer compressionsMu.RUnlock()
+	for _, c := range compressions {
+		if magic, _ := br.Peek(len(c.Magic)); string(magic) != c.Magic {
+			continue
+		}
+		r, err := c.NewReader(br)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		if br, ok := r.(binaryReader); ok {
+			return br, nil
+		}
+		return bufio.NewReader(r), nil
+	}
+	return br, nil
+}
@@ -0,0 +1,89 @@
+package iptrie
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrieWriteToCompressed(t *testing.T) {
+	trie := NewTrie()
+	for i := 0; i < 1000; i++ {
+		ip := GenIPV4()
+		network, _ := ip.Prefix(int(ip.As4()[3]%25) + 8)
+		trie.Insert(network, fmt.Sprintf("net=%s", network))
+	}
+	data, err := trie.MarshalBinary()
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	n, err := trie.WriteToCompressed(&buf, nil, CompressionGzip)
+	require.NoError(t, err)
+	assert.Equal(t, int64(buf.Len()), n)
+	assert.Less(t, buf.Len(), len(data))
+
+	loaded := NewTrie()
+	n, err = loaded.ReadFrom(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, int64(buf.Len()), n)
+	assert.Equal(t, trie.String(), loaded.String())
+
+	loaded = NewTrie()
+	require.NoError(t, loaded.UnmarshalBinary(buf.Bytes()))
+	assert.Equal(t, trie.String(), loaded.String())
+
+	// Truncated compressed data.
+	compressed := buf.Bytes()
+	for _, size := range []int{1, 10, len(compressed) / 2, len(compressed) - 1} {
+		assert.ErrorIs(t, loaded.UnmarshalBinary(compressed[:size]), ErrInvalidBinary, "len=%d", size)
+	}
+	assert.Equal(t, trie.String(), loaded.String())
+}
+
+func TestRegisterCompression(t *testing.T) {
+	// Raw deflate has no magic of its own, so give it one for the test.
+	const magic = "DFL\x00"
+	c := Compression{
+		Name:  "deflate",
+		Magic: magic,
+		NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+			if _, err := io.WriteString(w, magic); err != nil {
+				return nil, err
+			}
+			return flate.NewWriter(w, flate.BestSpeed)
+		},
+		NewReader: func(r io.Reader) (io.Reader, error) {
+			if _, err := io.ReadFull(r, make([]byte, len(magic))); err != nil {
+				return nil, err
+			}
+			return flate.NewReader(r), nil
+		},
+	}
+
+	trie := NewTrie()
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	var buf bytes.Buffer
+	_, err := trie.WriteToCompressed(&buf, nil, c)
+	require.NoError(t, err)
+	assert.Error(t, NewTrie().UnmarshalBinary(buf.Bytes()))
+
+	compressionsMu.Lock()
+	orig := compressions
+	compressionsMu.Unlock()
+	defer func() {
+		compressionsMu.Lock()
+		compressions = orig
+		compressionsMu.Unlock()
+	}()
+	RegisterCompression(c)
+
+	loaded := NewTrie()
+	require.NoError(t, loaded.UnmarshalBinary(buf.Bytes()))
+	assert.Equal(t, "a", loaded.Find(netip.MustParseAddr("10.0.0.1")))
+}
@@ -0,0 +1,93 @@
+package iptrie
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// DebugString returns the output of Dump as a string.
+func (pt *Trie) DebugString() string {
+	var b strings.Builder
+	pt.Dump(&b)
+	return b.String()
+}
+
+// Dump writes the internal structure of the trie to w, one line per node, for diagnosing problems with the trie itself.
+// Unlike String, it includes details which are normally hidden, such as:
+//
+//	#3 ::ffff:10.0.0.0/104 explicit bit=104 gen=1 parent=#2 children=[#4 -] value="a"
+//
+// Nodes are numbered in depth order, with children indented beneath their parent. Each line shows whether the node is
+// an entry (explicit) or only joins its children (implicit), the position of the bit which selects the child to
+// descend to (bit), the copy-on-write generation the node belongs to (gen), the node it was reached from (parent), and
+// the node in each child slot. Networks are shown normalized to IPv6.
+//
+// Nodes don't hold a link to their parent, so the parent is the one found while walking the trie. A node shared with a
+// snapshot has a different parent in each version of the trie.
+func (pt *Trie) Dump(w io.Writer) error {
+	d := dumper{
+		bw:   bufio.NewWriter(w),
+		ids:  map[*node]int{},
+		gens: map[*owner]int{},
+	}
+	// Number the nodes up front, so links can refer to nodes appearing later.
+	var number func(n *node)
+	number = func(n *node) {
+		d.ids[n] = len(d.ids)
+		for _, child := range n.children {
+			if child != nil {
+				number(child)
+			}
+		}
+	}
+	number(&pt.node)
+	d.dump(&pt.node, nil, 0)
+	return d.bw.Flush()
+}
+
+type dumper struct {
+	bw   *bufio.Writer
+	ids  map[*node]int
+	gens map[*owner]int
+}
+
+// ref returns the reference to n within the dump.
+func (d *dumper) ref(n *node) string {
+	if n == nil {
+		return "-"
+	}
+	return "#" + strconv.Itoa(d.ids[n])
+}
+
+func (d *dumper) dump(n *node, parent *node, level int) {
+	gen, ok := d.gens[n.owner]
+	if !ok {
+		gen = len(d.gens)
+		d.gens[n.owner] = gen
+	}
+	kind := "implicit"
+	if n.value != nil {
+		kind = "explicit"
+	}
+	bit := "-"
+	if n.bits < 128 {
+		bit = strconv.Itoa(int(n.bits))
+	}
+
+	d.bw.WriteString(strings.Repeat("  ", level))
+	fmt.Fprintf(d.bw, "%s %s %s bit=%s gen=%d parent=%s children=[%s %s]",
+		d.ref(n), n.network(), kind, bit, gen, d.ref(parent), d.ref(n.children[0]), d.ref(n.children[1]))
+	if n.value != nil {
+		fmt.Fprintf(d.bw, " value=%#v", unempty(n.value))
+	}
+	d.bw.WriteString("\n")
+
+	for _, child := range n.children {
+		if child != nil {
+			d.dump(child, n, level+1)
+		}
+	}
+}
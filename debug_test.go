@@ -0,0 +1,37 @@
+package iptrie
+
+import (
+	"io"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrieDump(t *testing.T) {
+	trie := NewTrie()
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	trie.Insert(netip.MustParsePrefix("10.1.0.0/16"), nil)
+	trie.Insert(netip.MustParsePrefix("10.2.0.0/16"), 5)
+	trie.Insert(netip.MustParsePrefix("2001:db8::1/128"), "h")
+
+	expected := `#0 ::/0 implicit bit=0 gen=0 parent=- children=[#1 -]
+  #1 ::/2 implicit bit=2 gen=0 parent=#0 children=[#2 #6]
+    #2 ::ffff:10.0.0.0/104 explicit bit=104 gen=0 parent=#1 children=[#3 -] value="a"
+      #3 ::ffff:10.0.0.0/110 implicit bit=110 gen=0 parent=#2 children=[#4 #5]
+        #4 ::ffff:10.1.0.0/112 explicit bit=112 gen=0 parent=#3 children=[- -] value=<nil>
+        #5 ::ffff:10.2.0.0/112 explicit bit=112 gen=0 parent=#3 children=[- -] value=5
+    #6 2001:db8::1/128 explicit bit=- gen=0 parent=#1 children=[- -] value="h"
+`
+	assert.Equal(t, expected, trie.DebugString())
+
+	// Nodes shared with a snapshot belong to another generation, but are shown beneath their parent within this one.
+	trie.Snapshot()
+	trie.Insert(netip.MustParsePrefix("10.3.0.0/16"), 6)
+	dump := trie.DebugString()
+	assert.Contains(t, dump, "#4 ::ffff:10.1.0.0/112 explicit bit=112 gen=1 parent=#3 children=[- -] value=<nil>")
+
+	r, w := io.Pipe()
+	r.Close()
+	assert.ErrorIs(t, trie.Dump(w), io.ErrClosedPipe)
+}
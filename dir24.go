@@ -0,0 +1,125 @@
+package iptrie
+
+import (
+	"encoding/binary"
+	"net/netip"
+)
+
+const (
+	// dir24Indirect flags a tbl24 entry as referencing a tbl8 block rather than a value.
+	dir24Indirect = 1 << 31
+)
+
+// DIR24Table is an immutable IPv4 lookup table using the DIR-24-8 scheme, built from a Trie with Trie.CompileDIR24.
+//
+// The first 24 bits of the address directly index a table of 2^24 entries (64MiB). Each entry holds either the result
+// of the lookup, or, where networks longer than /24 exist, a reference to a block of 256 entries indexed by the last 8
+// bits of the address. A lookup is therefore at most 2 memory accesses, regardless of the contents of the table.
+//
+// Only IPv4 addresses are supported. Networks from the trie which contain IPv4 addresses, such as ::/0, are included.
+//
+// A DIR24Table is safe for concurrent use.
+type DIR24Table struct {
+	tbl24 []uint32
+	tbl8  []uint32
+	// values contains the value of each network. Entries reference their value by index+1, with 0 indicating no match.
+	values []any
+}
+
+// CompileDIR24 builds a DIR24Table from the IPv4 networks within the trie. The trie itself is unaffected, and may
+// continue to be modified.
+func (pt *Trie) CompileDIR24() *DIR24Table {
+	dt := &DIR24Table{
+		tbl24: make([]uint32, 1<<24),
+	}
+	pt.node.compileDIR24(dt)
+	return dt
+}
+
+// compileDIR24 adds the IPv4 networks of the node and its descendants to the table. As nodes are visited in depth-first
+// order, more specific networks overwrite the less specific networks containing them.
+func (pt *node) compileDIR24(dt *DIR24Table) {
+	if pt.bits <= 96 && !pt.contains(v4Prefix) {
+		return
+	}
+	if pt.bits >= 96 && !netContains(v4Prefix, 96, pt.addr) {
+		return
+	}
+	if pt.value != nil {
+		dt.values = append(dt.values, pt.value)
+		var addr uint32
+		var bits uint8
+		if pt.bits > 96 {
+			addr, bits = uint32(pt.addr.lo), pt.bits-96
+		}
+		dt.fill(addr, bits, uint32(len(dt.values)))
+	}
+	for _, child := range pt.children {
+		if child != nil {
+			child.compileDIR24(dt)
+		}
+	}
+}
+
+func (dt *DIR24Table) fill(addr uint32, bits uint8, entry uint32) {
+	if bits <= 24 {
+		start := addr >> 8
+		for i := start; i < start+1<<(24-bits); i++ {
+			if e := dt.tbl24[i]; e&dir24Indirect != 0 {
+				block := dt.tbl8[(e&^dir24Indirect)*256:][:256]
+				for j := range block {
+					block[j] = entry
+				}
+				continue
+			}
+			dt.tbl24[i] = entry
+		}
+		return
+	}
+
+	i := addr >> 8
+	e := dt.tbl24[i]
+	if e&dir24Indirect == 0 {
+		// Convert the entry into a tbl8 block, inheriting the existing result.
+		blockIdx := uint32(len(dt.tbl8) / 256)
+		for j := 0; j < 256; j++ {
+			dt.tbl8 = append(dt.tbl8, e)
+		}
+		e = dir24Indirect | blockIdx
+		dt.tbl24[i] = e
+	}
+	block := dt.tbl8[(e&^dir24Indirect)*256:][:256]
+	start := addr & 0xff
+	for j := start; j < start+1<<(32-bits); j++ {
+		block[j] = entry
+	}
+}
+
+// Find returns the value from the most specific network (largest prefix) containing the given address. nil is returned
+// for IPv6 addresses.
+func (dt *DIR24Table) Find(ip netip.Addr) any {
+	idx := dt.lookup(ip)
+	if idx == 0 {
+		return nil
+	}
+	return unempty(dt.values[idx-1])
+}
+
+// Contains indicates whether the table contains the given ip.
+func (dt *DIR24Table) Contains(ip netip.Addr) bool {
+	return dt.lookup(ip) != 0
+}
+
+func (dt *DIR24Table) lookup(ip netip.Addr) uint32 {
+	ip = ip.Unmap()
+	if !ip.Is4() {
+		return 0
+	}
+	b := ip.As4()
+	addr := binary.BigEndian.Uint32(b[:])
+	e := dt.tbl24[addr>>8]
+	if e&dir24Indirect != 0 {
+		e = dt.tbl8[(e&^dir24Indirect)*256+addr&0xff]
+	}
+	return e
+}
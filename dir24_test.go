@@ -0,0 +1,43 @@
+package iptrie
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDIR24Table(t *testing.T) {
+	trie := NewTrie()
+	trie.Insert(netip.MustParsePrefix("::/0"), "default")
+	trie.Insert(netip.MustParsePrefix("2001:db8::/32"), "v6")
+	for i := 0; i < 1000; i++ {
+		ip := GenIPV4()
+		network, _ := ip.Prefix(int(ip.As4()[3]%25) + 8)
+		trie.Insert(network, i)
+	}
+	trie.Insert(netip.MustParsePrefix("192.0.2.0/24"), "v4 net")
+	trie.Insert(netip.MustParsePrefix("192.0.2.128/25"), "v4 half")
+	trie.Insert(netip.MustParsePrefix("192.0.2.1/32"), "v4 host")
+	trie.Insert(netip.MustParsePrefix("198.51.100.0/22"), "v4 super")
+	trie.Insert(netip.MustParsePrefix("198.51.100.64/26"), nil)
+
+	dt := trie.CompileDIR24()
+	ips := []netip.Addr{
+		netip.MustParseAddr("192.0.2.1"), netip.MustParseAddr("192.0.2.2"), netip.MustParseAddr("192.0.2.129"),
+		netip.MustParseAddr("::ffff:192.0.2.1"), netip.MustParseAddr("198.51.101.1"),
+	}
+	for i := 0; i < 1000; i++ {
+		ips = append(ips, GenIPV4())
+	}
+	for _, ip := range ips {
+		assert.Equal(t, trie.Find(ip), dt.Find(ip), "ip=%s", ip)
+		assert.True(t, dt.Contains(ip), "ip=%s", ip)
+	}
+	assert.Nil(t, dt.Find(netip.MustParseAddr("198.51.100.65")))
+	assert.True(t, dt.Contains(netip.MustParseAddr("198.51.100.65")))
+	assert.Nil(t, dt.Find(netip.MustParseAddr("2001:db8::1")))
+
+	dt = NewTrie().CompileDIR24()
+	assert.False(t, dt.Contains(netip.MustParseAddr("10.0.0.1")))
+}
@@ -0,0 +1,159 @@
+package iptrie
+
+import (
+	"net/netip"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// EntryInfo describes an entry of a trie, along with the metadata tracked when SetTrackEntries is enabled.
+type EntryInfo struct {
+	// Network is the network of the entry, normalized to IPv6.
+	Network netip.Prefix
+	Value   any
+	// Inserted is the time the entry was last inserted with Insert. It is the zero time for entries added by other
+	// means, such as UnmarshalBinary, or before tracking was enabled.
+	Inserted time.Time
+	// Hits is the number of times the entry has been returned by Find.
+	Hits uint64
+}
+
+// entryKey identifies an entry by its normalized network.
+type entryKey struct {
+	addr uint128
+	bits uint8
+}
+
+// entryStats holds the tracked metadata of the entries of a trie. It is shared by the trie and its copies.
+type entryStats struct {
+	mu sync.RWMutex
+	m  map[entryKey]*entryCounters
+}
+
+type entryCounters struct {
+	// inserted is guarded by entryStats.mu.
+	inserted time.Time
+	hits     atomic.Uint64
+}
+
+// get returns the counters of the entry, creating them if they don't exist.
+func (es *entryStats) get(k entryKey) *entryCounters {
+	es.mu.RLock()
+	c := es.m[k]
+	es.mu.RUnlock()
+	if c != nil {
+		return c
+	}
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	if c = es.m[k]; c == nil {
+		c = &entryCounters{}
+		es.m[k] = c
+	}
+	return c
+}
+
+func (es *entryStats) inserted(k entryKey, t time.Time) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	if c := es.m[k]; c != nil {
+		c.inserted = t
+		return
+	}
+	es.m[k] = &entryCounters{inserted: t}
+}
+
+func (es *entryStats) removed(k entryKey) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	delete(es.m, k)
+}
+
+// info fills in the tracked metadata of info for the entry.
+func (es *entryStats) info(k entryKey, info *EntryInfo) {
+	es.mu.RLock()
+	defer es.mu.RUnlock()
+	if c := es.m[k]; c != nil {
+		info.Inserted = c.inserted
+		info.Hits = c.hits.Load()
+	}
+}
+
+// SetTrackEntries sets whether to track the time each entry was inserted, and the number of times each entry is
+// returned by Find, as reported by EntryInfo. This allows stale or unused entries to be identified. Disabling tracking
+// discards the tracked metadata.
+//
+// Tracking makes Find slower, as it must locate the matching entry from the root of the trie and update its counter.
+// Snapshots, and the tries created with NewRCUTrieFrom or NewVersionedTrieFrom, share the metadata rather than copying
+// it. The insertions and removals made in an RCUTrie transaction are only recorded when it's committed (see Txn).
+// Replacing the contents of the trie, such as with UnmarshalBinary, discards the metadata.
+func (pt *Trie) SetTrackEntries(track bool) {
+	if !track {
+		pt.stats = nil
+	} else if pt.stats == nil {
+		pt.stats = &entryStats{m: map[entryKey]*entryCounters{}}
+	}
+}
+
+// EntryInfo returns information about the entry for the exact given network, and whether it exists.
+func (pt *Trie) EntryInfo(network netip.Prefix) (EntryInfo, bool) {
+	network = normalizePrefix(network)
+	addr, bits := prefix128(network)
+	n := pt.get(addr, bits)
+	if n == nil || n.value == nil {
+		return EntryInfo{}, false
+	}
+	info := EntryInfo{Network: n.network(), Value: unempty(n.value)}
+	if pt.stats != nil {
+		pt.stats.info(entryKey{n.addr, n.bits}, &info)
+	}
+	return info, true
+}
+
+// TopN returns the n entries with the most hits, in descending order of hits. Entries with an equal number of hits are
+// in depth order. Entries which have never been returned by Find are omitted, so fewer than n entries may be returned.
+//
+// TopN returns nil if tracking is not enabled with SetTrackEntries.
+func (pt *Trie) TopN(n int) []EntryInfo {
+	if pt.stats == nil || n <= 0 {
+		return nil
+	}
+	var infos []EntryInfo
+	pt.stats.mu.RLock()
+	pt.walk(func(nd *node) bool {
+		c := pt.stats.m[entryKey{nd.addr, nd.bits}]
+		if c == nil {
+			return true
+		}
+		if hits := c.hits.Load(); hits > 0 {
+			infos = append(infos, EntryInfo{
+				Network:  nd.network(),
+				Value:    unempty(nd.value),
+				Inserted: c.inserted,
+				Hits:     hits,
+			})
+		}
+		return true
+	})
+	pt.stats.mu.RUnlock()
+	sort.SliceStable(infos, func(i, j int) bool { return infos[i].Hits > infos[j].Hits })
+	if len(infos) > n {
+		infos = infos[:n]
+	}
+	return infos
+}
+
+// findTracked is findValue for a trie with tracking enabled.
+func (pt *Trie) findTracked(ip netip.Addr) any {
+	if pt.zoneExcluded(ip) {
+		return nil
+	}
+	n := pt.findNode(lookupAddr128(ip))
+	if n == nil {
+		return nil
+	}
+	pt.stats.get(entryKey{n.addr, n.bits}).hits.Add(1)
+	return n.value
+}
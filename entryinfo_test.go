@@ -0,0 +1,172 @@
+package iptrie
+
+import (
+	"fmt"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrieEntryInfo(t *testing.T) {
+	trie := NewTrie()
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), "untracked")
+	trie.SetTrackEntries(true)
+
+	before := time.Now()
+	trie.Insert(netip.MustParsePrefix("10.1.0.0/16"), "a")
+	trie.Insert(netip.MustParsePrefix("2001:db8::/32"), nil)
+	after := time.Now()
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, "a", trie.Find(netip.MustParseAddr("10.1.0.1")))
+	}
+	assert.Equal(t, "untracked", trie.Find(netip.MustParseAddr("10.2.0.1")))
+	assert.Nil(t, trie.Find(netip.MustParseAddr("192.0.2.1")))
+	assert.True(t, trie.Contains(netip.MustParseAddr("10.1.0.1")))
+
+	info, ok := trie.EntryInfo(netip.MustParsePrefix("10.1.0.0/16"))
+	require.True(t, ok)
+	assert.Equal(t, netip.MustParsePrefix("::ffff:10.1.0.0/112"), info.Network)
+	assert.Equal(t, "a", info.Value)
+	assert.Equal(t, uint64(3), info.Hits)
+	assert.False(t, info.Inserted.Before(before) || info.Inserted.After(after))
+
+	info, ok = trie.EntryInfo(netip.MustParsePrefix("10.0.0.0/8"))
+	require.True(t, ok)
+	assert.True(t, info.Inserted.IsZero())
+	assert.Equal(t, uint64(1), info.Hits)
+
+	info, ok = trie.EntryInfo(netip.MustParsePrefix("2001:db8::/32"))
+	require.True(t, ok)
+	assert.Nil(t, info.Value)
+	assert.Zero(t, info.Hits)
+
+	_, ok = trie.EntryInfo(netip.MustParsePrefix("10.0.0.0/9"))
+	assert.False(t, ok)
+
+	// Replacing the value keeps the hits, while removing the entry discards them.
+	trie.Insert(netip.MustParsePrefix("10.1.0.0/16"), "b")
+	info, _ = trie.EntryInfo(netip.MustParsePrefix("10.1.0.0/16"))
+	assert.Equal(t, uint64(3), info.Hits)
+	trie.Remove(netip.MustParsePrefix("10.1.0.0/16"))
+	trie.Insert(netip.MustParsePrefix("10.1.0.0/16"), "c")
+	info, _ = trie.EntryInfo(netip.MustParsePrefix("10.1.0.0/16"))
+	assert.Zero(t, info.Hits)
+
+	trie.SetTrackEntries(false)
+	trie.Find(netip.MustParseAddr("10.1.0.1"))
+	info, _ = trie.EntryInfo(netip.MustParsePrefix("10.1.0.0/16"))
+	assert.Zero(t, info.Hits)
+}
+
+func TestTrieEntryInfoConcurrent(t *testing.T) {
+	trie := NewTrie()
+	trie.SetTrackEntries(true)
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	snap := trie.Snapshot()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				snap.Find(netip.MustParseAddr("10.0.0.1"))
+			}
+		}()
+	}
+	for j := 0; j < 100; j++ {
+		trie.Insert(netip.MustParsePrefix("192.0.2.0/24"), j)
+	}
+	wg.Wait()
+
+	info, _ := trie.EntryInfo(netip.MustParsePrefix("10.0.0.0/8"))
+	assert.Equal(t, uint64(4000), info.Hits)
+}
+
+func TestTxnEntryInfoAbort(t *testing.T) {
+	trie := NewTrie()
+	trie.SetTrackEntries(true)
+	rt := NewRCUTrieFrom(trie)
+	rt.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	rt.Insert(netip.MustParsePrefix("10.1.0.0/16"), "b")
+	infoA, _ := rt.Load().EntryInfo(netip.MustParsePrefix("10.0.0.0/8"))
+	infoB, _ := rt.Load().EntryInfo(netip.MustParsePrefix("10.1.0.0/16"))
+	require.False(t, infoA.Inserted.IsZero())
+	require.False(t, infoB.Inserted.IsZero())
+
+	time.Sleep(time.Millisecond)
+	txn := rt.Txn()
+	txn.Insert(netip.MustParsePrefix("10.0.0.0/8"), "c")
+	txn.Remove(netip.MustParsePrefix("10.1.0.0/16"))
+	txn.Abort()
+	info, _ := rt.Load().EntryInfo(netip.MustParsePrefix("10.0.0.0/8"))
+	assert.Equal(t, infoA.Inserted, info.Inserted)
+	info, _ = rt.Load().EntryInfo(netip.MustParsePrefix("10.1.0.0/16"))
+	assert.Equal(t, infoB.Inserted, info.Inserted)
+
+	txn = rt.Txn()
+	txn.Insert(netip.MustParsePrefix("10.0.0.0/8"), "c")
+	txn.Commit()
+	info, _ = rt.Load().EntryInfo(netip.MustParsePrefix("10.0.0.0/8"))
+	assert.True(t, info.Inserted.After(infoA.Inserted))
+}
+
+func TestTrieEntryInfoUnmarshal(t *testing.T) {
+	src := NewTrie()
+	src.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	data, err := src.MarshalBinary()
+	require.NoError(t, err)
+
+	trie := NewTrie()
+	trie.SetTrackEntries(true)
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), "old")
+	trie.Find(netip.MustParseAddr("10.0.0.1"))
+	snap := trie.Snapshot()
+
+	require.NoError(t, trie.UnmarshalBinary(data))
+	// The metadata of the previous entries doesn't describe the loaded ones.
+	info, ok := trie.EntryInfo(netip.MustParsePrefix("10.0.0.0/8"))
+	require.True(t, ok)
+	assert.Equal(t, "a", info.Value)
+	assert.True(t, info.Inserted.IsZero())
+	assert.Zero(t, info.Hits)
+	// Snapshots of the previous entries keep their metadata.
+	info, _ = snap.EntryInfo(netip.MustParsePrefix("10.0.0.0/8"))
+	assert.Equal(t, uint64(1), info.Hits)
+}
+
+func TestTrieTopN(t *testing.T) {
+	trie := NewTrie()
+	assert.Nil(t, trie.TopN(5))
+
+	trie.SetTrackEntries(true)
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	trie.Insert(netip.MustParsePrefix("10.1.0.0/16"), "b")
+	trie.Insert(netip.MustParsePrefix("192.0.2.0/24"), "c")
+	trie.Insert(netip.MustParsePrefix("2001:db8::/32"), "d")
+	trie.Insert(netip.MustParsePrefix("198.51.100.0/24"), "e")
+
+	for ip, n := range map[string]int{"10.1.0.1": 3, "10.2.0.1": 1, "192.0.2.1": 3, "2001:db8::1": 5} {
+		for i := 0; i < n; i++ {
+			trie.Find(netip.MustParseAddr(ip))
+		}
+	}
+
+	var got []string
+	for _, info := range trie.TopN(3) {
+		got = append(got, fmt.Sprintf("%s %v %d", info.Network, info.Value, info.Hits))
+	}
+	assert.Equal(t, []string{
+		"2001:db8::/32 d 5",
+		"::ffff:10.1.0.0/112 b 3",
+		"::ffff:192.0.2.0/120 c 3",
+	}, got)
+
+	assert.Len(t, trie.TopN(10), 4)
+	assert.Nil(t, trie.TopN(0))
+}
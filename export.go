@@ -0,0 +1,302 @@
+package iptrie
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/netip"
+)
+
+// Aggregate returns the smallest list of networks which covers exactly the same addresses as the entries of the trie,
+// in ascending order. Networks contained within another entry are omitted, and adjacent networks are merged.
+//
+// Note: Inserted addresses are normalized to IPv6, so the returned list will be IPv6 only, unless SetDenormalize is
+// used.
+func (pt *Trie) Aggregate() []netip.Prefix {
+	networks := pt.aggregate(nil)
+	return pt.denormalizeFrom(networks, 0)
+}
+
+// aggregate is like Aggregate, but only considers the networks for which include returns true. include is called with
+// the normalized network of each entry not contained within another included entry. If include is nil, all entries are
+// considered.
+func (pt *Trie) aggregate(include func(addr uint128, bits uint8) bool) []netip.Prefix {
+	type prefix struct {
+		addr uint128
+		bits uint8
+	}
+	var stack []prefix
+	var walk func(n *node)
+	walk = func(n *node) {
+		if n.value == nil || (include != nil && !include(n.addr, n.bits)) {
+			for _, child := range n.children {
+				if child != nil {
+					walk(child)
+				}
+			}
+			return
+		}
+		p := prefix{n.addr, n.bits}
+		// The networks arrive in ascending order without overlap, so merging only needs to consider the most recent
+		// network.
+		for len(stack) > 0 {
+			top := stack[len(stack)-1]
+			if p.bits == 0 || top.bits != p.bits || top.addr.bit(p.bits-1) != 0 || p.addr.bit(p.bits-1) != 1 ||
+				!netContains(top.addr, p.bits-1, p.addr) {
+				break
+			}
+			stack = stack[:len(stack)-1]
+			p = prefix{top.addr, p.bits - 1}
+		}
+		stack = append(stack, p)
+	}
+	walk(&pt.node)
+
+	networks := make([]netip.Prefix, len(stack))
+	for i, p := range stack {
+		networks[i] = netip.PrefixFrom(addrFrom128(p.addr), int(p.bits))
+	}
+	return networks
+}
+
+// isV4 indicates whether the normalized network is an IPv4 network.
+func isV4(addr uint128, bits uint8) bool {
+	return bits >= 96 && netContains(v4Prefix, 96, addr)
+}
+
+// parseFamily indicates whether family is "inet" (IPv4), as opposed to "inet6" (IPv6).
+func parseFamily(family string) (v4 bool, err error) {
+	switch family {
+	case "inet":
+		return true, nil
+	case "inet6":
+		return false, nil
+	}
+	return false, fmt.Errorf("iptrie: unsupported family %q", family)
+}
+
+// familyEntries returns the networks of the entries of the given family in their denormalized form, in ascending
+// order. If outermost is true, networks contained within another entry are omitted.
+func (pt *Trie) familyEntries(family string, outermost bool) ([]netip.Prefix, error) {
+	v4, err := parseFamily(family)
+	if err != nil {
+		return nil, err
+	}
+	var networks []netip.Prefix
+	var last *node
+	pt.walk(func(n *node) bool {
+		if isV4(n.addr, n.bits) != v4 || (outermost && last != nil && netContains(last.addr, last.bits, n.addr)) {
+			return true
+		}
+		last = n
+		networks = append(networks, denormalizePrefix(n.network()))
+		return true
+	})
+	return networks, nil
+}
+
+// aggregateFamily returns the aggregated networks of the given family ("inet" or "inet6") in their denormalized form.
+// IPv4 networks are those within ::ffff:0:0/96, so an IPv6 network such as ::/0 is not considered to cover any IPv4
+// addresses.
+func (pt *Trie) aggregateFamily(family string) ([]netip.Prefix, error) {
+	v4, err := parseFamily(family)
+	if err != nil {
+		return nil, err
+	}
+	networks := pt.aggregate(func(addr uint128, bits uint8) bool {
+		return isV4(addr, bits) == v4
+	})
+	for i, network := range networks {
+		networks[i] = denormalizePrefix(network)
+	}
+	return networks, nil
+}
+
+// ipsetDefaultMaxElem is the default maximum number of elements of an ipset.
+const ipsetDefaultMaxElem = 65536
+
+// ExportIPSet writes the networks of the given family ("inet" for IPv4 or "inet6" for IPv6) to w as a hash:net set,
+// in the format read by `ipset restore`. The networks are aggregated first, as by Aggregate.
+//
+// Sets of type hash:net cannot contain a network with a prefix length of 0, so such a network is written as its 2
+// halves.
+func (pt *Trie) ExportIPSet(w io.Writer, setName string, family string) error {
+	networks, err := pt.aggregateFamily(family)
+	if err != nil {
+		return err
+	}
+	if len(networks) == 1 && networks[0].Bits() == 0 {
+		if family == "inet" {
+			networks = []netip.Prefix{netip.MustParsePrefix("0.0.0.0/1"), netip.MustParsePrefix("128.0.0.0/1")}
+		} else {
+			networks = []netip.Prefix{netip.MustParsePrefix("::/1"), netip.MustParsePrefix("8000::/1")}
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "create %s hash:net family %s", setName, family)
+	if len(networks) > ipsetDefaultMaxElem {
+		fmt.Fprintf(bw, " maxelem %d", len(networks))
+	}
+	bw.WriteString("\n")
+	for _, network := range networks {
+		fmt.Fprintf(bw, "add %s %s\n", setName, network)
+	}
+	return bw.Flush()
+}
+
+// addrRange is a contiguous range of addresses. If the range consists of exactly one network, single is true.
+type addrRange struct {
+	first, last netip.Addr
+	network     netip.Prefix
+	single      bool
+}
+
+// ranges returns the networks of the given family as contiguous ranges of addresses, merging adjacent networks.
+func (pt *Trie) ranges(family string) ([]addrRange, error) {
+	networks, err := pt.aggregateFamily(family)
+	if err != nil {
+		return nil, err
+	}
+	var ranges []addrRange
+	var last uint128
+	for _, network := range networks {
+		addr, bits := prefix128(normalizePrefix(network))
+		if len(ranges) > 0 && last != mask6(0).not() && last.addOne() == addr {
+			r := &ranges[len(ranges)-1]
+			last = addr.bitsSetFrom(bits)
+			r.last = denormalizeAddr(addrFrom128(last))
+			r.single = false
+			continue
+		}
+		last = addr.bitsSetFrom(bits)
+		ranges = append(ranges, addrRange{
+			first:   network.Addr(),
+			last:    denormalizeAddr(addrFrom128(last)),
+			network: network,
+			single:  true,
+		})
+	}
+	return ranges, nil
+}
+
+// denormalizeAddr is the inverse of normalizeAddr, returning IPv4-mapped addresses in their IPv4 form.
+func denormalizeAddr(addr netip.Addr) netip.Addr {
+	return addr.Unmap()
+}
+
+// nftElement returns the nftables representation of r.
+func (r addrRange) nftElement() string {
+	switch {
+	case !r.single:
+		return r.first.String() + "-" + r.last.String()
+	case r.network.IsSingleIP():
+		return r.first.String()
+	}
+	return r.network.String()
+}
+
+// ExportNFTables writes the networks of the given family ("inet" for IPv4 or "inet6" for IPv6) to w as the elements
+// of an nftables interval set, of the form `elements = { 10.0.0.0/8, 192.0.2.1, 198.51.100.0-198.51.100.191 }`.
+// Adjacent networks are merged into ranges. Nothing is written if there are no networks of the family.
+func (pt *Trie) ExportNFTables(w io.Writer, family string) error {
+	ranges, err := pt.ranges(family)
+	if err != nil || len(ranges) == 0 {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	bw.WriteString("elements = { ")
+	for i, r := range ranges {
+		if i > 0 {
+			bw.WriteString(", ")
+		}
+		bw.WriteString(r.nftElement())
+	}
+	bw.WriteString(" }\n")
+	return bw.Flush()
+}
+
+// NFTablesSet identifies an nftables set.
+type NFTablesSet struct {
+	// Family is the family of the table containing the set, such as "ip", "ip6" or "inet".
+	Family string
+	Table  string
+	Name   string
+}
+
+// ExportNFTablesJSON is like ExportNFTables, but writes the elements as an nftables JSON command adding them to the
+// given set, in the format read by `nft -j -f`.
+func (pt *Trie) ExportNFTablesJSON(w io.Writer, family string, set NFTablesSet) error {
+	ranges, err := pt.ranges(family)
+	if err != nil {
+		return err
+	}
+	elems := make([]any, len(ranges))
+	for i, r := range ranges {
+		switch {
+		case !r.single:
+			elems[i] = map[string]any{"range": []string{r.first.String(), r.last.String()}}
+		case r.network.IsSingleIP():
+			elems[i] = r.first.String()
+		default:
+			elems[i] = map[string]any{"prefix": map[string]any{"addr": r.first.String(), "len": r.network.Bits()}}
+		}
+	}
+	cmd := map[string]any{
+		"nftables": []any{
+			map[string]any{"add": map[string]any{"element": map[string]any{
+				"family": set.Family,
+				"table":  set.Table,
+				"name":   set.Name,
+				"elem":   elems,
+			}}},
+		},
+	}
+	return json.NewEncoder(w).Encode(cmd)
+}
+
+// ExportCiscoPrefixList writes the entries of the given family ("inet" for IPv4 or "inet6" for IPv6) to w as a Cisco
+// IOS prefix list, with sequence numbers in increments of 5.
+//
+// If orLonger is true, each entry also matches the networks within it (`le 32` or `le 128`), and entries contained
+// within another entry are omitted as redundant. Otherwise each entry only matches its exact network.
+func (pt *Trie) ExportCiscoPrefixList(w io.Writer, name string, family string, orLonger bool) error {
+	networks, err := pt.familyEntries(family, orLonger)
+	if err != nil {
+		return err
+	}
+	cmd := "ip prefix-list"
+	if family == "inet6" {
+		cmd = "ipv6 prefix-list"
+	}
+	bw := bufio.NewWriter(w)
+	for i, network := range networks {
+		fmt.Fprintf(bw, "%s %s seq %d permit %s", cmd, name, (i+1)*5, network)
+		// le must be greater than the prefix length, so a host network cannot have one.
+		if maxBits := network.Addr().BitLen(); orLonger && network.Bits() < maxBits {
+			fmt.Fprintf(bw, " le %d", maxBits)
+		}
+		bw.WriteString("\n")
+	}
+	return bw.Flush()
+}
+
+// ExportJuniperPrefixList writes the entries of the given family ("inet" for IPv4 or "inet6" for IPv6) to w as a
+// Junos prefix-list statement, for inclusion within the policy-options hierarchy.
+//
+// Junos prefix lists only match exact networks. To also match the networks within them, reference the list from a
+// policy with `prefix-list-filter <name> orlonger`.
+func (pt *Trie) ExportJuniperPrefixList(w io.Writer, name string, family string) error {
+	networks, err := pt.familyEntries(family, false)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "prefix-list %s {\n", name)
+	for _, network := range networks {
+		fmt.Fprintf(bw, "    %s;\n", network)
+	}
+	bw.WriteString("}\n")
+	return bw.Flush()
+}
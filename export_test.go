@@ -0,0 +1,157 @@
+package iptrie
+
+import (
+	"bytes"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrieAggregate(t *testing.T) {
+	trie := NewTrie()
+	for _, network := range []string{
+		"10.0.0.0/9", "10.128.0.0/9", // merge into 10.0.0.0/8
+		"10.1.0.0/16",                                                          // contained
+		"192.168.0.0/24", "192.168.1.0/24", "192.168.2.0/24", "192.168.3.0/25", // merge into /23, with /24 and /25 left
+		"2001:db8::/33", "2001:db8:8000::/33",
+	} {
+		trie.Insert(netip.MustParsePrefix(network), nil)
+	}
+	// Adjacent networks which together are not a complete network are not merged.
+	trie.Insert(netip.MustParsePrefix("172.16.0.0/25"), nil)
+	trie.Insert(netip.MustParsePrefix("172.16.0.128/26"), nil)
+
+	var networks []string
+	for _, network := range trie.Aggregate() {
+		networks = append(networks, denormalizePrefix(network).String())
+	}
+	assert.Equal(t, []string{
+		"10.0.0.0/8",
+		"172.16.0.0/25",
+		"172.16.0.128/26",
+		"192.168.0.0/23",
+		"192.168.2.0/24",
+		"192.168.3.0/25",
+		"2001:db8::/32",
+	}, networks)
+
+	// Merging cascades across multiple levels.
+	trie = NewTrie()
+	for _, network := range []string{"10.0.0.0/10", "10.64.0.0/10", "10.128.0.0/9"} {
+		trie.Insert(netip.MustParsePrefix(network), nil)
+	}
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("::ffff:10.0.0.0/104")}, trie.Aggregate())
+}
+
+func TestTrieExportIPSet(t *testing.T) {
+	trie := NewTrie()
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/9"), nil)
+	trie.Insert(netip.MustParsePrefix("10.128.0.0/9"), nil)
+	trie.Insert(netip.MustParsePrefix("192.0.2.1/32"), nil)
+	trie.Insert(netip.MustParsePrefix("2001:db8::/32"), nil)
+	trie.Insert(netip.MustParsePrefix("::/0"), nil)
+
+	var buf bytes.Buffer
+	require.NoError(t, trie.ExportIPSet(&buf, "blocklist", "inet"))
+	assert.Equal(t, `create blocklist hash:net family inet
+add blocklist 10.0.0.0/8
+add blocklist 192.0.2.1/32
+`, buf.String())
+
+	buf.Reset()
+	require.NoError(t, trie.ExportIPSet(&buf, "blocklist6", "inet6"))
+	assert.Equal(t, `create blocklist6 hash:net family inet6
+add blocklist6 ::/1
+add blocklist6 8000::/1
+`, buf.String())
+
+	assert.Error(t, trie.ExportIPSet(&buf, "x", "ipv4"))
+}
+
+func TestTrieExportNFTables(t *testing.T) {
+	trie := NewTrie()
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), nil)
+	trie.Insert(netip.MustParsePrefix("192.0.2.1/32"), nil)
+	trie.Insert(netip.MustParsePrefix("198.51.100.0/25"), nil)
+	trie.Insert(netip.MustParsePrefix("198.51.100.128/26"), nil)
+	trie.Insert(netip.MustParsePrefix("2001:db8::/32"), nil)
+
+	var buf bytes.Buffer
+	require.NoError(t, trie.ExportNFTables(&buf, "inet"))
+	assert.Equal(t, "elements = { 10.0.0.0/8, 192.0.2.1, 198.51.100.0-198.51.100.191 }\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, trie.ExportNFTables(&buf, "inet6"))
+	assert.Equal(t, "elements = { 2001:db8::/32 }\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, NewTrie().ExportNFTables(&buf, "inet"))
+	assert.Empty(t, buf.String())
+
+	assert.Error(t, trie.ExportNFTables(&buf, "ip"))
+}
+
+func TestTrieExportNFTablesJSON(t *testing.T) {
+	trie := NewTrie()
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), nil)
+	trie.Insert(netip.MustParsePrefix("192.0.2.1/32"), nil)
+	trie.Insert(netip.MustParsePrefix("198.51.100.0/25"), nil)
+	trie.Insert(netip.MustParsePrefix("198.51.100.128/26"), nil)
+
+	var buf bytes.Buffer
+	require.NoError(t, trie.ExportNFTablesJSON(&buf, "inet", NFTablesSet{Family: "inet", Table: "filter", Name: "blocklist"}))
+	assert.JSONEq(t, `{"nftables": [{"add": {"element": {
+		"family": "inet",
+		"table": "filter",
+		"name": "blocklist",
+		"elem": [
+			{"prefix": {"addr": "10.0.0.0", "len": 8}},
+			"192.0.2.1",
+			{"range": ["198.51.100.0", "198.51.100.191"]}
+		]
+	}}}]}`, buf.String())
+}
+
+func TestTrieExportCiscoPrefixList(t *testing.T) {
+	trie := NewTrie()
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), nil)
+	trie.Insert(netip.MustParsePrefix("10.1.0.0/16"), nil)
+	trie.Insert(netip.MustParsePrefix("192.0.2.1/32"), nil)
+	trie.Insert(netip.MustParsePrefix("2001:db8::/32"), nil)
+
+	var buf bytes.Buffer
+	require.NoError(t, trie.ExportCiscoPrefixList(&buf, "CUSTOMERS", "inet", false))
+	assert.Equal(t, `ip prefix-list CUSTOMERS seq 5 permit 10.0.0.0/8
+ip prefix-list CUSTOMERS seq 10 permit 10.1.0.0/16
+ip prefix-list CUSTOMERS seq 15 permit 192.0.2.1/32
+`, buf.String())
+
+	buf.Reset()
+	require.NoError(t, trie.ExportCiscoPrefixList(&buf, "CUSTOMERS", "inet", true))
+	assert.Equal(t, `ip prefix-list CUSTOMERS seq 5 permit 10.0.0.0/8 le 32
+ip prefix-list CUSTOMERS seq 10 permit 192.0.2.1/32
+`, buf.String())
+
+	buf.Reset()
+	require.NoError(t, trie.ExportCiscoPrefixList(&buf, "CUSTOMERS6", "inet6", true))
+	assert.Equal(t, "ipv6 prefix-list CUSTOMERS6 seq 5 permit 2001:db8::/32 le 128\n", buf.String())
+
+	assert.Error(t, trie.ExportCiscoPrefixList(&buf, "x", "ip", false))
+}
+
+func TestTrieExportJuniperPrefixList(t *testing.T) {
+	trie := NewTrie()
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), nil)
+	trie.Insert(netip.MustParsePrefix("10.1.0.0/16"), nil)
+	trie.Insert(netip.MustParsePrefix("2001:db8::/32"), nil)
+
+	var buf bytes.Buffer
+	require.NoError(t, trie.ExportJuniperPrefixList(&buf, "customers", "inet"))
+	assert.Equal(t, `prefix-list customers {
+    10.0.0.0/8;
+    10.1.0.0/16;
+}
+`, buf.String())
+}
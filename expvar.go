@@ -0,0 +1,91 @@
+//go:build !tinygo
+
+// The expvar package depends on net/http, which is unavailable under TinyGo, so PublishExpvar is omitted there.
+
+package iptrie
+
+import (
+	"expvar"
+	"net/netip"
+	"sync/atomic"
+	"time"
+)
+
+// expvarCounters is the Instrumentation counting lookups for PublishExpvar. Operations are also passed on to next, the
+// instrumentation which was previously set, if any.
+type expvarCounters struct {
+	next    Instrumentation
+	lookups atomic.Uint64
+	hits    atomic.Uint64
+}
+
+func (ec *expvarCounters) Lookup(op LookupOp, hit bool, elapsed time.Duration) {
+	ec.lookups.Add(1)
+	if hit {
+		ec.hits.Add(1)
+	}
+	if ec.next != nil {
+		ec.next.Lookup(op, hit, elapsed)
+	}
+}
+
+func (ec *expvarCounters) Insert(network netip.Prefix, elapsed time.Duration) {
+	if ec.next != nil {
+		ec.next.Insert(network, elapsed)
+	}
+}
+
+func (ec *expvarCounters) Remove(network netip.Prefix, removed bool, elapsed time.Duration) {
+	if ec.next != nil {
+		ec.next.Remove(network, removed, elapsed)
+	}
+}
+
+func (ec *expvarCounters) Walk(entries int, elapsed time.Duration) {
+	if ec.next != nil {
+		ec.next.Walk(entries, elapsed)
+	}
+}
+
+// publish publishes the counters along with the size of the trie returned by load under the given name.
+func (ec *expvarCounters) publish(name string, load func() *Trie) {
+	expvar.Publish(name, expvar.Func(func() any {
+		nodes, entries := load().Count()
+		return map[string]any{
+			"entries": entries,
+			"nodes":   nodes,
+			"lookups": ec.lookups.Load(),
+			"hits":    ec.hits.Load(),
+		}
+	}))
+}
+
+// PublishExpvar publishes live counters of the trie with the expvar package under the given name, so they are served at
+// /debug/vars. The published value is an object of the following:
+//
+//   - entries: The number of entries.
+//   - nodes: The number of nodes, including implicit nodes.
+//   - lookups: The number of calls of Find, FindLargest and Contains since PublishExpvar was called.
+//   - hits: The number of those lookups which matched an entry.
+//
+// Lookups are counted through the trie's instrumentation (see SetInstrumentation), which passes operations on to any
+// instrumentation already set. The counts of entries and nodes are gathered each time the variable is read, which
+// visits every node of the trie, so the trie must not be modified concurrently. Use RCUTrie.PublishExpvar for a trie
+// which is.
+//
+// As with expvar.Publish, PublishExpvar panics if the name is already in use.
+func (pt *Trie) PublishExpvar(name string) {
+	ec := &expvarCounters{next: pt.instr}
+	ec.publish(name, func() *Trie { return pt })
+	pt.SetInstrumentation(ec)
+}
+
+// PublishExpvar publishes live counters of the trie with the expvar package under the given name. See
+// Trie.PublishExpvar.
+func (rt *RCUTrie) PublishExpvar(name string) {
+	rt.update(func(pt *Trie) {
+		ec := &expvarCounters{next: pt.instr}
+		ec.publish(name, rt.Load)
+		pt.SetInstrumentation(ec)
+	})
+}
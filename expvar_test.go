@@ -0,0 +1,45 @@
+//go:build !tinygo
+
+package iptrie
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTriePublishExpvar(t *testing.T) {
+	trie := NewTrie()
+	ri := &recordingInstrumentation{}
+	trie.SetInstrumentation(ri)
+	trie.PublishExpvar("TestTriePublishExpvar")
+
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	trie.Insert(netip.MustParsePrefix("10.1.0.0/16"), "b")
+	trie.Find(netip.MustParseAddr("10.1.0.1"))
+	trie.Find(netip.MustParseAddr("192.0.2.1"))
+	trie.Contains(netip.MustParseAddr("10.0.0.1"))
+
+	var vars map[string]uint64
+	require.NoError(t, json.Unmarshal([]byte(expvar.Get("TestTriePublishExpvar").String()), &vars))
+	assert.Equal(t, map[string]uint64{"entries": 2, "nodes": 3, "lookups": 3, "hits": 2}, vars)
+	// The previously set instrumentation still receives the operations.
+	assert.Len(t, ri.events, 5)
+
+	assert.Panics(t, func() { NewTrie().PublishExpvar("TestTriePublishExpvar") })
+}
+
+func TestRCUTriePublishExpvar(t *testing.T) {
+	rt := NewRCUTrie()
+	rt.PublishExpvar("TestRCUTriePublishExpvar")
+	rt.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	rt.Find(netip.MustParseAddr("10.0.0.1"))
+
+	var vars map[string]uint64
+	require.NoError(t, json.Unmarshal([]byte(expvar.Get("TestRCUTriePublishExpvar").String()), &vars))
+	assert.Equal(t, map[string]uint64{"entries": 1, "nodes": 2, "lookups": 1, "hits": 1}, vars)
+}
@@ -0,0 +1,104 @@
+package iptrie
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/netip"
+	"strings"
+	"sync"
+)
+
+// FeedParser parses the entries of a feed read from r, calling fn with each one.
+type FeedParser func(r io.Reader, fn func(network netip.Prefix, value any)) error
+
+// ParseSpamhausDROP is a FeedParser for the text format of the Spamhaus DROP and EDROP lists. Each line holds a network
+// followed by the SBL identifier of the listing, separated by a ';', which is used as the (string) value. Lines starting
+// with ';' are comments.
+func ParseSpamhausDROP(r io.Reader, fn func(network netip.Prefix, value any)) error {
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line, sbl, _ := strings.Cut(scanner.Text(), ";")
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		network, err := parseCIDR(line)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		fn(network, strings.TrimSpace(sbl))
+	}
+	return scanner.Err()
+}
+
+// ParseCIDRList is a FeedParser for lists with one network per line, such as the Team Cymru bogon lists, in the format
+// read by Trie.LoadCIDRList. The value of each entry is nil.
+func ParseCIDRList(r io.Reader, fn func(network netip.Prefix, value any)) error {
+	return scanCIDRList(r, func(line string) error {
+		network, err := parseCIDR(line)
+		if err != nil {
+			return err
+		}
+		fn(network, nil)
+		return nil
+	})
+}
+
+// LoadSpamhausDROP inserts the entries of a Spamhaus DROP or EDROP list read from r, as parsed by ParseSpamhausDROP.
+func (pt *Trie) LoadSpamhausDROP(r io.Reader) error {
+	return ParseSpamhausDROP(r, pt.Insert)
+}
+
+// Feed maintains the entries of an RCUTrie which come from a feed, such as a blocklist which is periodically
+// downloaded. Each refresh replaces the entries from the previous version of the feed, leaving the other entries of the
+// trie in place. Entries for the same network as one from the feed are overwritten, and removed if the network is
+// dropped from the feed.
+//
+// A Feed is safe for concurrent use.
+type Feed struct {
+	mu       sync.Mutex
+	trie     *RCUTrie
+	parse    FeedParser
+	networks map[netip.Prefix]struct{}
+}
+
+// NewFeed creates a Feed which maintains entries parsed by parse within trie.
+func NewFeed(trie *RCUTrie, parse FeedParser) *Feed {
+	return &Feed{
+		trie:  trie,
+		parse: parse,
+	}
+}
+
+// Refresh replaces the entries of the feed with those read from r. The changes are published atomically, so readers of
+// the trie see either the previous version of the feed or the new one.
+//
+// The feed is read in full before the trie is modified, so if an error occurs, the trie is left unchanged.
+func (f *Feed) Refresh(r io.Reader) error {
+	var entries []pfxEntry
+	if err := f.parse(r, func(network netip.Prefix, value any) {
+		entries = append(entries, pfxEntry{normalizePrefix(network.Masked()), value})
+	}); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	networks := make(map[netip.Prefix]struct{}, len(entries))
+	for _, entry := range entries {
+		networks[entry.Prefix] = struct{}{}
+	}
+	f.trie.update(func(pt *Trie) {
+		for network := range f.networks {
+			if _, ok := networks[network]; !ok {
+				pt.Remove(network)
+			}
+		}
+		for _, entry := range entries {
+			pt.Insert(entry.Prefix, entry.Value)
+		}
+	})
+	f.networks = networks
+	return nil
+}
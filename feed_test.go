@@ -0,0 +1,62 @@
+package iptrie
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrieLoadSpamhausDROP(t *testing.T) {
+	trie := NewTrie()
+	err := trie.LoadSpamhausDROP(strings.NewReader(`; Spamhaus DROP List 2024/01/01 - (c) 2024 The Spamhaus Project
+; Last-Modified: Mon, 01 Jan 2024 00:00:00 GMT
+1.10.16.0/20 ; SBL256894
+2.56.192.0/22 ; SBL459831
+`))
+	require.NoError(t, err)
+	assert.Equal(t, "SBL256894", trie.Find(netip.MustParseAddr("1.10.16.1")))
+	assert.Equal(t, "SBL459831", trie.Find(netip.MustParseAddr("2.56.195.255")))
+	assert.Len(t, trie.appendEntries(nil), 2)
+
+	assert.ErrorContains(t, NewTrie().LoadSpamhausDROP(strings.NewReader("; header\nbogus ; SBL1\n")), "line 2: ")
+}
+
+func TestParseCIDRList(t *testing.T) {
+	var networks []netip.Prefix
+	err := ParseCIDRList(strings.NewReader("# last updated 1700000000\n0.0.0.0/8\n10.0.0.0/8\n"), func(network netip.Prefix, value any) {
+		assert.Nil(t, value)
+		networks = append(networks, network)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("0.0.0.0/8"), netip.MustParsePrefix("10.0.0.0/8")}, networks)
+}
+
+func TestFeedRefresh(t *testing.T) {
+	rt := NewRCUTrie()
+	rt.Insert(netip.MustParsePrefix("192.0.2.0/24"), "local")
+	feed := NewFeed(rt, ParseSpamhausDROP)
+
+	require.NoError(t, feed.Refresh(strings.NewReader("10.0.0.0/8 ; SBL1\n172.16.0.0/12 ; SBL2\n")))
+	snap := rt.Load()
+	assert.Equal(t, "SBL1", rt.Find(netip.MustParseAddr("10.0.0.1")))
+	assert.Equal(t, "SBL2", rt.Find(netip.MustParseAddr("172.16.0.1")))
+
+	require.NoError(t, feed.Refresh(strings.NewReader("10.0.0.0/8 ; SBL3\n198.51.100.0/24 ; SBL4\n")))
+	assert.Equal(t, "SBL3", rt.Find(netip.MustParseAddr("10.0.0.1")))
+	assert.Nil(t, rt.Find(netip.MustParseAddr("172.16.0.1")))
+	assert.Equal(t, "SBL4", rt.Find(netip.MustParseAddr("198.51.100.1")))
+	assert.Equal(t, "local", rt.Find(netip.MustParseAddr("192.0.2.1")))
+	// The previous version is unchanged.
+	assert.Equal(t, "SBL2", snap.Find(netip.MustParseAddr("172.16.0.1")))
+
+	// A failed refresh leaves the trie unchanged.
+	assert.Error(t, feed.Refresh(strings.NewReader("10.0.0.0/8 ; SBL5\nbogus\n")))
+	assert.Equal(t, "SBL3", rt.Find(netip.MustParseAddr("10.0.0.1")))
+
+	// IPv4 networks are tracked in their normalized form.
+	require.NoError(t, feed.Refresh(strings.NewReader("")))
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("::ffff:192.0.2.0/120")}, rt.Load().appendEntries(nil))
+}
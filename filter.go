@@ -0,0 +1,178 @@
+package iptrie
+
+import (
+	"math/bits"
+	"net/netip"
+)
+
+const (
+	// filterGranularity4 and filterGranularity6 are the prefix lengths (in normalized form) at which addresses are
+	// recorded in the sketch of a FilteredTrie. IPv4 addresses are recorded per /24, and IPv6 addresses per /48.
+	filterGranularity4 = 96 + 24
+	filterGranularity6 = 48
+
+	// filterMaxExpansion is the number of bits a network may be shorter than the granularity while still being
+	// recorded in the sketch. Shorter networks would need too many sketch entries, and are kept separately.
+	filterMaxExpansion = 8
+)
+
+// FilteredTrie wraps a Trie with a probabilistic pre-filter, which rejects lookups of addresses that are definitely not
+// contained in the trie without traversing it. This benefits miss-heavy workloads, such as deny lists where the vast
+// majority of lookups don't match.
+//
+// The filter is a sketch (a single-hash Bloom filter) recording each block of addresses (/24 for IPv4, /48 for IPv6)
+// which overlaps an entry. Lookups of addresses within a block which doesn't overlap any entry skip the trie. Entries
+// too short to record efficiently are kept in a small secondary trie which is always consulted.
+//
+// Removals do not clear the sketch, as other entries may share the same bits. This never causes incorrect results, but
+// increases the false positive rate. Rebuild may be used to reconstruct the sketch after many removals.
+//
+// The wrapped trie must not be modified directly. A FilteredTrie is not safe for concurrent modification.
+type FilteredTrie struct {
+	trie   *Trie
+	sketch []uint64
+	// mask is the number of bits in the sketch - 1.
+	mask  uint64
+	short *Trie
+}
+
+// NewFilteredTrie creates a FilteredTrie wrapping trie, with a sketch of the given number of bits (rounded up to a power
+// of 2). For a low false positive rate, size should be at least 16 times the number of blocks overlapped by the
+// entries.
+func NewFilteredTrie(trie *Trie, size int) *FilteredTrie {
+	if size < 64 {
+		size = 64
+	}
+	size = 1 << bits.Len(uint(size-1))
+	ft := &FilteredTrie{
+		trie:   trie,
+		sketch: make([]uint64, size/64),
+		mask:   uint64(size - 1),
+	}
+	ft.Rebuild()
+	return ft
+}
+
+// Rebuild reconstructs the filter from the contents of the trie.
+func (ft *FilteredTrie) Rebuild() {
+	clear(ft.sketch)
+	ft.short = NewTrie()
+	ft.trie.node.addToFilter(ft)
+}
+
+func (pt *node) addToFilter(ft *FilteredTrie) {
+	if pt.value != nil {
+		ft.add(pt.addr, pt.bits)
+	}
+	for _, child := range pt.children {
+		if child != nil {
+			child.addToFilter(ft)
+		}
+	}
+}
+
+// Insert inserts an entry into the trie.
+func (ft *FilteredTrie) Insert(network netip.Prefix, value any) {
+	ft.trie.Insert(network, value)
+	ft.add(prefix128(normalizePrefix(network)))
+}
+
+// Remove removes the entry identified by given network from trie.
+func (ft *FilteredTrie) Remove(network netip.Prefix) any {
+	v := ft.trie.Remove(network)
+	ft.short.Remove(network)
+	return v
+}
+
+// Find returns the value from the most specific network (largest prefix) containing the given address.
+func (ft *FilteredTrie) Find(ip netip.Addr) any {
+	if !ft.mayContain(ip) {
+		return nil
+	}
+	return ft.trie.Find(ip)
+}
+
+// FindLargest returns the value from the largest network (smallest prefix) containing the given address.
+func (ft *FilteredTrie) FindLargest(ip netip.Addr) any {
+	if !ft.mayContain(ip) {
+		return nil
+	}
+	return ft.trie.FindLargest(ip)
+}
+
+// Contains indicates whether the trie contains the given ip.
+func (ft *FilteredTrie) Contains(ip netip.Addr) bool {
+	return ft.mayContain(ip) && ft.trie.Contains(ip)
+}
+
+func (ft *FilteredTrie) add(addr uint128, pfxLen uint8) {
+	g := uint8(filterGranularity6)
+	if pfxLen >= 96 && netContains(v4Prefix, 96, addr) {
+		g = filterGranularity4
+	} else if pfxLen < 96 && netContains(addr, pfxLen, v4Prefix) {
+		// The network contains both IPv4 and IPv6 addresses, so can't be recorded at either granularity.
+		ft.addShort(addr, pfxLen)
+		return
+	}
+
+	if pfxLen >= g {
+		ft.set(addr.bitsClearedFrom(g))
+		return
+	}
+	if g-pfxLen > filterMaxExpansion {
+		ft.addShort(addr, pfxLen)
+		return
+	}
+	// Record every block within the network.
+	for i := uint64(0); i < 1<<(g-pfxLen); i++ {
+		block := addr
+		if shift := 128 - g; shift >= 64 {
+			block.hi += i << (shift - 64)
+		} else {
+			block.lo += i << shift
+		}
+		ft.set(block)
+	}
+}
+
+func (ft *FilteredTrie) addShort(addr uint128, pfxLen uint8) {
+	ft.short.insert(addr, pfxLen, empty)
+	ft.short.refreshV4()
+}
+
+// mayContain returns false if the trie definitely doesn't contain ip.
+func (ft *FilteredTrie) mayContain(ip netip.Addr) bool {
+	var block uint128
+	if ip.Is4() {
+		block = v4Addr128(ip).bitsClearedFrom(filterGranularity4)
+	} else if ip128 := addr128(ip); netContains(v4Prefix, 96, ip128) {
+		block = ip128.bitsClearedFrom(filterGranularity4)
+	} else {
+		block = ip128.bitsClearedFrom(filterGranularity6)
+	}
+	if ft.test(block) {
+		return true
+	}
+	return ft.short.Contains(ip)
+}
+
+func (ft *FilteredTrie) set(block uint128) {
+	h := filterHash(block) & ft.mask
+	ft.sketch[h/64] |= 1 << (h % 64)
+}
+
+func (ft *FilteredTrie) test(block uint128) bool {
+	h := filterHash(block) & ft.mask
+	return ft.sketch[h/64]&(1<<(h%64)) != 0
+}
+
+// filterHash mixes the bits of u into a 64-bit hash.
+func filterHash(u uint128) uint64 {
+	h := u.hi*0x9e3779b97f4a7c15 ^ u.lo
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
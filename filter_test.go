@@ -0,0 +1,60 @@
+package iptrie
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilteredTrie(t *testing.T) {
+	trie := NewTrie()
+	var networks []netip.Prefix
+	for i := 0; i < 1000; i++ {
+		ip := GenIPV4()
+		network, _ := ip.Prefix(int(ip.As4()[3]%17) + 16)
+		networks = append(networks, network)
+		trie.Insert(network, i)
+	}
+	trie.Insert(netip.MustParsePrefix("2001:db8::/32"), "v6")
+	trie.Insert(netip.MustParsePrefix("2001:db9::1/128"), "v6 host")
+	ft := NewFilteredTrie(trie, 1<<22)
+
+	// Short networks are kept separately.
+	ft.Insert(netip.MustParsePrefix("12.0.0.0/8"), "short")
+
+	var misses, rejected int
+	for i := 0; i < 10000; i++ {
+		ip := GenIPV4()
+		v := trie.Find(ip)
+		assert.Equal(t, v, ft.Find(ip), "ip=%s", ip)
+		assert.Equal(t, trie.Contains(ip), ft.Contains(ip), "ip=%s", ip)
+		if v == nil {
+			misses++
+			if !ft.mayContain(ip) {
+				rejected++
+			}
+		}
+	}
+	assert.Greater(t, float64(rejected)/float64(misses), 0.9)
+
+	for _, ip := range []string{"2001:db8::1", "2001:db9::1", "2001:db9::2", "12.1.2.3", "::ffff:12.1.2.3"} {
+		addr := netip.MustParseAddr(ip)
+		assert.Equal(t, trie.Find(addr), ft.Find(addr), "ip=%s", ip)
+	}
+	assert.False(t, ft.mayContain(netip.MustParseAddr("2001:dba::1")))
+
+	// Networks covering both IPv4 and IPv6 addresses must not be filtered out.
+	ft.Insert(netip.MustParsePrefix("::/0"), "default")
+	assert.Equal(t, "default", ft.Find(netip.MustParseAddr("2001:dba::1")))
+	assert.Equal(t, "default", ft.FindLargest(netip.MustParseAddr("13.0.0.1")))
+
+	// Removal followed by a rebuild clears the filter.
+	ft.Remove(netip.MustParsePrefix("::/0"))
+	for _, network := range networks {
+		ft.Remove(network)
+	}
+	ft.Rebuild()
+	assert.False(t, ft.mayContain(networks[0].Addr()))
+	assert.Equal(t, "short", ft.Find(netip.MustParseAddr("12.1.2.3")))
+}
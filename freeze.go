@@ -0,0 +1,201 @@
+package iptrie
+
+import (
+	"net/netip"
+)
+
+// FrozenTrie is an immutable, compacted form of a Trie, optimized purely for lookups. It is created with Trie.Freeze.
+//
+// Nodes are flattened into a single array in depth-first order, referencing their children by index rather than by
+// pointer. This keeps related nodes close together in memory, and gives the garbage collector far fewer objects to
+// scan.
+//
+// A FrozenTrie is safe for concurrent use.
+type FrozenTrie struct {
+	// nodes contains the nodes of the trie, with the root at index 0.
+	nodes []frozenNode
+	// values contains the values of all entries. Nodes reference their value by index+1.
+	values []any
+}
+
+type frozenNode struct {
+	addr uint128
+	// children contains the indexes of the node's children within FrozenTrie.nodes. As the root node can never be a
+	// child, 0 indicates the absence of a child.
+	children [2]uint32
+	// value is the index+1 of the node's value within FrozenTrie.values, or 0 if the node is not an entry.
+	value uint32
+	bits  uint8
+}
+
+// Freeze returns an immutable copy of the trie, optimized for lookups. The trie itself is unaffected, and may continue to
+// be modified.
+func (pt *Trie) Freeze() *FrozenTrie {
+	ft := &FrozenTrie{}
+	ft.add(&pt.node)
+	return ft
+}
+
+// add appends the given node and all of its descendants, returning the index of the node.
+func (ft *FrozenTrie) add(n *node) uint32 {
+	idx := uint32(len(ft.nodes))
+	ft.nodes = append(ft.nodes, frozenNode{addr: n.addr, bits: n.bits})
+	if n.value != nil {
+		ft.values = append(ft.values, unempty(n.value))
+		ft.nodes[idx].value = uint32(len(ft.values))
+	}
+	for bit, child := range n.children {
+		if child != nil {
+			childIdx := ft.add(child)
+			ft.nodes[idx].children[bit] = childIdx
+		}
+	}
+	return idx
+}
+
+// Find returns the value from the most specific network (largest prefix) containing the given address.
+func (ft *FrozenTrie) Find(ip netip.Addr) any {
+	ip128 := addr128(normalizeAddr(ip))
+	var value uint32
+	for i := uint32(0); ; {
+		n := &ft.nodes[i]
+		if !netContains(n.addr, n.bits, ip128) {
+			break
+		}
+		// As with Trie, entries with nil values are skipped in favor of less specific networks, except on a full
+		// address match.
+		if n.value != 0 && (n.bits == 128 || ft.values[n.value-1] != nil) {
+			value = n.value
+		}
+		if n.bits == 128 {
+			break
+		}
+		if i = n.children[ip128.bit(n.bits)]; i == 0 {
+			break
+		}
+	}
+	return ft.value(value)
+}
+
+// FindLargest returns the value from the largest network (smallest prefix) containing the given address.
+func (ft *FrozenTrie) FindLargest(ip netip.Addr) any {
+	return ft.value(ft.findLargest(ip))
+}
+
+// Contains indicates whether the trie contains the given ip.
+func (ft *FrozenTrie) Contains(ip netip.Addr) bool {
+	return ft.findLargest(ip) != 0
+}
+
+func (ft *FrozenTrie) findLargest(ip netip.Addr) uint32 {
+	ip128 := addr128(normalizeAddr(ip))
+	for i := uint32(0); ; {
+		n := &ft.nodes[i]
+		if !netContains(n.addr, n.bits, ip128) {
+			return 0
+		}
+		if n.value != 0 {
+			return n.value
+		}
+		if n.bits == 128 {
+			return 0
+		}
+		if i = n.children[ip128.bit(n.bits)]; i == 0 {
+			return 0
+		}
+	}
+}
+
+// ContainingNetworks returns the list of networks containing the given ip in ascending prefix order (largest network to
+// smallest).
+//
+// Note: Inserted addresses are normalized to IPv6, so the returned list will be IPv6 only.
+func (ft *FrozenTrie) ContainingNetworks(ip netip.Addr) []netip.Prefix {
+	ip128 := addr128(normalizeAddr(ip))
+	var results []netip.Prefix
+	for i := uint32(0); ; {
+		n := &ft.nodes[i]
+		if !netContains(n.addr, n.bits, ip128) {
+			break
+		}
+		if n.value != 0 {
+			results = append(results, n.network())
+		}
+		if n.bits == 128 {
+			break
+		}
+		if i = n.children[ip128.bit(n.bits)]; i == 0 {
+			break
+		}
+	}
+	return results
+}
+
+// CoveredNetworks returns the list of networks contained within the given network.
+//
+// Note: Inserted addresses are normalized to IPv6, so the returned list will be IPv6 only.
+func (ft *FrozenTrie) CoveredNetworks(network netip.Prefix) []netip.Prefix {
+	addr, bits := prefix128(normalizePrefix(network))
+	for i := uint32(0); ; {
+		n := &ft.nodes[i]
+		if bits <= n.bits && netContains(addr, bits, n.addr) {
+			return ft.appendEntries(nil, i)
+		}
+		if n.bits == 128 || !netContains(n.addr, n.bits, addr) {
+			return nil
+		}
+		if i = n.children[addr.bit(n.bits)]; i == 0 {
+			return nil
+		}
+	}
+}
+
+func (ft *FrozenTrie) appendEntries(dst []netip.Prefix, i uint32) []netip.Prefix {
+	n := &ft.nodes[i]
+	if n.value != 0 {
+		dst = append(dst, n.network())
+	}
+	for _, child := range n.children {
+		if child != 0 {
+			dst = ft.appendEntries(dst, child)
+		}
+	}
+	return dst
+}
+
+// Walk calls fn for each entry in depth order, until fn returns false.
+//
+// Note: Inserted addresses are normalized to IPv6, so the networks will be IPv6 only.
+func (ft *FrozenTrie) Walk(fn func(network netip.Prefix, value any) bool) {
+	ft.walk(0, fn)
+}
+
+func (ft *FrozenTrie) walk(i uint32, fn func(network netip.Prefix, value any) bool) bool {
+	n := &ft.nodes[i]
+	if n.value != 0 && !fn(n.network(), ft.value(n.value)) {
+		return false
+	}
+	for _, child := range n.children {
+		if child != 0 && !ft.walk(child, fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// Len returns the number of entries in the trie.
+func (ft *FrozenTrie) Len() int {
+	return len(ft.values)
+}
+
+func (ft *FrozenTrie) value(idx uint32) any {
+	if idx == 0 {
+		return nil
+	}
+	return ft.values[idx-1]
+}
+
+// network returns the network of the node as a netip.Prefix.
+func (n *frozenNode) network() netip.Prefix {
+	return netip.PrefixFrom(addrFrom128(n.addr), int(n.bits))
+}
@@ -0,0 +1,65 @@
+package iptrie
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFrozenTrie(t *testing.T) {
+	trie := NewTrie()
+	trie.Insert(netip.MustParsePrefix("2001:db8::/32"), "v6")
+	trie.Insert(netip.MustParsePrefix("2001:db8::1/128"), "v6 host")
+	for i := 0; i < 1000; i++ {
+		ip := GenIPV4()
+		network, _ := ip.Prefix(int(ip.As4()[3]%25) + 8)
+		trie.Insert(network, i)
+	}
+	ft := trie.Freeze()
+	assert.Equal(t, len(trie.CoveredNetworks(netip.MustParsePrefix("::/0"))), ft.Len())
+
+	// Modifications to the trie must not affect the frozen copy.
+	trie.Insert(netip.MustParsePrefix("2001:db8:1::/48"), "after")
+	assert.Equal(t, "v6", ft.Find(netip.MustParseAddr("2001:db8:1::1")))
+	trie.Remove(netip.MustParsePrefix("2001:db8:1::/48"))
+
+	ips := []netip.Addr{netip.MustParseAddr("2001:db8::1"), netip.MustParseAddr("2001:db8::2"), netip.MustParseAddr("::1")}
+	for i := 0; i < 1000; i++ {
+		ips = append(ips, GenIPV4())
+	}
+	for _, ip := range ips {
+		assert.Equal(t, trie.Find(ip), ft.Find(ip), "ip=%s", ip)
+		assert.Equal(t, trie.FindLargest(ip), ft.FindLargest(ip), "ip=%s", ip)
+		assert.Equal(t, trie.Contains(ip), ft.Contains(ip), "ip=%s", ip)
+		assert.Equal(t, trie.ContainingNetworks(ip), ft.ContainingNetworks(ip), "ip=%s", ip)
+	}
+	for _, search := range []string{"::/0", "0.0.0.0/0", "10.0.0.0/8", "192.168.0.0/16", "2001:db8::/32", "2001:db8::/64"} {
+		network := netip.MustParsePrefix(search)
+		assert.Equal(t, trie.CoveredNetworks(network), ft.CoveredNetworks(network), "network=%s", search)
+	}
+}
+
+func TestFrozenTrieMatchesTrie(t *testing.T) {
+	for _, v4Only := range []bool{true, false} {
+		trie, ips := genMatchTrie(v4Only)
+		assertMatchesTrie(t, trie, trie.Freeze(), ips)
+	}
+
+	trie := NewTrie()
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	trie.Insert(netip.MustParsePrefix("10.1.0.0/16"), nil)
+	trie.Insert(netip.MustParsePrefix("10.2.0.1/32"), nil)
+	ft := trie.Freeze()
+	assert.Equal(t, "a", ft.Find(netip.MustParseAddr("10.1.0.1")))
+	assert.Nil(t, ft.Find(netip.MustParseAddr("10.2.0.1")))
+	assert.True(t, ft.Contains(netip.MustParseAddr("10.2.0.1")))
+}
+
+func TestFrozenTrieEmpty(t *testing.T) {
+	ft := NewTrie().Freeze()
+	assert.Nil(t, ft.Find(netip.MustParseAddr("10.0.0.1")))
+	assert.False(t, ft.Contains(netip.MustParseAddr("::1")))
+	assert.Nil(t, ft.CoveredNetworks(netip.MustParsePrefix("::/0")))
+	assert.Equal(t, 0, ft.Len())
+}
@@ -0,0 +1,83 @@
+package iptrie
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+)
+
+// GeoLite2Row is a row of a MaxMind GeoLite2 (or GeoIP2) blocks CSV file.
+type GeoLite2Row struct {
+	// Network is the network of the row.
+	Network netip.Prefix
+
+	columns map[string]int
+	record  []string
+}
+
+// Field returns the value of the named column of the row, such as "geoname_id" or "autonomous_system_number", or an
+// empty string if there is no such column.
+func (row GeoLite2Row) Field(name string) string {
+	if i, ok := row.columns[name]; ok && i < len(row.record) {
+		return row.record[i]
+	}
+	return ""
+}
+
+// LoadGeoLite2CSV inserts the rows read from r, which is a MaxMind GeoLite2 blocks CSV file, such as
+// GeoLite2-City-Blocks-IPv4.csv or GeoLite2-ASN-Blocks-IPv6.csv. The first line must be the header, which must include
+// a "network" column.
+//
+// valueFn is called for each row, and returns the value to insert. If valueFn is nil, the GeoLite2Row is inserted as the
+// value. The geoname_id columns refer to the rows of the corresponding locations CSV file, which can be loaded
+// beforehand to have valueFn return the location itself.
+//
+// Loading stops at the first error, which identifies the line it occurred on. Entries from the preceding lines remain
+// inserted.
+func (pt *Trie) LoadGeoLite2CSV(r io.Reader, valueFn func(row GeoLite2Row) (any, error)) error {
+	if valueFn == nil {
+		valueFn = func(row GeoLite2Row) (any, error) {
+			return row, nil
+		}
+	}
+
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err == io.EOF {
+		return errors.New("missing header")
+	}
+	if err != nil {
+		return err
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[name] = i
+	}
+	networkCol, ok := columns["network"]
+	if !ok {
+		return errors.New("line 1: missing network column")
+	}
+
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		lineNum, _ := cr.FieldPos(0)
+
+		network, err := netip.ParsePrefix(record[networkCol])
+		if err != nil {
+			return fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		value, err := valueFn(GeoLite2Row{Network: network, columns: columns, record: record})
+		if err != nil {
+			return fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		pt.Insert(network, value)
+	}
+}
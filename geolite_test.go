@@ -0,0 +1,55 @@
+package iptrie
+
+import (
+	"errors"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const geoLite2Sample = `network,geoname_id,registered_country_geoname_id,represented_country_geoname_id,is_anonymous_proxy,is_satellite_provider
+1.0.0.0/24,2077456,2077456,,0,0
+1.0.1.0/24,1814991,1814991,,0,0
+2001:db8::/32,6252001,6252001,,0,0
+`
+
+func TestTrieLoadGeoLite2CSV(t *testing.T) {
+	trie := NewTrie()
+	require.NoError(t, trie.LoadGeoLite2CSV(strings.NewReader(geoLite2Sample), nil))
+	assert.Len(t, trie.appendEntries(nil), 3)
+
+	row, ok := trie.Find(netip.MustParseAddr("1.0.1.1")).(GeoLite2Row)
+	require.True(t, ok)
+	assert.Equal(t, netip.MustParsePrefix("1.0.1.0/24"), row.Network)
+	assert.Equal(t, "1814991", row.Field("geoname_id"))
+	assert.Equal(t, "", row.Field("represented_country_geoname_id"))
+	assert.Equal(t, "", row.Field("postal_code"))
+}
+
+func TestTrieLoadGeoLite2CSVValueFn(t *testing.T) {
+	locations := map[string]string{"2077456": "AU", "1814991": "CN", "6252001": "US"}
+	trie := NewTrie()
+	err := trie.LoadGeoLite2CSV(strings.NewReader(geoLite2Sample), func(row GeoLite2Row) (any, error) {
+		return locations[row.Field("geoname_id")], nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "AU", trie.Find(netip.MustParseAddr("1.0.0.1")))
+	assert.Equal(t, "US", trie.Find(netip.MustParseAddr("2001:db8::1")))
+
+	errTest := errors.New("test")
+	err = NewTrie().LoadGeoLite2CSV(strings.NewReader(geoLite2Sample), func(row GeoLite2Row) (any, error) {
+		return nil, errTest
+	})
+	assert.ErrorIs(t, err, errTest)
+	assert.ErrorContains(t, err, "line 2: ")
+}
+
+func TestTrieLoadGeoLite2CSVError(t *testing.T) {
+	assert.Error(t, NewTrie().LoadGeoLite2CSV(strings.NewReader(""), nil))
+	assert.ErrorContains(t, NewTrie().LoadGeoLite2CSV(strings.NewReader("geoname_id\n1\n"), nil), "network column")
+	assert.ErrorContains(t, NewTrie().LoadGeoLite2CSV(strings.NewReader("network,geoname_id\n1.0.0.0/24,1\nbogus,2\n"), nil), "line 3: ")
+	assert.Error(t, NewTrie().LoadGeoLite2CSV(strings.NewReader("network,geoname_id\n1.0.0.0/24,1,extra\n"), nil))
+}
@@ -0,0 +1,60 @@
+package iptrie
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+)
+
+// Hash returns a SHA-256 digest of the entries of the trie. Tries with the same entries and values have the same hash,
+// regardless of the order the entries were inserted in, or how the trie was constructed. This allows copies of a trie
+// to be cheaply compared, such as for use as an HTTP ETag.
+//
+// Values are hashed by their Go-syntax representation (the %#v verb of the fmt package), which is sufficient for
+// values such as strings, numbers and structs of them, but not values containing pointers. See HashCodec for hashing
+// the encoding of values.
+func (pt *Trie) Hash() [32]byte {
+	sum, _ := pt.HashCodec(ValueCodecFuncs{
+		EncodeFunc: func(value any) ([]byte, error) {
+			return fmt.Appendf(nil, "%T:%#v", value, value), nil
+		},
+	})
+	return sum
+}
+
+// HashCodec is like Hash, but hashes the values as encoded by codec. If codec is nil, StringCodec is used.
+func (pt *Trie) HashCodec(codec ValueCodec) ([32]byte, error) {
+	if codec == nil {
+		codec = StringCodec
+	}
+	h := sha256.New()
+	var buf []byte
+	var err error
+	pt.walk(func(n *node) bool {
+		// Each entry is hashed as its prefix length and full address, followed by a flag indicating whether it has a
+		// value, and the length prefixed value.
+		buf = append(buf[:0], n.bits)
+		buf = binary.BigEndian.AppendUint64(buf, n.addr.hi)
+		buf = binary.BigEndian.AppendUint64(buf, n.addr.lo)
+		if n.value == empty {
+			buf = append(buf, 0)
+		} else {
+			var value []byte
+			if value, err = codec.Encode(n.value); err != nil {
+				err = fmt.Errorf("encoding value for %s: %w", n.network(), err)
+				return false
+			}
+			buf = append(buf, 1)
+			buf = binary.AppendUvarint(buf, uint64(len(value)))
+			buf = append(buf, value...)
+		}
+		h.Write(buf)
+		return true
+	})
+	var sum [32]byte
+	if err != nil {
+		return sum, err
+	}
+	h.Sum(sum[:0])
+	return sum, nil
+}
@@ -0,0 +1,87 @@
+package iptrie
+
+import (
+	"fmt"
+	"math/rand"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrieHash(t *testing.T) {
+	var networks []netip.Prefix
+	for i := 0; i < 1000; i++ {
+		ip := GenIPV4()
+		network, _ := ip.Prefix(int(ip.As4()[3]%25) + 8)
+		networks = append(networks, network)
+	}
+
+	trie1 := NewTrie()
+	for _, network := range networks {
+		trie1.Insert(network, network.String())
+	}
+
+	// Insert in a different order, with extra entries which are then removed.
+	trie2 := NewTrie()
+	var extras []netip.Prefix
+	for _, i := range rand.Perm(len(networks)) {
+		trie2.Insert(networks[i], networks[i].String())
+		extra := netip.PrefixFrom(networks[i].Addr(), 32)
+		if trie1.get(prefix128(normalizePrefix(extra))) == nil {
+			trie2.Insert(extra, "extra")
+			extras = append(extras, extra)
+		}
+	}
+	for _, extra := range extras {
+		trie2.Remove(extra)
+	}
+
+	data1, err := trie1.MarshalBinary()
+	require.NoError(t, err)
+
+	assert.Equal(t, trie1.Hash(), trie1.Hash())
+	loaded := NewTrie()
+	require.NoError(t, loaded.UnmarshalBinary(data1))
+	assert.Equal(t, trie1.Hash(), loaded.Hash())
+
+	hash1, err := trie1.HashCodec(nil)
+	require.NoError(t, err)
+	assert.NotEqual(t, trie1.Hash(), hash1)
+
+	data2, err := trie2.MarshalBinary()
+	require.NoError(t, err)
+	assert.Equal(t, data1, data2)
+	assert.Equal(t, trie1.Hash(), trie2.Hash())
+}
+
+func TestTrieHashDiffers(t *testing.T) {
+	trie := NewTrie()
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	hash := trie.Hash()
+
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), "b")
+	assert.NotEqual(t, hash, trie.Hash())
+
+	// Values of different types with the same representation.
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), 1)
+	hash = trie.Hash()
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), uint(1))
+	assert.NotEqual(t, hash, trie.Hash())
+
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), nil)
+	hash = trie.Hash()
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/9"), nil)
+	assert.NotEqual(t, hash, trie.Hash())
+	trie.Remove(netip.MustParsePrefix("10.0.0.0/9"))
+	assert.Equal(t, hash, trie.Hash())
+
+	_, err := trie.HashCodec(ValueCodecFuncs{EncodeFunc: func(value any) ([]byte, error) {
+		return nil, fmt.Errorf("unexpected")
+	}})
+	assert.NoError(t, err, "nil values are not encoded")
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), 1)
+	_, err = trie.HashCodec(nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,72 @@
+package iptrie
+
+import (
+	"net/netip"
+	"slices"
+)
+
+// hooks holds the mutation hooks registered on a trie.
+type hooks struct {
+	insert  []func(network netip.Prefix, value any)
+	replace []func(network netip.Prefix, old, new any)
+	remove  []func(network netip.Prefix, value any)
+}
+
+// OnInsert registers fn to be called when Insert adds a new entry to the trie. The network is normalized to IPv6.
+//
+// Hooks are called synchronously after the modification has been applied, in the order they were registered.
+// Modifications of snapshots call the same hooks, as do those of the tries created with NewRCUTrieFrom and
+// NewVersionedTrieFrom. For the modifications made in an RCUTrie transaction, hooks are called when it's committed, and
+// not at all if it's aborted (see Txn). Hooks must not modify the trie.
+func (pt *Trie) OnInsert(fn func(network netip.Prefix, value any)) {
+	h := pt.hooks.clone()
+	h.insert = append(h.insert, fn)
+	pt.hooks = h
+}
+
+// OnReplace registers fn to be called when Insert replaces the value of an existing entry. See OnInsert.
+func (pt *Trie) OnReplace(fn func(network netip.Prefix, old, new any)) {
+	h := pt.hooks.clone()
+	h.replace = append(h.replace, fn)
+	pt.hooks = h
+}
+
+// OnRemove registers fn to be called when Remove removes an entry from the trie, with the value which was removed. See
+// OnInsert.
+func (pt *Trie) OnRemove(fn func(network netip.Prefix, value any)) {
+	h := pt.hooks.clone()
+	h.remove = append(h.remove, fn)
+	pt.hooks = h
+}
+
+// clone returns a copy of h which can be modified without affecting h. h may be nil.
+func (h *hooks) clone() *hooks {
+	if h == nil {
+		return &hooks{}
+	}
+	return &hooks{
+		insert:  slices.Clip(h.insert),
+		replace: slices.Clip(h.replace),
+		remove:  slices.Clip(h.remove),
+	}
+}
+
+// inserted calls the hooks for an insert. old is the previous value of the node, which is nil if it was not an entry.
+func (h *hooks) inserted(network netip.Prefix, old, value any) {
+	if old == nil {
+		for _, fn := range h.insert {
+			fn(network, value)
+		}
+		return
+	}
+	old = unempty(old)
+	for _, fn := range h.replace {
+		fn(network, old, value)
+	}
+}
+
+func (h *hooks) removed(network netip.Prefix, value any) {
+	for _, fn := range h.remove {
+		fn(network, value)
+	}
+}
@@ -0,0 +1,96 @@
+package iptrie
+
+import (
+	"fmt"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrieHooks(t *testing.T) {
+	trie := NewTrie()
+	var events []string
+	trie.OnInsert(func(network netip.Prefix, value any) {
+		events = append(events, fmt.Sprintf("insert %s %v", network, value))
+	})
+	trie.OnReplace(func(network netip.Prefix, old, new any) {
+		events = append(events, fmt.Sprintf("replace %s %v %v", network, old, new))
+	})
+	trie.OnRemove(func(network netip.Prefix, value any) {
+		events = append(events, fmt.Sprintf("remove %s %v", network, value))
+	})
+	trie.OnInsert(func(network netip.Prefix, value any) {
+		events = append(events, "second insert hook")
+	})
+
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	trie.Insert(netip.MustParsePrefix("10.1.0.0/16"), nil)
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), "b")
+	trie.Insert(netip.MustParsePrefix("10.1.0.0/16"), "c")
+	trie.Remove(netip.MustParsePrefix("10.0.0.0/8"))
+	trie.Remove(netip.MustParsePrefix("10.0.0.0/8"))
+	trie.Insert(netip.MustParsePrefix("10.2.0.0/16"), "d")
+	// 10.0.0.0/14 exists as an implicit node, so this is an insert rather than a replace.
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/14"), "e")
+	assert.Equal(t, []string{
+		"insert ::ffff:10.0.0.0/104 a",
+		"second insert hook",
+		"insert ::ffff:10.1.0.0/112 <nil>",
+		"second insert hook",
+		"replace ::ffff:10.0.0.0/104 a b",
+		"replace ::ffff:10.1.0.0/112 <nil> c",
+		"remove ::ffff:10.0.0.0/104 b",
+		"insert ::ffff:10.2.0.0/112 d",
+		"second insert hook",
+		"insert ::ffff:10.0.0.0/110 e",
+		"second insert hook",
+	}, events)
+}
+
+func TestTrieHooksSnapshot(t *testing.T) {
+	trie := NewTrie()
+	var trieEvents, snapEvents int
+	trie.OnInsert(func(network netip.Prefix, value any) {
+		trieEvents++
+	})
+	snap := trie.Snapshot()
+	snap.OnInsert(func(network netip.Prefix, value any) {
+		snapEvents++
+	})
+
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	assert.Equal(t, 1, trieEvents)
+	assert.Equal(t, 0, snapEvents)
+}
+
+func TestTxnHooksAbort(t *testing.T) {
+	trie := NewTrie()
+	var events []string
+	trie.OnInsert(func(network netip.Prefix, value any) {
+		events = append(events, fmt.Sprintf("insert %s %v", network, value))
+	})
+	trie.OnRemove(func(network netip.Prefix, value any) {
+		events = append(events, fmt.Sprintf("remove %s %v", network, value))
+	})
+	rt := NewRCUTrieFrom(trie)
+
+	txn := rt.Txn()
+	txn.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	txn.Remove(netip.MustParsePrefix("10.0.0.0/8"))
+	assert.Empty(t, events)
+	txn.Abort()
+	assert.Empty(t, events)
+
+	txn = rt.Txn()
+	txn.Insert(netip.MustParsePrefix("10.1.0.0/16"), "b")
+	txn.Insert(netip.MustParsePrefix("10.2.0.0/16"), "c")
+	txn.Remove(netip.MustParsePrefix("10.1.0.0/16"))
+	assert.Empty(t, events)
+	txn.Commit()
+	assert.Equal(t, []string{
+		"insert ::ffff:10.1.0.0/112 b",
+		"insert ::ffff:10.2.0.0/112 c",
+		"remove ::ffff:10.1.0.0/112 b",
+	}, events)
+}
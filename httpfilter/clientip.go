@@ -0,0 +1,81 @@
+package httpfilter
+
+import (
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/phemmer/go-iptrie"
+)
+
+// RealIP returns the address of the client which made the request, using the X-Forwarded-For header set by the
+// reverse proxies in trustedProxies.
+//
+// If the request did not arrive from a trusted proxy, the address it arrived from (http.Request.RemoteAddr) is
+// returned. Otherwise the addresses in X-Forwarded-For are considered from right to left, skipping those of trusted
+// proxies, and the first which is not trusted is returned. Addresses to the left of it are ignored, as they are
+// supplied by the client and could be spoofed. If every address is trusted, the leftmost is returned.
+//
+// If an entry of the header is malformed, the address of the proxy which added it is returned. The zero netip.Addr is
+// returned if RemoteAddr is not a valid address. If trustedProxies is nil, RemoteAddr is always used.
+//
+// See Filter.ClientIP for proxies which set the Forwarded header instead.
+func RealIP(r *http.Request, trustedProxies iptrie.Finder) netip.Addr {
+	return clientIP(r, trustedProxies, "X-Forwarded-For")
+}
+
+// clientIP implements RealIP, taking the client address from the given header.
+func clientIP(r *http.Request, trustedProxies iptrie.Finder, header string) netip.Addr {
+	ip := parseAddr(r.RemoteAddr)
+	if trustedProxies == nil {
+		return ip
+	}
+	var hops []string
+	for _, value := range r.Header.Values(header) {
+		hops = append(hops, strings.Split(value, ",")...)
+	}
+	forwarded := http.CanonicalHeaderKey(header) == "Forwarded"
+	for i := len(hops) - 1; i >= 0 && ip.IsValid() && trustedProxies.Contains(ip); i-- {
+		hop := strings.TrimSpace(hops[i])
+		if forwarded {
+			hop = forwardedFor(hop)
+		}
+		next := parseAddr(hop)
+		if !next.IsValid() {
+			break
+		}
+		ip = next
+	}
+	return ip
+}
+
+// parseAddr parses an address which may have a port, such as 192.0.2.1, 192.0.2.1:80, 2001:db8::1 or [2001:db8::1]:80.
+// The zero netip.Addr is returned if s is not valid.
+func parseAddr(s string) netip.Addr {
+	ip, err := netip.ParseAddr(s)
+	if err != nil {
+		addrPort, err := netip.ParseAddrPort(s)
+		if err != nil {
+			return netip.Addr{}
+		}
+		ip = addrPort.Addr()
+	}
+	return ip.Unmap()
+}
+
+// forwardedFor returns the value of the "for" parameter of an element of a Forwarded header (RFC 7239), such as
+// `for="[2001:db8::1]:80";proto=https`, with any quotes removed.
+func forwardedFor(element string) string {
+	for _, pair := range strings.Split(element, ";") {
+		key, value, _ := strings.Cut(strings.TrimSpace(pair), "=")
+		if strings.EqualFold(key, "for") {
+			value = strings.Trim(value, `"`)
+			// An IPv6 address without a port is still enclosed in brackets.
+			if strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]") {
+				value = value[1 : len(value)-1]
+			}
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,78 @@
+// Package httpfilter provides net/http middleware which allows or denies requests based on the client's IP address,
+// using tries of networks.
+package httpfilter
+
+import (
+	"net/http"
+	"net/netip"
+
+	"github.com/phemmer/go-iptrie"
+)
+
+// Filter is middleware which rejects requests from clients not permitted by its tries. It is created with a composite
+// literal, and its Handler method wraps the handler to be protected:
+//
+//	f := &httpfilter.Filter{Deny: blocklist, TrustedProxies: proxies}
+//	http.ListenAndServe(":8080", f.Handler(mux))
+//
+// The fields must not be modified once Handler has been called. The tries may be modified concurrently with requests
+// if they support it, such as iptrie.RCUTrie.
+type Filter struct {
+	// Allow lists the networks of the clients which are permitted. If nil, all clients not denied are permitted.
+	Allow iptrie.Finder
+	// Deny lists the networks of the clients which are rejected, taking precedence over Allow.
+	Deny iptrie.Finder
+
+	// TrustedProxies lists the networks of the reverse proxies in front of the server. When a request arrives from a
+	// trusted proxy, the client's address is taken from the header set by the proxy, as described by ClientIP. If nil,
+	// the address the request arrived from is always used, and the header is ignored.
+	TrustedProxies iptrie.Finder
+	// Header is the header the trusted proxies record the client address in: either "X-Forwarded-For" or "Forwarded"
+	// (RFC 7239). If empty, "X-Forwarded-For" is used. Only one header can be used, as a client could otherwise spoof
+	// its address by sending the header which the proxies don't set.
+	Header string
+
+	// Rejected handles the requests which are rejected. If nil, rejected requests receive a 403 Forbidden response.
+	Rejected http.Handler
+}
+
+// Handler returns a handler which passes the requests permitted by the filter to next, and the others to f.Rejected.
+func (f *Filter) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !f.Allowed(f.ClientIP(r)) {
+			f.reject(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Allowed indicates whether the filter permits a client with the given address. An invalid address, such as the zero
+// netip.Addr, is never permitted.
+func (f *Filter) Allowed(ip netip.Addr) bool {
+	if !ip.IsValid() {
+		return false
+	}
+	if f.Deny != nil && f.Deny.Contains(ip) {
+		return false
+	}
+	return f.Allow == nil || f.Allow.Contains(ip)
+}
+
+// ClientIP returns the address of the client which made the request. It is the same as RealIP, but uses the configured
+// header, including the "for" parameter of a Forwarded header.
+func (f *Filter) ClientIP(r *http.Request) netip.Addr {
+	header := f.Header
+	if header == "" {
+		header = "X-Forwarded-For"
+	}
+	return clientIP(r, f.TrustedProxies, header)
+}
+
+func (f *Filter) reject(w http.ResponseWriter, r *http.Request) {
+	if f.Rejected != nil {
+		f.Rejected.ServeHTTP(w, r)
+		return
+	}
+	http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+}
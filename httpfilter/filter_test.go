@@ -0,0 +1,125 @@
+package httpfilter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/phemmer/go-iptrie"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTrie(networks ...string) *iptrie.Trie {
+	trie := iptrie.NewTrie()
+	for _, network := range networks {
+		trie.Insert(netip.MustParsePrefix(network), nil)
+	}
+	return trie
+}
+
+func TestFilterHandler(t *testing.T) {
+	f := &Filter{
+		Allow:          newTrie("192.0.2.0/24", "2001:db8::/32"),
+		Deny:           newTrie("192.0.2.128/25"),
+		TrustedProxies: newTrie("10.0.0.0/8"),
+	}
+	h := f.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+
+	for _, tc := range []struct {
+		remoteAddr string
+		xff        string
+		status     int
+	}{
+		{"192.0.2.1:1234", "", http.StatusOK},
+		{"[2001:db8::1]:1234", "", http.StatusOK},
+		{"192.0.2.200:1234", "", http.StatusForbidden},
+		{"198.51.100.1:1234", "", http.StatusForbidden},
+		// The header is ignored from a client which isn't a trusted proxy.
+		{"198.51.100.1:1234", "192.0.2.1", http.StatusForbidden},
+		{"10.0.0.1:1234", "192.0.2.1", http.StatusOK},
+		{"10.0.0.1:1234", "192.0.2.200", http.StatusForbidden},
+		// A spoofed entry to the left of the client's address is ignored.
+		{"10.0.0.1:1234", "192.0.2.1, 198.51.100.1", http.StatusForbidden},
+		{"10.0.0.1:1234", "198.51.100.1, 192.0.2.1, 10.0.0.2", http.StatusOK},
+		// A request from a trusted proxy without the header is from the proxy itself.
+		{"10.0.0.1:1234", "", http.StatusForbidden},
+		{"garbage", "", http.StatusForbidden},
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tc.remoteAddr
+		if tc.xff != "" {
+			r.Header.Set("X-Forwarded-For", tc.xff)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		assert.Equal(t, tc.status, w.Code, "%s %s", tc.remoteAddr, tc.xff)
+	}
+}
+
+func TestFilterRejected(t *testing.T) {
+	f := &Filter{
+		Deny: newTrie("::/0", "0.0.0.0/0"),
+		Rejected: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		}),
+	}
+	w := httptest.NewRecorder()
+	f.Handler(http.NotFoundHandler()).ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, http.StatusTeapot, w.Code)
+}
+
+func TestFilterClientIP(t *testing.T) {
+	trusted := newTrie("10.0.0.0/8", "2001:db8::/32")
+	for _, tc := range []struct {
+		header string
+		values []string
+		expect string
+	}{
+		{"X-Forwarded-For", nil, "10.0.0.1"},
+		{"X-Forwarded-For", []string{"192.0.2.1"}, "192.0.2.1"},
+		{"X-Forwarded-For", []string{"192.0.2.1", "10.1.1.1"}, "192.0.2.1"},
+		{"X-Forwarded-For", []string{"192.0.2.1:4711, [2001:db8::2]:80"}, "192.0.2.1"},
+		{"X-Forwarded-For", []string{"::ffff:192.0.2.1"}, "192.0.2.1"},
+		{"X-Forwarded-For", []string{"10.1.1.1, 10.2.2.2"}, "10.1.1.1"},
+		// The proxy which added the malformed entry is returned.
+		{"X-Forwarded-For", []string{"192.0.2.1, garbage, 10.2.2.2"}, "10.2.2.2"},
+		{"Forwarded", []string{`for=192.0.2.60;proto=http;by=203.0.113.43`}, "192.0.2.60"},
+		{"Forwarded", []string{`for=192.0.2.1, For="[2001:db8:cafe::17]:4711"`}, "192.0.2.1"},
+		{"Forwarded", []string{`for="[2001:db8:cafe::17]"`}, "2001:db8:cafe::17"},
+		{"Forwarded", []string{`for=unknown`}, "10.0.0.1"},
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = "10.0.0.1:1234"
+		r.Header.Set("Forwarded", "for=198.51.100.1")
+		r.Header.Del(tc.header)
+		for _, v := range tc.values {
+			r.Header.Add(tc.header, v)
+		}
+		f := &Filter{TrustedProxies: trusted, Header: tc.header}
+		assert.Equal(t, netip.MustParseAddr(tc.expect), f.ClientIP(r), "%s: %q", tc.header, tc.values)
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	r.Header.Set("X-Forwarded-For", "192.0.2.1")
+	assert.Equal(t, netip.MustParseAddr("10.0.0.1"), (&Filter{}).ClientIP(r))
+}
+
+func TestRealIP(t *testing.T) {
+	trusted := newTrie("10.0.0.0/8")
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	r.Header.Set("X-Forwarded-For", "198.51.100.1, 192.0.2.1, 10.0.0.2")
+	r.Header.Set("Forwarded", "for=203.0.113.1")
+	assert.Equal(t, netip.MustParseAddr("192.0.2.1"), RealIP(r, trusted))
+	assert.Equal(t, netip.MustParseAddr("10.0.0.1"), RealIP(r, nil))
+
+	r.Header.Set("X-Forwarded-For", "10.1.1.1, 10.0.0.2")
+	assert.Equal(t, netip.MustParseAddr("10.1.1.1"), RealIP(r, trusted))
+
+	r.RemoteAddr = "192.0.2.9:1234"
+	assert.Equal(t, netip.MustParseAddr("192.0.2.9"), RealIP(r, trusted))
+}
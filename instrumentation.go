@@ -0,0 +1,78 @@
+package iptrie
+
+import (
+	"fmt"
+	"net/netip"
+	"time"
+)
+
+// LookupOp identifies the method which performed a lookup reported to Instrumentation.
+type LookupOp uint8
+
+const (
+	LookupFind LookupOp = iota + 1
+	LookupFindLargest
+	LookupContains
+)
+
+func (op LookupOp) String() string {
+	switch op {
+	case LookupFind:
+		return "find"
+	case LookupFindLargest:
+		return "find_largest"
+	case LookupContains:
+		return "contains"
+	}
+	return fmt.Sprintf("LookupOp(%d)", uint8(op))
+}
+
+// Instrumentation receives the operations performed on a trie, allowing metrics to be collected without wrapping the
+// trie. See SetInstrumentation.
+//
+// Methods are called synchronously after each operation completes, and may be called concurrently by concurrent
+// readers, so they should be fast and safe for concurrent use. They must not modify the trie.
+type Instrumentation interface {
+	// Lookup is called for each call of Find, FindLargest and Contains, with whether an entry matched. A lookup which
+	// returns the value set with SetDefault is not considered to match.
+	Lookup(op LookupOp, hit bool, elapsed time.Duration)
+	// Insert is called for each insert, including those through Apply.
+	Insert(network netip.Prefix, elapsed time.Duration)
+	// Remove is called for each removal, with whether an entry was removed.
+	Remove(network netip.Prefix, removed bool, elapsed time.Duration)
+	// Walk is called for each call of Walk, WalkCtx and their variants such as WalkMaxBits, with the number of entries
+	// visited.
+	Walk(entries int, elapsed time.Duration)
+}
+
+// SetInstrumentation sets the Instrumentation to receive the operations performed on the trie. Networks are reported
+// normalized to IPv6. Passing nil disables instrumentation.
+//
+// Snapshots report to the same Instrumentation, as do the RCUTrie and VersionedTrie created from the trie with
+// NewRCUTrieFrom and NewVersionedTrieFrom. While instrumentation is set, operations are timed, so Find, FindLargest and
+// Contains are slower.
+func (pt *Trie) SetInstrumentation(instr Instrumentation) {
+	pt.instr = instr
+}
+
+// instrumentWalk returns fn wrapped to count the entries visited by a walk, along with a function to be called when the
+// walk is finished to report it.
+func (pt *Trie) instrumentWalk(fn func(network netip.Prefix, value any) bool) (func(netip.Prefix, any) bool, func()) {
+	start := time.Now()
+	entries := 0
+	counted := func(network netip.Prefix, value any) bool {
+		entries++
+		return fn(network, value)
+	}
+	return counted, func() {
+		pt.instr.Walk(entries, time.Since(start))
+	}
+}
+
+// SetInstrumentation sets the Instrumentation to receive the operations performed on the trie. See
+// Trie.SetInstrumentation.
+func (rt *RCUTrie) SetInstrumentation(instr Instrumentation) {
+	rt.update(func(pt *Trie) {
+		pt.SetInstrumentation(instr)
+	})
+}
@@ -0,0 +1,103 @@
+package iptrie
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingInstrumentation struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (ri *recordingInstrumentation) record(format string, args ...any) {
+	ri.mu.Lock()
+	defer ri.mu.Unlock()
+	ri.events = append(ri.events, fmt.Sprintf(format, args...))
+}
+
+func (ri *recordingInstrumentation) Lookup(op LookupOp, hit bool, elapsed time.Duration) {
+	ri.record("%s %t", op, hit)
+}
+
+func (ri *recordingInstrumentation) Insert(network netip.Prefix, elapsed time.Duration) {
+	ri.record("insert %s", network)
+}
+
+func (ri *recordingInstrumentation) Remove(network netip.Prefix, removed bool, elapsed time.Duration) {
+	ri.record("remove %s %t", network, removed)
+}
+
+func (ri *recordingInstrumentation) Walk(entries int, elapsed time.Duration) {
+	ri.record("walk %d", entries)
+}
+
+func TestTrieSetInstrumentation(t *testing.T) {
+	trie := NewTrie()
+	trie.Insert(netip.MustParsePrefix("192.0.2.0/24"), "x")
+	ri := &recordingInstrumentation{}
+	trie.SetInstrumentation(ri)
+	trie.SetDefault("default")
+
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	trie.Insert(netip.MustParsePrefix("2001:db8::/32"), "b")
+	assert.Equal(t, "a", trie.Find(netip.MustParseAddr("10.0.0.1")))
+	assert.Equal(t, "default", trie.Find(netip.MustParseAddr("198.51.100.1")))
+	assert.Equal(t, "b", trie.FindLargest(netip.MustParseAddr("2001:db8::1")))
+	assert.False(t, trie.Contains(netip.MustParseAddr("2001:db9::1")))
+	trie.Walk(func(netip.Prefix, any) bool { return true })
+	trie.Walk(func(netip.Prefix, any) bool { return false })
+	require.NoError(t, trie.WalkCtx(context.Background(), func(netip.Prefix, any) bool { return true }))
+	trie.Remove(netip.MustParsePrefix("10.0.0.0/8"))
+	trie.Remove(netip.MustParsePrefix("10.0.0.0/8"))
+	trie.Snapshot().Find(netip.MustParseAddr("192.0.2.1"))
+
+	trie.SetInstrumentation(nil)
+	trie.Find(netip.MustParseAddr("192.0.2.1"))
+
+	assert.Equal(t, []string{
+		"insert ::ffff:10.0.0.0/104",
+		"insert 2001:db8::/32",
+		"find true",
+		"find false",
+		"find_largest true",
+		"contains false",
+		"walk 3",
+		"walk 1",
+		"walk 3",
+		"remove ::ffff:10.0.0.0/104 true",
+		"remove ::ffff:10.0.0.0/104 false",
+		"find true",
+	}, ri.events)
+}
+
+func TestTrieSetInstrumentationTracked(t *testing.T) {
+	trie := NewTrie()
+	trie.SetTrackEntries(true)
+	ri := &recordingInstrumentation{}
+	trie.SetInstrumentation(ri)
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	trie.Find(netip.MustParseAddr("10.0.0.1"))
+	trie.Find(netip.MustParseAddr("192.0.2.1"))
+
+	assert.Equal(t, []string{"insert ::ffff:10.0.0.0/104", "find true", "find false"}, ri.events)
+	info, _ := trie.EntryInfo(netip.MustParsePrefix("10.0.0.0/8"))
+	assert.Equal(t, uint64(1), info.Hits)
+}
+
+func TestRCUTrieSetInstrumentation(t *testing.T) {
+	rt := NewRCUTrie()
+	ri := &recordingInstrumentation{}
+	rt.SetInstrumentation(ri)
+	rt.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	rt.Find(netip.MustParseAddr("10.0.0.1"))
+
+	assert.Equal(t, []string{"insert ::ffff:10.0.0.0/104", "find true"}, ri.events)
+}
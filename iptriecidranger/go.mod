@@ -0,0 +1,19 @@
+module github.com/phemmer/go-iptrie/iptriecidranger
+
+go 1.21.1
+
+require (
+	github.com/phemmer/go-iptrie v0.0.0
+	github.com/stretchr/testify v1.9.0
+	github.com/yl2chen/cidranger v1.0.2
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+// The module uses APIs of go-iptrie which are not yet in a release, so it's built against the parent directory until
+// one including them is published.
+replace github.com/phemmer/go-iptrie => ../
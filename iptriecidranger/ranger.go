@@ -0,0 +1,143 @@
+// Package iptriecidranger provides an implementation of cidranger.Ranger backed by an iptrie.Trie, allowing users of
+// github.com/yl2chen/cidranger to switch by replacing cidranger.NewPCTrieRanger with NewRanger.
+//
+// It is a separate module, so that using iptrie does not require depending on cidranger.
+package iptriecidranger
+
+import (
+	"net"
+	"net/netip"
+
+	"github.com/phemmer/go-iptrie"
+	"github.com/yl2chen/cidranger"
+)
+
+// Ranger implements cidranger.Ranger, storing each cidranger.RangerEntry as the value of its network.
+//
+// As with the rangers of cidranger, IPv4 and IPv6 networks are kept apart: an IPv6 network such as ::/0 does not
+// contain any IPv4 address. A Ranger is not safe for concurrent use.
+type Ranger struct {
+	trie *iptrie.Trie
+	len  int
+}
+
+var _ cidranger.Ranger = (*Ranger)(nil)
+
+// NewRanger creates a new Ranger. It is a replacement for cidranger.NewPCTrieRanger.
+func NewRanger() cidranger.Ranger {
+	return &Ranger{trie: iptrie.NewTrie()}
+}
+
+// prefix converts network to a normalized netip.Prefix.
+func prefix(network net.IPNet) (netip.Prefix, error) {
+	a, err := addr(network.IP)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	ones, bits := network.Mask.Size()
+	if bits == 0 {
+		return netip.Prefix{}, cidranger.ErrInvalidNetworkInput
+	}
+	// An IPv4 mask is relative to the IPv4 portion of the address.
+	p := netip.PrefixFrom(a, ones+128-bits)
+	if !p.IsValid() {
+		return netip.Prefix{}, cidranger.ErrInvalidNetworkInput
+	}
+	return p.Masked(), nil
+}
+
+// addr converts ip to a normalized netip.Addr.
+func addr(ip net.IP) (netip.Addr, error) {
+	a, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return netip.Addr{}, cidranger.ErrInvalidNetworkNumberInput
+	}
+	return addr4In6(a), nil
+}
+
+// addr4In6 returns the IPv4-mapped IPv6 form of a, as used by iptrie for IPv4 addresses.
+func addr4In6(a netip.Addr) netip.Addr {
+	if a.Is4() {
+		return netip.AddrFrom16(a.As16())
+	}
+	return a
+}
+
+// isV4 indicates whether the normalized network is an IPv4 network.
+func isV4(p netip.Prefix) bool {
+	return p.Bits() >= 96 && p.Addr().Is4In6()
+}
+
+// Insert inserts the entry, replacing any existing entry with the same network.
+func (r *Ranger) Insert(entry cidranger.RangerEntry) error {
+	p, err := prefix(entry.Network())
+	if err != nil {
+		return err
+	}
+	if _, ok := r.trie.EntryInfo(p); !ok {
+		r.len++
+	}
+	r.trie.Insert(p, entry)
+	return nil
+}
+
+// Remove removes the entry with the given network, returning it, or nil if there is none.
+func (r *Ranger) Remove(network net.IPNet) (cidranger.RangerEntry, error) {
+	p, err := prefix(network)
+	if err != nil {
+		return nil, err
+	}
+	v := r.trie.Remove(p)
+	if v == nil {
+		return nil, nil
+	}
+	r.len--
+	return v.(cidranger.RangerEntry), nil
+}
+
+// Contains indicates whether any entry contains ip.
+func (r *Ranger) Contains(ip net.IP) (bool, error) {
+	entries, err := r.ContainingNetworks(ip)
+	return len(entries) > 0, err
+}
+
+// ContainingNetworks returns the entries which contain ip, from the largest network to the smallest.
+func (r *Ranger) ContainingNetworks(ip net.IP) ([]cidranger.RangerEntry, error) {
+	a, err := addr(ip)
+	if err != nil {
+		return nil, err
+	}
+	v4 := a.Is4In6()
+	var entries []cidranger.RangerEntry
+	r.trie.WalkFilter(func(network netip.Prefix) bool {
+		return network.Contains(a)
+	}, func(network netip.Prefix, value any) bool {
+		if isV4(network) == v4 {
+			entries = append(entries, value.(cidranger.RangerEntry))
+		}
+		return true
+	})
+	return entries, nil
+}
+
+// CoveredNetworks returns the entries which are within the given network.
+func (r *Ranger) CoveredNetworks(network net.IPNet) ([]cidranger.RangerEntry, error) {
+	p, err := prefix(network)
+	if err != nil {
+		return nil, err
+	}
+	v4 := isV4(p)
+	var entries []cidranger.RangerEntry
+	r.trie.WalkFilter(p.Overlaps, func(network netip.Prefix, value any) bool {
+		if network.Bits() >= p.Bits() && p.Contains(network.Addr()) && isV4(network) == v4 {
+			entries = append(entries, value.(cidranger.RangerEntry))
+		}
+		return true
+	})
+	return entries, nil
+}
+
+// Len returns the number of entries.
+func (r *Ranger) Len() int {
+	return r.len
+}
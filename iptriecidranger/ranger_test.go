@@ -0,0 +1,88 @@
+package iptriecidranger
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yl2chen/cidranger"
+)
+
+func mustParseCIDR(s string) net.IPNet {
+	_, network, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return *network
+}
+
+func networks(entries []cidranger.RangerEntry) []string {
+	var networks []string
+	for _, entry := range entries {
+		network := entry.Network()
+		networks = append(networks, network.String())
+	}
+	return networks
+}
+
+// TestRangerCompat checks that Ranger behaves the same as the rangers of cidranger.
+func TestRangerCompat(t *testing.T) {
+	for name, newRanger := range map[string]func() cidranger.Ranger{
+		"iptrie":    NewRanger,
+		"cidranger": cidranger.NewPCTrieRanger,
+	} {
+		t.Run(name, func(t *testing.T) {
+			ranger := newRanger()
+			for _, cidr := range []string{
+				"0.0.0.0/0",
+				"10.0.0.0/8",
+				"10.1.0.0/16",
+				"10.1.2.0/24",
+				"192.0.2.0/24",
+				"::/0",
+				"2001:db8::/32",
+				"2001:db8:1::/48",
+			} {
+				require.NoError(t, ranger.Insert(cidranger.NewBasicRangerEntry(mustParseCIDR(cidr))))
+			}
+			require.NoError(t, ranger.Insert(cidranger.NewBasicRangerEntry(mustParseCIDR("10.1.0.0/16"))))
+			assert.Equal(t, 8, ranger.Len())
+
+			entries, err := ranger.ContainingNetworks(net.ParseIP("10.1.2.3"))
+			require.NoError(t, err)
+			assert.Equal(t, []string{"0.0.0.0/0", "10.0.0.0/8", "10.1.0.0/16", "10.1.2.0/24"}, networks(entries))
+
+			entries, err = ranger.ContainingNetworks(net.ParseIP("2001:db8:1::1"))
+			require.NoError(t, err)
+			assert.Equal(t, []string{"::/0", "2001:db8::/32", "2001:db8:1::/48"}, networks(entries))
+
+			contains, err := ranger.Contains(net.ParseIP("198.51.100.1"))
+			require.NoError(t, err)
+			assert.True(t, contains)
+
+			entries, err = ranger.CoveredNetworks(mustParseCIDR("10.0.0.0/8"))
+			require.NoError(t, err)
+			assert.ElementsMatch(t, []string{"10.0.0.0/8", "10.1.0.0/16", "10.1.2.0/24"}, networks(entries))
+
+			entries, err = ranger.CoveredNetworks(*cidranger.AllIPv6)
+			require.NoError(t, err)
+			assert.ElementsMatch(t, []string{"::/0", "2001:db8::/32", "2001:db8:1::/48"}, networks(entries))
+
+			entry, err := ranger.Remove(mustParseCIDR("0.0.0.0/0"))
+			require.NoError(t, err)
+			assert.Equal(t, "0.0.0.0/0", networks([]cidranger.RangerEntry{entry})[0])
+			entry, err = ranger.Remove(mustParseCIDR("0.0.0.0/0"))
+			require.NoError(t, err)
+			assert.Nil(t, entry)
+			assert.Equal(t, 7, ranger.Len())
+
+			contains, err = ranger.Contains(net.ParseIP("198.51.100.1"))
+			require.NoError(t, err)
+			assert.False(t, contains)
+
+			_, err = ranger.Contains(net.IP{1, 2, 3})
+			assert.ErrorIs(t, err, cidranger.ErrInvalidNetworkNumberInput)
+		})
+	}
+}
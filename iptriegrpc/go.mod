@@ -0,0 +1,25 @@
+module github.com/phemmer/go-iptrie/iptriegrpc
+
+go 1.21.1
+
+require (
+	github.com/phemmer/go-iptrie v0.0.0
+	github.com/stretchr/testify v1.9.0
+	google.golang.org/grpc v1.62.1
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/golang/protobuf v1.5.3 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/net v0.20.0 // indirect
+	golang.org/x/sys v0.16.0 // indirect
+	golang.org/x/text v0.14.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240123012728-ef4313101c80 // indirect
+	google.golang.org/protobuf v1.32.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+// The module uses APIs of go-iptrie which are not yet in a release, so it's built against the parent directory until
+// one including them is published.
+replace github.com/phemmer/go-iptrie => ../
@@ -0,0 +1,128 @@
+// Package iptriegrpc provides gRPC server interceptors which allow or deny calls based on the peer's IP address, using
+// tries of networks.
+//
+// It is a separate module, so that using iptrie does not require depending on gRPC.
+package iptriegrpc
+
+import (
+	"context"
+	"net"
+	"net/netip"
+
+	"github.com/phemmer/go-iptrie"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Filter rejects calls from peers not permitted by its tries with codes.PermissionDenied. It is created with a
+// composite literal, and installed with its interceptors:
+//
+//	f := &iptriegrpc.Filter{Allow: tenants}
+//	srv := grpc.NewServer(
+//		grpc.UnaryInterceptor(f.UnaryServerInterceptor()),
+//		grpc.StreamInterceptor(f.StreamServerInterceptor()),
+//	)
+//
+// Calls from a peer without an IP address, such as over a Unix socket, are rejected. The fields must not be modified once
+// the interceptors are in use. The tries may be modified concurrently with calls if they support it, such as
+// iptrie.RCUTrie.
+type Filter struct {
+	// Allow lists the networks of the peers which are permitted. If nil, all peers not denied are permitted.
+	//
+	// The value of the most specific network containing the peer's address (as returned by Find) is attached to the
+	// context of the call, such as to identify a tenant or rate class. See WithValue.
+	Allow iptrie.Finder
+	// Deny lists the networks of the peers which are rejected, taking precedence over Allow.
+	Deny iptrie.Finder
+
+	// WithValue returns the context for a permitted call, given the value from Allow for the peer's address. If nil, the
+	// value is attached to the context so it can be retrieved with Value. WithValue isn't called if Allow is nil.
+	WithValue func(ctx context.Context, value any) context.Context
+}
+
+type valueKey struct{}
+
+// Value returns the value from Filter.Allow attached to the context of a call permitted by a Filter, and whether there
+// is one.
+func Value(ctx context.Context) (any, bool) {
+	v, ok := ctx.Value(valueKey{}).(allowValue)
+	return v.value, ok
+}
+
+// allowValue wraps the value attached to a context, so that a nil value can be distinguished from its absence.
+type allowValue struct {
+	value any
+}
+
+// PeerAddr returns the IP address of the peer of the call with the given context. The zero netip.Addr is returned if
+// the peer doesn't have an IP address.
+func PeerAddr(ctx context.Context) netip.Addr {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return netip.Addr{}
+	}
+	if tcpAddr, ok := p.Addr.(*net.TCPAddr); ok {
+		return tcpAddr.AddrPort().Addr().Unmap()
+	}
+	addrPort, err := netip.ParseAddrPort(p.Addr.String())
+	if err != nil {
+		return netip.Addr{}
+	}
+	return addrPort.Addr().Unmap()
+}
+
+// check returns the context to continue the call with, or an error if the call is rejected.
+func (f *Filter) check(ctx context.Context) (context.Context, error) {
+	ip := PeerAddr(ctx)
+	if !ip.IsValid() {
+		return nil, status.Error(codes.PermissionDenied, "peer has no IP address")
+	}
+	if f.Deny != nil && f.Deny.Contains(ip) {
+		return nil, status.Errorf(codes.PermissionDenied, "peer %s is denied", ip)
+	}
+	if f.Allow == nil {
+		return ctx, nil
+	}
+	if !f.Allow.Contains(ip) {
+		return nil, status.Errorf(codes.PermissionDenied, "peer %s is not allowed", ip)
+	}
+	value := f.Allow.Find(ip)
+	if f.WithValue != nil {
+		return f.WithValue(ctx, value), nil
+	}
+	return context.WithValue(ctx, valueKey{}, allowValue{value}), nil
+}
+
+// UnaryServerInterceptor returns an interceptor which applies the filter to unary calls.
+func (f *Filter) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := f.check(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns an interceptor which applies the filter to streaming calls.
+func (f *Filter) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := f.check(ss.Context())
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// serverStream is a grpc.ServerStream with a replaced context.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (ss *serverStream) Context() context.Context {
+	return ss.ctx
+}
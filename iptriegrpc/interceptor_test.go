@@ -0,0 +1,114 @@
+package iptriegrpc
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/phemmer/go-iptrie"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func peerContext(addr net.Addr) context.Context {
+	return peer.NewContext(context.Background(), &peer.Peer{Addr: addr})
+}
+
+func tcpPeer(s string) context.Context {
+	return peerContext(net.TCPAddrFromAddrPort(netip.MustParseAddrPort(s)))
+}
+
+func TestFilterUnaryServerInterceptor(t *testing.T) {
+	allow := iptrie.NewTrie()
+	allow.Insert(netip.MustParsePrefix("10.0.0.0/8"), "tenant-a")
+	allow.Insert(netip.MustParsePrefix("10.1.0.0/16"), "tenant-b")
+	allow.Insert(netip.MustParsePrefix("2001:db8::/32"), nil)
+	deny := iptrie.NewTrie()
+	deny.Insert(netip.MustParsePrefix("10.9.0.0/16"), nil)
+	f := &Filter{Allow: allow, Deny: deny}
+	interceptor := f.UnaryServerInterceptor()
+
+	call := func(ctx context.Context) (any, error) {
+		return interceptor(ctx, "req", &grpc.UnaryServerInfo{}, func(ctx context.Context, req any) (any, error) {
+			v, ok := Value(ctx)
+			assert.True(t, ok)
+			return v, nil
+		})
+	}
+
+	v, err := call(tcpPeer("10.2.0.1:1234"))
+	require.NoError(t, err)
+	assert.Equal(t, "tenant-a", v)
+
+	v, err = call(tcpPeer("[::ffff:10.1.0.1]:1234"))
+	require.NoError(t, err)
+	assert.Equal(t, "tenant-b", v)
+
+	v, err = call(peerContext(&net.UDPAddr{IP: net.ParseIP("2001:db8::1"), Port: 53}))
+	require.NoError(t, err)
+	assert.Nil(t, v)
+
+	for _, ctx := range []context.Context{
+		tcpPeer("10.9.0.1:1234"),
+		tcpPeer("192.0.2.1:1234"),
+		peerContext(&net.UnixAddr{Name: "/run/sock", Net: "unix"}),
+		context.Background(),
+	} {
+		_, err = call(ctx)
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	}
+}
+
+type testServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (ss testServerStream) Context() context.Context {
+	return ss.ctx
+}
+
+func TestFilterStreamServerInterceptor(t *testing.T) {
+	allow := iptrie.NewRCUTrie()
+	allow.Insert(netip.MustParsePrefix("10.0.0.0/8"), 3)
+	type rateClassKey struct{}
+	f := &Filter{
+		Allow: allow,
+		WithValue: func(ctx context.Context, value any) context.Context {
+			return context.WithValue(ctx, rateClassKey{}, value)
+		},
+	}
+	interceptor := f.StreamServerInterceptor()
+
+	var rateClass any
+	handler := func(srv any, ss grpc.ServerStream) error {
+		rateClass = ss.Context().Value(rateClassKey{})
+		return nil
+	}
+	require.NoError(t, interceptor(nil, testServerStream{ctx: tcpPeer("10.0.0.1:1234")}, &grpc.StreamServerInfo{}, handler))
+	assert.Equal(t, 3, rateClass)
+
+	err := interceptor(nil, testServerStream{ctx: tcpPeer("192.0.2.1:1234")}, &grpc.StreamServerInfo{}, handler)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestFilterDenyOnly(t *testing.T) {
+	deny := iptrie.NewTrie()
+	deny.Insert(netip.MustParsePrefix("192.0.2.0/24"), nil)
+	interceptor := (&Filter{Deny: deny}).UnaryServerInterceptor()
+	handler := func(ctx context.Context, req any) (any, error) {
+		_, ok := Value(ctx)
+		assert.False(t, ok)
+		return "ok", nil
+	}
+
+	_, err := interceptor(tcpPeer("198.51.100.1:1234"), nil, &grpc.UnaryServerInfo{}, handler)
+	assert.NoError(t, err)
+	_, err = interceptor(tcpPeer("192.0.2.1:1234"), nil, &grpc.UnaryServerInfo{}, handler)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
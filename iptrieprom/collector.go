@@ -0,0 +1,140 @@
+// Package iptrieprom exposes metrics of an iptrie.Trie or iptrie.RCUTrie to Prometheus.
+//
+// It is a separate module, so that using iptrie does not require depending on the Prometheus client.
+package iptrieprom
+
+import (
+	"net/netip"
+	"time"
+
+	"github.com/phemmer/go-iptrie"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultBuckets are the default buckets of the operation duration histogram, in seconds, ranging from 100ns to about
+// 26ms. Lookups typically take well under a microsecond.
+var DefaultBuckets = prometheus.ExponentialBuckets(100e-9, 4, 10)
+
+// Opts configures a Collector.
+type Opts struct {
+	// Namespace and Subsystem prefix the metric names, as in prometheus.Opts.
+	Namespace string
+	Subsystem string
+	// ConstLabels are added to every metric, such as to distinguish multiple tries.
+	ConstLabels prometheus.Labels
+	// Buckets are the buckets of the operation duration histogram. If nil, DefaultBuckets is used.
+	Buckets []float64
+}
+
+// Collector is a prometheus.Collector exposing the following metrics of a trie:
+//
+//   - iptrie_entries: The number of entries.
+//   - iptrie_nodes: The number of nodes, including implicit nodes.
+//   - iptrie_lookups_total: The number of lookups, by op (find, find_largest or contains) and result (hit or miss).
+//     The lookup rate and hit ratio can be derived from this.
+//   - iptrie_operation_duration_seconds: A histogram of the duration of each lookup, insert, remove and walk, by op.
+//
+// The counts of entries and nodes are gathered on each collection, which visits every node of the trie.
+type Collector struct {
+	load     func() *iptrie.Trie
+	entries  *prometheus.Desc
+	nodes    *prometheus.Desc
+	lookups  *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+// NewCollector creates a Collector for the trie, and sets it as the trie's instrumentation with
+// iptrie.Trie.SetInstrumentation.
+//
+// The trie must not be modified concurrently with collection, as with any other read of a Trie. Use NewRCUCollector for
+// a trie which is modified concurrently.
+func NewCollector(trie *iptrie.Trie, opts Opts) *Collector {
+	c := newCollector(func() *iptrie.Trie { return trie }, opts)
+	trie.SetInstrumentation(c)
+	return c
+}
+
+// NewRCUCollector creates a Collector for the RCUTrie, and sets it as the trie's instrumentation with
+// iptrie.RCUTrie.SetInstrumentation.
+func NewRCUCollector(trie *iptrie.RCUTrie, opts Opts) *Collector {
+	c := newCollector(trie.Load, opts)
+	trie.SetInstrumentation(c)
+	return c
+}
+
+func newCollector(load func() *iptrie.Trie, opts Opts) *Collector {
+	buckets := opts.Buckets
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	return &Collector{
+		load: load,
+		entries: prometheus.NewDesc(
+			prometheus.BuildFQName(opts.Namespace, opts.Subsystem, "iptrie_entries"),
+			"The number of entries in the trie.",
+			nil, opts.ConstLabels,
+		),
+		nodes: prometheus.NewDesc(
+			prometheus.BuildFQName(opts.Namespace, opts.Subsystem, "iptrie_nodes"),
+			"The number of nodes in the trie, including implicit nodes.",
+			nil, opts.ConstLabels,
+		),
+		lookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   opts.Namespace,
+			Subsystem:   opts.Subsystem,
+			Name:        "iptrie_lookups_total",
+			Help:        "The number of lookups of the trie, by whether an entry matched.",
+			ConstLabels: opts.ConstLabels,
+		}, []string{"op", "result"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   opts.Namespace,
+			Subsystem:   opts.Subsystem,
+			Name:        "iptrie_operation_duration_seconds",
+			Help:        "The duration of operations on the trie.",
+			ConstLabels: opts.ConstLabels,
+			Buckets:     buckets,
+		}, []string{"op"}),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.entries
+	ch <- c.nodes
+	c.lookups.Describe(ch)
+	c.duration.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	nodes, entries := c.load().Count()
+	ch <- prometheus.MustNewConstMetric(c.entries, prometheus.GaugeValue, float64(entries))
+	ch <- prometheus.MustNewConstMetric(c.nodes, prometheus.GaugeValue, float64(nodes))
+	c.lookups.Collect(ch)
+	c.duration.Collect(ch)
+}
+
+// Lookup implements iptrie.Instrumentation.
+func (c *Collector) Lookup(op iptrie.LookupOp, hit bool, elapsed time.Duration) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	c.lookups.WithLabelValues(op.String(), result).Inc()
+	c.duration.WithLabelValues(op.String()).Observe(elapsed.Seconds())
+}
+
+// Insert implements iptrie.Instrumentation.
+func (c *Collector) Insert(network netip.Prefix, elapsed time.Duration) {
+	c.duration.WithLabelValues("insert").Observe(elapsed.Seconds())
+}
+
+// Remove implements iptrie.Instrumentation.
+func (c *Collector) Remove(network netip.Prefix, removed bool, elapsed time.Duration) {
+	c.duration.WithLabelValues("remove").Observe(elapsed.Seconds())
+}
+
+// Walk implements iptrie.Instrumentation.
+func (c *Collector) Walk(entries int, elapsed time.Duration) {
+	c.duration.WithLabelValues("walk").Observe(elapsed.Seconds())
+}
@@ -0,0 +1,50 @@
+package iptrieprom
+
+import (
+	"net/netip"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/phemmer/go-iptrie"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollector(t *testing.T) {
+	trie := iptrie.NewTrie()
+	c := NewCollector(trie, Opts{Namespace: "test"})
+	reg := prometheus.NewPedanticRegistry()
+	require.NoError(t, reg.Register(c))
+
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	trie.Insert(netip.MustParsePrefix("10.1.0.0/16"), "b")
+	trie.Insert(netip.MustParsePrefix("192.0.2.0/24"), "c")
+	trie.Find(netip.MustParseAddr("10.1.0.1"))
+	trie.Find(netip.MustParseAddr("10.2.0.1"))
+	trie.Find(netip.MustParseAddr("198.51.100.1"))
+	trie.Contains(netip.MustParseAddr("192.0.2.1"))
+	trie.Remove(netip.MustParsePrefix("10.1.0.0/16"))
+
+	nodes, _ := trie.Count()
+	expected := `
+# HELP test_iptrie_entries The number of entries in the trie.
+# TYPE test_iptrie_entries gauge
+test_iptrie_entries 2
+# HELP test_iptrie_lookups_total The number of lookups of the trie, by whether an entry matched.
+# TYPE test_iptrie_lookups_total counter
+test_iptrie_lookups_total{op="contains",result="hit"} 1
+test_iptrie_lookups_total{op="find",result="hit"} 2
+test_iptrie_lookups_total{op="find",result="miss"} 1
+# HELP test_iptrie_nodes The number of nodes in the trie, including implicit nodes.
+# TYPE test_iptrie_nodes gauge
+test_iptrie_nodes ` + strconv.Itoa(nodes) + `
+`
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expected),
+		"test_iptrie_entries", "test_iptrie_nodes", "test_iptrie_lookups_total"))
+
+	// The histogram has a series for each of find, contains, insert and remove.
+	assert.Equal(t, 4, testutil.CollectAndCount(c, "test_iptrie_operation_duration_seconds"))
+}
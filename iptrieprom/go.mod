@@ -0,0 +1,26 @@
+module github.com/phemmer/go-iptrie/iptrieprom
+
+go 1.21.1
+
+require (
+	github.com/phemmer/go-iptrie v0.0.0
+	github.com/prometheus/client_golang v1.19.1
+	github.com/stretchr/testify v1.9.0
+)
+
+require (
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/prometheus/client_model v0.5.0 // indirect
+	github.com/prometheus/common v0.48.0 // indirect
+	github.com/prometheus/procfs v0.12.0 // indirect
+	golang.org/x/sys v0.18.0 // indirect
+	google.golang.org/protobuf v1.33.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+// The module uses APIs of go-iptrie which are not yet in a release, so it's built against the parent directory until
+// one including them is published.
+replace github.com/phemmer/go-iptrie => ../
@@ -0,0 +1,61 @@
+//go:build go1.23
+
+package iptrie
+
+import (
+	"iter"
+	"net/netip"
+)
+
+// ContainingSeq is like ContainingNetworks, but returns an iterator over the networks and values of the entries
+// containing ip, from the largest network to the smallest. The entries are found as they are iterated over, so no slice
+// is allocated, and breaking out of the loop stops the lookup.
+//
+// Note: Inserted addresses are normalized to IPv6, so the networks will be IPv6 only, unless SetDenormalize is used.
+func (pt *Trie) ContainingSeq(ip netip.Addr) iter.Seq2[netip.Prefix, any] {
+	return func(yield func(netip.Prefix, any) bool) {
+		if pt.zoneExcluded(ip) {
+			return
+		}
+		ip128 := lookupAddr128(ip)
+		for n := &pt.node; n != nil && n.contains(ip128); n = n.children[n.discriminatorBit(ip128)] {
+			if n.value != nil && !yield(pt.resultNetwork(n), unempty(n.value)) {
+				return
+			}
+			if n.bits == 128 {
+				return
+			}
+		}
+	}
+}
+
+// CoveredSeq is like CoveredNetworks, but returns an iterator over the networks and values of the entries contained
+// within network, in the same order as Walk. The entries are found as they are iterated over, so no slice is allocated,
+// and breaking out of the loop stops the walk.
+//
+// Note: Inserted addresses are normalized to IPv6, so the networks will be IPv6 only, unless SetDenormalize is used.
+func (pt *Trie) CoveredSeq(network netip.Prefix) iter.Seq2[netip.Prefix, any] {
+	return func(yield func(netip.Prefix, any) bool) {
+		root := pt.coveredRoot(prefix128(normalizePrefix(network)))
+		if root == nil {
+			return
+		}
+		root.walk(func(n *node) bool {
+			return yield(pt.resultNetwork(n), unempty(n.value))
+		})
+	}
+}
+
+// ContainingSeq is like Trie.ContainingSeq, iterating over the version of the trie current when iteration starts.
+func (rt *RCUTrie) ContainingSeq(ip netip.Addr) iter.Seq2[netip.Prefix, any] {
+	return func(yield func(netip.Prefix, any) bool) {
+		rt.Load().ContainingSeq(ip)(yield)
+	}
+}
+
+// CoveredSeq is like Trie.CoveredSeq, iterating over the version of the trie current when iteration starts.
+func (rt *RCUTrie) CoveredSeq(network netip.Prefix) iter.Seq2[netip.Prefix, any] {
+	return func(yield func(netip.Prefix, any) bool) {
+		rt.Load().CoveredSeq(network)(yield)
+	}
+}
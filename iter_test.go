@@ -0,0 +1,84 @@
+//go:build go1.23
+
+package iptrie
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrieContainingSeq(t *testing.T) {
+	trie := NewTrie()
+	trie.SetDenormalize(true)
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	trie.Insert(netip.MustParsePrefix("10.1.0.0/16"), nil)
+	trie.Insert(netip.MustParsePrefix("10.1.2.0/24"), "c")
+	trie.Insert(netip.MustParsePrefix("10.2.0.0/16"), "d")
+
+	var networks []netip.Prefix
+	var values []any
+	for network, value := range trie.ContainingSeq(netip.MustParseAddr("10.1.2.3")) {
+		networks = append(networks, network)
+		values = append(values, value)
+	}
+	assert.Equal(t, trie.ContainingNetworks(netip.MustParseAddr("10.1.2.3")), networks)
+	assert.Equal(t, []any{"a", nil, "c"}, values)
+
+	networks = nil
+	for network := range trie.ContainingSeq(netip.MustParseAddr("10.1.2.3")) {
+		networks = append(networks, network)
+		if len(networks) == 2 {
+			break
+		}
+	}
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("10.1.0.0/16")}, networks)
+
+	for range trie.ContainingSeq(netip.MustParseAddr("192.0.2.1")) {
+		t.Fatal("unexpected entry")
+	}
+}
+
+func TestTrieCoveredSeq(t *testing.T) {
+	trie := NewTrie()
+	for _, network := range []string{"10.0.0.0/8", "10.1.0.0/16", "10.1.2.0/24", "10.2.0.0/16", "192.0.2.0/24", "2001:db8::/32"} {
+		trie.Insert(netip.MustParsePrefix(network), network)
+	}
+
+	for _, cidr := range []string{"10.0.0.0/8", "10.1.0.0/15", "0.0.0.0/0", "::/0", "10.1.2.3/32", "198.51.100.0/24"} {
+		network := netip.MustParsePrefix(cidr)
+		var networks []netip.Prefix
+		for n, value := range trie.CoveredSeq(network) {
+			networks = append(networks, n)
+			assert.Equal(t, denormalizePrefix(n).String(), value)
+		}
+		assert.Equal(t, trie.CoveredNetworks(network), networks, cidr)
+	}
+
+	var count int
+	for range trie.CoveredSeq(netip.MustParsePrefix("10.0.0.0/8")) {
+		if count++; count == 2 {
+			break
+		}
+	}
+	assert.Equal(t, 2, count)
+}
+
+func TestRCUTrieSeq(t *testing.T) {
+	rt := NewRCUTrie()
+	rt.Insert(netip.MustParsePrefix("10.0.0.0/8"), 1)
+	rt.Insert(netip.MustParsePrefix("10.1.0.0/16"), 2)
+
+	var values []any
+	for _, value := range rt.ContainingSeq(netip.MustParseAddr("10.1.0.1")) {
+		values = append(values, value)
+	}
+	assert.Equal(t, []any{1, 2}, values)
+
+	values = nil
+	for _, value := range rt.CoveredSeq(netip.MustParsePrefix("10.0.0.0/8")) {
+		values = append(values, value)
+	}
+	assert.Equal(t, []any{1, 2}, values)
+}
@@ -0,0 +1,75 @@
+package iptrie
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/netip"
+)
+
+// pfxEntry is a single entry of a trie, in the form it is exchanged with external systems.
+type pfxEntry struct {
+	Prefix netip.Prefix `json:"prefix"`
+	Value  any          `json:"value"`
+}
+
+// MarshalJSON implements json.Marshaler, encoding the trie as an array of {"prefix": "...", "value": ...} objects in
+// depth order. IPv4 networks are encoded in their IPv4 form.
+func (pt *Trie) MarshalJSON() ([]byte, error) {
+	entries := []pfxEntry{}
+	pt.walk(func(n *node) bool {
+		entries = append(entries, pfxEntry{Prefix: denormalizePrefix(n.network()), Value: unempty(n.value)})
+		return true
+	})
+	return json.Marshal(entries)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, replacing the contents of the trie with the entries produced by
+// MarshalJSON. Values are decoded in the same manner as json.Unmarshal decodes into an interface value.
+//
+// The trie is only modified if data is successfully decoded.
+func (pt *Trie) UnmarshalJSON(data []byte) error {
+	var entries []pfxEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+	for i, entry := range entries {
+		if !entry.Prefix.IsValid() {
+			return fmt.Errorf("iptrie: entry %d has no valid prefix", i)
+		}
+	}
+	pt.replaceRoot(pt.entriesRoot(entries))
+	return nil
+}
+
+// jsonNode is the JSON representation of a node within a trie, as produced by TreeJSON.
+type jsonNode struct {
+	Prefix   netip.Prefix `json:"prefix"`
+	Implicit bool         `json:"implicit,omitempty"`
+	Value    any          `json:"value,omitempty"`
+	Children []*jsonNode  `json:"children,omitempty"`
+}
+
+// TreeJSON returns the structure of the trie as nested JSON objects of the form
+// {"prefix": "...", "value": ..., "children": [...]}, for use in visualization and debugging.
+//
+// Unlike MarshalJSON, the result includes the implicit nodes which exist as parents for multiple entries. These are
+// flagged with "implicit": true, and have no value.
+//
+// Note: Addresses are normalized to IPv6.
+func (pt *Trie) TreeJSON() ([]byte, error) {
+	return json.Marshal(pt.node.jsonNode())
+}
+
+func (pt *node) jsonNode() *jsonNode {
+	jn := &jsonNode{
+		Prefix:   pt.network(),
+		Implicit: pt.value == nil,
+		Value:    unempty(pt.value),
+	}
+	for _, child := range pt.children {
+		if child != nil {
+			jn.Children = append(jn.Children, child.jsonNode())
+		}
+	}
+	return jn
+}
@@ -0,0 +1,68 @@
+package iptrie
+
+import (
+	"encoding/json"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrieMarshalJSON(t *testing.T) {
+	trie := NewTrie()
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	trie.Insert(netip.MustParsePrefix("10.1.0.0/16"), map[string]any{"b": 1.0})
+	trie.Insert(netip.MustParsePrefix("2001:db8::/32"), nil)
+
+	data, err := json.Marshal(trie)
+	require.NoError(t, err)
+	assert.JSONEq(t, `[
+		{"prefix": "10.0.0.0/8", "value": "a"},
+		{"prefix": "10.1.0.0/16", "value": {"b": 1}},
+		{"prefix": "2001:db8::/32", "value": null}
+	]`, string(data))
+
+	loaded := NewTrie()
+	loaded.Insert(netip.MustParsePrefix("192.0.2.0/24"), "removed")
+	require.NoError(t, json.Unmarshal(data, loaded))
+	assert.Equal(t, trie.String(), loaded.String())
+	assert.Equal(t, map[string]any{"b": 1.0}, loaded.Find(netip.MustParseAddr("10.1.0.1")))
+	assert.Nil(t, loaded.Find(netip.MustParseAddr("192.0.2.1")))
+
+	data, err = json.Marshal(NewTrie())
+	require.NoError(t, err)
+	assert.Equal(t, "[]", string(data))
+}
+
+func TestTrieUnmarshalJSONInvalid(t *testing.T) {
+	trie := NewTrie()
+	trie.Insert(netip.MustParsePrefix("192.0.2.0/24"), "orig")
+
+	assert.Error(t, json.Unmarshal([]byte(`[{"prefix": "10.0.0.0/33"}]`), trie))
+	assert.Error(t, json.Unmarshal([]byte(`[{"value": 1}]`), trie))
+	assert.Error(t, json.Unmarshal([]byte(`{}`), trie))
+	assert.Equal(t, "orig", trie.Find(netip.MustParseAddr("192.0.2.1")))
+}
+
+func TestTrieTreeJSON(t *testing.T) {
+	trie := NewTrie()
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	trie.Insert(netip.MustParsePrefix("10.1.0.0/16"), "b")
+	trie.Insert(netip.MustParsePrefix("10.2.0.0/16"), "c")
+	trie.Insert(netip.MustParsePrefix("2001:db8::/32"), "d")
+
+	data, err := trie.TreeJSON()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"prefix": "::/0", "implicit": true, "children": [
+		{"prefix": "::/2", "implicit": true, "children": [
+			{"prefix": "::ffff:10.0.0.0/104", "value": "a", "children": [
+				{"prefix": "::ffff:10.0.0.0/110", "implicit": true, "children": [
+					{"prefix": "::ffff:10.1.0.0/112", "value": "b"},
+					{"prefix": "::ffff:10.2.0.0/112", "value": "c"}
+				]}
+			]},
+			{"prefix": "2001:db8::/32", "value": "d"}
+		]}
+	]}`, string(data))
+}
@@ -0,0 +1,59 @@
+package iptrie
+
+// relayoutClusterDepth is the number of levels below a node which Relayout places together with it.
+const relayoutClusterDepth = 2
+
+// Relayout copies all of the nodes of the trie into a single contiguous block of memory, ordered so that nodes are
+// placed close to their children.
+//
+// Nodes are placed in clusters, each containing a node's children and grandchildren in breadth-first order, so that the
+// two children of a node are always adjacent, and a lookup descending through a cluster stays within a few cache lines.
+// The clusters themselves are placed in breadth-first order. When a trie is built by inserting networks in random
+// order, its nodes are scattered across the heap, and following the pointers between them is the dominant cost of
+// lookups on large tries. Relayout is intended to be called once such a trie has been fully loaded.
+//
+// Go provides no way of issuing explicit prefetches during a descent, so the layout relies on adjacency instead: the
+// cache line holding a node often holds its children as well, and the hardware prefetcher covers accesses which stay
+// within a cluster.
+//
+// The memory of the block is only reclaimed once none of its nodes are referenced, so removing entries afterwards does
+// not free memory. Nodes shared with snapshots are left untouched.
+func (pt *Trie) Relayout() {
+	// The copies belong to a new generation, so that a TrieLoader holding the previous nodes starts over from the root.
+	pt.owner = pt.owner.fork()
+	nodes, _ := pt.node.count()
+	// The root is embedded within the Trie itself.
+	slab := make([]node, nodes-1)
+	place := func(src *node) *node {
+		n := &slab[0]
+		slab = slab[1:]
+		n.children = src.children
+		n.addr = src.addr
+		n.bits = src.bits
+		n.value = src.value
+		n.owner = pt.owner
+		return n
+	}
+
+	// clusters holds the nodes which have been placed, but whose children have not.
+	clusters := []*node{&pt.node}
+	for len(clusters) > 0 {
+		level := clusters[:1]
+		clusters = clusters[1:]
+		for depth := 0; depth < relayoutClusterDepth && len(level) > 0; depth++ {
+			var nextLevel []*node
+			for _, n := range level {
+				for bit, child := range n.children {
+					if child == nil {
+						continue
+					}
+					n.children[bit] = place(child)
+					nextLevel = append(nextLevel, n.children[bit])
+				}
+			}
+			level = nextLevel
+		}
+		clusters = append(clusters, level...)
+	}
+	pt.refreshV4()
+}
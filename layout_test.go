@@ -0,0 +1,80 @@
+package iptrie
+
+import (
+	"math/rand"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrieRelayout(t *testing.T) {
+	trie := NewTrie()
+	expected := NewTrie()
+	for i := 0; i < 1000; i++ {
+		network := GenLeafIPNet(GenIPV4())
+		trie.Insert(network, i)
+		expected.Insert(network, i)
+	}
+	for _, trie := range []*Trie{trie, expected} {
+		trie.Insert(netip.MustParsePrefix("::/0"), "default")
+		trie.Insert(netip.MustParsePrefix("2001:db8::/32"), "a")
+	}
+	snap := trie.Snapshot()
+	snapStr := snap.String()
+	networks := trie.CoveredNetworks(netip.MustParsePrefix("::/0"))
+
+	trie.Relayout()
+	assert.Equal(t, expected.String(), trie.String())
+	for _, network := range networks {
+		assert.Equal(t, expected.Find(network.Addr()), trie.Find(network.Addr()))
+	}
+
+	// Removals must still compress paths through the new nodes.
+	for _, network := range networks[:500] {
+		assert.Equal(t, expected.Remove(network), trie.Remove(network))
+	}
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), "b")
+	expected.Insert(netip.MustParsePrefix("10.0.0.0/8"), "b")
+	assert.Equal(t, expected.String(), trie.String())
+	assert.Equal(t, snapStr, snap.String())
+}
+
+func BenchmarkTrieRelayout_Find(b *testing.B) {
+	trie := NewTrie()
+	for i := 0; i < 1000000; i++ {
+		trie.Insert(netip.PrefixFrom(GenIPV4(), 8+rand.Intn(25)).Masked(), i)
+	}
+	lookups := make([]netip.Addr, 10000)
+	for i := range lookups {
+		lookups[i] = GenIPV4()
+	}
+
+	b.Run("before", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			trie.Find(lookups[n%len(lookups)])
+		}
+	})
+	trie.Relayout()
+	b.Run("after", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			trie.Find(lookups[n%len(lookups)])
+		}
+	})
+}
+
+func TestTrieRelayoutLoader(t *testing.T) {
+	trie := NewTrie()
+	loader := NewTrieLoader(trie)
+	loader.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	loader.Insert(netip.MustParsePrefix("10.1.0.0/16"), "b")
+	trie.Relayout()
+
+	// The loader's cached path holds the nodes from before the relayout, so must not be used.
+	loader.Insert(netip.MustParsePrefix("10.1.1.0/24"), "c")
+	loader.Insert(netip.MustParsePrefix("10.2.0.0/16"), "d")
+	assert.Equal(t, "c", trie.Find(netip.MustParseAddr("10.1.1.1")))
+	assert.Equal(t, "d", trie.Find(netip.MustParseAddr("10.2.0.1")))
+	_, entries := trie.Count()
+	assert.Equal(t, 4, entries)
+}
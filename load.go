@@ -0,0 +1,119 @@
+package iptrie
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// LoadCIDRList inserts entries read from r, which contains one entry per line.
+//
+// Everything following a '#' is treated as a comment, and blank lines are ignored. Each remaining line is passed to
+// valueFn with surrounding whitespace removed, which returns the network and value to insert. If valueFn is nil, each
+// line must contain just a network (or single address), which is inserted with a nil value.
+//
+// Loading stops at the first error, which identifies the line it occurred on. Entries from the preceding lines remain
+// inserted.
+func (pt *Trie) LoadCIDRList(r io.Reader, valueFn func(line string) (netip.Prefix, any, error)) error {
+	if valueFn == nil {
+		valueFn = func(line string) (netip.Prefix, any, error) {
+			network, err := parseCIDR(line)
+			return network, nil, err
+		}
+	}
+
+	return scanCIDRList(r, func(line string) error {
+		network, value, err := valueFn(line)
+		if err != nil {
+			return err
+		}
+		pt.Insert(network, value)
+		return nil
+	})
+}
+
+// LoadCIDRListFile is like LoadCIDRList, but reads the named file. Errors identify the file, as well as the line.
+func (pt *Trie) LoadCIDRListFile(name string, valueFn func(line string) (netip.Prefix, any, error)) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := pt.LoadCIDRList(f, valueFn); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// ParseCIDRValue parses a line of the form "network [value]", for use as the valueFn of LoadCIDRList. The network is
+// in CIDR notation, or a single address, and is separated from the value by whitespace. The value is the rest of the
+// line as a string, or nil if absent.
+func ParseCIDRValue(line string) (netip.Prefix, any, error) {
+	cidr, value := line, ""
+	if i := strings.IndexFunc(line, unicode.IsSpace); i >= 0 {
+		cidr, value = line[:i], strings.TrimSpace(line[i:])
+	}
+	network, err := parseCIDR(cidr)
+	if err != nil {
+		return netip.Prefix{}, nil, err
+	}
+	if value == "" {
+		return network, nil, nil
+	}
+	return network, value, nil
+}
+
+// scanCIDRList calls fn with each line of r, with comments and surrounding whitespace removed, and skipping blank
+// lines, as described by LoadCIDRList.
+func scanCIDRList(r io.Reader, fn func(line string) error) error {
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if err := fn(line); err != nil {
+			return fmt.Errorf("line %d: %w", lineNum, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// parseCIDR parses a network in CIDR notation, or a single address, which is treated as a network of just that
+// address.
+func parseCIDR(s string) (netip.Prefix, error) {
+	if !strings.Contains(s, "/") {
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+	return netip.ParsePrefix(s)
+}
+
+// appendRange appends the smallest list of networks covering the (normalized) addresses from first to last inclusive
+// to dst, in ascending order. first must not be greater than last.
+func appendRange(dst []netip.Prefix, first, last uint128) []netip.Prefix {
+	for {
+		// Widen the network for as long as first remains its first address, and it doesn't extend past last.
+		bits := uint8(128)
+		for bits > 0 && first.bitsClearedFrom(bits-1) == first && !last.less(first.bitsSetFrom(bits-1)) {
+			bits--
+		}
+		dst = append(dst, netip.PrefixFrom(addrFrom128(first), int(bits)))
+		end := first.bitsSetFrom(bits)
+		if end == last {
+			return dst
+		}
+		first = end.addOne()
+	}
+}
@@ -0,0 +1,89 @@
+package iptrie
+
+import (
+	"errors"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrieLoadCIDRList(t *testing.T) {
+	trie := NewTrie()
+	err := trie.LoadCIDRList(strings.NewReader(`# header comment
+
+10.0.0.0/8
+  192.0.2.1  # trailing comment
+2001:db8::/32
+`), nil)
+	require.NoError(t, err)
+	assert.Equal(t, []netip.Prefix{
+		netip.MustParsePrefix("::ffff:10.0.0.0/104"),
+		netip.MustParsePrefix("::ffff:192.0.2.1/128"),
+		netip.MustParsePrefix("2001:db8::/32"),
+	}, trie.appendEntries(nil))
+	assert.True(t, trie.Contains(netip.MustParseAddr("10.1.2.3")))
+}
+
+func TestTrieLoadCIDRListValueFn(t *testing.T) {
+	trie := NewTrie()
+	err := trie.LoadCIDRList(strings.NewReader("10.0.0.0/8 a\n10.1.0.0/16\tb\n"), func(line string) (netip.Prefix, any, error) {
+		fields := strings.Fields(line)
+		network, err := netip.ParsePrefix(fields[0])
+		return network, fields[1], err
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "a", trie.Find(netip.MustParseAddr("10.0.0.1")))
+	assert.Equal(t, "b", trie.Find(netip.MustParseAddr("10.1.0.1")))
+
+	errBad := errors.New("bad line")
+	err = trie.LoadCIDRList(strings.NewReader("# comment\n192.0.2.0/24\n\nbad\n198.51.100.0/24\n"), func(line string) (netip.Prefix, any, error) {
+		if line == "bad" {
+			return netip.Prefix{}, nil, errBad
+		}
+		network, err := netip.ParsePrefix(line)
+		return network, "c", err
+	})
+	assert.ErrorIs(t, err, errBad)
+	assert.ErrorContains(t, err, "line 4")
+	assert.True(t, trie.Contains(netip.MustParseAddr("192.0.2.1")))
+	assert.False(t, trie.Contains(netip.MustParseAddr("198.51.100.1")))
+}
+
+func TestTrieLoadCIDRListInvalid(t *testing.T) {
+	trie := NewTrie()
+	err := trie.LoadCIDRList(strings.NewReader("10.0.0.0/8\n10.0.0.0/33\n"), nil)
+	assert.ErrorContains(t, err, "line 2")
+}
+
+func TestParseCIDRValue(t *testing.T) {
+	network, value, err := ParseCIDRValue("10.0.0.0/8")
+	require.NoError(t, err)
+	assert.Equal(t, netip.MustParsePrefix("10.0.0.0/8"), network)
+	assert.Nil(t, value)
+
+	network, value, err = ParseCIDRValue("2001:db8::1 \tsome value")
+	require.NoError(t, err)
+	assert.Equal(t, netip.MustParsePrefix("2001:db8::1/128"), network)
+	assert.Equal(t, "some value", value)
+
+	_, _, err = ParseCIDRValue("10.0.0.0/33 a")
+	assert.Error(t, err)
+}
+
+func TestTrieLoadCIDRListFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "list")
+	require.NoError(t, os.WriteFile(name, []byte("10.0.0.0/8 a\n192.0.2.1\nbogus\n"), 0o644))
+
+	trie := NewTrie()
+	err := trie.LoadCIDRListFile(name, ParseCIDRValue)
+	assert.ErrorContains(t, err, name+": line 3: ")
+	assert.Equal(t, "a", trie.Find(netip.MustParseAddr("10.0.0.1")))
+	assert.True(t, trie.Contains(netip.MustParseAddr("192.0.2.1")))
+
+	assert.Error(t, trie.LoadCIDRListFile(filepath.Join(t.TempDir(), "missing"), nil))
+}
@@ -0,0 +1,168 @@
+package iptrie
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/phemmer/go-iptrie/bitstrie"
+)
+
+// macBits is the width of a MAC (EUI-48) address in bits.
+const macBits = 48
+
+// ErrInvalidMACPrefix is returned by ParseMACPrefix for a string which is not a valid MAC prefix.
+var ErrInvalidMACPrefix = errors.New("iptrie: invalid MAC prefix")
+
+// MACPrefix is a prefix of the MAC (EUI-48) address space, such as the 24-bit OUI assigned to a vendor. It is
+// comparable, and the zero value is the prefix of length 0, containing all addresses.
+type MACPrefix struct {
+	addr [6]byte
+	bits uint8
+}
+
+// MACPrefixFrom returns the prefix of the given length of addr, which must be a 6-byte MAC address. Bits of addr
+// after the prefix are cleared. ok is false if addr is not 6 bytes or bits is not between 0 and 48.
+func MACPrefixFrom(addr net.HardwareAddr, bits int) (pfx MACPrefix, ok bool) {
+	if len(addr) != 6 || bits < 0 || bits > macBits {
+		return pfx, false
+	}
+	key := bitstrie.KeyFromBytes(addr).Masked(bits)
+	copy(pfx.addr[:], key.Bytes(6))
+	pfx.bits = uint8(bits)
+	return pfx, true
+}
+
+// ParseMACPrefix parses a MAC prefix. The address may be given in full or as just its leading octets, separated by ':'
+// or '-', and is optionally followed by a '/' and the prefix length. If the length is omitted, it is the number of bits
+// given, so "00:1a:2b" is the 24-bit OUI 00:1a:2b:00:00:00/24, and a full address is a prefix of just that address.
+//
+// For example, "00:1A:2B/24", "00-1a-2b", "00:1a:2b:00:00:00/24" and "00:1a:2b:3c:4d:5e" are all valid.
+func ParseMACPrefix(s string) (MACPrefix, error) {
+	addrStr, bitsStr, hasBits := strings.Cut(s, "/")
+	var addr net.HardwareAddr
+	for _, octet := range strings.FieldsFunc(addrStr, func(r rune) bool { return r == ':' || r == '-' }) {
+		b, err := hex.DecodeString(octet)
+		if err != nil || len(b) != 1 {
+			return MACPrefix{}, fmt.Errorf("%w: %q", ErrInvalidMACPrefix, s)
+		}
+		addr = append(addr, b[0])
+	}
+	if len(addr) == 0 || len(addr) > 6 || strings.Count(addrStr, ":")+strings.Count(addrStr, "-") != len(addr)-1 {
+		return MACPrefix{}, fmt.Errorf("%w: %q", ErrInvalidMACPrefix, s)
+	}
+	bits := len(addr) * 8
+	if hasBits {
+		var err error
+		if bits, err = strconv.Atoi(bitsStr); err != nil || bits > len(addr)*8 {
+			return MACPrefix{}, fmt.Errorf("%w: %q", ErrInvalidMACPrefix, s)
+		}
+	}
+	addr = append(addr, make(net.HardwareAddr, 6-len(addr))...)
+	pfx, ok := MACPrefixFrom(addr, bits)
+	if !ok {
+		return MACPrefix{}, fmt.Errorf("%w: %q", ErrInvalidMACPrefix, s)
+	}
+	return pfx, nil
+}
+
+// MustParseMACPrefix is like ParseMACPrefix, but panics on error. It is intended for use in tests and with hard-coded
+// prefixes.
+func MustParseMACPrefix(s string) MACPrefix {
+	pfx, err := ParseMACPrefix(s)
+	if err != nil {
+		panic(err)
+	}
+	return pfx
+}
+
+// Addr returns the address of the prefix, with the bits after the prefix cleared.
+func (pfx MACPrefix) Addr() net.HardwareAddr {
+	return append(net.HardwareAddr(nil), pfx.addr[:]...)
+}
+
+// Bits returns the length of the prefix in bits.
+func (pfx MACPrefix) Bits() int {
+	return int(pfx.bits)
+}
+
+// Contains indicates whether addr is within the prefix.
+func (pfx MACPrefix) Contains(addr net.HardwareAddr) bool {
+	return len(addr) == 6 && bitstrie.KeyFromBytes(addr).Masked(int(pfx.bits)) == pfx.key()
+}
+
+// String returns the prefix in the form 00:1a:2b:00:00:00/24.
+func (pfx MACPrefix) String() string {
+	return net.HardwareAddr(pfx.addr[:]).String() + "/" + strconv.Itoa(int(pfx.bits))
+}
+
+func (pfx MACPrefix) key() bitstrie.Key {
+	return bitstrie.KeyFromBytes(pfx.addr[:])
+}
+
+// MACTrie is a trie of MAC address prefixes, supporting longest-prefix-match lookups, such as classifying devices by
+// the vendor OUI of their address alongside more specific assignments.
+//
+// As with Trie, a MACTrie is not safe for concurrent modification.
+type MACTrie struct {
+	trie *bitstrie.Trie[any]
+}
+
+// NewMACTrie creates a new MACTrie.
+func NewMACTrie() *MACTrie {
+	return &MACTrie{trie: bitstrie.New[any](macBits)}
+}
+
+// Insert inserts an entry into the trie, replacing the value of any existing entry for the same prefix.
+func (mt *MACTrie) Insert(prefix MACPrefix, value any) {
+	mt.trie.Insert(prefix.key(), prefix.Bits(), value)
+}
+
+// InsertString is like Insert, but parses the prefix from a string, as ParseMACPrefix.
+func (mt *MACTrie) InsertString(prefix string, value any) error {
+	pfx, err := ParseMACPrefix(prefix)
+	if err != nil {
+		return err
+	}
+	mt.Insert(pfx, value)
+	return nil
+}
+
+// Remove removes the entry for the given prefix, returning its value, or nil if there was none.
+func (mt *MACTrie) Remove(prefix MACPrefix) any {
+	v, _ := mt.trie.Remove(prefix.key(), prefix.Bits())
+	return v
+}
+
+// Find returns the value from the most specific prefix containing the given address, or nil if there is none or addr
+// is not a 6-byte MAC address.
+func (mt *MACTrie) Find(addr net.HardwareAddr) any {
+	if len(addr) != 6 {
+		return nil
+	}
+	v, _ := mt.trie.Find(bitstrie.KeyFromBytes(addr))
+	return v
+}
+
+// Contains indicates whether the trie contains the given address.
+func (mt *MACTrie) Contains(addr net.HardwareAddr) bool {
+	return len(addr) == 6 && mt.trie.Contains(bitstrie.KeyFromBytes(addr))
+}
+
+// Len returns the number of entries in the trie.
+func (mt *MACTrie) Len() int {
+	return mt.trie.Len()
+}
+
+// Walk calls fn with each entry of the trie, in ascending order with each prefix visited before the prefixes within it.
+// It stops when fn returns false.
+func (mt *MACTrie) Walk(fn func(prefix MACPrefix, value any) bool) {
+	mt.trie.Walk(func(key bitstrie.Key, bits int, value any) bool {
+		pfx := MACPrefix{bits: uint8(bits)}
+		copy(pfx.addr[:], key.Bytes(6))
+		return fn(pfx, value)
+	})
+}
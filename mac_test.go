@@ -0,0 +1,91 @@
+package iptrie
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustMAC(s string) net.HardwareAddr {
+	addr, err := net.ParseMAC(s)
+	if err != nil {
+		panic(err)
+	}
+	return addr
+}
+
+func TestParseMACPrefix(t *testing.T) {
+	for s, expected := range map[string]string{
+		"00:1A:2B/24":          "00:1a:2b:00:00:00/24",
+		"00-1a-2b":             "00:1a:2b:00:00:00/24",
+		"00:1a:2b:00:00:00/24": "00:1a:2b:00:00:00/24",
+		"00:1a:2b:3c:4d:5e":    "00:1a:2b:3c:4d:5e/48",
+		"00:1a:2b:3c:4d:5e/28": "00:1a:2b:30:00:00/28",
+		"02:00:00:00:00:00/7":  "02:00:00:00:00:00/7",
+		"ff/0":                 "00:00:00:00:00:00/0",
+	} {
+		pfx, err := ParseMACPrefix(s)
+		if assert.NoError(t, err, s) {
+			assert.Equal(t, expected, pfx.String(), s)
+		}
+	}
+
+	for _, s := range []string{"", "/24", "00:1a:2b/25", "00:1a:2b:3c:4d:5e:6f", "00:1a:2b/-1", "001a2b", "00::1a", "0g:1a", "00:1a:2b/x", "0:1a"} {
+		_, err := ParseMACPrefix(s)
+		assert.ErrorIs(t, err, ErrInvalidMACPrefix, s)
+	}
+}
+
+func TestMACPrefix(t *testing.T) {
+	pfx, ok := MACPrefixFrom(mustMAC("00:1a:2b:3c:4d:5e"), 24)
+	require.True(t, ok)
+	assert.Equal(t, MustParseMACPrefix("00:1a:2b"), pfx)
+	assert.Equal(t, mustMAC("00:1a:2b:00:00:00"), pfx.Addr())
+	assert.Equal(t, 24, pfx.Bits())
+	assert.True(t, pfx.Contains(mustMAC("00:1a:2b:ff:ff:ff")))
+	assert.False(t, pfx.Contains(mustMAC("00:1a:2c:00:00:00")))
+	assert.False(t, pfx.Contains(mustMAC("00:00:00:00:fe:80:00:00:00:00:00:00:02:00:5e:10:00:00:00:01")))
+
+	_, ok = MACPrefixFrom(mustMAC("00:1a:2b:3c:4d:5e"), 49)
+	assert.False(t, ok)
+	_, ok = MACPrefixFrom(net.HardwareAddr{1, 2, 3}, 24)
+	assert.False(t, ok)
+}
+
+func TestMACTrie(t *testing.T) {
+	mt := NewMACTrie()
+	require.NoError(t, mt.InsertString("00:1a:2b", "vendor"))
+	require.NoError(t, mt.InsertString("00:1a:2b:3c/32", "assets"))
+	require.NoError(t, mt.InsertString("00:1a:2b:3c:4d:5e", "printer"))
+	mt.Insert(MustParseMACPrefix("02:00:00:00:00:00/7"), "local")
+	assert.Error(t, mt.InsertString("00:1a:2b/33", nil))
+	assert.Equal(t, 4, mt.Len())
+
+	assert.Equal(t, "printer", mt.Find(mustMAC("00:1a:2b:3c:4d:5e")))
+	assert.Equal(t, "assets", mt.Find(mustMAC("00:1a:2b:3c:00:01")))
+	assert.Equal(t, "vendor", mt.Find(mustMAC("00-1A-2B-FF-00-01")))
+	assert.Equal(t, "local", mt.Find(mustMAC("03:00:00:00:00:01")))
+	assert.Nil(t, mt.Find(mustMAC("00:1a:2c:00:00:01")))
+	assert.Nil(t, mt.Find(net.HardwareAddr{0x00, 0x1a, 0x2b}))
+	assert.True(t, mt.Contains(mustMAC("00:1a:2b:00:00:01")))
+	assert.False(t, mt.Contains(mustMAC("00:1a:2c:00:00:01")))
+
+	var walked []string
+	mt.Walk(func(prefix MACPrefix, value any) bool {
+		walked = append(walked, prefix.String())
+		return true
+	})
+	assert.Equal(t, []string{
+		"00:1a:2b:00:00:00/24",
+		"00:1a:2b:3c:00:00/32",
+		"00:1a:2b:3c:4d:5e/48",
+		"02:00:00:00:00:00/7",
+	}, walked)
+
+	assert.Equal(t, "assets", mt.Remove(MustParseMACPrefix("00:1a:2b:3c/32")))
+	assert.Nil(t, mt.Remove(MustParseMACPrefix("00:1a:2b:3c/32")))
+	assert.Equal(t, "vendor", mt.Find(mustMAC("00:1a:2b:3c:00:01")))
+	assert.Equal(t, 3, mt.Len())
+}
@@ -0,0 +1,207 @@
+package iptrie
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+)
+
+// The mapped format is laid out as follows, with all integers little endian:
+//
+//	header (16 bytes):
+//		magic      [4]byte  "IPTM"
+//		version    uint16
+//		reserved   uint16
+//		node count uint32
+//		values len uint32
+//	nodes (mappedNodeSize bytes each, root first):
+//		addr       uint64 (high), uint64 (low)
+//		children   [2]uint32 (0 = no child)
+//		value off  uint32 (relative to the start of values)
+//		value len  uint32
+//		bits       uint8
+//		flags      uint8
+//		padding    [6]byte
+//	values ([]byte)
+//
+// Nodes reference each other by index, and values by offset, so the data is position independent.
+const (
+	mappedMagic      = "IPTM"
+	mappedVersion    = 1
+	mappedHeaderSize = 16
+	mappedNodeSize   = 40
+
+	// mappedFlagEntry indicates that the node is an entry, and not just an implicit node.
+	mappedFlagEntry = 1 << 0
+)
+
+// ErrInvalidMapped is returned when data is not a valid mapped trie.
+var ErrInvalidMapped = errors.New("iptrie: invalid mapped trie data")
+
+// WriteMapped writes the trie to w in a position independent format which can be queried in place by MappedTrie,
+// without deserialization. This allows a large trie to be memory mapped from disk, with the mapping shared by multiple
+// processes.
+//
+// codec encodes each value into the bytes to be stored. If codec is nil, StringCodec is used.
+func (pt *Trie) WriteMapped(w io.Writer, codec ValueCodec) error {
+	if codec == nil {
+		codec = StringCodec
+	}
+	ft := pt.Freeze()
+
+	var values []byte
+	nodes := make([]byte, len(ft.nodes)*mappedNodeSize)
+	for i, n := range ft.nodes {
+		rec := nodes[i*mappedNodeSize:][:mappedNodeSize]
+		binary.LittleEndian.PutUint64(rec[0:], n.addr.hi)
+		binary.LittleEndian.PutUint64(rec[8:], n.addr.lo)
+		binary.LittleEndian.PutUint32(rec[16:], n.children[0])
+		binary.LittleEndian.PutUint32(rec[20:], n.children[1])
+		rec[32] = n.bits
+		if n.value == 0 {
+			continue
+		}
+		var value []byte
+		var err error
+		if v := ft.values[n.value-1]; v != nil {
+			value, err = codec.Encode(v)
+		}
+		if err != nil {
+			return fmt.Errorf("encoding value for %s: %w", n.network(), err)
+		}
+		binary.LittleEndian.PutUint32(rec[24:], uint32(len(values)))
+		binary.LittleEndian.PutUint32(rec[28:], uint32(len(value)))
+		rec[33] = mappedFlagEntry
+		values = append(values, value...)
+	}
+
+	header := make([]byte, mappedHeaderSize)
+	copy(header, mappedMagic)
+	binary.LittleEndian.PutUint16(header[4:], mappedVersion)
+	binary.LittleEndian.PutUint32(header[8:], uint32(len(ft.nodes)))
+	binary.LittleEndian.PutUint32(header[12:], uint32(len(values)))
+	for _, b := range [][]byte{header, nodes, values} {
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MappedTrie is a read-only trie which is queried directly from data written by Trie.WriteMapped, without
+// deserialization. It is typically created with OpenMapped, which memory maps the data from a file.
+//
+// Values are returned as slices of the underlying data, and must not be modified, or used after Close.
+//
+// A MappedTrie is safe for concurrent use.
+type MappedTrie struct {
+	nodes  []byte
+	values []byte
+	count  uint32
+	closer func() error
+}
+
+// NewMappedTrie creates a MappedTrie which queries the given data in place.
+func NewMappedTrie(data []byte) (*MappedTrie, error) {
+	if len(data) < mappedHeaderSize || string(data[:4]) != mappedMagic {
+		return nil, ErrInvalidMapped
+	}
+	if v := binary.LittleEndian.Uint16(data[4:]); v != mappedVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidMapped, v)
+	}
+	count := binary.LittleEndian.Uint32(data[8:])
+	valuesLen := binary.LittleEndian.Uint32(data[12:])
+	nodesLen := uint64(count) * mappedNodeSize
+	if count == 0 || uint64(len(data)) != mappedHeaderSize+nodesLen+uint64(valuesLen) {
+		return nil, ErrInvalidMapped
+	}
+	return &MappedTrie{
+		nodes:  data[mappedHeaderSize:][:nodesLen],
+		values: data[mappedHeaderSize+nodesLen:],
+		count:  count,
+	}, nil
+}
+
+// OpenMapped memory maps the given file, which must have been written by Trie.WriteMapped. On platforms without
+// memory mapping support, the file is read into memory instead.
+//
+// Close must be called to release the mapping.
+func OpenMapped(path string) (*MappedTrie, error) {
+	data, closer, err := mapFile(path)
+	if err != nil {
+		return nil, err
+	}
+	mt, err := NewMappedTrie(data)
+	if err != nil {
+		closer()
+		return nil, err
+	}
+	mt.closer = closer
+	return mt, nil
+}
+
+// Close releases the underlying memory mapping, if any.
+func (mt *MappedTrie) Close() error {
+	if mt.closer == nil {
+		return nil
+	}
+	closer := mt.closer
+	mt.closer = nil
+	mt.nodes, mt.values, mt.count = nil, nil, 0
+	return closer()
+}
+
+// Find returns the value from the most specific network (largest prefix) containing the given address.
+func (mt *MappedTrie) Find(ip netip.Addr) []byte {
+	value, _ := mt.find(ip, false)
+	return value
+}
+
+// FindLargest returns the value from the largest network (smallest prefix) containing the given address.
+func (mt *MappedTrie) FindLargest(ip netip.Addr) []byte {
+	value, _ := mt.find(ip, true)
+	return value
+}
+
+// Contains indicates whether the trie contains the given ip.
+func (mt *MappedTrie) Contains(ip netip.Addr) bool {
+	_, ok := mt.find(ip, true)
+	return ok
+}
+
+func (mt *MappedTrie) find(ip netip.Addr, largest bool) ([]byte, bool) {
+	ip128 := addr128(normalizeAddr(ip))
+	var value []byte
+	var found bool
+	for i := uint32(0); i < mt.count; {
+		rec := mt.nodes[uint64(i)*mappedNodeSize:][:mappedNodeSize]
+		addr := uint128{binary.LittleEndian.Uint64(rec[0:]), binary.LittleEndian.Uint64(rec[8:])}
+		bits := rec[32]
+		if bits > 128 || !netContains(addr, bits, ip128) {
+			break
+		}
+		if rec[33]&mappedFlagEntry != 0 {
+			off := uint64(binary.LittleEndian.Uint32(rec[24:]))
+			end := off + uint64(binary.LittleEndian.Uint32(rec[28:]))
+			if end > uint64(len(mt.values)) {
+				return nil, false
+			}
+			value, found = mt.values[off:end:end], true
+			if largest {
+				break
+			}
+		}
+		if bits == 128 {
+			break
+		}
+		child := binary.LittleEndian.Uint32(rec[16+4*uint32(ip128.bit(bits)):])
+		// Children always follow their parent, which also guarantees the traversal terminates.
+		if child <= i {
+			break
+		}
+		i = child
+	}
+	return value, found
+}
@@ -0,0 +1,82 @@
+package iptrie
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMappedTrie(t *testing.T) {
+	trie := NewTrie()
+	trie.Insert(netip.MustParsePrefix("2001:db8::/32"), "v6")
+	trie.Insert(netip.MustParsePrefix("2001:db8::1/128"), "v6 host")
+	for i := 0; i < 1000; i++ {
+		ip := GenIPV4()
+		network, _ := ip.Prefix(int(ip.As4()[3]%25) + 8)
+		trie.Insert(network, fmt.Sprintf("net=%s", network))
+	}
+
+	path := filepath.Join(t.TempDir(), "trie.iptm")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	require.NoError(t, trie.WriteMapped(f, nil))
+	require.NoError(t, f.Close())
+
+	mt, err := OpenMapped(path)
+	require.NoError(t, err)
+	defer mt.Close()
+
+	str := func(b []byte) any {
+		if b == nil {
+			return nil
+		}
+		return string(b)
+	}
+	ips := []netip.Addr{netip.MustParseAddr("2001:db8::1"), netip.MustParseAddr("2001:db8::2"), netip.MustParseAddr("::1")}
+	for i := 0; i < 1000; i++ {
+		ips = append(ips, GenIPV4())
+	}
+	for _, ip := range ips {
+		assert.Equal(t, trie.Find(ip), str(mt.Find(ip)), "ip=%s", ip)
+		assert.Equal(t, trie.FindLargest(ip), str(mt.FindLargest(ip)), "ip=%s", ip)
+		assert.Equal(t, trie.Contains(ip), mt.Contains(ip), "ip=%s", ip)
+	}
+}
+
+func TestMappedTrieEncode(t *testing.T) {
+	trie := NewTrie()
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), 1)
+
+	var buf bytes.Buffer
+	assert.Error(t, trie.WriteMapped(&buf, nil))
+
+	buf.Reset()
+	err := trie.WriteMapped(&buf, JSONCodec)
+	require.NoError(t, err)
+	mt, err := NewMappedTrie(buf.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, []byte("1"), mt.Find(netip.MustParseAddr("10.0.0.1")))
+	assert.Nil(t, mt.Find(netip.MustParseAddr("11.0.0.1")))
+}
+
+func TestMappedTrieInvalid(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, NewTrie().WriteMapped(&buf, nil))
+	data := buf.Bytes()
+
+	_, err := NewMappedTrie(data[:len(data)-1])
+	assert.True(t, errors.Is(err, ErrInvalidMapped))
+	_, err = NewMappedTrie([]byte("nope"))
+	assert.True(t, errors.Is(err, ErrInvalidMapped))
+
+	mt, err := NewMappedTrie(data)
+	require.NoError(t, err)
+	assert.False(t, mt.Contains(netip.MustParseAddr("10.0.0.1")))
+}
@@ -0,0 +1,37 @@
+package iptrie
+
+import (
+	"unsafe"
+)
+
+// MemoryUsage returns the number of nodes in the trie, including implicit nodes, and an estimate of the number of bytes
+// of heap they consume. The memory consumed by the values themselves is not included. See MemoryUsageFunc.
+func (pt *Trie) MemoryUsage() (nodes int, bytes uintptr) {
+	return pt.MemoryUsageFunc(nil)
+}
+
+// MemoryUsageFunc is like MemoryUsage, but includes the memory consumed by values, as reported by sizer. sizer is called
+// for every entry, with the value that was inserted. If sizer is nil, values are not included.
+//
+// Nodes shared with snapshots are counted in full, as are nodes allocated in a slab (such as by BuildFromSorted).
+func (pt *Trie) MemoryUsageFunc(sizer func(value any) uintptr) (nodes int, bytes uintptr) {
+	nodes, bytes = pt.node.memoryUsage(sizer)
+	// The root node is embedded within the Trie, so account for the remainder of the Trie.
+	bytes += unsafe.Sizeof(Trie{}) - unsafe.Sizeof(node{})
+	return nodes, bytes
+}
+
+func (pt *node) memoryUsage(sizer func(value any) uintptr) (nodes int, bytes uintptr) {
+	nodes, bytes = 1, unsafe.Sizeof(*pt)
+	if pt.value != nil && sizer != nil {
+		bytes += sizer(unempty(pt.value))
+	}
+	for _, child := range pt.children {
+		if child != nil {
+			n, b := child.memoryUsage(sizer)
+			nodes += n
+			bytes += b
+		}
+	}
+	return nodes, bytes
+}
@@ -0,0 +1,36 @@
+package iptrie
+
+import (
+	"net/netip"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrieMemoryUsage(t *testing.T) {
+	trie := NewTrie()
+	nodes, bytes := trie.MemoryUsage()
+	assert.Equal(t, 1, nodes)
+	assert.Equal(t, unsafe.Sizeof(Trie{}), bytes)
+
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/24"), "abc")
+	trie.Insert(netip.MustParsePrefix("10.0.1.0/24"), "defgh")
+	trie.Insert(netip.MustParsePrefix("10.0.1.0/30"), nil)
+	// The root, the implicit 10.0.0.0/23 node, and the 3 entries.
+	nodes, bytes = trie.MemoryUsage()
+	assert.Equal(t, 5, nodes)
+	assert.Equal(t, unsafe.Sizeof(Trie{})+4*unsafe.Sizeof(node{}), bytes)
+
+	var sized []any
+	nodes, bytes = trie.MemoryUsageFunc(func(v any) uintptr {
+		sized = append(sized, v)
+		if s, ok := v.(string); ok {
+			return uintptr(len(s))
+		}
+		return 0
+	})
+	assert.Equal(t, 5, nodes)
+	assert.Equal(t, unsafe.Sizeof(Trie{})+4*unsafe.Sizeof(node{})+8, bytes)
+	assert.Equal(t, []any{"abc", "defgh", nil}, sized)
+}
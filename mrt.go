@@ -0,0 +1,221 @@
+package iptrie
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+)
+
+// MRT record types and subtypes, as defined by RFC 6396 and RFC 8050.
+const (
+	mrtTableDumpV2 = 13
+
+	mrtRIBIPv4Unicast        = 2
+	mrtRIBIPv6Unicast        = 4
+	mrtRIBIPv4UnicastAddPath = 8
+	mrtRIBIPv6UnicastAddPath = 10
+
+	bgpAttrFlagExtendedLength = 0x10
+	bgpAttrASPath             = 2
+)
+
+// ErrInvalidMRT is returned when data is not a valid MRT file.
+var ErrInvalidMRT = errors.New("iptrie: invalid MRT data")
+
+// MRTRIB is the set of routes to a network, from a RIB record of an MRT TABLE_DUMP_V2 file.
+type MRTRIB struct {
+	Network netip.Prefix
+	// Entries holds the route to the network from each peer.
+	Entries []MRTRIBEntry
+}
+
+// MRTRIBEntry is a route to a network, as received from a single peer.
+type MRTRIBEntry struct {
+	// PeerIndex is the index of the peer within the PEER_INDEX_TABLE record of the file.
+	PeerIndex uint16
+	// PathID is the path identifier of the route, for files containing ADD-PATH RIB records.
+	PathID uint32
+	// ASPath is the AS path of the route, with the members of any AS_SET segments included in the order they appear.
+	ASPath []uint32
+}
+
+// OriginAS returns the AS which originated the route, which is the last AS of the path, or 0 if the path is empty.
+func (e MRTRIBEntry) OriginAS() uint32 {
+	if len(e.ASPath) == 0 {
+		return 0
+	}
+	return e.ASPath[len(e.ASPath)-1]
+}
+
+// LoadMRT inserts the unicast routes read from r, which is a BGP RIB dump in the MRT TABLE_DUMP_V2 format (RFC 6396), as
+// published by RouteViews and RIPE RIS. The dumps are usually compressed, and must be decompressed by the caller, such
+// as with compress/bzip2 or compress/gzip. Records of other types are skipped.
+//
+// valueFn is called with the routes to each network, and returns the value to insert, and whether the network should be
+// inserted at all. If valueFn is nil, the origin AS (as a uint32) of the first route is inserted, and networks without
+// any routes are skipped.
+//
+// Loading stops at the first error. Entries from the preceding records remain inserted.
+func (pt *Trie) LoadMRT(r io.Reader, valueFn func(rib MRTRIB) (any, bool)) error {
+	if valueFn == nil {
+		valueFn = func(rib MRTRIB) (any, bool) {
+			if len(rib.Entries) == 0 {
+				return nil, false
+			}
+			return rib.Entries[0].OriginAS(), true
+		}
+	}
+
+	br := bufio.NewReader(r)
+	var hdr [12]byte
+	for {
+		// timestamp uint32, type uint16, subtype uint16, length uint32
+		if _, err := io.ReadFull(br, hdr[:]); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return mrtError(err)
+		}
+		typ := binary.BigEndian.Uint16(hdr[4:])
+		subtype := binary.BigEndian.Uint16(hdr[6:])
+		length := binary.BigEndian.Uint32(hdr[8:])
+
+		// Only unicast RIB records are of interest.
+		ok := typ == mrtTableDumpV2
+		var v6, addPath bool
+		switch subtype {
+		case mrtRIBIPv4Unicast:
+		case mrtRIBIPv6Unicast:
+			v6 = true
+		case mrtRIBIPv4UnicastAddPath:
+			addPath = true
+		case mrtRIBIPv6UnicastAddPath:
+			v6, addPath = true, true
+		default:
+			ok = false
+		}
+		if !ok {
+			if _, err := br.Discard(int(length)); err != nil {
+				return mrtError(err)
+			}
+			continue
+		}
+
+		data, err := readN(br, uint64(length))
+		if err != nil {
+			return mrtError(err)
+		}
+		rib, err := parseMRTRIB(data, v6, addPath)
+		if err != nil {
+			return err
+		}
+		if value, ok := valueFn(rib); ok {
+			pt.Insert(rib.Network, value)
+		}
+	}
+}
+
+// mrtError wraps an error encountered while reading a record.
+func mrtError(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return fmt.Errorf("%w: %w", ErrInvalidMRT, io.ErrUnexpectedEOF)
+	}
+	return err
+}
+
+// parseMRTRIB parses the body of a RIB record.
+func parseMRTRIB(b []byte, v6, addPath bool) (MRTRIB, error) {
+	var rib MRTRIB
+	// sequence uint32, prefix length uint8, prefix, entry count uint16
+	if len(b) < 5 {
+		return rib, ErrInvalidMRT
+	}
+	bits := int(b[4])
+	size := (bits + 7) / 8
+	b = b[5:]
+	if (v6 && bits > 128) || (!v6 && bits > 32) || len(b) < size+2 {
+		return rib, fmt.Errorf("%w: invalid network", ErrInvalidMRT)
+	}
+	var addr [16]byte
+	copy(addr[:], b[:size])
+	ip := netip.AddrFrom16(addr)
+	if !v6 {
+		ip = netip.AddrFrom4([4]byte(addr[:4]))
+	}
+	rib.Network = netip.PrefixFrom(ip, bits).Masked()
+	count := int(binary.BigEndian.Uint16(b[size:]))
+	b = b[size+2:]
+
+	// peer index uint16, originated time uint32, [path identifier uint32], attribute length uint16, attributes
+	hdrSize := 8
+	if addPath {
+		hdrSize += 4
+	}
+	rib.Entries = make([]MRTRIBEntry, count)
+	for i := range rib.Entries {
+		if len(b) < hdrSize {
+			return rib, ErrInvalidMRT
+		}
+		e := &rib.Entries[i]
+		e.PeerIndex = binary.BigEndian.Uint16(b)
+		if addPath {
+			e.PathID = binary.BigEndian.Uint32(b[6:])
+		}
+		attrSize := int(binary.BigEndian.Uint16(b[hdrSize-2:]))
+		b = b[hdrSize:]
+		if len(b) < attrSize {
+			return rib, ErrInvalidMRT
+		}
+		var err error
+		if e.ASPath, err = bgpASPath(b[:attrSize]); err != nil {
+			return rib, err
+		}
+		b = b[attrSize:]
+	}
+	return rib, nil
+}
+
+// bgpASPath returns the AS path from the given BGP path attributes. Within MRT RIB records, ASes are always encoded as
+// 4 bytes.
+func bgpASPath(b []byte) ([]uint32, error) {
+	var path []uint32
+	for len(b) > 0 {
+		// flags uint8, type uint8, length uint8 (or uint16 if extended), value
+		if len(b) < 3 {
+			return nil, ErrInvalidMRT
+		}
+		flags, typ := b[0], b[1]
+		size, hdrSize := int(b[2]), 3
+		if flags&bgpAttrFlagExtendedLength != 0 {
+			if len(b) < 4 {
+				return nil, ErrInvalidMRT
+			}
+			size, hdrSize = int(binary.BigEndian.Uint16(b[2:])), 4
+		}
+		if len(b) < hdrSize+size {
+			return nil, ErrInvalidMRT
+		}
+		value := b[hdrSize : hdrSize+size]
+		b = b[hdrSize+size:]
+		if typ != bgpAttrASPath {
+			continue
+		}
+
+		// Each segment is a type uint8, AS count uint8, and the ASes.
+		for len(value) > 0 {
+			if len(value) < 2 || len(value) < 2+int(value[1])*4 {
+				return nil, fmt.Errorf("%w: invalid AS path", ErrInvalidMRT)
+			}
+			count := int(value[1])
+			value = value[2:]
+			for i := 0; i < count; i++ {
+				path = append(path, binary.BigEndian.Uint32(value[i*4:]))
+			}
+			value = value[count*4:]
+		}
+	}
+	return path, nil
+}
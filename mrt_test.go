@@ -0,0 +1,91 @@
+package iptrie
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mrtRecord builds an MRT record with the given type, subtype and body.
+func mrtRecord(typ, subtype uint16, body []byte) []byte {
+	b := binary.BigEndian.AppendUint32(nil, 1700000000)
+	b = binary.BigEndian.AppendUint16(b, typ)
+	b = binary.BigEndian.AppendUint16(b, subtype)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(body)))
+	return append(b, body...)
+}
+
+// mrtRIBRecord builds a RIB record for the given network, with an entry for each of the given AS paths.
+func mrtRIBRecord(network netip.Prefix, paths ...[]uint32) []byte {
+	subtype := uint16(mrtRIBIPv4Unicast)
+	if network.Addr().Is6() {
+		subtype = mrtRIBIPv6Unicast
+	}
+	b := binary.BigEndian.AppendUint32(nil, 0)
+	b = append(b, uint8(network.Bits()))
+	b = append(b, network.Addr().AsSlice()[:(network.Bits()+7)/8]...)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(paths)))
+	for i, path := range paths {
+		// ORIGIN attribute, followed by the AS_PATH as a single AS_SEQUENCE segment.
+		attrs := []byte{0x40, 1, 1, 0}
+		attrs = append(attrs, 0x50, bgpAttrASPath)
+		attrs = binary.BigEndian.AppendUint16(attrs, uint16(2+len(path)*4))
+		attrs = append(attrs, 2, uint8(len(path)))
+		for _, as := range path {
+			attrs = binary.BigEndian.AppendUint32(attrs, as)
+		}
+
+		b = binary.BigEndian.AppendUint16(b, uint16(i))
+		b = binary.BigEndian.AppendUint32(b, 1700000000)
+		b = binary.BigEndian.AppendUint16(b, uint16(len(attrs)))
+		b = append(b, attrs...)
+	}
+	return mrtRecord(mrtTableDumpV2, subtype, b)
+}
+
+func TestTrieLoadMRT(t *testing.T) {
+	var buf bytes.Buffer
+	// A PEER_INDEX_TABLE record, which is skipped.
+	buf.Write(mrtRecord(mrtTableDumpV2, 1, []byte{1, 2, 3, 4, 0, 0, 0, 0}))
+	buf.Write(mrtRIBRecord(netip.MustParsePrefix("1.0.0.0/24"), []uint32{3356, 13335}, []uint32{174, 13335}))
+	buf.Write(mrtRIBRecord(netip.MustParsePrefix("10.0.0.0/8")))
+	buf.Write(mrtRIBRecord(netip.MustParsePrefix("2001:db8::/32"), []uint32{6939, 64496}))
+
+	trie := NewTrie()
+	require.NoError(t, trie.LoadMRT(bytes.NewReader(buf.Bytes()), nil))
+	assert.Equal(t, []netip.Prefix{
+		netip.MustParsePrefix("::ffff:1.0.0.0/120"),
+		netip.MustParsePrefix("2001:db8::/32"),
+	}, trie.appendEntries(nil))
+	assert.Equal(t, uint32(13335), trie.Find(netip.MustParseAddr("1.0.0.1")))
+	assert.Equal(t, uint32(64496), trie.Find(netip.MustParseAddr("2001:db8::1")))
+
+	trie = NewTrie()
+	err := trie.LoadMRT(bytes.NewReader(buf.Bytes()), func(rib MRTRIB) (any, bool) {
+		return rib, true
+	})
+	require.NoError(t, err)
+	rib := trie.Find(netip.MustParseAddr("1.0.0.1")).(MRTRIB)
+	assert.Equal(t, netip.MustParsePrefix("1.0.0.0/24"), rib.Network)
+	assert.Equal(t, []MRTRIBEntry{
+		{PeerIndex: 0, ASPath: []uint32{3356, 13335}},
+		{PeerIndex: 1, ASPath: []uint32{174, 13335}},
+	}, rib.Entries)
+	assert.True(t, trie.Contains(netip.MustParseAddr("10.0.0.1")))
+}
+
+func TestTrieLoadMRTInvalid(t *testing.T) {
+	data := mrtRIBRecord(netip.MustParsePrefix("1.0.0.0/24"), []uint32{3356, 13335})
+	for i := 1; i < len(data); i++ {
+		assert.ErrorIs(t, NewTrie().LoadMRT(bytes.NewReader(data[:i]), nil), ErrInvalidMRT, "length %d", i)
+	}
+
+	// A prefix length too long for the family.
+	bad := append([]byte(nil), data...)
+	bad[12+4] = 33
+	assert.ErrorIs(t, NewTrie().LoadMRT(bytes.NewReader(bad), nil), ErrInvalidMRT)
+}
@@ -0,0 +1,98 @@
+package iptrie
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+)
+
+// ErrInvalidIPNet is returned for a net.IPNet which has an invalid address or mask, such as a nil network or a
+// non-contiguous mask.
+var ErrInvalidIPNet = errors.New("iptrie: invalid net.IPNet")
+
+// PrefixFromIPNet converts network to a netip.Prefix. An IPv4 network is returned in IPv4 form, regardless of whether
+// its address is stored in 4 or 16 bytes.
+func PrefixFromIPNet(network *net.IPNet) (netip.Prefix, error) {
+	if network == nil {
+		return netip.Prefix{}, ErrInvalidIPNet
+	}
+	addr, ok := netip.AddrFromSlice(network.IP)
+	ones, bits := network.Mask.Size()
+	if !ok || bits == 0 {
+		return netip.Prefix{}, ErrInvalidIPNet
+	}
+	if bits == 32 {
+		addr = addr.Unmap()
+	}
+	p := netip.PrefixFrom(addr, ones)
+	if !p.IsValid() {
+		return netip.Prefix{}, ErrInvalidIPNet
+	}
+	return p, nil
+}
+
+// IPNetFromPrefix converts network to a *net.IPNet. Networks normalized to IPv6 by the trie, such as those returned by
+// ContainingNetworks, are converted back to their IPv4 form, with a 4 byte address and mask.
+func IPNetFromPrefix(network netip.Prefix) *net.IPNet {
+	network = denormalizePrefix(network.Masked())
+	return &net.IPNet{
+		IP:   network.Addr().AsSlice(),
+		Mask: net.CIDRMask(network.Bits(), network.Addr().BitLen()),
+	}
+}
+
+// addrFromIP converts ip to a netip.Addr, returning false if it isn't a valid address.
+func addrFromIP(ip net.IP) (netip.Addr, bool) {
+	addr, ok := netip.AddrFromSlice(ip)
+	return addr.Unmap(), ok
+}
+
+// InsertIPNet is like Insert, but takes the network as a *net.IPNet, for code which has not migrated to net/netip.
+func (pt *Trie) InsertIPNet(network *net.IPNet, value any) error {
+	p, err := PrefixFromIPNet(network)
+	if err != nil {
+		return err
+	}
+	pt.Insert(p, value)
+	return nil
+}
+
+// RemoveIPNet is like Remove, but takes the network as a *net.IPNet.
+func (pt *Trie) RemoveIPNet(network *net.IPNet) (any, error) {
+	p, err := PrefixFromIPNet(network)
+	if err != nil {
+		return nil, err
+	}
+	return unempty(pt.Remove(p)), nil
+}
+
+// FindIP is like Find, but takes the address as a net.IP. An invalid address, such as a nil net.IP, doesn't match any
+// entry.
+func (pt *Trie) FindIP(ip net.IP) any {
+	addr, ok := addrFromIP(ip)
+	if !ok {
+		return pt.defaultValue
+	}
+	return pt.Find(addr)
+}
+
+// ContainsIP is like Contains, but takes the address as a net.IP. An invalid address, such as a nil net.IP, is not
+// contained.
+func (pt *Trie) ContainsIP(ip net.IP) bool {
+	addr, ok := addrFromIP(ip)
+	return ok && pt.Contains(addr)
+}
+
+// ContainingIPNets is like ContainingNetworks, but takes the address as a net.IP, and returns the networks as
+// *net.IPNet, with IPv4 networks in their IPv4 form.
+func (pt *Trie) ContainingIPNets(ip net.IP) []*net.IPNet {
+	addr, ok := addrFromIP(ip)
+	if !ok {
+		return nil
+	}
+	var networks []*net.IPNet
+	for _, network := range pt.ContainingNetworks(addr) {
+		networks = append(networks, IPNetFromPrefix(network))
+	}
+	return networks
+}
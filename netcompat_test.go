@@ -0,0 +1,61 @@
+package iptrie
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustParseIPNet(s string) *net.IPNet {
+	_, network, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
+
+func TestTrieIPNet(t *testing.T) {
+	trie := NewTrie()
+	require.NoError(t, trie.InsertIPNet(mustParseIPNet("10.0.0.0/8"), "a"))
+	// An IPv4 network with a 16 byte address.
+	require.NoError(t, trie.InsertIPNet(&net.IPNet{IP: net.ParseIP("10.1.0.0"), Mask: net.CIDRMask(16, 32)}, "b"))
+	require.NoError(t, trie.InsertIPNet(mustParseIPNet("2001:db8::/32"), "c"))
+
+	assert.Equal(t, "a", trie.FindIP(net.ParseIP("10.2.0.1")))
+	assert.Equal(t, "b", trie.FindIP(net.IPv4(10, 1, 0, 1)))
+	assert.Equal(t, "b", trie.FindIP(net.IPv4(10, 1, 0, 1).To4()))
+	assert.Equal(t, "c", trie.FindIP(net.ParseIP("2001:db8::1")))
+	assert.Nil(t, trie.FindIP(nil))
+	assert.True(t, trie.ContainsIP(net.ParseIP("10.1.0.1")))
+	assert.False(t, trie.ContainsIP(net.ParseIP("192.0.2.1")))
+	assert.False(t, trie.ContainsIP(net.IP{1, 2, 3}))
+
+	assert.Equal(t, []*net.IPNet{mustParseIPNet("10.0.0.0/8"), mustParseIPNet("10.1.0.0/16")},
+		trie.ContainingIPNets(net.ParseIP("10.1.0.1")))
+	assert.Equal(t, []*net.IPNet{mustParseIPNet("2001:db8::/32")}, trie.ContainingIPNets(net.ParseIP("2001:db8::1")))
+	assert.Nil(t, trie.ContainingIPNets(nil))
+
+	v, err := trie.RemoveIPNet(mustParseIPNet("10.1.0.0/16"))
+	require.NoError(t, err)
+	assert.Equal(t, "b", v)
+	assert.Equal(t, "a", trie.FindIP(net.ParseIP("10.1.0.1")))
+
+	assert.ErrorIs(t, trie.InsertIPNet(nil, "x"), ErrInvalidIPNet)
+	assert.ErrorIs(t, trie.InsertIPNet(&net.IPNet{IP: net.IPv4(10, 0, 0, 0), Mask: net.IPMask{255, 0, 255, 0}}, "x"),
+		ErrInvalidIPNet)
+	_, err = trie.RemoveIPNet(&net.IPNet{IP: net.IP{1, 2}, Mask: net.CIDRMask(8, 32)})
+	assert.ErrorIs(t, err, ErrInvalidIPNet)
+}
+
+func TestIPNetFromPrefix(t *testing.T) {
+	assert.Equal(t, mustParseIPNet("10.0.0.0/8"), IPNetFromPrefix(netip.MustParsePrefix("10.1.2.3/8")))
+	assert.Equal(t, mustParseIPNet("10.0.0.0/8"), IPNetFromPrefix(netip.MustParsePrefix("::ffff:10.0.0.0/104")))
+	assert.Equal(t, mustParseIPNet("2001:db8::/32"), IPNetFromPrefix(netip.MustParsePrefix("2001:db8::/32")))
+
+	p, err := PrefixFromIPNet(mustParseIPNet("192.0.2.0/24"))
+	require.NoError(t, err)
+	assert.Equal(t, netip.MustParsePrefix("192.0.2.0/24"), p)
+}
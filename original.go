@@ -0,0 +1,33 @@
+package iptrie
+
+import "net/netip"
+
+// OriginalEntry is the value stored for each entry of a trie with SetPreserveOriginal enabled.
+type OriginalEntry struct {
+	// Network is the network exactly as it was given to Insert, without being masked or normalized, such as
+	// 10.1.2.3/8.
+	Network netip.Prefix
+	// Value is the value which was inserted.
+	Value any
+}
+
+// SetPreserveOriginal sets whether to record the network of each entry exactly as it was given to Insert, so that it
+// can be reported as configured, such as in audit output.
+//
+// While enabled, each inserted value is wrapped in an OriginalEntry, which is what lookups such as Find then return.
+// Values which are already an OriginalEntry, such as when copying entries from another trie, are inserted as is. As the
+// value of an entry inserted with a nil value is an OriginalEntry, it is not treated as a nil value by Find.
+//
+// Snapshots, and the tries created with NewRCUTrieFrom or NewVersionedTrieFrom, keep preserving the original networks.
+// Values must be encoded with a ValueCodec which supports OriginalEntry, such as JSONCodecOf[OriginalEntry].
+func (pt *Trie) SetPreserveOriginal(preserve bool) {
+	pt.preserveOriginal = preserve
+}
+
+// preserveOriginal wraps value in an OriginalEntry recording the given network, unless it already is one.
+func preserveOriginal(network netip.Prefix, value any) any {
+	if _, ok := value.(OriginalEntry); ok {
+		return value
+	}
+	return OriginalEntry{Network: network, Value: value}
+}
@@ -0,0 +1,44 @@
+package iptrie
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrieSetPreserveOriginal(t *testing.T) {
+	trie := NewTrie()
+	trie.SetPreserveOriginal(true)
+	trie.Insert(netip.MustParsePrefix("10.1.2.3/8"), "a")
+	trie.Insert(netip.MustParsePrefix("2001:db8::1/32"), nil)
+
+	assert.Equal(t, OriginalEntry{netip.MustParsePrefix("10.1.2.3/8"), "a"}, trie.Find(netip.MustParseAddr("10.9.9.9")))
+	assert.Equal(t, OriginalEntry{netip.MustParsePrefix("2001:db8::1/32"), nil}, trie.Find(netip.MustParseAddr("2001:db8::5")))
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("::ffff:10.0.0.0/104")},
+		trie.ContainingNetworks(netip.MustParseAddr("10.9.9.9")))
+
+	// Entries copied between tries keep their original network.
+	copied := NewTrie()
+	copied.SetPreserveOriginal(true)
+	trie.Walk(func(network netip.Prefix, value any) bool {
+		copied.Insert(network, value)
+		return true
+	})
+	assert.Equal(t, trie.String(), copied.String())
+
+	// The original network survives serialization with a suitable codec.
+	codec := JSONCodecOf[OriginalEntry]()
+	data, err := trie.MarshalBinaryCodec(codec)
+	require.NoError(t, err)
+	loaded := NewTrie()
+	require.NoError(t, loaded.UnmarshalBinaryCodec(data, codec))
+	assert.Equal(t, OriginalEntry{netip.MustParsePrefix("10.1.2.3/8"), "a"}, loaded.Find(netip.MustParseAddr("10.9.9.9")))
+
+	assert.True(t, trie.Snapshot().preserveOriginal)
+
+	trie.SetPreserveOriginal(false)
+	trie.Insert(netip.MustParsePrefix("10.1.2.3/8"), "c")
+	assert.Equal(t, "c", trie.Find(netip.MustParseAddr("10.9.9.9")))
+}
@@ -0,0 +1,332 @@
+package iptrie
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// persistentCompactMin is the minimum size of the change log before a PersistentTrie compacts automatically.
+const persistentCompactMin = 1 << 20
+
+// PersistentTrie is a Trie which is kept durable on disk, and recovered when reopened.
+//
+// The state is stored within a directory as a snapshot of the trie, in the format written by Trie.WriteTo, and a change
+// log, in the format written by ChangeLogWriter, of the modifications made since the snapshot. Modifications are
+// appended to the change log before being applied. Once the change log grows larger than the snapshot, the trie is
+// compacted, writing a new snapshot and starting a new change log.
+//
+// When reopened after a crash, a change which was only partially written is discarded. Modifications are written to
+// the operating system as they are made, but are only guaranteed to survive a system crash once Sync is called.
+//
+// A PersistentTrie is safe for concurrent use.
+type PersistentTrie struct {
+	mu    sync.RWMutex
+	trie  *Trie
+	dir   string
+	codec ValueCodec
+
+	// gen is the generation of the current snapshot and change log.
+	gen  uint64
+	log  *os.File
+	logw *countingWriter
+	clw  *ChangeLogWriter
+
+	snapshotSize int64
+	compactMin   int64
+}
+
+// OpenPersistentTrie opens the PersistentTrie stored within the given directory, creating it if it does not exist.
+// codec is used to encode and decode values, and if nil, StringCodec is used.
+func OpenPersistentTrie(dir string, codec ValueCodec) (*PersistentTrie, error) {
+	if codec == nil {
+		codec = StringCodec
+	}
+	if err := os.MkdirAll(dir, 0o777); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var gen uint64
+	var haveSnapshot bool
+	for _, entry := range entries {
+		if g, ok := persistentGen(entry.Name(), "snapshot-"); ok && (!haveSnapshot || g > gen) {
+			gen, haveSnapshot = g, true
+		}
+	}
+
+	pst := &PersistentTrie{
+		trie:       NewTrie(),
+		dir:        dir,
+		codec:      codec,
+		gen:        gen,
+		compactMin: persistentCompactMin,
+	}
+	if haveSnapshot {
+		if err := pst.loadSnapshot(); err != nil {
+			return nil, err
+		}
+	}
+	if err := pst.replayLog(); err != nil {
+		return nil, err
+	}
+
+	// Remove the files of previous generations, and of any compaction which didn't complete.
+	for _, entry := range entries {
+		name := entry.Name()
+		g, ok := persistentGen(name, "snapshot-")
+		if !ok {
+			g, ok = persistentGen(name, "log-")
+		}
+		if (ok && g != gen) || strings.HasSuffix(name, ".tmp") {
+			os.Remove(filepath.Join(dir, name))
+		}
+	}
+	return pst, nil
+}
+
+// persistentGen parses the generation from the name of a file with the given prefix.
+func persistentGen(name, prefix string) (uint64, bool) {
+	if !strings.HasPrefix(name, prefix) {
+		return 0, false
+	}
+	gen, err := strconv.ParseUint(name[len(prefix):], 16, 64)
+	return gen, err == nil
+}
+
+func (pst *PersistentTrie) path(prefix string, gen uint64) string {
+	return filepath.Join(pst.dir, fmt.Sprintf("%s%016x", prefix, gen))
+}
+
+func (pst *PersistentTrie) loadSnapshot() error {
+	f, err := os.Open(pst.path("snapshot-", pst.gen))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	n, err := pst.trie.ReadFromCodec(f, pst.codec)
+	if err != nil {
+		return fmt.Errorf("loading snapshot: %w", err)
+	}
+	pst.snapshotSize = n
+	return nil
+}
+
+// replayLog applies the change log of the current generation, creating it if it does not exist, and leaves it open for
+// appending. An incomplete change at the end of the log is truncated.
+func (pst *PersistentTrie) replayLog() error {
+	f, err := os.OpenFile(pst.path("log-", pst.gen), os.O_RDWR|os.O_CREATE, 0o666)
+	if err != nil {
+		return err
+	}
+	cr := &countingReader{r: f}
+	br := bufio.NewReader(cr)
+	var size int64
+	for {
+		c, err := readChange(br, pst.codec)
+		if err == io.EOF {
+			break
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			if err = f.Truncate(size); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			f.Close()
+			return fmt.Errorf("replaying change log: %w", err)
+		}
+		if err := pst.trie.Apply(c); err != nil {
+			f.Close()
+			return err
+		}
+		size = cr.n - int64(br.Buffered())
+	}
+	if _, err := f.Seek(size, io.SeekStart); err != nil {
+		f.Close()
+		return err
+	}
+	pst.setLog(f, size)
+	return nil
+}
+
+func (pst *PersistentTrie) setLog(f *os.File, size int64) {
+	pst.log = f
+	pst.logw = &countingWriter{w: f, n: size}
+	pst.clw = NewChangeLogWriter(pst.logw, pst.codec)
+}
+
+// Insert inserts an entry into the trie.
+//
+// If writing the change log fails, the trie is not modified, and the error is returned. Such errors are permanent, and
+// cause all further modifications to fail. An error may also be returned from an automatic compaction, in which case
+// the modification has been applied.
+func (pst *PersistentTrie) Insert(network netip.Prefix, value any) error {
+	pst.mu.Lock()
+	defer pst.mu.Unlock()
+	return pst.apply(Change{Op: ChangeInsert, Network: network, Value: value})
+}
+
+// Remove removes the entry identified by given network from trie. See Insert regarding errors.
+func (pst *PersistentTrie) Remove(network netip.Prefix) (any, error) {
+	pst.mu.Lock()
+	defer pst.mu.Unlock()
+	addr, bits := prefix128(normalizePrefix(network))
+	n := pst.trie.get(addr, bits)
+	if n == nil || n.value == nil {
+		return nil, nil
+	}
+	value := unempty(n.value)
+	if err := pst.apply(Change{Op: ChangeRemove, Network: network}); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// apply logs and then applies the given change. pst.mu must be held.
+func (pst *PersistentTrie) apply(c Change) error {
+	if pst.log == nil {
+		return os.ErrClosed
+	}
+	pst.clw.Log(c)
+	if err := pst.clw.Err(); err != nil {
+		return err
+	}
+	pst.trie.Apply(c)
+
+	if size := pst.logw.n; size > pst.compactMin && size > pst.snapshotSize {
+		return pst.compact()
+	}
+	return nil
+}
+
+// Sync commits the change log to stable storage.
+func (pst *PersistentTrie) Sync() error {
+	pst.mu.Lock()
+	defer pst.mu.Unlock()
+	if pst.log == nil {
+		return os.ErrClosed
+	}
+	return pst.log.Sync()
+}
+
+// Compact writes a new snapshot of the trie, replacing the existing snapshot and change log.
+//
+// Compaction is performed automatically as the change log grows, and so it is not normally necessary to call Compact.
+func (pst *PersistentTrie) Compact() error {
+	pst.mu.Lock()
+	defer pst.mu.Unlock()
+	if pst.log == nil {
+		return os.ErrClosed
+	}
+	return pst.compact()
+}
+
+func (pst *PersistentTrie) compact() error {
+	gen := pst.gen + 1
+	snapshotPath := pst.path("snapshot-", gen)
+	tmp, err := os.Create(snapshotPath + ".tmp")
+	if err != nil {
+		return err
+	}
+	size, err := pst.trie.WriteToCodec(tmp, pst.codec)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		// Once renamed, the new snapshot supersedes the previous generation, even if the new change log is never
+		// created.
+		err = os.Rename(tmp.Name(), snapshotPath)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	syncDir(pst.dir)
+
+	log, err := os.OpenFile(pst.path("log-", gen), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+	if err != nil {
+		// The new snapshot is in place, so the previous change log must not be appended to.
+		pst.log.Close()
+		pst.log = nil
+		return err
+	}
+
+	pst.log.Close()
+	os.Remove(pst.path("log-", pst.gen))
+	os.Remove(pst.path("snapshot-", pst.gen))
+	pst.gen = gen
+	pst.snapshotSize = size
+	pst.setLog(log, 0)
+	return nil
+}
+
+// syncDir commits the entries of the directory to stable storage, where supported.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
+
+// Close closes the change log. The PersistentTrie can no longer be modified, but can still be read from.
+func (pst *PersistentTrie) Close() error {
+	pst.mu.Lock()
+	defer pst.mu.Unlock()
+	if pst.log == nil {
+		return nil
+	}
+	err := pst.log.Sync()
+	if cerr := pst.log.Close(); err == nil {
+		err = cerr
+	}
+	pst.log = nil
+	return err
+}
+
+// Find returns the value from the most specific network (largest prefix) containing the given address.
+func (pst *PersistentTrie) Find(ip netip.Addr) any {
+	pst.mu.RLock()
+	defer pst.mu.RUnlock()
+	return pst.trie.Find(ip)
+}
+
+// FindLargest returns the value from the largest network (smallest prefix) containing the given address.
+func (pst *PersistentTrie) FindLargest(ip netip.Addr) any {
+	pst.mu.RLock()
+	defer pst.mu.RUnlock()
+	return pst.trie.FindLargest(ip)
+}
+
+// Contains indicates whether the trie contains the given ip.
+func (pst *PersistentTrie) Contains(ip netip.Addr) bool {
+	pst.mu.RLock()
+	defer pst.mu.RUnlock()
+	return pst.trie.Contains(ip)
+}
+
+// Snapshot returns an immutable point-in-time view of the trie.
+func (pst *PersistentTrie) Snapshot() *Trie {
+	pst.mu.Lock()
+	defer pst.mu.Unlock()
+	return pst.trie.Snapshot()
+}
+
+// String returns string representation of trie.
+func (pst *PersistentTrie) String() string {
+	pst.mu.RLock()
+	defer pst.mu.RUnlock()
+	return pst.trie.String()
+}
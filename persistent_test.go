@@ -0,0 +1,103 @@
+package iptrie
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPersistentTrie(t *testing.T) {
+	dir := t.TempDir()
+	pst, err := OpenPersistentTrie(dir, nil)
+	require.NoError(t, err)
+
+	for i := 0; i < 100; i++ {
+		network := GenLeafIPNet(GenIPV4())
+		require.NoError(t, pst.Insert(network, network.String()))
+	}
+	require.NoError(t, pst.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a"))
+	require.NoError(t, pst.Insert(netip.MustParsePrefix("2001:db8::/32"), nil))
+	v, err := pst.Remove(netip.MustParsePrefix("10.0.0.0/8"))
+	require.NoError(t, err)
+	assert.Equal(t, "a", v)
+	v, err = pst.Remove(netip.MustParsePrefix("10.0.0.0/8"))
+	require.NoError(t, err)
+	assert.Nil(t, v)
+	expected := pst.String()
+	require.NoError(t, pst.Close())
+	assert.ErrorIs(t, pst.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a"), os.ErrClosed)
+
+	pst, err = OpenPersistentTrie(dir, nil)
+	require.NoError(t, err)
+	assert.Equal(t, expected, pst.String())
+
+	require.NoError(t, pst.Compact())
+	require.NoError(t, pst.Insert(netip.MustParsePrefix("192.0.2.0/24"), "b"))
+	expected = pst.String()
+	require.NoError(t, pst.Close())
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	require.NoError(t, err)
+	assert.Len(t, files, 2)
+
+	pst, err = OpenPersistentTrie(dir, nil)
+	require.NoError(t, err)
+	defer pst.Close()
+	assert.Equal(t, expected, pst.String())
+	assert.Equal(t, "b", pst.Find(netip.MustParseAddr("192.0.2.1")))
+	assert.True(t, pst.Contains(netip.MustParseAddr("2001:db8::1")))
+}
+
+func TestPersistentTrieRecovery(t *testing.T) {
+	dir := t.TempDir()
+	pst, err := OpenPersistentTrie(dir, nil)
+	require.NoError(t, err)
+	require.NoError(t, pst.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a"))
+	require.NoError(t, pst.Insert(netip.MustParsePrefix("10.1.0.0/16"), "b"))
+	require.NoError(t, pst.Close())
+
+	// Simulate a crash part way through writing the last change, and during a compaction.
+	logs, err := filepath.Glob(filepath.Join(dir, "log-*"))
+	require.NoError(t, err)
+	require.Len(t, logs, 1)
+	info, err := os.Stat(logs[0])
+	require.NoError(t, err)
+	require.NoError(t, os.Truncate(logs[0], info.Size()-1))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "snapshot-0000000000000001.tmp"), []byte("partial"), 0o666))
+
+	pst, err = OpenPersistentTrie(dir, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "a", pst.Find(netip.MustParseAddr("10.1.0.1")))
+	require.NoError(t, pst.Insert(netip.MustParsePrefix("10.2.0.0/16"), "c"))
+	require.NoError(t, pst.Close())
+
+	pst, err = OpenPersistentTrie(dir, nil)
+	require.NoError(t, err)
+	defer pst.Close()
+	assert.Equal(t, "a", pst.Find(netip.MustParseAddr("10.1.0.1")))
+	assert.Equal(t, "c", pst.Find(netip.MustParseAddr("10.2.0.1")))
+	_, err = os.Stat(filepath.Join(dir, "snapshot-0000000000000001.tmp"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestPersistentTrieAutoCompact(t *testing.T) {
+	dir := t.TempDir()
+	pst, err := OpenPersistentTrie(dir, JSONCodec)
+	require.NoError(t, err)
+	pst.compactMin = 1 << 10
+
+	for i := 0; i < 1000; i++ {
+		require.NoError(t, pst.Insert(netip.MustParsePrefix("10.0.0.0/8"), float64(i)))
+	}
+	assert.Greater(t, pst.gen, uint64(1))
+	require.NoError(t, pst.Close())
+
+	pst, err = OpenPersistentTrie(dir, JSONCodec)
+	require.NoError(t, err)
+	defer pst.Close()
+	assert.Equal(t, 999.0, pst.Find(netip.MustParseAddr("10.0.0.1")))
+}
@@ -0,0 +1,130 @@
+package iptrie
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// PortRange is an inclusive range of transport ports, optionally restricted to a protocol, qualifying an entry inserted
+// with InsertWithPorts.
+type PortRange struct {
+	// Proto is the protocol the range applies to, such as "tcp" or "udp". If empty, the range applies to all protocols.
+	Proto       string
+	First, Last uint16
+}
+
+// ParsePortRange parses a port range of the form [proto/]first[-last], such as "443", "tcp/443" or "udp/1000-2000".
+func ParsePortRange(s string) (PortRange, error) {
+	var pr PortRange
+	ports := s
+	if i := strings.IndexByte(s, '/'); i >= 0 {
+		pr.Proto, ports = s[:i], s[i+1:]
+	}
+	firstStr, lastStr, isRange := strings.Cut(ports, "-")
+	first, err := strconv.ParseUint(firstStr, 10, 16)
+	if err != nil {
+		return PortRange{}, fmt.Errorf("iptrie: invalid port range %q", s)
+	}
+	last := first
+	if isRange {
+		if last, err = strconv.ParseUint(lastStr, 10, 16); err != nil || last < first {
+			return PortRange{}, fmt.Errorf("iptrie: invalid port range %q", s)
+		}
+	}
+	pr.First, pr.Last = uint16(first), uint16(last)
+	return pr, nil
+}
+
+// Contains indicates whether the range includes the given port of the given protocol. An empty proto matches ranges of
+// any protocol.
+func (pr PortRange) Contains(proto string, port uint16) bool {
+	return (pr.Proto == "" || proto == "" || pr.Proto == proto) && port >= pr.First && port <= pr.Last
+}
+
+// String returns the range in the form parsed by ParsePortRange.
+func (pr PortRange) String() string {
+	s := strconv.Itoa(int(pr.First))
+	if pr.Last != pr.First {
+		s += "-" + strconv.Itoa(int(pr.Last))
+	}
+	if pr.Proto != "" {
+		s = pr.Proto + "/" + s
+	}
+	return s
+}
+
+// PortQualified is the value stored for an entry inserted with InsertWithPorts.
+type PortQualified struct {
+	// Ports lists the ranges of the ports the entry applies to.
+	Ports []PortRange
+	// Value is the value which was inserted.
+	Value any
+}
+
+// contains indicates whether any of the ranges include the given port.
+func (pq PortQualified) contains(proto string, port uint16) bool {
+	for _, pr := range pq.Ports {
+		if pr.Contains(proto, port) {
+			return true
+		}
+	}
+	return false
+}
+
+// InsertWithPorts inserts an entry into the trie which only applies to the given ports, for use with FindAddrPort. For
+// example, an entry for 10.0.0.0/8 restricted to tcp/443 expresses a rule permitting only HTTPS from that network. The
+// entry's value is stored as a PortQualified, which is what lookups such as Find return. Values must be encoded with a
+// ValueCodec which supports PortQualified, such as JSONCodecOf[PortQualified].
+func (pt *Trie) InsertWithPorts(network netip.Prefix, value any, ports ...PortRange) {
+	pt.Insert(network, PortQualified{Ports: ports, Value: value})
+}
+
+// FindAddrPort returns the value from the most specific entry containing the address which applies to its port, of
+// any protocol. Entries not inserted with InsertWithPorts apply to all ports, so for a trie without port-qualified
+// entries, this is the same as Find of the address.
+//
+// For entries inserted with InsertWithPorts, the value within the PortQualified is returned. If SetPreserveOriginal is
+// enabled, it is returned within the entry's OriginalEntry.
+func (pt *Trie) FindAddrPort(ap netip.AddrPort) any {
+	return pt.FindProtoAddrPort("", ap)
+}
+
+// FindProtoAddrPort is like FindAddrPort, but only considers the port ranges for the given protocol, such as "tcp", and
+// those not restricted to a protocol.
+func (pt *Trie) FindProtoAddrPort(proto string, ap netip.AddrPort) any {
+	ip := ap.Addr()
+	if pt.zoneExcluded(ip) {
+		return pt.defaultValue
+	}
+	addr, port := lookupAddr128(ip), ap.Port()
+	var found any
+	matched := false
+	for n := &pt.node; n != nil && n.contains(addr); n = n.children[n.discriminatorBit(addr)] {
+		// As with Find, placeholders for nil values are skipped, except on a full address match.
+		if n.value != nil && (n.value != empty || n.bits == 128) {
+			v := unempty(n.value)
+			oe, original := v.(OriginalEntry)
+			if original {
+				v = oe.Value
+			}
+			if pq, ok := v.(PortQualified); !ok {
+				found, matched = unempty(n.value), true
+			} else if pq.contains(proto, port) {
+				if original {
+					found, matched = OriginalEntry{Network: oe.Network, Value: pq.Value}, true
+				} else {
+					found, matched = pq.Value, true
+				}
+			}
+		}
+		if n.bits == 128 {
+			break
+		}
+	}
+	if !matched {
+		return pt.defaultValue
+	}
+	return found
+}
@@ -0,0 +1,76 @@
+package iptrie
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePortRange(t *testing.T) {
+	for s, expected := range map[string]PortRange{
+		"443":           {First: 443, Last: 443},
+		"tcp/443":       {Proto: "tcp", First: 443, Last: 443},
+		"udp/1000-2000": {Proto: "udp", First: 1000, Last: 2000},
+		"0-65535":       {First: 0, Last: 65535},
+	} {
+		pr, err := ParsePortRange(s)
+		require.NoError(t, err, s)
+		assert.Equal(t, expected, pr, s)
+		assert.Equal(t, s, pr.String())
+	}
+
+	for _, s := range []string{"", "tcp/", "65536", "2000-1000", "tcp/80-", "x"} {
+		_, err := ParsePortRange(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestTrieFindAddrPort(t *testing.T) {
+	trie := NewTrie()
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), "any")
+	trie.InsertWithPorts(netip.MustParsePrefix("10.1.0.0/16"), "https", PortRange{Proto: "tcp", First: 443, Last: 443})
+	trie.InsertWithPorts(netip.MustParsePrefix("10.1.2.0/24"), "dns", PortRange{Proto: "udp", First: 53, Last: 53},
+		PortRange{Proto: "tcp", First: 53, Last: 53})
+	trie.InsertWithPorts(netip.MustParsePrefix("192.0.2.0/24"), nil, PortRange{First: 8000, Last: 8999})
+
+	find := func(proto, ap string) any {
+		return trie.FindProtoAddrPort(proto, netip.MustParseAddrPort(ap))
+	}
+	assert.Equal(t, "https", find("tcp", "10.1.0.1:443"))
+	assert.Equal(t, "any", find("udp", "10.1.0.1:443"))
+	assert.Equal(t, "any", find("tcp", "10.1.0.1:80"))
+	assert.Equal(t, "https", find("tcp", "10.1.2.3:443"))
+	assert.Equal(t, "dns", find("udp", "10.1.2.3:53"))
+	assert.Equal(t, "dns", find("tcp", "10.1.2.3:53"))
+	assert.Equal(t, "any", find("tcp", "10.2.0.1:53"))
+	assert.Equal(t, "https", trie.FindAddrPort(netip.MustParseAddrPort("10.1.0.1:443")))
+	assert.Equal(t, "any", trie.FindAddrPort(netip.MustParseAddrPort("[::ffff:10.1.0.1]:80")))
+
+	assert.Nil(t, find("tcp", "192.0.2.1:8080"))
+	assert.Nil(t, find("tcp", "192.0.2.1:80"))
+	trie.SetDefault("default")
+	assert.Nil(t, find("tcp", "192.0.2.1:8080"))
+	assert.Equal(t, "default", find("tcp", "192.0.2.1:80"))
+	assert.Equal(t, "default", find("tcp", "198.51.100.1:80"))
+
+	assert.Equal(t, PortQualified{Ports: []PortRange{{Proto: "tcp", First: 443, Last: 443}}, Value: "https"},
+		trie.Find(netip.MustParseAddr("10.1.0.1")))
+}
+
+func TestTrieFindAddrPortPreserveOriginal(t *testing.T) {
+	trie := NewTrie()
+	trie.SetPreserveOriginal(true)
+	trie.Insert(netip.MustParsePrefix("10.0.0.1/8"), "any")
+	trie.InsertWithPorts(netip.MustParsePrefix("10.1.0.1/16"), "https", PortRange{Proto: "tcp", First: 443, Last: 443})
+
+	find := func(proto, ap string) any {
+		return trie.FindProtoAddrPort(proto, netip.MustParseAddrPort(ap))
+	}
+	assert.Equal(t, OriginalEntry{Network: netip.MustParsePrefix("10.1.0.1/16"), Value: "https"},
+		find("tcp", "10.1.0.1:443"))
+	assert.Equal(t, OriginalEntry{Network: netip.MustParsePrefix("10.0.0.1/8"), Value: "any"},
+		find("tcp", "10.1.0.1:80"))
+	assert.Nil(t, find("tcp", "192.0.2.1:443"))
+}
@@ -0,0 +1,52 @@
+package iptrie
+
+import "net/netip"
+
+// Prioritized is the value stored for an entry inserted with InsertWithPriority.
+type Prioritized struct {
+	// Priority ranks the entry against the other entries containing an address, with greater values taking precedence.
+	Priority int
+	// Value is the value which was inserted.
+	Value any
+}
+
+// InsertWithPriority inserts an entry into the trie with the given priority, for use with FindByPriority. The entry's
+// value is stored as a Prioritized, which is what lookups such as Find return. Values must be encoded with a ValueCodec
+// which supports Prioritized, such as JSONCodecOf[Prioritized].
+func (pt *Trie) InsertWithPriority(network netip.Prefix, value any, priority int) {
+	pt.Insert(network, Prioritized{Priority: priority, Value: value})
+}
+
+// FindByPriority returns the value from the entry with the highest priority containing the given address, rather than
+// from the most specific one as Find does. Among entries with the same priority, the most specific one is used. Entries
+// not inserted with InsertWithPriority have a priority of 0.
+//
+// For entries inserted with InsertWithPriority, the value within the Prioritized is returned.
+func (pt *Trie) FindByPriority(ip netip.Addr) any {
+	if pt.zoneExcluded(ip) {
+		return pt.defaultValue
+	}
+	addr := lookupAddr128(ip)
+	var found any
+	var foundPriority int
+	matched := false
+	for n := &pt.node; n != nil && n.contains(addr); n = n.children[n.discriminatorBit(addr)] {
+		// As with Find, placeholders for nil values are skipped, except on a full address match.
+		if n.value != nil && (n.value != empty || n.bits == 128) {
+			v, priority := unempty(n.value), 0
+			if p, ok := v.(Prioritized); ok {
+				v, priority = p.Value, p.Priority
+			}
+			if !matched || priority >= foundPriority {
+				found, foundPriority, matched = v, priority, true
+			}
+		}
+		if n.bits == 128 {
+			break
+		}
+	}
+	if !matched {
+		return pt.defaultValue
+	}
+	return found
+}
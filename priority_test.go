@@ -0,0 +1,32 @@
+package iptrie
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrieFindByPriority(t *testing.T) {
+	trie := NewTrie()
+	trie.InsertWithPriority(netip.MustParsePrefix("10.0.0.0/8"), "deny", 100)
+	trie.InsertWithPriority(netip.MustParsePrefix("10.1.0.0/16"), "allow", 10)
+	trie.InsertWithPriority(netip.MustParsePrefix("10.1.2.0/24"), "log", 100)
+	trie.Insert(netip.MustParsePrefix("192.0.2.0/24"), "plain")
+	trie.InsertWithPriority(netip.MustParsePrefix("192.0.2.128/25"), "low", -1)
+	trie.InsertWithPriority(netip.MustParsePrefix("2001:db8::/32"), nil, 5)
+
+	assert.Equal(t, "deny", trie.FindByPriority(netip.MustParseAddr("10.1.0.1")))
+	assert.Equal(t, "log", trie.FindByPriority(netip.MustParseAddr("10.1.2.3")))
+	assert.Equal(t, "deny", trie.FindByPriority(netip.MustParseAddr("10.2.0.1")))
+	assert.Equal(t, "plain", trie.FindByPriority(netip.MustParseAddr("192.0.2.200")))
+	assert.Nil(t, trie.FindByPriority(netip.MustParseAddr("2001:db8::1")))
+	assert.Nil(t, trie.FindByPriority(netip.MustParseAddr("198.51.100.1")))
+
+	// Find still returns the most specific entry.
+	assert.Equal(t, Prioritized{Priority: 10, Value: "allow"}, trie.Find(netip.MustParseAddr("10.1.0.1")))
+
+	trie.SetDefault("default")
+	assert.Equal(t, "default", trie.FindByPriority(netip.MustParseAddr("198.51.100.1")))
+	assert.Nil(t, trie.FindByPriority(netip.MustParseAddr("2001:db8::1")))
+}
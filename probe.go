@@ -0,0 +1,196 @@
+package iptrie
+
+import (
+	"net/netip"
+	"sync/atomic"
+)
+
+// maxProbes is the most nodes a lookup can visit: one for each prefix length from the root (/0) to a full address.
+const maxProbes = 129
+
+// ProbeProfile is a histogram of the number of nodes visited by each lookup, for quantifying how the shape of a trie
+// affects lookup latency. Deep tries, such as those with many long IPv6 networks, visit more nodes per lookup, which
+// backends such as CompiledTrie and MultibitTrie bound at the cost of memory. See Trie.SetProbeProfile.
+//
+// The zero value is an empty profile ready to use. It is safe for concurrent use, so it may be shared by concurrent
+// readers, and by multiple tries to profile them together.
+type ProbeProfile struct {
+	counts [maxProbes + 1]atomic.Uint64
+}
+
+// record counts a lookup which visited the given number of nodes.
+func (pp *ProbeProfile) record(visited int) {
+	pp.counts[visited].Add(1)
+}
+
+// Histogram returns the number of lookups by the number of nodes they visited, so that element i is the number of
+// lookups which visited i nodes. It is truncated after the largest number of nodes any lookup visited.
+//
+// Lookups which visit no nodes are those answered without traversing the trie, such as an IPv4 lookup by FindLargest
+// when an entry contains all of IPv4.
+func (pp *ProbeProfile) Histogram() []uint64 {
+	var hist []uint64
+	for i := range pp.counts {
+		if count := pp.counts[i].Load(); count != 0 {
+			for len(hist) < i {
+				hist = append(hist, 0)
+			}
+			hist = append(hist, count)
+		}
+	}
+	return hist
+}
+
+// Lookups returns the number of lookups recorded.
+func (pp *ProbeProfile) Lookups() uint64 {
+	var total uint64
+	for i := range pp.counts {
+		total += pp.counts[i].Load()
+	}
+	return total
+}
+
+// Mean returns the average number of nodes visited per lookup, or 0 if no lookups were recorded.
+func (pp *ProbeProfile) Mean() float64 {
+	var total, sum uint64
+	for i := range pp.counts {
+		count := pp.counts[i].Load()
+		total += count
+		sum += count * uint64(i)
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(sum) / float64(total)
+}
+
+// Percentile returns the number of nodes which p percent of lookups visited at most, such as 99 for the 99th
+// percentile, or 0 if no lookups were recorded.
+func (pp *ProbeProfile) Percentile(p float64) int {
+	hist := pp.Histogram()
+	var total uint64
+	for _, count := range hist {
+		total += count
+	}
+	if total == 0 {
+		return 0
+	}
+	rank := uint64(p / 100 * float64(total))
+	if rank >= total {
+		rank = total - 1
+	}
+	var seen uint64
+	for visited, count := range hist {
+		seen += count
+		if seen > rank {
+			return visited
+		}
+	}
+	return len(hist) - 1
+}
+
+// Reset clears the recorded lookups.
+func (pp *ProbeProfile) Reset() {
+	for i := range pp.counts {
+		pp.counts[i].Store(0)
+	}
+}
+
+// SetProbeProfile sets the ProbeProfile recording the number of nodes visited by each call of Find, FindLargest and
+// Contains. Passing nil disables profiling.
+//
+// Lookups of snapshots record into the same profile, as do those of the tries created with NewRCUTrieFrom and
+// NewVersionedTrieFrom. Lookups are slightly slower while profiling, as they take a separate path which counts the
+// nodes. Find is not profiled while entry tracking is enabled (see SetTrackEntries), as it takes its own path.
+func (pt *Trie) SetProbeProfile(pp *ProbeProfile) {
+	pt.probes = pp
+}
+
+// SetProbeProfile sets the ProbeProfile recording the number of nodes visited by each lookup. See
+// Trie.SetProbeProfile.
+func (rt *RCUTrie) SetProbeProfile(pp *ProbeProfile) {
+	rt.update(func(pt *Trie) {
+		pt.SetProbeProfile(pp)
+	})
+}
+
+// findProbed is like findValue, but records the number of nodes visited.
+func (pt *Trie) findProbed(ip netip.Addr) any {
+	if ip.Is4() && pt.v4.start != nil {
+		n, visited := pt.v4.start.findNodeProbed(v4Addr128(ip))
+		pt.probes.record(visited)
+		if n == nil {
+			return pt.v4.last
+		}
+		return n.value
+	}
+	if pt.zoneExcluded(ip) {
+		pt.probes.record(0)
+		return nil
+	}
+	n, visited := pt.findNodeProbed(lookupAddr128(ip))
+	pt.probes.record(visited)
+	if n == nil {
+		return nil
+	}
+	return n.value
+}
+
+// findLargestProbed is like findLargestValue, but records the number of nodes visited.
+func (pt *Trie) findLargestProbed(ip netip.Addr) any {
+	start, addr := &pt.node, lookupAddr128(ip)
+	if ip.Is4() && pt.v4.start != nil {
+		if pt.v4.first != nil {
+			pt.probes.record(0)
+			return pt.v4.first
+		}
+		start = pt.v4.start
+	} else if pt.zoneExcluded(ip) {
+		pt.probes.record(0)
+		return nil
+	}
+	v, visited := start.findLargestProbed(addr)
+	pt.probes.record(visited)
+	return v
+}
+
+// findNodeProbed is like findNode, but also returns the number of nodes visited, including the last node examined,
+// which may not contain ip.
+func (pt *node) findNodeProbed(ip uint128) (*node, int) {
+	var found *node
+	visited := 0
+	for n := pt; n != nil; n = n.children[n.discriminatorBit(ip)] {
+		visited++
+		if !n.contains(ip) {
+			break
+		}
+		if n.bits == 128 {
+			if n.value != nil {
+				return n, visited
+			}
+			break
+		}
+		if n.value != nil && n.value != empty {
+			found = n
+		}
+	}
+	return found, visited
+}
+
+// findLargestProbed is like findLargest, but also returns the number of nodes visited.
+func (pt *node) findLargestProbed(ip uint128) (any, int) {
+	visited := 0
+	for n := pt; n != nil; n = n.children[n.discriminatorBit(ip)] {
+		visited++
+		if !n.contains(ip) {
+			break
+		}
+		if n.value != nil {
+			return n.value, visited
+		}
+		if n.bits == 128 {
+			break
+		}
+	}
+	return nil, visited
+}
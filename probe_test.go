@@ -0,0 +1,77 @@
+package iptrie
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProbeProfile(t *testing.T) {
+	trie := NewTrie()
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	trie.Insert(netip.MustParsePrefix("10.1.0.0/16"), "b")
+	trie.Insert(netip.MustParsePrefix("10.2.0.0/16"), "c")
+	trie.Insert(netip.MustParsePrefix("2001:db8::/32"), "v6")
+
+	var pp ProbeProfile
+	trie.SetProbeProfile(&pp)
+	assert.Equal(t, "b", trie.Find(netip.MustParseAddr("10.1.0.1")))
+	assert.Equal(t, "a", trie.Find(netip.MustParseAddr("10.3.0.1")))
+	assert.Nil(t, trie.Find(netip.MustParseAddr("11.0.0.1")))
+	assert.Equal(t, "v6", trie.Find(netip.MustParseAddr("2001:db8::1")))
+	assert.Equal(t, "a", trie.FindLargest(netip.MustParseAddr("10.1.0.1")))
+	assert.True(t, trie.Contains(netip.MustParseAddr("10.2.0.1")))
+	assert.Equal(t, "b", trie.FindRaw(0, 0xffff0a010001))
+
+	// IPv4 lookups start at the IPv4 shortcut (::/2), and visit ::ffff:10.0.0.0/104, the implicit ::ffff:10.0.0.0/110,
+	// and the /112 beneath it. Misses stop at the first node not containing the address, so 11.0.0.1 visits 2 nodes, as do
+	// FindLargest and Contains, which stop at the /104. While profiling, FindRaw looks up the IPv4-mapped address, which
+	// starts at the root rather than the shortcut.
+	assert.Equal(t, []uint64{0, 0, 3, 1, 2, 1}, pp.Histogram())
+	assert.EqualValues(t, 7, pp.Lookups())
+	assert.InDelta(t, 22.0/7, pp.Mean(), 0.0001)
+	assert.Equal(t, 3, pp.Percentile(50))
+	assert.Equal(t, 5, pp.Percentile(100))
+
+	// The profile is inherited by snapshots.
+	snap := trie.Snapshot()
+	snap.Find(netip.MustParseAddr("10.1.0.1"))
+	assert.EqualValues(t, 8, pp.Lookups())
+
+	pp.Reset()
+	assert.Nil(t, pp.Histogram())
+	assert.Zero(t, pp.Mean())
+	assert.Zero(t, pp.Percentile(99))
+
+	trie.SetProbeProfile(nil)
+	trie.Find(netip.MustParseAddr("10.1.0.1"))
+	assert.Zero(t, pp.Lookups())
+}
+
+func TestProbeProfileMatchesFind(t *testing.T) {
+	trie := NewTrie()
+	for i := 0; i < 1000; i++ {
+		ip := GenIPV4()
+		network, _ := ip.Prefix(int(ip.As4()[3]%25) + 8)
+		trie.Insert(network, i)
+	}
+	trie.Insert(netip.MustParsePrefix("2001:db8::/32"), "v6")
+
+	var ips []netip.Addr
+	for i := 0; i < 1000; i++ {
+		ips = append(ips, GenIPV4())
+	}
+	ips = append(ips, netip.MustParseAddr("2001:db8::1"), netip.MustParseAddr("::1"))
+	want := map[netip.Addr][2]any{}
+	for _, ip := range ips {
+		want[ip] = [2]any{trie.Find(ip), trie.FindLargest(ip)}
+	}
+
+	var pp ProbeProfile
+	trie.SetProbeProfile(&pp)
+	for _, ip := range ips {
+		assert.Equal(t, want[ip], [2]any{trie.Find(ip), trie.FindLargest(ip)}, "ip=%s", ip)
+	}
+	assert.EqualValues(t, 2*len(ips), pp.Lookups())
+}
@@ -0,0 +1,189 @@
+package iptrie
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/netip"
+)
+
+// The protocol buffer encoding is implemented directly, rather than with generated code, to avoid a dependency on the
+// protobuf runtime. It must remain compatible with the schema in proto/iptrie.proto.
+const (
+	protoWireVarint  = 0
+	protoWireFixed64 = 1
+	protoWireBytes   = 2
+	protoWireFixed32 = 5
+
+	protoSnapshotEntries = 1
+	protoEntryAddr       = 1
+	protoEntryBits       = 2
+	protoEntryValue      = 3
+)
+
+// ErrInvalidProto is returned when data is not a valid protocol buffer encoded trie.
+var ErrInvalidProto = errors.New("iptrie: invalid protobuf trie data")
+
+// MarshalProto encodes the entries of the trie as an iptrie.Snapshot protocol buffer message, as defined in
+// proto/iptrie.proto, allowing the trie to be exchanged with non-Go services. IPv4 networks are encoded in their IPv4
+// form. codec is used to encode values, and if nil, StringCodec is used.
+func (pt *Trie) MarshalProto(codec ValueCodec) ([]byte, error) {
+	if codec == nil {
+		codec = StringCodec
+	}
+	var buf, entry []byte
+	var err error
+	pt.walk(func(n *node) bool {
+		network := denormalizePrefix(n.network())
+		entry = protoAppendBytes(entry[:0], protoEntryAddr, network.Addr().AsSlice())
+		if network.Bits() != 0 {
+			entry = protoAppendVarint(entry, protoEntryBits, uint64(network.Bits()))
+		}
+		if n.value != empty {
+			var value []byte
+			if value, err = codec.Encode(n.value); err != nil {
+				err = fmt.Errorf("encoding value for %s: %w", network, err)
+				return false
+			}
+			entry = protoAppendBytes(entry, protoEntryValue, value)
+		}
+		buf = protoAppendBytes(buf, protoSnapshotEntries, entry)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// UnmarshalProto replaces the contents of the trie with the entries of an iptrie.Snapshot protocol buffer message.
+// codec is used to decode values, and if nil, StringCodec is used.
+//
+// The trie is only modified if data is successfully decoded.
+func (pt *Trie) UnmarshalProto(data []byte, codec ValueCodec) error {
+	if codec == nil {
+		codec = StringCodec
+	}
+	var entries []pfxEntry
+	err := protoFields(data, func(field uint64, wire uint8, v uint64, b []byte) error {
+		if field != protoSnapshotEntries {
+			return nil
+		}
+		if wire != protoWireBytes {
+			return ErrInvalidProto
+		}
+		entry, err := protoEntry(b, codec)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	pt.replaceRoot(pt.entriesRoot(entries))
+	return nil
+}
+
+// protoEntry decodes an iptrie.Entry message.
+func protoEntry(data []byte, codec ValueCodec) (pfxEntry, error) {
+	var entry pfxEntry
+	var addr []byte
+	var bits uint64
+	err := protoFields(data, func(field uint64, wire uint8, v uint64, b []byte) error {
+		switch field {
+		case protoEntryAddr:
+			if wire != protoWireBytes {
+				return ErrInvalidProto
+			}
+			addr = b
+		case protoEntryBits:
+			if wire != protoWireVarint {
+				return ErrInvalidProto
+			}
+			bits = v
+		case protoEntryValue:
+			if wire != protoWireBytes {
+				return ErrInvalidProto
+			}
+			value, err := codec.Decode(b)
+			if err != nil {
+				return err
+			}
+			entry.Value = value
+		}
+		return nil
+	})
+	if err != nil {
+		return entry, err
+	}
+
+	ip, ok := netip.AddrFromSlice(addr)
+	if !ok || bits > uint64(ip.BitLen()) {
+		return entry, fmt.Errorf("%w: invalid network", ErrInvalidProto)
+	}
+	entry.Prefix = netip.PrefixFrom(ip, int(bits))
+	return entry, nil
+}
+
+// protoFields calls fn for each field of the encoded message. For varint fields v holds the value, and for length
+// delimited fields b holds the data. Fixed width fields are skipped.
+func protoFields(data []byte, fn func(field uint64, wire uint8, v uint64, b []byte) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return ErrInvalidProto
+		}
+		data = data[n:]
+		field, wire := key>>3, uint8(key&7)
+		if field == 0 {
+			return ErrInvalidProto
+		}
+
+		var v uint64
+		var b []byte
+		switch wire {
+		case protoWireVarint:
+			if v, n = binary.Uvarint(data); n <= 0 {
+				return ErrInvalidProto
+			}
+			data = data[n:]
+		case protoWireBytes:
+			size, n := binary.Uvarint(data)
+			if n <= 0 || size > uint64(len(data)-n) {
+				return ErrInvalidProto
+			}
+			b = data[n : n+int(size)]
+			data = data[n+int(size):]
+		case protoWireFixed64, protoWireFixed32:
+			size := 8
+			if wire == protoWireFixed32 {
+				size = 4
+			}
+			if len(data) < size {
+				return ErrInvalidProto
+			}
+			data = data[size:]
+			continue
+		default:
+			return ErrInvalidProto
+		}
+		if err := fn(field, wire, v, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func protoAppendVarint(buf []byte, field uint64, v uint64) []byte {
+	buf = binary.AppendUvarint(buf, field<<3|protoWireVarint)
+	return binary.AppendUvarint(buf, v)
+}
+
+func protoAppendBytes(buf []byte, field uint64, b []byte) []byte {
+	buf = binary.AppendUvarint(buf, field<<3|protoWireBytes)
+	buf = binary.AppendUvarint(buf, uint64(len(b)))
+	return append(buf, b...)
+}
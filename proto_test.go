@@ -0,0 +1,73 @@
+package iptrie
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrieMarshalProto(t *testing.T) {
+	trie := NewTrie()
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	trie.Insert(netip.MustParsePrefix("2001:db8::/32"), nil)
+
+	data, err := trie.MarshalProto(nil)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{
+		// entries: {addr: 10.0.0.0, bits: 8, value: "a"}
+		0x0a, 0x0b,
+		0x0a, 0x04, 10, 0, 0, 0,
+		0x10, 0x08,
+		0x1a, 0x01, 'a',
+		// entries: {addr: 2001:db8::, bits: 32}
+		0x0a, 0x14,
+		0x0a, 0x10, 0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		0x10, 0x20,
+	}, data)
+
+	loaded := NewTrie()
+	loaded.Insert(netip.MustParsePrefix("192.0.2.0/24"), "removed")
+	require.NoError(t, loaded.UnmarshalProto(data, nil))
+	assert.Equal(t, trie.String(), loaded.String())
+	assert.Nil(t, loaded.Find(netip.MustParseAddr("192.0.2.1")))
+
+	// Unknown fields are skipped.
+	data = append(data, 0x10, 0x01, 0x1d, 1, 2, 3, 4)
+	require.NoError(t, loaded.UnmarshalProto(data, nil))
+	assert.Equal(t, trie.String(), loaded.String())
+}
+
+func TestTrieUnmarshalProtoChangeLog(t *testing.T) {
+	src := NewTrie()
+	src.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	src.Insert(netip.MustParsePrefix("192.0.2.1/32"), nil)
+	data, err := src.MarshalProto(nil)
+	require.NoError(t, err)
+
+	trie := NewTrie()
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), "old")
+	var changes []Change
+	trie.SetChangeLog(func(c Change) {
+		changes = append(changes, c)
+	})
+	require.NoError(t, trie.UnmarshalProto(data, nil))
+	assert.Empty(t, changes)
+	assert.Equal(t, src.String(), trie.String())
+}
+
+func TestTrieUnmarshalProtoInvalid(t *testing.T) {
+	trie := NewTrie()
+	trie.Insert(netip.MustParsePrefix("192.0.2.0/24"), "orig")
+	for _, data := range [][]byte{
+		{0x0a, 0x05, 0x0a, 0x03, 1, 2, 3},
+		{0x0a, 0x08, 0x0a, 0x04, 10, 0, 0, 0, 0x10, 0x21},
+		{0x0a, 0x10},
+		{0x08, 0x01},
+		{0x0b},
+	} {
+		assert.ErrorIs(t, trie.UnmarshalProto(data, nil), ErrInvalidProto, "data=%x", data)
+	}
+	assert.Equal(t, "orig", trie.Find(netip.MustParseAddr("192.0.2.1")))
+}
@@ -0,0 +1,40 @@
+package iptrie
+
+import "net/netip"
+
+// InsertRaw is like Insert, but takes the network as integers, for callers which already hold addresses in this form,
+// such as packet-processing pipelines, avoiding the conversion to and from netip.Prefix.
+//
+// hi and lo are the upper and lower 64 bits of the address in its 128-bit IPv6 form, and bits is the prefix length of
+// the network in that form. IPv4 networks must be given in their IPv4-mapped form, as they are stored, so 192.0.2.0/24
+// is hi=0, lo=0xffff_c000_0200, bits=120. Address bits beyond the prefix length are ignored. InsertRaw panics if bits
+// is greater than 128.
+//
+// The conversion is only avoided when the trie has no hooks, change log, entry tracking, instrumentation, merge
+// function or SetPreserveOriginal, as these report the network.
+func (pt *Trie) InsertRaw(hi, lo uint64, bits int, value any) {
+	if bits < 0 || bits > 128 {
+		panic("iptrie: invalid prefix length for InsertRaw")
+	}
+	if pt.hooks != nil || pt.changeLog != nil || pt.stats != nil || pt.instr != nil || pt.merge != nil ||
+		pt.preserveOriginal {
+		pt.Insert(netip.PrefixFrom(addrFrom128(uint128{hi, lo}), bits), value)
+		return
+	}
+	pt.insert(uint128{hi, lo}.and(mask6(bits)), uint8(bits), emptyize(value))
+	pt.refreshV4()
+}
+
+// FindRaw is like Find, but takes the address as integers, in the same form as InsertRaw, avoiding the conversion from
+// netip.Addr. As with InsertRaw, the conversion is still performed when entry tracking, instrumentation or probe
+// profiling is enabled.
+func (pt *Trie) FindRaw(hi, lo uint64) any {
+	ip := uint128{hi, lo}
+	if pt.stats != nil || pt.instr != nil || pt.probes != nil {
+		return pt.Find(addrFrom128(ip))
+	}
+	if pt.v4.start != nil && netContains(v4Prefix, 96, ip) {
+		return pt.result(pt.v4.find(ip))
+	}
+	return pt.result(pt.find(ip))
+}
@@ -0,0 +1,69 @@
+package iptrie
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrieInsertRaw(t *testing.T) {
+	trie := NewTrie()
+	trie.InsertRaw(0, 0xffff_0a00_0000, 104, "10.0.0.0/8")
+	trie.InsertRaw(0, 0xffff_c000_02ff, 120, "192.0.2.0/24") // host bits are ignored
+	trie.InsertRaw(0x2001_0db8_0000_0000, 0, 32, "2001:db8::/32")
+	trie.InsertRaw(0, 0, 0, nil)
+
+	assert.Equal(t, []netip.Prefix{
+		netip.MustParsePrefix("::/0"),
+		netip.MustParsePrefix("::ffff:10.0.0.0/104"),
+		netip.MustParsePrefix("::ffff:192.0.2.0/120"),
+		netip.MustParsePrefix("2001:db8::/32"),
+	}, trie.CoveredNetworks(netip.MustParsePrefix("::/0")))
+	assert.Equal(t, "10.0.0.0/8", trie.Find(netip.MustParseAddr("10.1.2.3")))
+	assert.Equal(t, "192.0.2.0/24", trie.Find(netip.MustParseAddr("192.0.2.1")))
+	assert.Equal(t, "2001:db8::/32", trie.Find(netip.MustParseAddr("2001:db8::1")))
+	assert.True(t, trie.Contains(netip.MustParseAddr("198.51.100.1")))
+
+	assert.Panics(t, func() { trie.InsertRaw(0, 0, 129, nil) })
+}
+
+func TestTrieInsertRawHooks(t *testing.T) {
+	trie := NewTrie()
+	var inserted []netip.Prefix
+	trie.OnInsert(func(network netip.Prefix, value any) {
+		inserted = append(inserted, network)
+	})
+	trie.InsertRaw(0, 0xffff_0a01_0203, 104, 1)
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("::ffff:10.0.0.0/104")}, inserted)
+	assert.Equal(t, 1, trie.Find(netip.MustParseAddr("10.9.9.9")))
+}
+
+func TestTrieFindRaw(t *testing.T) {
+	trie := NewTrie()
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	trie.Insert(netip.MustParsePrefix("10.1.0.0/16"), nil)
+	trie.Insert(netip.MustParsePrefix("2001:db8::/32"), "b")
+
+	assert.Equal(t, "a", trie.FindRaw(0, 0xffff_0a02_0304))
+	assert.Equal(t, trie.Find(netip.MustParseAddr("10.1.3.4")), trie.FindRaw(0, 0xffff_0a01_0304))
+	assert.Equal(t, "b", trie.FindRaw(0x2001_0db8_0000_0000, 1))
+	assert.Nil(t, trie.FindRaw(0, 0xffff_c000_0201))
+
+	trie.SetDefault("default")
+	assert.Equal(t, "default", trie.FindRaw(0, 0xffff_c000_0201))
+
+	trie.SetTrackEntries(true)
+	assert.Equal(t, "a", trie.FindRaw(0, 0xffff_0a02_0304))
+	info, _ := trie.EntryInfo(netip.MustParsePrefix("10.0.0.0/8"))
+	assert.EqualValues(t, 1, info.Hits)
+}
+
+func TestTrieFindRawAllocs(t *testing.T) {
+	trie := NewTrie()
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	allocs := testing.AllocsPerRun(100, func() {
+		trie.FindRaw(0, 0xffff_0a02_0304)
+	})
+	assert.Zero(t, allocs)
+}
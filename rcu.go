@@ -0,0 +1,202 @@
+package iptrie
+
+import (
+	"net/netip"
+	"sync"
+	"sync/atomic"
+)
+
+// RCUTrie is a Trie which can be read concurrently with writes, without readers ever acquiring a lock.
+//
+// Writes are serialized, and are performed against copies of the nodes being modified (copy-on-write). Once a write is
+// complete, the new version of the trie is published with an atomic pointer swap. Readers which loaded the previous
+// version continue to see it unchanged.
+//
+// Each write copies the nodes along the path to the modified entry, so writes are more expensive than on a plain Trie.
+type RCUTrie struct {
+	mu   sync.Mutex
+	root atomic.Pointer[Trie]
+}
+
+// NewRCUTrie creates a new RCUTrie.
+func NewRCUTrie() *RCUTrie {
+	rt := &RCUTrie{}
+	rt.root.Store(NewTrie())
+	return rt
+}
+
+// NewRCUTrieFrom creates a new RCUTrie starting with the entries and settings of pt, such as its hooks and default
+// value. pt is left unchanged, and may continue to be used independently, as the RCUTrie starts from a snapshot of it.
+//
+// NewRCUTrieFrom must be called on the root of a trie, and must not be called concurrently with modifications of it.
+func NewRCUTrieFrom(pt *Trie) *RCUTrie {
+	rt := &RCUTrie{}
+	rt.root.Store(pt.Snapshot())
+	return rt
+}
+
+// Load returns the current version of the trie. This can be used to perform multiple lookups against a consistent
+// view.
+//
+// The returned Trie must not be modified.
+func (rt *RCUTrie) Load() *Trie {
+	return rt.root.Load()
+}
+
+// Insert inserts an entry into the trie.
+func (rt *RCUTrie) Insert(network netip.Prefix, value any) {
+	rt.update(func(pt *Trie) {
+		pt.Insert(network, value)
+	})
+}
+
+// Remove removes the entry identified by given network from trie.
+func (rt *RCUTrie) Remove(network netip.Prefix) any {
+	var v any
+	rt.update(func(pt *Trie) {
+		v = pt.Remove(network)
+	})
+	return v
+}
+
+// update applies fn to a new copy-on-write version of the trie, and then publishes it.
+func (rt *RCUTrie) update(fn func(pt *Trie)) {
+	txn := rt.Txn()
+	fn(txn.root)
+	txn.Commit()
+}
+
+// Txn starts a transaction, batching multiple modifications so that readers either see all of them or none of them.
+//
+// Only one transaction (or other write) may be in progress at a time. Txn blocks until any other write has finished,
+// and other writes will block until the transaction is committed or aborted. The transaction must therefore always be
+// finished with either Commit or Abort.
+func (rt *RCUTrie) Txn() *Txn {
+	rt.mu.Lock()
+	txn := &Txn{
+		rt:   rt,
+		root: rt.root.Load().cow(),
+	}
+	txn.root.effects = &txn.effects
+	return txn
+}
+
+// Txn is a set of modifications to an RCUTrie which are published atomically. It is created with RCUTrie.Txn.
+//
+// The side effects of the modifications, being the records passed to the change log (see Trie.SetChangeLog), the calls
+// of hooks such as Trie.OnInsert, and the updates to entry tracking (see Trie.SetTrackEntries), are deferred until the
+// transaction is committed, once readers can observe the modifications. If the transaction is aborted, they never
+// occur.
+//
+// A Txn is not safe for concurrent use, and must not be used after Commit or Abort.
+type Txn struct {
+	rt   *RCUTrie
+	root *Trie
+	// effects are the side effects of the modifications, to be applied on Commit.
+	effects []func()
+}
+
+// Insert inserts an entry into the trie.
+func (txn *Txn) Insert(network netip.Prefix, value any) {
+	txn.root.Insert(network, value)
+}
+
+// Remove removes the entry identified by given network from trie.
+func (txn *Txn) Remove(network netip.Prefix) any {
+	return txn.root.Remove(network)
+}
+
+// Trie returns the transaction's version of the trie, including all modifications made so far. This can be used to
+// perform lookups which observe the uncommitted modifications.
+//
+// The returned Trie must not be modified directly, and must not be used after the transaction is finished.
+func (txn *Txn) Trie() *Trie {
+	return txn.root
+}
+
+// Commit publishes all modifications made in the transaction, and then applies their side effects in the order the
+// modifications were made.
+func (txn *Txn) Commit() {
+	txn.root.effects = nil
+	txn.rt.root.Store(txn.root)
+	for _, fn := range txn.effects {
+		fn()
+	}
+	txn.finish()
+}
+
+// Abort discards all modifications made in the transaction.
+func (txn *Txn) Abort() {
+	txn.finish()
+}
+
+func (txn *Txn) finish() {
+	rt := txn.rt
+	txn.rt = nil
+	txn.root = nil
+	txn.effects = nil
+	rt.mu.Unlock()
+}
+
+// Find returns the value from the most specific network (largest prefix) containing the given address.
+func (rt *RCUTrie) Find(ip netip.Addr) any {
+	return rt.Load().Find(ip)
+}
+
+// FindLargest returns the value from the largest network (smallest prefix) containing the given address.
+func (rt *RCUTrie) FindLargest(ip netip.Addr) any {
+	return rt.Load().FindLargest(ip)
+}
+
+// Contains indicates whether the trie contains the given ip.
+func (rt *RCUTrie) Contains(ip netip.Addr) bool {
+	return rt.Load().Contains(ip)
+}
+
+// ContainingNetworks returns the list of networks containing the given ip in ascending prefix order (largest network to
+// smallest).
+//
+// Note: Inserted addresses are normalized to IPv6, so the returned list will be IPv6 only.
+func (rt *RCUTrie) ContainingNetworks(ip netip.Addr) []netip.Prefix {
+	return rt.Load().ContainingNetworks(ip)
+}
+
+// ContainingNetworksAppend is like ContainingNetworks, but appends the networks to dst and returns the extended slice.
+func (rt *RCUTrie) ContainingNetworksAppend(dst []netip.Prefix, ip netip.Addr) []netip.Prefix {
+	return rt.Load().ContainingNetworksAppend(dst, ip)
+}
+
+// CoveredNetworks returns the list of networks contained within the given network.
+//
+// Note: Inserted addresses are normalized to IPv6, so the returned list will be IPv6 only.
+func (rt *RCUTrie) CoveredNetworks(network netip.Prefix) []netip.Prefix {
+	return rt.Load().CoveredNetworks(network)
+}
+
+// CoveredNetworksAppend is like CoveredNetworks, but appends the networks to dst and returns the extended slice.
+func (rt *RCUTrie) CoveredNetworksAppend(dst []netip.Prefix, network netip.Prefix) []netip.Prefix {
+	return rt.Load().CoveredNetworksAppend(dst, network)
+}
+
+// IsFullyCovered indicates whether every address within the given network is covered by an entry in the trie.
+func (rt *RCUTrie) IsFullyCovered(network netip.Prefix) bool {
+	return rt.Load().IsFullyCovered(network)
+}
+
+// Walk calls fn for each entry of the current version of the trie in depth order, until fn returns false. Modifications
+// made during the walk are not seen by it.
+func (rt *RCUTrie) Walk(fn func(network netip.Prefix, value any) bool) {
+	rt.Load().Walk(fn)
+}
+
+// String returns string representation of trie.
+func (rt *RCUTrie) String() string {
+	return rt.Load().String()
+}
+
+// Snapshot returns an immutable point-in-time view of the trie.
+//
+// As each version of an RCUTrie is already immutable, this is equivalent to Load.
+func (rt *RCUTrie) Snapshot() *Trie {
+	return rt.Load()
+}
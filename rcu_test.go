@@ -0,0 +1,115 @@
+package iptrie
+
+import (
+	"net/netip"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRCUTrieVersionIsolation(t *testing.T) {
+	rt := NewRCUTrie()
+	rt.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	rt.Insert(netip.MustParsePrefix("10.1.0.0/16"), "b")
+
+	old := rt.Load()
+	oldStr := old.String()
+
+	rt.Insert(netip.MustParsePrefix("10.1.1.0/24"), "c")
+	rt.Insert(netip.MustParsePrefix("10.2.0.0/16"), "d")
+	rt.Remove(netip.MustParsePrefix("10.1.0.0/16"))
+
+	assert.Equal(t, oldStr, old.String())
+	assert.Equal(t, "b", old.Find(netip.MustParseAddr("10.1.1.1")))
+	assert.Equal(t, "a", old.Find(netip.MustParseAddr("10.2.0.1")))
+
+	assert.Equal(t, "c", rt.Find(netip.MustParseAddr("10.1.1.1")))
+	assert.Equal(t, "a", rt.Find(netip.MustParseAddr("10.1.2.1")))
+	assert.Equal(t, "d", rt.Find(netip.MustParseAddr("10.2.0.1")))
+	assert.Equal(t, `::/0
+├ ::ffff:10.0.0.0/104 • a
+├ ├ ::ffff:10.0.0.0/110
+├ ├ ├ ::ffff:10.1.1.0/120 • c
+├ ├ ├ ::ffff:10.2.0.0/112 • d`, rt.String())
+}
+
+func TestRCUTrieConcurrent(t *testing.T) {
+	rt := NewRCUTrie()
+	rt.Insert(netip.MustParsePrefix("10.0.0.0/8"), 1)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			ip := GenIPV4()
+			rt.Insert(GenLeafIPNet(ip), 2)
+			if i%2 == 0 {
+				rt.Remove(GenLeafIPNet(ip))
+			}
+		}
+	}()
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				assert.Equal(t, 1, rt.Find(netip.MustParseAddr("10.0.0.1")))
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestRCUTrieTxn(t *testing.T) {
+	rt := NewRCUTrie()
+	rt.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+
+	txn := rt.Txn()
+	txn.Insert(netip.MustParsePrefix("10.1.0.0/16"), "b")
+	txn.Insert(netip.MustParsePrefix("10.2.0.0/16"), "c")
+	assert.Equal(t, "a", txn.Remove(netip.MustParsePrefix("10.0.0.0/8")))
+
+	// Not yet visible to readers.
+	assert.Equal(t, "a", rt.Find(netip.MustParseAddr("10.1.0.1")))
+	assert.Equal(t, "b", txn.Trie().Find(netip.MustParseAddr("10.1.0.1")))
+
+	txn.Commit()
+	assert.Equal(t, "b", rt.Find(netip.MustParseAddr("10.1.0.1")))
+	assert.Equal(t, "c", rt.Find(netip.MustParseAddr("10.2.0.1")))
+	assert.Nil(t, rt.Find(netip.MustParseAddr("10.3.0.1")))
+
+	txn = rt.Txn()
+	txn.Insert(netip.MustParsePrefix("10.3.0.0/16"), "d")
+	txn.Remove(netip.MustParsePrefix("10.1.0.0/16"))
+	txn.Abort()
+	assert.Equal(t, "b", rt.Find(netip.MustParseAddr("10.1.0.1")))
+	assert.Nil(t, rt.Find(netip.MustParseAddr("10.3.0.1")))
+
+	// Writes are possible again once the transaction is finished.
+	rt.Insert(netip.MustParsePrefix("10.3.0.0/16"), "e")
+	assert.Equal(t, "e", rt.Find(netip.MustParseAddr("10.3.0.1")))
+}
+
+func TestNewRCUTrieFrom(t *testing.T) {
+	trie := NewTrie()
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	trie.SetDefault("default")
+	var inserted []netip.Prefix
+	trie.OnInsert(func(network netip.Prefix, value any) {
+		inserted = append(inserted, network)
+	})
+
+	rt := NewRCUTrieFrom(trie)
+	rt.Insert(netip.MustParsePrefix("10.1.0.0/16"), "b")
+	assert.Equal(t, "b", rt.Find(netip.MustParseAddr("10.1.0.1")))
+	assert.Equal(t, "default", rt.Find(netip.MustParseAddr("192.0.2.1")))
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("::ffff:10.1.0.0/112")}, inserted)
+
+	// The trie and the RCUTrie are independent.
+	trie.Insert(netip.MustParsePrefix("10.2.0.0/16"), "c")
+	assert.Equal(t, "a", rt.Find(netip.MustParseAddr("10.2.0.1")))
+	assert.Equal(t, "a", trie.Find(netip.MustParseAddr("10.1.0.1")))
+}
@@ -0,0 +1,99 @@
+package iptrie
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrNotModified is returned by a Refresher's fetch function when the feed hasn't changed since it was last fetched,
+// such as when a server responds to a conditional request with 304 Not Modified. The trie is left as is, and this is
+// not reported as an error.
+var ErrNotModified = errors.New("iptrie: feed not modified")
+
+// Refresher keeps an AtomicTrie loaded with the contents of a remote feed, such as a blocklist published over HTTP.
+// Each refresh fetches the feed, builds a new trie from it, optionally validates it, and then swaps it into the
+// AtomicTrie. If any step fails, the previous trie remains in place.
+type Refresher struct {
+	// Validate, if set, is called with each new trie before it is swapped in, and rejects it by returning an error. This
+	// guards against swapping in a truncated or otherwise broken feed, such as by requiring a minimum number of
+	// entries.
+	Validate func(pt *Trie) error
+	// OnRefresh, if set, is called with the new trie after each successful refresh, along with the time taken to fetch
+	// and build it.
+	OnRefresh func(pt *Trie, elapsed time.Duration)
+	// OnError, if set, is called with the error of each failed refresh.
+	OnError func(err error)
+
+	target *AtomicTrie
+	fetch  func(ctx context.Context) (io.ReadCloser, error)
+	load   func(r io.Reader) (*Trie, error)
+}
+
+// NewRefresher creates a Refresher which loads the feed returned by fetch into target using load. fetch may return
+// ErrNotModified to skip a refresh. For feeds served over HTTP, HTTPFetcher provides a fetch function. If load is nil,
+// the feed is read with LoadCIDRList, with a nil value for each entry.
+//
+// Validate, OnRefresh and OnError may be set on the returned Refresher before it is first used.
+func NewRefresher(target *AtomicTrie, fetch func(ctx context.Context) (io.ReadCloser, error),
+	load func(r io.Reader) (*Trie, error)) *Refresher {
+	if load == nil {
+		load = func(r io.Reader) (*Trie, error) {
+			pt := NewTrie()
+			return pt, pt.LoadCIDRList(r, nil)
+		}
+	}
+	return &Refresher{
+		target: target,
+		fetch:  fetch,
+		load:   load,
+	}
+}
+
+// Refresh fetches the feed and swaps a trie built from it into the target, returning whether it did so. If the feed is
+// not modified, it returns false and no error.
+//
+// Refresh must not be called concurrently with itself or Run.
+func (rf *Refresher) Refresh(ctx context.Context) (bool, error) {
+	start := time.Now()
+	r, err := rf.fetch(ctx)
+	if errors.Is(err, ErrNotModified) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer r.Close()
+	pt, err := rf.load(r)
+	if err != nil {
+		return false, err
+	}
+	if rf.Validate != nil {
+		if err := rf.Validate(pt); err != nil {
+			return false, err
+		}
+	}
+	rf.target.Store(pt)
+	if rf.OnRefresh != nil {
+		rf.OnRefresh(pt, time.Since(start))
+	}
+	return true, nil
+}
+
+// Run calls Refresh every interval, starting immediately, until ctx is done, reporting errors to OnError. It returns
+// the context's error.
+func (rf *Refresher) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := rf.Refresh(ctx); err != nil && rf.OnError != nil && ctx.Err() == nil {
+			rf.OnError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,77 @@
+//go:build !tinygo
+
+package iptrie
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// HTTPFetcher returns a fetch function for NewRefresher which retrieves the feed at url with client, or
+// http.DefaultClient if client is nil.
+//
+// Requests are conditional upon the ETag and Last-Modified of the last response which was read in full, so a feed
+// which hasn't changed is not downloaded again, and ErrNotModified is returned instead. Responses with a status other
+// than 200 OK or 304 Not Modified are an error.
+func HTTPFetcher(client *http.Client, url string) func(ctx context.Context) (io.ReadCloser, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	var mu sync.Mutex
+	var etag, lastModified string
+	return func(ctx context.Context) (io.ReadCloser, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		mu.Lock()
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if lastModified != "" {
+			req.Header.Set("If-Modified-Since", lastModified)
+		}
+		mu.Unlock()
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		switch resp.StatusCode {
+		case http.StatusOK:
+		case http.StatusNotModified:
+			resp.Body.Close()
+			return nil, ErrNotModified
+		default:
+			resp.Body.Close()
+			return nil, fmt.Errorf("iptrie: fetching %s: %s", url, resp.Status)
+		}
+		return &httpFeedBody{
+			ReadCloser: resp.Body,
+			complete: func() {
+				mu.Lock()
+				etag, lastModified = resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+				mu.Unlock()
+			},
+		}, nil
+	}
+}
+
+// httpFeedBody is the body of a feed response, which calls complete once the body has been read in full. This avoids
+// recording the validators of a response which was cut short, which would prevent it from being fetched again.
+type httpFeedBody struct {
+	io.ReadCloser
+	complete func()
+}
+
+func (b *httpFeedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF && b.complete != nil {
+		b.complete()
+		b.complete = nil
+	}
+	return n, err
+}
@@ -0,0 +1,56 @@
+//go:build !tinygo
+
+package iptrie
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPFetcher(t *testing.T) {
+	feed, etag := "10.0.0.0/8\n", `"v1"`
+	var conditional []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conditional = append(conditional, r.Header.Get("If-None-Match"))
+		if r.URL.Path != "/feed" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		io.WriteString(w, feed)
+	}))
+	defer srv.Close()
+
+	at := NewAtomicTrie(nil)
+	rf := NewRefresher(at, HTTPFetcher(srv.Client(), srv.URL+"/feed"), nil)
+	ok, err := rf.Refresh(context.Background())
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, at.Contains(netip.MustParseAddr("10.0.0.1")))
+
+	ok, err = rf.Refresh(context.Background())
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	feed, etag = "192.0.2.0/24\n", `"v2"`
+	ok, err = rf.Refresh(context.Background())
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, at.Contains(netip.MustParseAddr("192.0.2.1")))
+	assert.Equal(t, []string{"", `"v1"`, `"v1"`}, conditional)
+
+	_, err = NewRefresher(at, HTTPFetcher(nil, srv.URL+"/missing"), nil).Refresh(context.Background())
+	assert.ErrorContains(t, err, "404 Not Found")
+	assert.True(t, at.Contains(netip.MustParseAddr("192.0.2.1")))
+}
@@ -0,0 +1,100 @@
+package iptrie
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefresherRefresh(t *testing.T) {
+	var feed string
+	var fetchErr error
+	fetch := func(ctx context.Context) (io.ReadCloser, error) {
+		if fetchErr != nil {
+			return nil, fetchErr
+		}
+		return io.NopCloser(strings.NewReader(feed)), nil
+	}
+	at := NewAtomicTrie(nil)
+	rf := NewRefresher(at, fetch, nil)
+	rf.Validate = func(pt *Trie) error {
+		if _, entries := pt.Count(); entries < 2 {
+			return errors.New("too few entries")
+		}
+		return nil
+	}
+	var refreshed []*Trie
+	rf.OnRefresh = func(pt *Trie, elapsed time.Duration) {
+		refreshed = append(refreshed, pt)
+	}
+
+	feed = "10.0.0.0/8\n192.0.2.0/24\n"
+	ok, err := rf.Refresh(context.Background())
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, at.Contains(netip.MustParseAddr("10.0.0.1")))
+	require.Len(t, refreshed, 1)
+	assert.Same(t, at.Load(), refreshed[0])
+
+	// Feeds which fail to fetch, parse or validate leave the previous trie in place.
+	feed = "198.51.100.0/24\n"
+	_, err = rf.Refresh(context.Background())
+	assert.EqualError(t, err, "too few entries")
+	feed = "198.51.100.0/24\nbogus\n"
+	_, err = rf.Refresh(context.Background())
+	assert.ErrorContains(t, err, "line 2")
+	fetchErr = errors.New("unreachable")
+	_, err = rf.Refresh(context.Background())
+	assert.EqualError(t, err, "unreachable")
+	assert.True(t, at.Contains(netip.MustParseAddr("10.0.0.1")))
+
+	fetchErr = ErrNotModified
+	ok, err = rf.Refresh(context.Background())
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Len(t, refreshed, 1)
+}
+
+func TestRefresherRun(t *testing.T) {
+	fetches := make(chan string)
+	fetch := func(ctx context.Context) (io.ReadCloser, error) {
+		select {
+		case feed := <-fetches:
+			return io.NopCloser(strings.NewReader(feed)), nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	at := NewAtomicTrie(nil)
+	rf := NewRefresher(at, fetch, nil)
+	errs := make(chan error, 1)
+	rf.OnError = func(err error) { errs <- err }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- rf.Run(ctx, time.Millisecond) }()
+
+	fetches <- "10.0.0.0/8\n"
+	fetches <- "bogus\n"
+	assert.ErrorContains(t, <-errs, "line 1")
+	assert.True(t, at.Contains(netip.MustParseAddr("10.0.0.1")))
+	fetches <- "192.0.2.0/24\n"
+	fetches <- "192.0.2.0/24\n" // ensures the previous refresh has completed
+	assert.False(t, at.Contains(netip.MustParseAddr("10.0.0.1")))
+	assert.True(t, at.Contains(netip.MustParseAddr("192.0.2.1")))
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+	select {
+	case err := <-errs:
+		t.Errorf("unexpected error after cancellation: %s", err)
+	default:
+	}
+}
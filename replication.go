@@ -0,0 +1,185 @@
+package iptrie
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net/netip"
+	"sync"
+)
+
+// replicationBacklog is the number of changes which may be queued for a follower before it is considered to have
+// fallen behind.
+const replicationBacklog = 4096
+
+// ErrReplicationLagged is returned by ReplicationLeader.Stream when the follower is not consuming changes fast enough.
+// The follower should reconnect, which will start it from a new snapshot.
+var ErrReplicationLagged = errors.New("iptrie: replication follower fell behind")
+
+// ReplicationLeader publishes the contents of a trie to followers, allowing many processes to keep a copy of a centrally
+// managed trie in sync.
+//
+// Each follower is sent a snapshot of the trie, followed by a stream of the changes made after the snapshot was taken.
+// The stream consists of the snapshot in the format written by Trie.WriteTo, followed by changes in the format written
+// by ChangeLogWriter, and is consumed with Trie.Follow or RCUTrie.Follow.
+//
+// All modifications of the trie must be made through the ReplicationLeader. A ReplicationLeader is safe for concurrent
+// use.
+type ReplicationLeader struct {
+	mu    sync.Mutex
+	trie  *Trie
+	codec ValueCodec
+	subs  map[*replicationSub]struct{}
+}
+
+type replicationSub struct {
+	changes chan Change
+	// lagged is closed if the subscriber is removed due to changes being full.
+	lagged chan struct{}
+}
+
+// NewReplicationLeader creates a ReplicationLeader publishing the given trie. codec is used to encode values, and if nil,
+// StringCodec is used.
+func NewReplicationLeader(trie *Trie, codec ValueCodec) *ReplicationLeader {
+	if codec == nil {
+		codec = StringCodec
+	}
+	return &ReplicationLeader{
+		trie:  trie,
+		codec: codec,
+		subs:  map[*replicationSub]struct{}{},
+	}
+}
+
+// Insert inserts an entry into the trie, and publishes the change to followers.
+func (rl *ReplicationLeader) Insert(network netip.Prefix, value any) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.trie.Insert(network, value)
+	network = normalizePrefix(network)
+	if rl.trie.merge != nil {
+		// Followers apply the value as stored, rather than merging it themselves.
+		addr, bits := prefix128(network)
+		value = unempty(rl.trie.get(addr, bits).value)
+	}
+	rl.publish(Change{Op: ChangeInsert, Network: network, Value: value})
+}
+
+// Remove removes the entry identified by given network from trie, and publishes the change to followers.
+func (rl *ReplicationLeader) Remove(network netip.Prefix) any {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	addr, bits := prefix128(normalizePrefix(network))
+	if n := rl.trie.get(addr, bits); n == nil || n.value == nil {
+		return nil
+	}
+	v := rl.trie.Remove(network)
+	rl.publish(Change{Op: ChangeRemove, Network: normalizePrefix(network), Value: unempty(v)})
+	return v
+}
+
+// Snapshot returns an immutable point-in-time view of the trie.
+func (rl *ReplicationLeader) Snapshot() *Trie {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.trie.Snapshot()
+}
+
+// publish queues the change to all followers. rl.mu must be held.
+func (rl *ReplicationLeader) publish(c Change) {
+	for sub := range rl.subs {
+		select {
+		case sub.changes <- c:
+		default:
+			delete(rl.subs, sub)
+			close(sub.lagged)
+		}
+	}
+}
+
+// Stream writes a snapshot of the trie to w, followed by each subsequent change, until ctx is done, writing to w fails,
+// or the follower falls behind (ErrReplicationLagged).
+func (rl *ReplicationLeader) Stream(ctx context.Context, w io.Writer) error {
+	sub := &replicationSub{
+		changes: make(chan Change, replicationBacklog),
+		lagged:  make(chan struct{}),
+	}
+	rl.mu.Lock()
+	snap := rl.trie.Snapshot()
+	rl.subs[sub] = struct{}{}
+	rl.mu.Unlock()
+	defer func() {
+		rl.mu.Lock()
+		delete(rl.subs, sub)
+		rl.mu.Unlock()
+	}()
+
+	bw := bufio.NewWriter(w)
+	if err := snap.writeBinary(bw, rl.codec); err != nil {
+		return err
+	}
+	clw := NewChangeLogWriter(bw, rl.codec)
+	for {
+		if err := bw.Flush(); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case c := <-sub.changes:
+			clw.Log(c)
+			// Write everything already queued before flushing.
+			for len(sub.changes) > 0 {
+				clw.Log(<-sub.changes)
+			}
+			if err := clw.Err(); err != nil {
+				return err
+			}
+		case <-sub.lagged:
+			return ErrReplicationLagged
+		}
+	}
+}
+
+// Follow replaces the contents of the trie with the snapshot read from r, and then applies each change read from r
+// until EOF, keeping the trie in sync with a ReplicationLeader. codec is used to decode values, and if nil, StringCodec
+// is used.
+//
+// The trie must not be read from while Follow is running. Use RCUTrie.Follow for a trie which can be.
+func (pt *Trie) Follow(r io.Reader, codec ValueCodec) error {
+	return readReplication(r, codec, pt, pt.replaceRoot, pt.Apply)
+}
+
+// Follow is like Trie.Follow, but each change is published atomically, so the trie can be read concurrently. The
+// settings of the trie, such as its hooks and default value, are kept.
+func (rt *RCUTrie) Follow(r io.Reader, codec ValueCodec) error {
+	t := rt.root.Load().cow()
+	return readReplication(r, codec, t, func(root *node) {
+		t.replaceRoot(root)
+		rt.mu.Lock()
+		rt.root.Store(t)
+		rt.mu.Unlock()
+	}, func(c Change) error {
+		var err error
+		rt.update(func(pt *Trie) {
+			err = pt.Apply(c)
+		})
+		return err
+	})
+}
+
+// readReplication reads a replication stream, calling snapshot with the root of the snapshot, and then apply with each
+// change. The nodes of the snapshot are allocated for use within t.
+func readReplication(r io.Reader, codec ValueCodec, t *Trie, snapshot func(root *node), apply func(Change) error) error {
+	if codec == nil {
+		codec = StringCodec
+	}
+	br := bufio.NewReader(r)
+	root, err := t.readBinary(br, codec)
+	if err != nil {
+		return err
+	}
+	snapshot(root)
+	return ReadChangeLog(br, codec, apply)
+}
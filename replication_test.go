@@ -0,0 +1,136 @@
+package iptrie
+
+import (
+	"context"
+	"io"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplication(t *testing.T) {
+	leader := NewReplicationLeader(NewTrie(), nil)
+	leader.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	leader.Insert(netip.MustParsePrefix("10.1.0.0/16"), "b")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pr, pw := io.Pipe()
+	streamErr := make(chan error, 1)
+	go func() {
+		err := leader.Stream(ctx, pw)
+		pw.CloseWithError(err)
+		streamErr <- err
+	}()
+
+	follower := NewRCUTrie()
+	followErr := make(chan error, 1)
+	go func() {
+		followErr <- follower.Follow(pr, nil)
+	}()
+
+	require.Eventually(t, func() bool {
+		return follower.Find(netip.MustParseAddr("10.1.0.1")) == "b"
+	}, time.Second, time.Millisecond)
+
+	leader.Insert(netip.MustParsePrefix("192.0.2.0/24"), "c")
+	leader.Remove(netip.MustParsePrefix("10.1.0.0/16"))
+	leader.Insert(netip.MustParsePrefix("2001:db8::/32"), nil)
+	require.Eventually(t, func() bool {
+		return follower.String() == leader.Snapshot().String()
+	}, time.Second, time.Millisecond)
+
+	cancel()
+	assert.ErrorIs(t, <-streamErr, context.Canceled)
+	assert.ErrorIs(t, <-followErr, context.Canceled)
+}
+
+func TestReplicationRCUTrieFollowSettings(t *testing.T) {
+	leader := NewReplicationLeader(NewTrie(), nil)
+	leader.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(leader.Stream(ctx, pw))
+	}()
+
+	trie := NewTrie()
+	trie.Insert(netip.MustParsePrefix("192.0.2.0/24"), "replaced")
+	trie.SetDefault("default")
+	inserted := make(chan netip.Prefix, 1)
+	trie.OnInsert(func(network netip.Prefix, value any) {
+		inserted <- network
+	})
+	follower := NewRCUTrieFrom(trie)
+	go follower.Follow(pr, nil)
+
+	require.Eventually(t, func() bool {
+		return follower.Find(netip.MustParseAddr("10.0.0.1")) == "a"
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, "default", follower.Find(netip.MustParseAddr("192.0.2.1")))
+
+	leader.Insert(netip.MustParsePrefix("10.1.0.0/16"), "b")
+	assert.Equal(t, netip.MustParsePrefix("::ffff:10.1.0.0/112"), <-inserted)
+}
+
+func TestReplicationTrieFollow(t *testing.T) {
+	leader := NewReplicationLeader(NewTrie(), JSONCodec)
+	leader.Insert(netip.MustParsePrefix("10.0.0.0/8"), 1.0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(leader.Stream(ctx, pw))
+	}()
+
+	done := make(chan struct{})
+	follower := NewTrie()
+	follower.Insert(netip.MustParsePrefix("192.0.2.0/24"), "replaced")
+	var changes int
+	follower.OnInsert(func(network netip.Prefix, value any) {
+		changes++
+		if changes == 2 {
+			cancel()
+		}
+	})
+	go func() {
+		defer close(done)
+		follower.Follow(pr, JSONCodec)
+	}()
+	// Ensure the changes are made after the snapshot is taken.
+	require.Eventually(t, func() bool {
+		leader.mu.Lock()
+		defer leader.mu.Unlock()
+		return len(leader.subs) == 1
+	}, time.Second, time.Millisecond)
+	leader.Insert(netip.MustParsePrefix("10.1.0.0/16"), 2.0)
+	leader.Insert(netip.MustParsePrefix("10.2.0.0/16"), 3.0)
+	<-done
+
+	assert.Nil(t, follower.Find(netip.MustParseAddr("192.0.2.1")))
+	assert.Equal(t, 3.0, follower.Find(netip.MustParseAddr("10.2.0.1")))
+}
+
+func TestReplicationLagged(t *testing.T) {
+	leader := NewReplicationLeader(NewTrie(), nil)
+	pr, pw := io.Pipe()
+	streamErr := make(chan error, 1)
+	go func() {
+		streamErr <- leader.Stream(context.Background(), pw)
+	}()
+	// Read just the snapshot, and then stop consuming.
+	buf := make([]byte, 7)
+	_, err := io.ReadFull(pr, buf)
+	require.NoError(t, err)
+
+	for i := 0; i < replicationBacklog*2; i++ {
+		leader.Insert(GenLeafIPNet(GenIPV4()), "a")
+	}
+	go io.Copy(io.Discard, pr)
+	assert.ErrorIs(t, <-streamErr, ErrReplicationLagged)
+	pw.Close()
+}
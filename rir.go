@@ -0,0 +1,114 @@
+package iptrie
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// RIRRecord is an address record from a Regional Internet Registry delegated statistics file.
+type RIRRecord struct {
+	// Registry is the registry holding the record, such as "arin" or "ripencc".
+	Registry string
+	// CountryCode is the ISO 3166 2-letter code of the country the holder is located in. It may be empty, or "ZZ", for
+	// records which are not allocated.
+	CountryCode string
+	// Network is one of the networks of the record. IPv4 records which are not a single network are split into multiple
+	// networks, each of which has its own RIRRecord.
+	Network netip.Prefix
+	// Date is the date of the allocation or assignment in the form YYYYMMDD. It may be empty.
+	Date string
+	// Status is the status of the record, such as "allocated", "assigned", "available" or "reserved".
+	Status string
+	// OpaqueID identifies the holder of the record. It is only present in the extended format, and is otherwise empty.
+	OpaqueID string
+}
+
+// LoadRIRDelegated inserts the IPv4 and IPv6 records read from r, which is in the RIR statistics exchange format used
+// by the delegated (and delegated-extended) files published by ARIN, RIPE NCC, APNIC, LACNIC and AFRINIC. ASN records,
+// the version header and summary lines are skipped.
+//
+// IPv4 records consist of a starting address and an address count, which is converted to the smallest list of networks
+// covering the range.
+//
+// valueFn is called for each network, and returns the value to insert, and whether the network should be inserted at
+// all. If valueFn is nil, networks with a status of "allocated" or "assigned" are inserted, with the RIRRecord as the
+// value.
+//
+// Loading stops at the first error, which identifies the line it occurred on. Entries from the preceding lines remain
+// inserted.
+func (pt *Trie) LoadRIRDelegated(r io.Reader, valueFn func(RIRRecord) (any, bool)) error {
+	if valueFn == nil {
+		valueFn = func(rec RIRRecord) (any, bool) {
+			return rec, rec.Status == "allocated" || rec.Status == "assigned"
+		}
+	}
+
+	scanner := bufio.NewScanner(r)
+	var networks []netip.Prefix
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		// registry|cc|type|start|value|date|status[|opaque-id[|extensions...]]
+		fields := strings.Split(line, "|")
+		if len(fields) < 7 || (fields[2] != "ipv4" && fields[2] != "ipv6") || fields[1] == "*" {
+			// The version header, summary lines, and ASN records.
+			continue
+		}
+		rec := RIRRecord{
+			Registry:    fields[0],
+			CountryCode: fields[1],
+			Date:        fields[5],
+			Status:      fields[6],
+		}
+		if len(fields) > 7 {
+			rec.OpaqueID = fields[7]
+		}
+
+		var err error
+		if networks, err = rirNetworks(networks[:0], fields[2], fields[3], fields[4]); err != nil {
+			return fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		for _, network := range networks {
+			rec.Network = denormalizePrefix(network)
+			if value, ok := valueFn(rec); ok {
+				pt.Insert(network, value)
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// rirNetworks appends the normalized networks of an RIR statistics record to dst. For IPv4, value is the number of
+// addresses, and for IPv6, the prefix length.
+func rirNetworks(dst []netip.Prefix, typ, start, value string) ([]netip.Prefix, error) {
+	addr, err := netip.ParseAddr(start)
+	if err != nil {
+		return dst, err
+	}
+	if typ == "ipv6" {
+		if !addr.Is6() {
+			return dst, fmt.Errorf("invalid IPv6 address %q", start)
+		}
+		bits, err := strconv.ParseUint(value, 10, 8)
+		if err != nil || bits > 128 {
+			return dst, fmt.Errorf("invalid prefix length %q", value)
+		}
+		return append(dst, netip.PrefixFrom(addr, int(bits)).Masked()), nil
+	}
+
+	if !addr.Is4() {
+		return dst, fmt.Errorf("invalid IPv4 address %q", start)
+	}
+	count, err := strconv.ParseUint(value, 10, 64)
+	first := addr128(normalizeAddr(addr))
+	if err != nil || count == 0 || count > 1<<32-first.lo&0xffffffff {
+		return dst, fmt.Errorf("invalid address count %q", value)
+	}
+	return appendRange(dst, first, first.add(count-1)), nil
+}
@@ -0,0 +1,83 @@
+package iptrie
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const rirDelegatedSample = `2|ripencc|1700000000|4|19830705|20231114|+0100
+# comment
+ripencc|*|ipv4|*|3|summary
+ripencc|*|ipv6|*|1|summary
+ripencc|FR|ipv4|2.0.0.0|1048576|20100712|allocated|a1b2
+ripencc|DE|ipv4|192.0.2.0|768|20100712|assigned|c3d4
+ripencc||ipv4|198.51.100.0|256||available
+ripencc|NL|asn|1101|1|19930901|allocated|e5f6
+ripencc|EU|ipv6|2001:db8::|32|19990826|allocated|a1b2
+`
+
+func TestTrieLoadRIRDelegated(t *testing.T) {
+	trie := NewTrie()
+	require.NoError(t, trie.LoadRIRDelegated(strings.NewReader(rirDelegatedSample), nil))
+	assert.Equal(t, []netip.Prefix{
+		netip.MustParsePrefix("::ffff:2.0.0.0/108"),
+		netip.MustParsePrefix("::ffff:192.0.2.0/119"),
+		netip.MustParsePrefix("::ffff:192.0.4.0/120"),
+		netip.MustParsePrefix("2001:db8::/32"),
+	}, trie.appendEntries(nil))
+
+	assert.Equal(t, RIRRecord{
+		Registry:    "ripencc",
+		CountryCode: "DE",
+		Network:     netip.MustParsePrefix("192.0.4.0/24"),
+		Date:        "20100712",
+		Status:      "assigned",
+		OpaqueID:    "c3d4",
+	}, trie.Find(netip.MustParseAddr("192.0.4.1")))
+	assert.Nil(t, trie.Find(netip.MustParseAddr("198.51.100.1")))
+}
+
+func TestTrieLoadRIRDelegatedValueFn(t *testing.T) {
+	trie := NewTrie()
+	err := trie.LoadRIRDelegated(strings.NewReader(rirDelegatedSample), func(rec RIRRecord) (any, bool) {
+		return rec.CountryCode, true
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "FR", trie.Find(netip.MustParseAddr("2.15.255.255")))
+	assert.Equal(t, "", trie.Find(netip.MustParseAddr("198.51.100.1")))
+	assert.Equal(t, "EU", trie.Find(netip.MustParseAddr("2001:db8::1")))
+}
+
+func TestTrieLoadRIRDelegatedError(t *testing.T) {
+	for _, line := range []string{
+		"arin|US|ipv4|10.0.0|256|20000101|allocated",
+		"arin|US|ipv4|255.255.255.0|257|20000101|allocated",
+		"arin|US|ipv4|10.0.0.0|0|20000101|allocated",
+		"arin|US|ipv4|2001:db8::|256|20000101|allocated",
+		"arin|US|ipv6|2001:db8::|129|20000101|allocated",
+	} {
+		err := NewTrie().LoadRIRDelegated(strings.NewReader("2|arin|20240101|1|19700101|20240101|-0500\n"+line+"\n"), nil)
+		assert.ErrorContains(t, err, "line 2: ", line)
+	}
+}
+
+func TestAppendRange(t *testing.T) {
+	first := addr128(netip.MustParseAddr("::ffff:10.0.0.1"))
+	last := addr128(netip.MustParseAddr("::ffff:10.0.1.0"))
+	networks := appendRange(nil, first, last)
+	var strs []string
+	for _, network := range networks {
+		strs = append(strs, denormalizePrefix(network).String())
+	}
+	assert.Equal(t, []string{
+		"10.0.0.1/32", "10.0.0.2/31", "10.0.0.4/30", "10.0.0.8/29", "10.0.0.16/28", "10.0.0.32/27", "10.0.0.64/26",
+		"10.0.0.128/25", "10.0.1.0/32",
+	}, strs)
+
+	all := mask6(0).not()
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("::/0")}, appendRange(nil, uint128{}, all))
+}
@@ -0,0 +1,53 @@
+package iptrie
+
+import (
+	"database/sql/driver"
+	"fmt"
+)
+
+// Value implements driver.Valuer, encoding the trie in the binary format of MarshalBinary, for storage in a BLOB (or
+// BYTEA) column. Values are encoded with StringCodec. Use SQLValue for another codec.
+func (pt *Trie) Value() (driver.Value, error) {
+	return SQLValue{Trie: pt}.Value()
+}
+
+// Scan implements sql.Scanner, replacing the contents of the trie with one read from a column written by Value. A NULL
+// column results in an empty trie.
+func (pt *Trie) Scan(src any) error {
+	return SQLValue{Trie: pt}.Scan(src)
+}
+
+// SQLValue implements driver.Valuer and sql.Scanner for a trie using the given codec, such as:
+//
+//	db.QueryRow("SELECT networks FROM acls WHERE id = ?", id).Scan(iptrie.SQLValue{Trie: trie, Codec: iptrie.JSONCodec})
+type SQLValue struct {
+	Trie *Trie
+	// Codec is used to encode and decode values. If nil, StringCodec is used.
+	Codec ValueCodec
+}
+
+func (sv SQLValue) codec() ValueCodec {
+	if sv.Codec == nil {
+		return StringCodec
+	}
+	return sv.Codec
+}
+
+// Value implements driver.Valuer.
+func (sv SQLValue) Value() (driver.Value, error) {
+	return sv.Trie.MarshalBinaryCodec(sv.codec())
+}
+
+// Scan implements sql.Scanner.
+func (sv SQLValue) Scan(src any) error {
+	switch src := src.(type) {
+	case nil:
+		sv.Trie.replaceRoot(&node{})
+		return nil
+	case []byte:
+		return sv.Trie.UnmarshalBinaryCodec(src, sv.codec())
+	case string:
+		return sv.Trie.UnmarshalBinaryCodec([]byte(src), sv.codec())
+	}
+	return fmt.Errorf("iptrie: cannot scan %T into a trie", src)
+}
@@ -0,0 +1,55 @@
+package iptrie
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	_ driver.Valuer = (*Trie)(nil)
+	_ sql.Scanner   = (*Trie)(nil)
+	_ driver.Valuer = SQLValue{}
+	_ sql.Scanner   = SQLValue{}
+)
+
+func TestTrieSQL(t *testing.T) {
+	trie := NewTrie()
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	trie.Insert(netip.MustParsePrefix("2001:db8::/32"), nil)
+
+	v, err := trie.Value()
+	require.NoError(t, err)
+	require.IsType(t, []byte{}, v)
+	assert.True(t, driver.IsValue(v))
+
+	scanned := NewTrie()
+	require.NoError(t, scanned.Scan(v))
+	assert.Equal(t, trie.String(), scanned.String())
+	require.NoError(t, scanned.Scan(string(v.([]byte))))
+	assert.Equal(t, trie.String(), scanned.String())
+
+	require.NoError(t, scanned.Scan(nil))
+	assert.Nil(t, scanned.Find(netip.MustParseAddr("10.0.0.1")))
+
+	assert.ErrorContains(t, scanned.Scan(int64(42)), "cannot scan int64")
+	assert.Error(t, scanned.Scan([]byte("garbage")))
+}
+
+func TestSQLValue(t *testing.T) {
+	trie := NewTrie()
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), 1.5)
+
+	_, err := trie.Value()
+	assert.Error(t, err)
+
+	v, err := SQLValue{Trie: trie, Codec: JSONCodec}.Value()
+	require.NoError(t, err)
+	scanned := NewTrie()
+	require.NoError(t, SQLValue{Trie: scanned, Codec: JSONCodec}.Scan(v))
+	assert.Equal(t, 1.5, scanned.Find(netip.MustParseAddr("10.0.0.1")))
+}
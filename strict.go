@@ -0,0 +1,64 @@
+package iptrie
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+)
+
+var (
+	// ErrInvalidPrefix is returned by InsertStrict and RemoveStrict for a network which is not valid, such as the zero
+	// netip.Prefix.
+	ErrInvalidPrefix = errors.New("iptrie: invalid prefix")
+	// ErrPrefixNotMasked is returned by InsertStrict and RemoveStrict for a network with address bits set beyond its
+	// prefix length, such as 10.1.2.3/8, when SetRequireMasked is enabled.
+	ErrPrefixNotMasked = errors.New("iptrie: prefix has bits set beyond its length")
+	// ErrMappedPrefix is returned by InsertStrict and RemoveStrict for an IPv4-mapped IPv6 network, such as
+	// ::ffff:10.0.0.0/104. As IPv4 networks are stored in this form, it is indistinguishable from the IPv4 network, and
+	// must be given in IPv4 form instead.
+	ErrMappedPrefix = errors.New("iptrie: IPv4-mapped IPv6 prefix")
+	// ErrNotFound is returned by RemoveStrict when there is no entry for the network.
+	ErrNotFound = errors.New("iptrie: entry not found")
+)
+
+// SetRequireMasked sets whether InsertStrict and RemoveStrict reject networks with address bits set beyond their prefix
+// length, rather than masking them.
+func (pt *Trie) SetRequireMasked(require bool) {
+	pt.requireMasked = require
+}
+
+// InsertStrict is like Insert, but returns an error instead of inserting a network which is invalid, which is an
+// IPv4-mapped IPv6 network, or which is not masked when SetRequireMasked is enabled.
+func (pt *Trie) InsertStrict(network netip.Prefix, value any) error {
+	if err := pt.checkPrefix(network); err != nil {
+		return err
+	}
+	pt.Insert(network, value)
+	return nil
+}
+
+// RemoveStrict is like Remove, but returns an error for the same networks as InsertStrict, or if there is no entry for
+// the network.
+func (pt *Trie) RemoveStrict(network netip.Prefix) (any, error) {
+	if err := pt.checkPrefix(network); err != nil {
+		return nil, err
+	}
+	addr, bits := prefix128(normalizePrefix(network))
+	if n := pt.get(addr, bits); n == nil || n.value == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, network)
+	}
+	return unempty(pt.Remove(network)), nil
+}
+
+// checkPrefix returns an error if network is not acceptable to InsertStrict.
+func (pt *Trie) checkPrefix(network netip.Prefix) error {
+	switch {
+	case !network.IsValid():
+		return fmt.Errorf("%w: %s", ErrInvalidPrefix, network)
+	case network.Addr().Is4In6():
+		return fmt.Errorf("%w: %s", ErrMappedPrefix, network)
+	case pt.requireMasked && network.Masked() != network:
+		return fmt.Errorf("%w: %s", ErrPrefixNotMasked, network)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package iptrie
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrieInsertStrict(t *testing.T) {
+	trie := NewTrie()
+	require.NoError(t, trie.InsertStrict(netip.MustParsePrefix("10.0.0.0/8"), "a"))
+	require.NoError(t, trie.InsertStrict(netip.MustParsePrefix("192.0.2.1/24"), "b"))
+	assert.Equal(t, "b", trie.Find(netip.MustParseAddr("192.0.2.200")))
+
+	assert.ErrorIs(t, trie.InsertStrict(netip.Prefix{}, "x"), ErrInvalidPrefix)
+	assert.ErrorIs(t, trie.InsertStrict(netip.PrefixFrom(netip.MustParseAddr("10.0.0.0"), 33), "x"), ErrInvalidPrefix)
+	err := trie.InsertStrict(netip.MustParsePrefix("::ffff:10.0.0.0/104"), "x")
+	assert.ErrorIs(t, err, ErrMappedPrefix)
+	assert.ErrorContains(t, err, "::ffff:10.0.0.0/104")
+	assert.Equal(t, "a", trie.Find(netip.MustParseAddr("10.0.0.1")))
+
+	trie.SetRequireMasked(true)
+	assert.ErrorIs(t, trie.InsertStrict(netip.MustParsePrefix("198.51.100.1/24"), "x"), ErrPrefixNotMasked)
+	assert.Nil(t, trie.Find(netip.MustParseAddr("198.51.100.1")))
+	assert.NoError(t, trie.InsertStrict(netip.MustParsePrefix("198.51.100.0/24"), "c"))
+}
+
+func TestTrieRemoveStrict(t *testing.T) {
+	trie := NewTrie()
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	trie.Insert(netip.MustParsePrefix("192.0.2.0/24"), nil)
+
+	_, err := trie.RemoveStrict(netip.MustParsePrefix("10.0.0.0/9"))
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = trie.RemoveStrict(netip.Prefix{})
+	assert.ErrorIs(t, err, ErrInvalidPrefix)
+
+	v, err := trie.RemoveStrict(netip.MustParsePrefix("10.0.0.0/8"))
+	require.NoError(t, err)
+	assert.Equal(t, "a", v)
+	_, err = trie.RemoveStrict(netip.MustParsePrefix("10.0.0.0/8"))
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	v, err = trie.RemoveStrict(netip.MustParsePrefix("192.0.2.0/24"))
+	require.NoError(t, err)
+	assert.Nil(t, v)
+}
@@ -0,0 +1,145 @@
+package iptrie
+
+import (
+	"fmt"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultibitTrieMatchesTrie(t *testing.T) {
+	for _, stride := range []int{4, 8} {
+		t.Run(fmt.Sprintf("stride=%d", stride), func(t *testing.T) {
+			trie := NewTrie()
+			mt := NewTrieWithOptions(Stride(stride))
+			assert.Equal(t, stride, mt.Stride())
+
+			networks := []netip.Prefix{
+				netip.MustParsePrefix("2001:db8::/32"),
+				netip.MustParsePrefix("2001:db8::/33"),
+				netip.MustParsePrefix("2001:db8::1/128"),
+				netip.MustParsePrefix("::ffff:0:0/95"),
+				netip.MustParsePrefix("192.0.2.1/32"),
+			}
+			for i := 0; i < 2000; i++ {
+				ip := GenIPV4()
+				network, _ := ip.Prefix(int(ip.As4()[3]%25) + 8)
+				networks = append(networks, network)
+			}
+			for i, network := range networks {
+				trie.Insert(network, i)
+				mt.Insert(network, i)
+			}
+			for _, network := range networks[3:1000] {
+				assert.Equal(t, trie.Remove(network), mt.Remove(network), "network=%s", network)
+			}
+			_, trieEntries := trie.Count()
+			_, entries := mt.Count()
+			assert.Equal(t, trieEntries, entries)
+
+			check := func() {
+				ips := []netip.Addr{netip.MustParseAddr("2001:db8::1"), netip.MustParseAddr("2001:db8:8000::1"),
+					netip.MustParseAddr("::1"), netip.MustParseAddr("192.0.2.1")}
+				for i := 0; i < 2000; i++ {
+					ips = append(ips, GenIPV4())
+				}
+				for _, network := range networks {
+					ips = append(ips, network.Addr())
+				}
+				for _, ip := range ips {
+					assert.Equal(t, trie.Find(ip), mt.Find(ip), "ip=%s", ip)
+					assert.Equal(t, trie.FindLargest(ip), mt.FindLargest(ip), "ip=%s", ip)
+					assert.Equal(t, trie.Contains(ip), mt.Contains(ip), "ip=%s", ip)
+					assert.Equal(t, trie.ContainingNetworks(ip), mt.ContainingNetworks(ip), "ip=%s", ip)
+				}
+				for _, network := range networks[1000:1100] {
+					supernet, _ := network.Addr().Prefix(network.Bits() - 4)
+					assert.Equal(t, trie.CoveredNetworks(supernet), mt.CoveredNetworks(supernet), "network=%s", supernet)
+				}
+
+				type entry struct {
+					network netip.Prefix
+					value   any
+				}
+				var want, got []entry
+				trie.Walk(func(network netip.Prefix, value any) bool {
+					want = append(want, entry{network, value})
+					return true
+				})
+				mt.Walk(func(network netip.Prefix, value any) bool {
+					got = append(got, entry{network, value})
+					return true
+				})
+				assert.Equal(t, want, got)
+			}
+			check()
+
+			// Networks above the IPv4 portion must still match IPv4 addresses.
+			trie.Insert(netip.MustParsePrefix("::/0"), "default")
+			mt.Insert(netip.MustParsePrefix("::/0"), "default")
+			check()
+		})
+	}
+}
+
+func TestMultibitTrieRemovePrunes(t *testing.T) {
+	mt := NewTrieWithOptions(Stride(4))
+	mt.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	mt.Insert(netip.MustParsePrefix("10.0.0.0/10"), "b")
+	mt.Insert(netip.MustParsePrefix("10.1.2.3/32"), nil)
+
+	assert.Equal(t, "b", mt.Find(netip.MustParseAddr("10.0.0.1")))
+	assert.Equal(t, "a", mt.Remove(netip.MustParsePrefix("10.0.0.0/8")))
+	assert.Equal(t, "b", mt.Find(netip.MustParseAddr("10.0.0.1")))
+	assert.Nil(t, mt.Find(netip.MustParseAddr("10.64.0.1")))
+	assert.Equal(t, "b", mt.Remove(netip.MustParsePrefix("10.0.0.0/10")))
+	assert.Nil(t, mt.Remove(netip.MustParsePrefix("10.0.0.0/10")))
+	assert.True(t, mt.Contains(netip.MustParseAddr("10.1.2.3")))
+	assert.Nil(t, mt.Remove(netip.MustParsePrefix("10.1.2.3/32")))
+	assert.False(t, mt.Contains(netip.MustParseAddr("10.1.2.3")))
+	nodes, entries := mt.Count()
+	assert.Equal(t, 1, nodes)
+	assert.Equal(t, 0, entries)
+	assert.Nil(t, mt.v4)
+}
+
+func TestNewTrieWithOptionsInvalidStride(t *testing.T) {
+	assert.Equal(t, 8, NewTrieWithOptions().Stride())
+	require.Panics(t, func() { NewTrieWithOptions(Stride(3)) })
+}
+
+func BenchmarkMultibitTrie_Find(b *testing.B) {
+	trie := NewTrie()
+	var ips []netip.Addr
+	for i := 0; i < 100000; i++ {
+		ip := GenIPV4()
+		network, _ := ip.Prefix(int(ip.As4()[3]%25) + 8)
+		trie.Insert(network, i)
+		ips = append(ips, ip)
+	}
+	finders := []struct {
+		name   string
+		finder Finder
+	}{{"Trie", trie}}
+	for _, stride := range []int{4, 8} {
+		mt := NewTrieWithOptions(Stride(stride))
+		trie.Walk(func(network netip.Prefix, value any) bool {
+			mt.Insert(network, value)
+			return true
+		})
+		finders = append(finders, struct {
+			name   string
+			finder Finder
+		}{fmt.Sprintf("Stride%d", stride), mt})
+	}
+
+	for _, f := range finders {
+		b.Run(f.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				f.finder.Find(ips[i%len(ips)])
+			}
+		})
+	}
+}
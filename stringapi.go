@@ -0,0 +1,41 @@
+package iptrie
+
+import "net/netip"
+
+// InsertString is like Insert, but parses the network from a string in CIDR notation. A single address is treated as a
+// network of just that address.
+func (pt *Trie) InsertString(cidr string, value any) error {
+	network, err := parseCIDR(cidr)
+	if err != nil {
+		return err
+	}
+	pt.Insert(network, value)
+	return nil
+}
+
+// RemoveString is like Remove, but parses the network from a string, as InsertString.
+func (pt *Trie) RemoveString(cidr string) (any, error) {
+	network, err := parseCIDR(cidr)
+	if err != nil {
+		return nil, err
+	}
+	return unempty(pt.Remove(network)), nil
+}
+
+// FindString is like Find, but parses the address from a string.
+func (pt *Trie) FindString(ip string) (any, error) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return nil, err
+	}
+	return pt.Find(addr), nil
+}
+
+// ContainsString is like Contains, but parses the address from a string.
+func (pt *Trie) ContainsString(ip string) (bool, error) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false, err
+	}
+	return pt.Contains(addr), nil
+}
@@ -0,0 +1,41 @@
+package iptrie
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrieStringAPI(t *testing.T) {
+	trie := NewTrie()
+	require.NoError(t, trie.InsertString("10.0.0.0/8", "a"))
+	require.NoError(t, trie.InsertString("192.0.2.1", "b"))
+	require.NoError(t, trie.InsertString("2001:db8::/32", "c"))
+	assert.Error(t, trie.InsertString("10.0.0.0/33", "x"))
+	assert.Error(t, trie.InsertString("bogus", "x"))
+
+	v, err := trie.FindString("10.1.2.3")
+	require.NoError(t, err)
+	assert.Equal(t, "a", v)
+	v, err = trie.FindString("192.0.2.1")
+	require.NoError(t, err)
+	assert.Equal(t, "b", v)
+	_, err = trie.FindString("10.0.0.0/8")
+	assert.Error(t, err)
+
+	ok, err := trie.ContainsString("2001:db8::1")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	_, err = trie.ContainsString("")
+	assert.Error(t, err)
+
+	v, err = trie.RemoveString("192.0.2.1/32")
+	require.NoError(t, err)
+	assert.Equal(t, "b", v)
+	v, err = trie.RemoveString("192.0.2.1")
+	require.NoError(t, err)
+	assert.Nil(t, v)
+	_, err = trie.RemoveString("192.0.2.1/")
+	assert.Error(t, err)
+}
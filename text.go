@@ -0,0 +1,79 @@
+package iptrie
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// MarshalText implements encoding.TextMarshaler, encoding the trie as one line per entry in depth order, of the form
+// "prefix value", such as:
+//
+//	10.0.0.0/8 private
+//	2001:db8::/32 documentation
+//
+// IPv4 networks are encoded in their IPv4 form. Values are encoded with StringCodec, so must be a string or []byte, and
+// must not contain a line break. An entry with a nil value is encoded as just its network.
+//
+// This allows a trie within a configuration struct to be serialized by YAML, TOML and similar libraries. Note that
+// encoding/json uses MarshalJSON instead.
+func (pt *Trie) MarshalText() ([]byte, error) {
+	var b bytes.Buffer
+	var err error
+	pt.walk(func(n *node) bool {
+		network := denormalizePrefix(n.network())
+		b.WriteString(network.String())
+		if v := unempty(n.value); v != nil {
+			var data []byte
+			if data, err = StringCodec.Encode(v); err != nil {
+				err = fmt.Errorf("encoding value for %s: %w", network, err)
+				return false
+			}
+			if bytes.ContainsAny(data, "\r\n") {
+				err = fmt.Errorf("encoding value for %s: value contains a line break", network)
+				return false
+			}
+			b.WriteByte(' ')
+			b.Write(data)
+		}
+		b.WriteByte('\n')
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, replacing the contents of the trie with the entries produced by
+// MarshalText. Everything following the first space of a line is its value, decoded as a string. Blank lines are
+// ignored.
+//
+// The trie is only modified if data is successfully decoded.
+func (pt *Trie) UnmarshalText(data []byte) error {
+	var entries []pfxEntry
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		cidr, value, hasValue := strings.Cut(line, " ")
+		network, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		entry := pfxEntry{Prefix: network}
+		if hasValue {
+			entry.Value = value
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	pt.replaceRoot(pt.entriesRoot(entries))
+	return nil
+}
@@ -0,0 +1,78 @@
+package iptrie
+
+import (
+	"encoding"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	_ encoding.TextMarshaler   = (*Trie)(nil)
+	_ encoding.TextUnmarshaler = (*Trie)(nil)
+)
+
+func TestTrieMarshalText(t *testing.T) {
+	trie := NewTrie()
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), "private network")
+	trie.Insert(netip.MustParsePrefix("10.1.0.0/16"), nil)
+	trie.Insert(netip.MustParsePrefix("192.0.2.0/24"), []byte("test-net-1"))
+	trie.Insert(netip.MustParsePrefix("2001:db8::/32"), "")
+
+	data, err := trie.MarshalText()
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.0/8 private network\n10.1.0.0/16\n192.0.2.0/24 test-net-1\n2001:db8::/32 \n", string(data))
+
+	loaded := NewTrie()
+	loaded.Insert(netip.MustParsePrefix("198.51.100.0/24"), "replaced")
+	require.NoError(t, loaded.UnmarshalText(data))
+	assert.Equal(t, "private network", loaded.Find(netip.MustParseAddr("10.0.0.1")))
+	assert.Equal(t, "test-net-1", loaded.Find(netip.MustParseAddr("192.0.2.1")))
+	assert.Equal(t, "", loaded.Find(netip.MustParseAddr("2001:db8::1")))
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("::ffff:10.0.0.0/104"), netip.MustParsePrefix("::ffff:10.1.0.0/112")},
+		loaded.ContainingNetworks(netip.MustParseAddr("10.1.0.1")))
+	assert.Nil(t, loaded.Find(netip.MustParseAddr("198.51.100.1")))
+
+	reencoded, err := loaded.MarshalText()
+	require.NoError(t, err)
+	assert.Equal(t, string(data), string(reencoded))
+}
+
+func TestTrieUnmarshalTextChangeLog(t *testing.T) {
+	src := NewTrie()
+	src.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	src.Insert(netip.MustParsePrefix("192.0.2.1/32"), nil)
+	data, err := src.MarshalText()
+	require.NoError(t, err)
+
+	trie := NewTrie()
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), "old")
+	var changes []Change
+	trie.SetChangeLog(func(c Change) {
+		changes = append(changes, c)
+	})
+	require.NoError(t, trie.UnmarshalText(data))
+	assert.Empty(t, changes)
+	assert.Equal(t, src.String(), trie.String())
+}
+
+func TestTrieMarshalTextError(t *testing.T) {
+	trie := NewTrie()
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), 1)
+	_, err := trie.MarshalText()
+	assert.ErrorContains(t, err, "encoding value for 10.0.0.0/8")
+
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a\nb")
+	_, err = trie.MarshalText()
+	assert.ErrorContains(t, err, "line break")
+}
+
+func TestTrieUnmarshalTextError(t *testing.T) {
+	trie := NewTrie()
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	err := trie.UnmarshalText([]byte("192.0.2.0/24 b\n\n192.0.2.300/24 c\n"))
+	assert.ErrorContains(t, err, "line 3: ")
+	assert.Equal(t, "a", trie.Find(netip.MustParseAddr("10.0.0.1")))
+}
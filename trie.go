@@ -2,11 +2,17 @@
 package iptrie
 
 import (
+	"bufio"
+	"context"
+	"encoding/binary"
 	"fmt"
+	"io"
 	"math/bits"
 	"net/netip"
+	"sort"
 	"strings"
-	"unsafe"
+	"time"
+	"unicode/utf8"
 )
 
 // Trie is a compressed IP radix trie implementation, similar to what is described at
@@ -15,72 +21,428 @@ import (
 // Path compression merges nodes with only one child into their parent, decreasing the amount of traversals needed when
 // looking up a value.
 type Trie struct {
-	parent   *Trie
-	children [2]*Trie
+	node
 
-	network netip.Prefix
-	value   any
+	// v4 caches the location of the IPv4 (::ffff:0:0/96) portion of the trie, allowing IPv4 lookups to skip the path
+	// leading to it.
+	v4 v4Root
+
+	// changeLog receives each modification made through Insert and Remove. See SetChangeLog.
+	changeLog func(Change)
+	// hooks are the registered mutation hooks. It is never modified in place, as it is shared with copies of the trie.
+	hooks *hooks
+	// denormalize indicates that networks returned to the caller should be denormalized. See SetDenormalize.
+	denormalize bool
+	// preserveOriginal indicates that inserted values should be wrapped in an OriginalEntry. See SetPreserveOriginal.
+	preserveOriginal bool
+	// zonePolicy is the handling of IPv6 addresses with a zone. See SetZonePolicy.
+	zonePolicy ZonePolicy
+	// requireMasked indicates that InsertStrict and RemoveStrict reject networks which are not masked.
+	requireMasked bool
+	// defaultValue is returned by Find and FindLargest when no entry matches. See SetDefault.
+	defaultValue any
+	// stats holds the metadata of entries, if enabled. See SetTrackEntries.
+	stats *entryStats
+	// merge combines the existing and inserted values when inserting a network which already has an entry. See
+	// NewTrieWithMerge.
+	merge func(old, new any) any
+	// instr receives the operations performed on the trie. See SetInstrumentation.
+	instr Instrumentation
+	// probes records the number of nodes visited by lookups. See SetProbeProfile.
+	probes *ProbeProfile
+	// effects, if not nil, collects the side effects of modifications (entry tracking, hooks and the change log) rather
+	// than applying them immediately. It is set on the trie of a Txn, so they are only applied if it is committed.
+	effects *[]func()
+}
+
+// node is a single node within a Trie. The root node of a trie is embedded within the Trie itself.
+type node struct {
+	children [2]*node
+
+	// addr and bits are the network of the node. They are kept in their raw form rather than as a netip.Prefix so that
+	// comparisons don't need to convert on every traversal, and are only converted at the API boundary.
+	addr  uint128
+	value any
+
+	// owner is the copy-on-write generation the node belongs to. A node may only be modified in place when its owner
+	// matches that of the root it is being modified through. Otherwise the node may be shared with another version of
+	// the trie, and must be copied first.
+	owner *owner
+
+	bits uint8
+}
+
+// owner identifies a copy-on-write generation of nodes.
+//
+// Generations are compared by pointer, so owner must never be zero sized, as the Go runtime may give distinct zero
+// sized allocations the same address.
+type owner struct {
+	_ byte
+}
+
+// fork returns a new generation.
+func (o *owner) fork() *owner {
+	return &owner{}
+}
+
+func (o *owner) newNode() *node {
+	return &node{owner: o}
 }
 
 // NewTrie creates a new Trie.
 func NewTrie() *Trie {
-	return &Trie{
-		network: netip.PrefixFrom(netip.IPv6Unspecified(), 0),
-	}
+	t := &Trie{}
+	t.refreshV4()
+	return t
 }
 
-func newSubTree(network netip.Prefix, value any) *Trie {
-	return &Trie{
-		network: network,
-		value:   value,
-	}
+func newSubTree(addr uint128, bits uint8, value any, o *owner) *node {
+	n := o.newNode()
+	n.addr = addr
+	n.bits = bits
+	n.value = value
+	return n
+}
+
+// NewTrieWithMerge creates a new Trie which combines values when inserting a network which already has an entry.
+// Instead of replacing the existing value, Insert stores the result of merge, which is called with the existing value
+// and the inserted value. For example, merge could append the inserted value to a slice, or keep the larger of the two.
+func NewTrieWithMerge(merge func(old, new any) any) *Trie {
+	t := NewTrie()
+	t.merge = merge
+	return t
 }
 
-// Insert inserts an entry into the trie.
+// Insert inserts an entry into the trie. If the trie was created with NewTrieWithMerge and the network already has an
+// entry, the values are merged.
 func (pt *Trie) Insert(network netip.Prefix, value any) {
+	pt.insertEntry(network, value, pt.merge)
+}
+
+// insertEntry is Insert, merging the values with merge if it's not nil.
+func (pt *Trie) insertEntry(network netip.Prefix, value any, merge func(old, new any) any) {
+	var start time.Time
+	if pt.instr != nil {
+		start = time.Now()
+	}
+	if pt.preserveOriginal {
+		value = preserveOriginal(network, value)
+	}
 	network = normalizePrefix(network)
-	pt.insert(network, emptyize(value))
+	addr, bits := prefix128(network)
+	var old any
+	if pt.hooks != nil || merge != nil {
+		if n := pt.get(addr, bits); n != nil {
+			old = n.value
+		}
+	}
+	if merge != nil && old != nil {
+		value = merge(unempty(old), value)
+	}
+	pt.insert(addr, bits, emptyize(value))
+	pt.refreshV4()
+	if pt.stats != nil || pt.hooks != nil || pt.changeLog != nil {
+		now := time.Now()
+		pt.notify(func() {
+			if pt.stats != nil {
+				pt.stats.inserted(entryKey{addr, bits}, now)
+			}
+			if pt.hooks != nil {
+				pt.hooks.inserted(network, old, value)
+			}
+			if pt.changeLog != nil {
+				pt.changeLog(Change{Op: ChangeInsert, Network: network, Value: value})
+			}
+		})
+	}
+	if pt.instr != nil {
+		pt.instr.Insert(network, time.Since(start))
+	}
 }
 
 // Remove removes the entry identified by given network from trie.
 func (pt *Trie) Remove(network netip.Prefix) any {
+	if pt.instr == nil {
+		return pt.removeEntry(normalizePrefix(network))
+	}
+	start := time.Now()
 	network = normalizePrefix(network)
-	return pt.remove(network)
+	v := pt.removeEntry(network)
+	pt.instr.Remove(network, v != nil, time.Since(start))
+	return v
+}
+
+// removeEntry is Remove for a normalized network.
+func (pt *Trie) removeEntry(network netip.Prefix) any {
+	addr, bits := prefix128(network)
+	// Check for existence first so that nodes aren't needlessly copied when nothing is removed.
+	if node := pt.get(addr, bits); node == nil || node.value == nil {
+		return nil
+	}
+	v := pt.remove(addr, bits)
+	pt.refreshV4()
+	if pt.stats != nil || pt.hooks != nil || pt.changeLog != nil {
+		pt.notify(func() {
+			if pt.stats != nil {
+				pt.stats.removed(entryKey{addr, bits})
+			}
+			if pt.hooks != nil {
+				pt.hooks.removed(network, unempty(v))
+			}
+			if pt.changeLog != nil {
+				pt.changeLog(Change{Op: ChangeRemove, Network: network, Value: unempty(v)})
+			}
+		})
+	}
+	return v
+}
+
+// notify applies fn, the side effects of a modification, immediately, or if the trie belongs to a Txn, once it is
+// committed.
+func (pt *Trie) notify(fn func()) {
+	if pt.effects != nil {
+		*pt.effects = append(*pt.effects, fn)
+		return
+	}
+	fn()
 }
 
 // Find returns the value from the most specific network (largest prefix) containing the given address.
+//
+// Find, FindLargest and Contains do not allocate. See SetZonePolicy regarding IPv6 addresses with a zone.
 func (pt *Trie) Find(ip netip.Addr) any {
-	ip = normalizeAddr(ip)
-	return unempty(pt.find(ip))
+	if pt.instr != nil {
+		start := time.Now()
+		v := pt.findValue(ip)
+		pt.instr.Lookup(LookupFind, v != nil, time.Since(start))
+		return pt.result(v)
+	}
+	return pt.result(pt.findValue(ip))
+}
+
+// findValue returns the value of the node found by Find, or nil if there is none.
+func (pt *Trie) findValue(ip netip.Addr) any {
+	if pt.stats != nil {
+		return pt.findTracked(ip)
+	}
+	if pt.probes != nil {
+		return pt.findProbed(ip)
+	}
+	if ip.Is4() && pt.v4.start != nil {
+		return pt.v4.find(v4Addr128(ip))
+	}
+	if pt.zoneExcluded(ip) {
+		return nil
+	}
+	return pt.find(lookupAddr128(ip))
+}
+
+// result returns the value to return to the caller of a lookup for the value of a node, which is nil if no entry
+// matched.
+func (pt *Trie) result(v any) any {
+	if v == nil {
+		return pt.defaultValue
+	}
+	return unempty(v)
 }
 
 // FindLargest returns the value from the largest network (smallest prefix) containing the given address.
 func (pt *Trie) FindLargest(ip netip.Addr) any {
-	ip = normalizeAddr(ip)
-	return unempty(pt.findLargest(ip))
+	if pt.instr != nil {
+		start := time.Now()
+		v := pt.findLargestValue(ip)
+		pt.instr.Lookup(LookupFindLargest, v != nil, time.Since(start))
+		return pt.result(v)
+	}
+	return pt.result(pt.findLargestValue(ip))
+}
+
+// findLargestValue returns the value of the node found by FindLargest, or nil if there is none.
+func (pt *Trie) findLargestValue(ip netip.Addr) any {
+	if pt.probes != nil {
+		return pt.findLargestProbed(ip)
+	}
+	if ip.Is4() && pt.v4.start != nil {
+		return pt.v4.findLargest(v4Addr128(ip))
+	}
+	if pt.zoneExcluded(ip) {
+		return nil
+	}
+	return pt.findLargest(lookupAddr128(ip))
 }
 
 // Contains indicates whether the trie contains the given ip.
 func (pt *Trie) Contains(ip netip.Addr) bool {
-	ip = normalizeAddr(ip)
-	return pt.findLargest(ip) != nil
+	if pt.instr != nil {
+		start := time.Now()
+		found := pt.findLargestValue(ip) != nil
+		pt.instr.Lookup(LookupContains, found, time.Since(start))
+		return found
+	}
+	return pt.findLargestValue(ip) != nil
 }
 
 // ContainingNetworks returns the list of networks containing the given ip in ascending prefix order (largest network to
 // smallest).
 //
-// Note: Inserted addresses are normalized to IPv6, so the returned list will be IPv6 only.
+// Note: Inserted addresses are normalized to IPv6, so the returned list will be IPv6 only, unless SetDenormalize is
+// used.
 func (pt *Trie) ContainingNetworks(ip netip.Addr) []netip.Prefix {
-	ip = normalizeAddr(ip)
-	return pt.containingNetworks(ip)
+	return pt.ContainingNetworksAppend(nil, ip)
+}
+
+// ContainingNetworksAppend is like ContainingNetworks, but appends the networks to dst and returns the extended slice.
+// Reusing dst across calls avoids allocating a new slice for each lookup.
+func (pt *Trie) ContainingNetworksAppend(dst []netip.Prefix, ip netip.Addr) []netip.Prefix {
+	if pt.zoneExcluded(ip) {
+		return dst
+	}
+	return pt.denormalizeFrom(pt.appendContainingNetworks(dst, lookupAddr128(ip)), len(dst))
 }
 
 // CoveredNetworks returns the list of networks contained within the given network.
 //
-// Note: Inserted addresses are normalized to IPv6, so the returned list will be IPv6 only.
+// Note: Inserted addresses are normalized to IPv6, so the returned list will be IPv6 only, unless SetDenormalize is
+// used.
 func (pt *Trie) CoveredNetworks(network netip.Prefix) []netip.Prefix {
-	network = normalizePrefix(network)
-	return pt.coveredNetworks(network)
+	return pt.CoveredNetworksAppend(nil, network)
+}
+
+// CoveredNetworksAppend is like CoveredNetworks, but appends the networks to dst and returns the extended slice.
+// Reusing dst across calls avoids allocating a new slice for each lookup.
+func (pt *Trie) CoveredNetworksAppend(dst []netip.Prefix, network netip.Prefix) []netip.Prefix {
+	addr, bits := prefix128(normalizePrefix(network))
+	return pt.denormalizeFrom(pt.appendCoveredNetworks(dst, addr, bits), len(dst))
+}
+
+// SetDefault sets the value returned by Find and FindLargest for addresses which no entry contains, providing an
+// "otherwise" rule without inserting entries for ::/0 and 0.0.0.0/0. The default is not an entry, so it isn't reported
+// by Contains, ContainingNetworks, Walk, or serialization. Passing nil removes the default.
+//
+// Snapshots keep the default, as do the tries created with NewRCUTrieFrom and NewVersionedTrieFrom.
+func (pt *Trie) SetDefault(value any) {
+	pt.defaultValue = value
+}
+
+// DefaultRoute returns the value set by SetDefault, or nil if there is none.
+func (pt *Trie) DefaultRoute() any {
+	return pt.defaultValue
+}
+
+// ZonePolicy is the handling of IPv6 addresses with a zone (such as fe80::1%eth0) by lookups. Networks cannot have a
+// zone, so entries never have one.
+type ZonePolicy uint8
+
+const (
+	// ZoneIgnore looks up addresses without regard to their zone, so fe80::1%eth0 and fe80::1%eth1 both match an entry
+	// for fe80::/10. This is the default.
+	ZoneIgnore ZonePolicy = iota
+	// ZoneNoMatch causes addresses with a zone to not match any entry. This is appropriate when entries describe global
+	// addresses, or when the same link-local addresses on different links must not be conflated.
+	ZoneNoMatch
+)
+
+// SetZonePolicy sets the handling of IPv6 addresses with a zone by Find, FindLargest, Contains and ContainingNetworks.
+// The policy carries over to snapshots, and to tries built from the trie with NewRCUTrieFrom or NewVersionedTrieFrom.
+func (pt *Trie) SetZonePolicy(policy ZonePolicy) {
+	pt.zonePolicy = policy
+}
+
+// zoneExcluded indicates whether ip is excluded from lookups by the zone policy.
+func (pt *Trie) zoneExcluded(ip netip.Addr) bool {
+	return pt.zonePolicy == ZoneNoMatch && ip.Zone() != ""
+}
+
+// SetDenormalize sets whether IPv4 networks are returned in their IPv4 form, such as 192.0.2.0/24, rather than their
+// normalized IPv6 form, ::ffff:192.0.2.0/120. This applies to ContainingNetworks, CoveredNetworks, Walk and Aggregate,
+// of the trie as well as of its snapshots and the tries created from it with NewRCUTrieFrom and NewVersionedTrieFrom.
+func (pt *Trie) SetDenormalize(denormalize bool) {
+	pt.denormalize = denormalize
+}
+
+// denormalizeFrom denormalizes the networks of dst from index start onwards, if pt.denormalize is set, and returns dst.
+func (pt *Trie) denormalizeFrom(dst []netip.Prefix, start int) []netip.Prefix {
+	if pt.denormalize {
+		for i := start; i < len(dst); i++ {
+			dst[i] = denormalizePrefix(dst[i])
+		}
+	}
+	return dst
+}
+
+// resultNetwork returns the network of n, as it should be returned to the caller.
+func (pt *Trie) resultNetwork(n *node) netip.Prefix {
+	if pt.denormalize {
+		return denormalizePrefix(n.network())
+	}
+	return n.network()
+}
+
+// IsFullyCovered indicates whether every address within the given network is covered by an entry in the trie. The
+// network may be covered by a single entry, or by multiple entries which together span the whole network.
+func (pt *Trie) IsFullyCovered(network netip.Prefix) bool {
+	addr, bits := prefix128(normalizePrefix(network))
+	if pt.bits > bits || !pt.contains(addr) {
+		return false
+	}
+	return pt.isFullyCovered(addr, bits)
+}
+
+// Snapshot returns an immutable point-in-time view of the trie in O(1) time.
+//
+// The snapshot shares its nodes with the trie. Subsequent modifications to the trie copy the nodes they modify, leaving
+// the snapshot unaffected. The snapshot may be read from concurrently with modifications to the trie, but must not be
+// modified itself.
+//
+// Snapshot must be called on the root of the trie, and must not be called concurrently with modifications.
+func (pt *Trie) Snapshot() *Trie {
+	snap := pt.cow()
+	// All existing nodes now belong to the snapshot as well, so move the trie to a new generation to prevent them from
+	// being modified in place.
+	pt.owner = pt.owner.fork()
+	return snap
+}
+
+// Walk calls fn for each entry in the trie, in depth order (a network is visited before the networks it contains).
+// Walking stops if fn returns false.
+//
+// Note: Inserted addresses are normalized to IPv6, unless SetDenormalize is used.
+func (pt *Trie) Walk(fn func(network netip.Prefix, value any) bool) {
+	if pt.instr != nil {
+		var done func()
+		fn, done = pt.instrumentWalk(fn)
+		defer done()
+	}
+	pt.walk(func(n *node) bool {
+		return fn(pt.resultNetwork(n), unempty(n.value))
+	})
+}
+
+// WalkCtx is like Walk, but additionally stops when ctx is done, in which case the context's error is returned.
+func (pt *Trie) WalkCtx(ctx context.Context, fn func(network netip.Prefix, value any) bool) error {
+	if pt.instr != nil {
+		var done func()
+		fn, done = pt.instrumentWalk(fn)
+		defer done()
+	}
+	done := ctx.Done()
+	var err error
+	pt.walk(func(n *node) bool {
+		select {
+		case <-done:
+			err = ctx.Err()
+			return false
+		default:
+		}
+		return fn(pt.resultNetwork(n), unempty(n.value))
+	})
+	return err
+}
+
+// Count returns the number of nodes in the trie, including the root and implicit nodes, and the number of entries. It
+// visits every node, so its cost is proportional to the size of the trie.
+func (pt *Trie) Count() (nodes, entries int) {
+	return pt.count()
 }
 
 // String returns string representation of trie.
@@ -88,245 +450,393 @@ func (pt *Trie) CoveredNetworks(network netip.Prefix) []netip.Prefix {
 // The result will contain implicit nodes which exist as parents for multiple entries, but can be distinguished by the
 // lack of a value.
 //
-// Note: Addresses are normalized to IPv6.
+// Note: Addresses are normalized to IPv6. See StringWith for other renderings.
 func (pt *Trie) String() string {
-	children := []string{}
-	padding := strings.Repeat("├ ", pt.level()+1)
-	for _, child := range pt.children {
-		if child == nil {
-			continue
+	return pt.StringWith(StringOpts{MaxValueWidth: 32})
+}
+
+// StringOpts controls the rendering of a trie by StringWith.
+type StringOpts struct {
+	// HideImplicit omits the implicit nodes, showing the children of each at the level it would have been shown at.
+	HideImplicit bool
+	// FormatValue returns the representation of a value. If nil, values are formatted with the %v verb.
+	FormatValue func(value any) string
+	// MaxValueWidth is the maximum number of characters of a value to show, with longer values being truncated. If 0,
+	// values are not truncated.
+	MaxValueWidth int
+	// Denormalize shows IPv4 networks in their IPv4 form, such as 192.0.2.0/24 rather than ::ffff:192.0.2.0/120.
+	Denormalize bool
+}
+
+// StringWith returns a string representation of trie, in the same form as String, rendered according to opts.
+func (pt *Trie) StringWith(opts StringOpts) string {
+	var b strings.Builder
+	pt.format(&b, &opts, 0)
+	return b.String()
+}
+
+func (pt *node) format(b *strings.Builder, opts *StringOpts, level int) {
+	if opts.HideImplicit && pt.value == nil {
+		for _, child := range pt.children {
+			if child != nil {
+				child.format(b, opts, level)
+			}
 		}
-		childStr := fmt.Sprintf("\n%s%s", padding, child.String())
-		children = append(children, childStr)
+		return
 	}
 
-	var value string
+	if b.Len() > 0 {
+		b.WriteString("\n")
+		b.WriteString(strings.Repeat("├ ", level))
+	}
+	network := pt.network()
+	if opts.Denormalize {
+		network = denormalizePrefix(network)
+	}
+	b.WriteString(network.String())
+
 	if pt.value != nil {
-		value = fmt.Sprintf("%v", unempty(pt.value))
-		if len(value) > 32 {
-			value = value[0:31] + "…"
+		var value string
+		if opts.FormatValue != nil {
+			value = opts.FormatValue(unempty(pt.value))
+		} else {
+			value = fmt.Sprintf("%v", unempty(pt.value))
 		}
-		value = " • " + value
+		if opts.MaxValueWidth > 0 && utf8.RuneCountInString(value) > opts.MaxValueWidth {
+			runes := []rune(value)
+			value = string(runes[:opts.MaxValueWidth-1]) + "…"
+		}
+		b.WriteString(" • ")
+		b.WriteString(value)
 	}
 
-	return fmt.Sprintf("%s%s%s", pt.network,
-		value, strings.Join(children, ""))
+	for _, child := range pt.children {
+		if child != nil {
+			child.format(b, opts, level+1)
+		}
+	}
 }
 
-func (pt *Trie) find(ip netip.Addr) any {
-	if !netContains(pt.network, ip) {
-		return nil
+// Render writes a representation of the trie to w in the same tree form as String, with each node on its own line,
+// without building the whole representation in memory. line is called for each node, including implicit nodes, in depth
+// order, and returns the text of the node's line. The network is normalized to IPv6, unless SetDenormalize is used.
+func (pt *Trie) Render(w io.Writer, line func(network netip.Prefix, value any, implicit bool) string) error {
+	bw := bufio.NewWriter(w)
+	var render func(n *node, level int)
+	render = func(n *node, level int) {
+		for i := 0; i < level; i++ {
+			bw.WriteString("├ ")
+		}
+		bw.WriteString(line(pt.resultNetwork(n), unempty(n.value), n.value == nil))
+		bw.WriteByte('\n')
+		for _, child := range n.children {
+			if child != nil {
+				render(child, level+1)
+			}
+		}
 	}
+	render(&pt.node, 0)
+	return bw.Flush()
+}
 
-	if pt.network.Bits() == 128 {
-		return pt.value
+// v4Prefix is the address of the ::ffff:0:0/96 network, under which all IPv4 addresses are stored.
+var v4Prefix = uint128{0, 0xffff << 32}
+
+// v4Root provides a shortcut to the portion of the trie containing IPv4 addresses, so that lookups of IPv4 addresses
+// don't need to traverse the nodes leading to it.
+type v4Root struct {
+	// start is the most specific node containing all of ::ffff:0:0/96. IPv4 lookups can begin at this node.
+	start *node
+	// first and last are the values of the least and most specific entries above start which contain ::ffff:0:0/96.
+	first any
+	last  any
+}
+
+// refreshV4 updates the v4 shortcut. It must be called after every modification of the trie.
+func (pt *Trie) refreshV4() {
+	start := &pt.node
+	for start.bits < 96 {
+		next := start.children[start.discriminatorBit(v4Prefix)]
+		if next == nil || next.bits > 96 || !next.contains(v4Prefix) {
+			break
+		}
+		start = next
 	}
 
-	bit := pt.discriminatorBitFromIP(ip)
-	child := pt.children[bit]
-	if child != nil {
-		if v := child.find(ip); v != nil {
-			return v
+	v4 := v4Root{start: start}
+	for n := &pt.node; n != start; n = n.children[n.discriminatorBit(v4Prefix)] {
+		if n.value == nil {
+			continue
+		}
+		if v4.first == nil {
+			v4.first = n.value
+		}
+		// Mirror the behavior of find, which doesn't fall back to the placeholder for nil values.
+		if n.value != empty {
+			v4.last = n.value
 		}
 	}
+	pt.v4 = v4
+}
 
-	return unempty(pt.value)
+func (v4 *v4Root) find(ip uint128) any {
+	if v := v4.start.find(ip); v != nil {
+		return v
+	}
+	return v4.last
 }
 
-func (pt *Trie) findLargest(ip netip.Addr) any {
-	if !netContains(pt.network, ip) {
-		return nil
+func (v4 *v4Root) findLargest(ip uint128) any {
+	if v4.first != nil {
+		return v4.first
 	}
+	return v4.start.findLargest(ip)
+}
 
-	if pt.value != nil {
-		return pt.value
+// network returns the network of the node as a netip.Prefix.
+func (pt *node) network() netip.Prefix {
+	return netip.PrefixFrom(addrFrom128(pt.addr), int(pt.bits))
+}
+
+func (pt *node) find(ip uint128) any {
+	if n := pt.findNode(ip); n != nil {
+		return n.value
 	}
+	return nil
+}
 
-	if pt.network.Bits() == 128 {
-		return nil
+// findNode returns the node of the entry whose value find returns, or nil if there is none.
+func (pt *node) findNode(ip uint128) *node {
+	var found *node
+	for n := pt; n != nil && n.contains(ip); n = n.children[n.discriminatorBit(ip)] {
+		if n.bits == 128 {
+			if n.value != nil {
+				return n
+			}
+			break
+		}
+		// Placeholders for nil values are skipped in favor of less specific networks, except on a full address match.
+		if n.value != nil && n.value != empty {
+			found = n
+		}
 	}
+	return found
+}
 
-	bit := pt.discriminatorBitFromIP(ip)
-	child := pt.children[bit]
-	if child != nil {
-		return child.findLargest(ip)
+// get returns the node for the exact given network, or nil if no such node exists. The returned node may be an
+// implicit node without a value.
+func (pt *node) get(addr uint128, bits uint8) *node {
+	for node := pt; node != nil; {
+		if node.bits == bits && node.addr == addr {
+			return node
+		}
+		if node.bits >= bits || !node.contains(addr) {
+			return nil
+		}
+		node = node.children[node.discriminatorBit(addr)]
 	}
+	return nil
+}
 
+func (pt *node) findLargest(ip uint128) any {
+	for n := pt; n != nil && n.contains(ip); n = n.children[n.discriminatorBit(ip)] {
+		if n.value != nil {
+			return n.value
+		}
+		if n.bits == 128 {
+			break
+		}
+	}
 	return nil
 }
 
-func (pt *Trie) containingNetworks(ip netip.Addr) []netip.Prefix {
-	var results []netip.Prefix
-	if !pt.network.Contains(ip) {
-		return results
+func (pt *node) appendContainingNetworks(dst []netip.Prefix, ip uint128) []netip.Prefix {
+	for n := pt; n != nil && n.contains(ip); n = n.children[n.discriminatorBit(ip)] {
+		if n.value != nil {
+			dst = append(dst, n.network())
+		}
+		if n.bits == 128 {
+			break
+		}
+	}
+	return dst
+}
+
+func (pt *node) appendCoveredNetworks(dst []netip.Prefix, addr uint128, bits uint8) []netip.Prefix {
+	if n := pt.coveredRoot(addr, bits); n != nil {
+		return n.appendEntries(dst)
 	}
+	return dst
+}
+
+// coveredRoot returns the topmost node within the given network, whose subtree holds all the entries covered by it, or
+// nil if there are none.
+func (pt *node) coveredRoot(addr uint128, bits uint8) *node {
+	for n := pt; n != nil; n = n.children[n.discriminatorBit(addr)] {
+		if bits <= n.bits && netContains(addr, bits, n.addr) {
+			return n
+		}
+		if n.bits == 128 {
+			break
+		}
+	}
+	return nil
+}
+
+// isFullyCovered expects the network to be contained within the network of pt.
+func (pt *node) isFullyCovered(addr uint128, bits uint8) bool {
 	if pt.value != nil {
-		results = []netip.Prefix{pt.network}
+		return true
 	}
-	if pt.network.Bits() == 128 {
-		return results
+	if pt.bits == 128 {
+		return false
 	}
-	bit := pt.discriminatorBitFromIP(ip)
-	child := pt.children[bit]
-	if child != nil {
-		ranges := child.containingNetworks(ip)
-		if len(ranges) > 0 {
-			if len(results) > 0 {
-				results = append(results, ranges...)
-			} else {
-				results = ranges
-			}
-		}
+
+	if bits > pt.bits {
+		return pt.childCovers(pt.discriminatorBit(addr), addr, bits)
 	}
-	return results
+
+	// The network is the same as that of pt, so both halves need to be covered.
+	upper := pt.addr.xor(mask6(int(pt.bits)).xor(mask6(int(pt.bits) + 1)))
+	return pt.childCovers(0, pt.addr, pt.bits+1) && pt.childCovers(1, upper, pt.bits+1)
 }
 
-func (pt *Trie) coveredNetworks(network netip.Prefix) []netip.Prefix {
-	var results []netip.Prefix
-	if network.Bits() <= pt.network.Bits() && network.Contains(pt.network.Addr()) {
-		for entry := range pt.walkDepth() {
-			results = append(results, entry)
-		}
-	} else if pt.network.Bits() < 128 {
-		bit := pt.discriminatorBitFromIP(network.Addr())
-		child := pt.children[bit]
-		if child != nil {
-			return child.coveredNetworks(network)
-		}
+// childCovers indicates whether the child at the given bit fully covers the network, which must reside within that half
+// of the network of pt.
+func (pt *node) childCovers(bit uint8, addr uint128, bits uint8) bool {
+	child := pt.children[bit]
+	if child == nil {
+		return false
+	}
+	// If the child is smaller than the network, then the rest of the network has no entries, as there are no other
+	// nodes on this side of pt.
+	if child.bits > bits || !child.contains(addr) {
+		return false
 	}
-	return results
+	return child.isFullyCovered(addr, bits)
+}
+
+// contains indicates whether the network of pt contains the given ip.
+func (pt *node) contains(ip uint128) bool {
+	return netContains(pt.addr, pt.bits, ip)
 }
 
-// This is an unsafe, but faster version of netip.Prefix.Contains
-func netContains(pfx netip.Prefix, ip netip.Addr) bool {
-	pfxAddr := addr128(pfx.Addr())
-	ipAddr := addr128(ip)
-	return ipAddr.xor(pfxAddr).and(mask6(pfx.Bits())).isZero()
+// netContains indicates whether the network given by addr and bits contains ip.
+func netContains(addr uint128, bits uint8, ip uint128) bool {
+	return ip.xor(addr).and(mask6(int(bits))).isZero()
 }
 
 // netDivergence returns the largest prefix shared by the provided 2 prefixes
-func netDivergence(net1 netip.Prefix, net2 netip.Prefix) netip.Prefix {
-	if net1.Bits() > net2.Bits() {
-		net1, net2 = net2, net1
+func netDivergence(addr1 uint128, bits1 uint8, addr2 uint128, bits2 uint8) (uint128, uint8) {
+	if bits1 > bits2 {
+		addr1, bits1, addr2, bits2 = addr2, bits2, addr1, bits1
 	}
 
-	if netContains(net1, net2.Addr()) {
-		return net1
+	if netContains(addr1, bits1, addr2) {
+		return addr1, bits1
 	}
 
-	diff := addr128(net1.Addr()).xor(addr128(net2.Addr()))
-	var bit int
-	if diff.hi != 0 {
-		bit = bits.LeadingZeros64(diff.hi)
-	} else {
-		bit = bits.LeadingZeros64(diff.lo) + 64
-	}
-	if bit > net1.Bits() {
-		bit = net1.Bits()
+	bit := commonBits(addr1, addr2)
+	if bit > bits1 {
+		bit = bits1
 	}
-	pfx, _ := net1.Addr().Prefix(bit)
-	return pfx
+	return addr1.bitsClearedFrom(bit), bit
 }
 
-func (pt *Trie) insert(network netip.Prefix, value any) *Trie {
-	if pt.network == network {
-		pt.value = value
-		return pt
+// commonBits returns the number of leading bits which are the same between the 2 addresses.
+func commonBits(addr1, addr2 uint128) uint8 {
+	diff := addr1.xor(addr2)
+	if diff.hi != 0 {
+		return uint8(bits.LeadingZeros64(diff.hi))
 	}
+	return uint8(bits.LeadingZeros64(diff.lo) + 64)
+}
+
+func (pt *node) insert(addr uint128, bits uint8, value any) *node {
+	for n := pt; ; {
+		if n.bits == bits && n.addr == addr {
+			n.value = value
+			return n
+		}
 
-	bit := pt.discriminatorBitFromIP(network.Addr())
-	existingChild := pt.children[bit]
+		bit := n.discriminatorBit(addr)
+		existingChild := n.children[bit]
 
-	// No existing child, insert new leaf trie.
-	if existingChild == nil {
-		pNew := newSubTree(network, value)
-		pt.appendTrie(bit, pNew)
-		return pNew
-	}
+		// No existing child, insert new leaf trie.
+		if existingChild == nil {
+			pNew := newSubTree(addr, bits, value, n.owner)
+			n.appendTrie(bit, pNew)
+			return pNew
+		}
+		existingChild = n.ownChild(bit)
 
-	// Check whether it is necessary to insert additional path prefix between current trie and existing child,
-	// in the case that inserted network diverges on its path to existing child.
-	netdiv := netDivergence(existingChild.network, network)
-	if netdiv != existingChild.network {
-		pathPrefix := newSubTree(netdiv, nil)
-		pt.insertPrefix(bit, pathPrefix, existingChild)
-		// Update new child
-		existingChild = pathPrefix
+		// Check whether it is necessary to insert additional path prefix between current trie and existing child,
+		// in the case that inserted network diverges on its path to existing child.
+		divAddr, divBits := netDivergence(existingChild.addr, existingChild.bits, addr, bits)
+		if divBits != existingChild.bits {
+			pathPrefix := newSubTree(divAddr, divBits, nil, n.owner)
+			n.insertPrefix(bit, pathPrefix, existingChild)
+			// Update new child
+			existingChild = pathPrefix
+		}
+		n = existingChild
 	}
-	return existingChild.insert(network, value)
 }
 
-func (pt *Trie) appendTrie(bit uint8, prefix *Trie) {
+func (pt *node) appendTrie(bit uint8, prefix *node) {
 	pt.children[bit] = prefix
-	prefix.parent = pt
 }
 
-func (pt *Trie) insertPrefix(bit uint8, pathPrefix, child *Trie) {
-	// Set parent/child relationship between current trie and inserted pathPrefix
+func (pt *node) insertPrefix(bit uint8, pathPrefix, child *node) {
 	pt.children[bit] = pathPrefix
-	pathPrefix.parent = pt
 
-	// Set parent/child relationship between inserted pathPrefix and original child
-	pathPrefixBit := pathPrefix.discriminatorBitFromIP(child.network.Addr())
+	// The original child now descends from pathPrefix.
+	pathPrefixBit := pathPrefix.discriminatorBit(child.addr)
 	pathPrefix.children[pathPrefixBit] = child
-	child.parent = pathPrefix
 }
 
-func (pt *Trie) remove(network netip.Prefix) any {
-	if pt.value != nil && pt.network == network {
+// remove removes the entry with the given network from below pt, returning its value. Nodes left without an entry
+// and with fewer than 2 children are removed on the way back up, though pt itself is never removed.
+func (pt *node) remove(addr uint128, bits uint8) any {
+	if pt.value != nil && pt.bits == bits && pt.addr == addr {
 		entry := pt.value
 		pt.value = nil
-
-		pt.compressPathIfPossible()
 		return entry
 	}
-	if pt.network.Bits() == 128 {
+	if pt.bits >= bits || !pt.contains(addr) {
 		return nil
 	}
-	bit := pt.discriminatorBitFromIP(network.Addr())
-	child := pt.children[bit]
-	if child != nil {
-		return child.remove(network)
+	bit := pt.discriminatorBit(addr)
+	child := pt.ownChild(bit)
+	if child == nil {
+		return nil
 	}
-	return nil
-}
-
-func (pt *Trie) qualifiesForPathCompression() bool {
-	// Current prefix trie can be path compressed if it meets all following.
-	//		1. records no CIDR entry
-	//		2. has single or no child
-	//		3. is not root trie
-	return pt.value == nil && pt.childrenCount() <= 1 && pt.parent != nil
+	entry := child.remove(addr, bits)
+	if entry != nil {
+		pt.compressChild(bit)
+	}
+	return entry
 }
 
-func (pt *Trie) compressPathIfPossible() {
-	if !pt.qualifiesForPathCompression() {
-		// Does not qualify to be compressed
+// compressChild replaces the child at the given bit with its lone child, if any, when the child has no entry and fewer
+// than 2 children. The child must already belong to the same copy-on-write generation as pt.
+func (pt *node) compressChild(bit uint8) {
+	child := pt.children[bit]
+	if child.value != nil || child.childrenCount() > 1 {
 		return
 	}
 
-	// Find lone child.
-	var loneChild *Trie
-	for _, child := range pt.children {
-		if child != nil {
-			loneChild = child
+	var loneChild *node
+	for childBit, grandchild := range child.children {
+		if grandchild != nil {
+			loneChild = child.ownChild(uint8(childBit))
 			break
 		}
 	}
-
-	// Find root of currnt single child lineage.
-	parent := pt.parent
-	for ; parent.qualifiesForPathCompression(); parent = parent.parent {
-	}
-	parentBit := parent.discriminatorBitFromIP(pt.network.Addr())
-	parent.children[parentBit] = loneChild
-
-	// Attempts to furthur apply path compression at current lineage parent, in case current lineage
-	// compressed into parent.
-	parent.compressPathIfPossible()
+	pt.children[bit] = loneChild
 }
 
-func (pt *Trie) childrenCount() int {
+func (pt *node) childrenCount() int {
 	count := 0
 	for _, child := range pt.children {
 		if child != nil {
@@ -336,85 +846,229 @@ func (pt *Trie) childrenCount() int {
 	return count
 }
 
-func (pt *Trie) discriminatorBitFromIP(addr netip.Addr) uint8 {
-	// This is a safe uint boxing of int since we should never attempt to get
-	// target bit at a negative position.
-	pos := pt.network.Bits()
-	a128 := addr128(addr)
-	if pos < 64 {
-		return uint8(a128.hi >> (63 - pos) & 1)
+// discriminatorBit returns the bit of addr immediately following the network of pt, which determines the child addr
+// belongs under.
+func (pt *node) discriminatorBit(addr uint128) uint8 {
+	return addr.bit(pt.bits)
+}
+
+// ownChild returns the child at the given bit, first replacing it with a copy if it belongs to a different copy-on-write
+// generation than pt. pt itself must already belong to the generation being modified.
+func (pt *node) ownChild(bit uint8) *node {
+	child := pt.children[bit]
+	if child == nil || child.owner == pt.owner {
+		return child
 	}
-	return uint8(a128.lo >> (63 - (pos - 64)) & 1)
+	clone := pt.owner.newNode()
+	clone.children = child.children
+	clone.addr = child.addr
+	clone.bits = child.bits
+	clone.value = child.value
+	pt.children[bit] = clone
+	return clone
 }
 
-func (pt *Trie) level() int {
-	if pt.parent == nil {
-		return 0
+// cow returns a new root sharing all nodes with pt, but belonging to a new copy-on-write generation. Modifications to
+// the returned root will copy the nodes being modified, leaving pt untouched.
+func (pt *Trie) cow() *Trie {
+	t := &Trie{
+		node: node{
+			children: pt.children,
+			addr:     pt.addr,
+			bits:     pt.bits,
+			value:    pt.value,
+			owner:    pt.owner.fork(),
+		},
+		changeLog:        pt.changeLog,
+		hooks:            pt.hooks,
+		denormalize:      pt.denormalize,
+		preserveOriginal: pt.preserveOriginal,
+		zonePolicy:       pt.zonePolicy,
+		requireMasked:    pt.requireMasked,
+		defaultValue:     pt.defaultValue,
+		stats:            pt.stats,
+		merge:            pt.merge,
+		instr:            pt.instr,
+		probes:           pt.probes,
 	}
-	return pt.parent.level() + 1
+	t.refreshV4()
+	return t
 }
 
-// walkDepth walks the trie in depth order
-func (pt *Trie) walkDepth() <-chan netip.Prefix {
-	entries := make(chan netip.Prefix)
-	go func() {
-		if pt.value != nil {
-			entries <- pt.network
-		}
-		childEntriesList := []<-chan netip.Prefix{}
-		for _, trie := range pt.children {
-			if trie == nil {
-				continue
-			}
-			childEntriesList = append(childEntriesList, trie.walkDepth())
+// walk calls fn for each node with an entry, in depth order. It stops and returns false as soon as fn returns false.
+func (pt *node) walk(fn func(n *node) bool) bool {
+	if pt.value != nil && !fn(pt) {
+		return false
+	}
+	for _, child := range pt.children {
+		if child != nil && !child.walk(fn) {
+			return false
 		}
-		for _, childEntries := range childEntriesList {
-			for entry := range childEntries {
-				entries <- entry
-			}
+	}
+	return true
+}
+
+// count returns the number of nodes at or below pt, including implicit nodes, and the number of those which are
+// entries.
+func (pt *node) count() (nodes, entries int) {
+	nodes = 1
+	if pt.value != nil {
+		entries = 1
+	}
+	for _, child := range pt.children {
+		if child != nil {
+			n, e := child.count()
+			nodes += n
+			entries += e
 		}
-		close(entries)
-	}()
-	return entries
+	}
+	return nodes, entries
+}
+
+// appendEntries appends the networks of all entries at or below pt to dst, in depth order.
+func (pt *node) appendEntries(dst []netip.Prefix) []netip.Prefix {
+	pt.walk(func(n *node) bool {
+		dst = append(dst, n.network())
+		return true
+	})
+	return dst
 }
 
 // TrieLoader can be used to improve the performance of bulk inserts to a Trie. It caches the node of the
 // last insert in the tree, using it as the starting point to start searching for the location of the next insert. This
 // is highly beneficial when the addresses are pre-sorted.
 type TrieLoader struct {
-	trie       *Trie
-	lastInsert *Trie
+	trie *Trie
+	// path holds the nodes from the root of the trie to the node of the last operation.
+	path  []*node
+	stats TrieLoaderStats
+}
+
+// TrieLoaderStats reports how effective the cached node of a TrieLoader has been. When the input is well ordered, the
+// number of root restarts is low, and the parent hops per operation are few.
+type TrieLoaderStats struct {
+	// Operations is the number of inserts and removals performed.
+	Operations uint64
+	// ParentHops is the number of levels ascended from the cached node to find where each operation starts.
+	ParentHops uint64
+	// RootRestarts is the number of operations which started from the root of the trie, either because the network
+	// shares no closer node with the cached node, or because the cached node could not be used after a snapshot was
+	// taken.
+	RootRestarts uint64
 }
 
 func NewTrieLoader(trie *Trie) *TrieLoader {
 	return &TrieLoader{
-		trie:       trie,
-		lastInsert: trie,
+		trie: trie,
+		path: []*node{&trie.node},
 	}
 }
 
 func (ptl *TrieLoader) Insert(pfx netip.Prefix, v any) {
 	pfx = normalizePrefix(pfx)
+	addr, bits := prefix128(pfx)
+	parent := ptl.ancestor(addr, bits)
+	inserted := parent.insert(addr, bits, emptyize(v))
+	for n := parent; n != inserted; {
+		n = n.children[n.discriminatorBit(addr)]
+		ptl.path = append(ptl.path, n)
+	}
+	ptl.trie.refreshV4()
+}
 
-	diff := addr128(ptl.lastInsert.network.Addr()).xor(addr128(pfx.Addr()))
-	var pos int
-	if diff.hi != 0 {
-		pos = bits.LeadingZeros64(diff.hi)
-	} else {
-		pos = bits.LeadingZeros64(diff.lo) + 64
+// ancestor returns the closest node to the cached node which contains the given network, truncating the path to it.
+func (ptl *TrieLoader) ancestor(addr uint128, bits uint8) *node {
+	// If the trie has since moved on to a new copy-on-write generation (e.g. a snapshot was taken), the cached node may
+	// be shared and can no longer be modified in place.
+	if ptl.path[len(ptl.path)-1].owner != ptl.trie.owner {
+		ptl.path = ptl.path[:1]
 	}
-	if pos > pfx.Bits() {
-		pos = pfx.Bits()
+	last := ptl.path[len(ptl.path)-1]
+
+	pos := commonBits(last.addr, addr)
+	if pos > bits {
+		pos = bits
 	}
-	if pos > ptl.lastInsert.network.Bits() {
-		pos = ptl.lastInsert.network.Bits()
+	if pos > last.bits {
+		pos = last.bits
 	}
 
-	parent := ptl.lastInsert
-	for parent.network.Bits() > pos {
-		parent = parent.parent
+	ptl.stats.Operations++
+	for ptl.path[len(ptl.path)-1].bits > pos {
+		ptl.path = ptl.path[:len(ptl.path)-1]
+		ptl.stats.ParentHops++
+	}
+	if len(ptl.path) == 1 {
+		ptl.stats.RootRestarts++
+	}
+	return ptl.path[len(ptl.path)-1]
+}
+
+// Stats returns statistics on the effectiveness of the cached node for the operations performed so far.
+func (ptl *TrieLoader) Stats() TrieLoaderStats {
+	return ptl.stats
+}
+
+// Remove removes the entry identified by the given network, returning its value, or nil if there is none. As with
+// Insert, the search for the entry starts from the cached node, which is highly beneficial when removing pre-sorted
+// networks.
+func (ptl *TrieLoader) Remove(pfx netip.Prefix) any {
+	pfx = normalizePrefix(pfx)
+	addr, bits := prefix128(pfx)
+	parent := ptl.ancestor(addr, bits)
+
+	if target := parent.get(addr, bits); target == nil || target.value == nil {
+		return nil
+	}
+	v := parent.remove(addr, bits)
+
+	// Nodes below parent have been compressed by remove, but parent and the nodes above it may now need compressing
+	// too.
+	for len(ptl.path) > 1 {
+		n := ptl.path[len(ptl.path)-1]
+		up := ptl.path[len(ptl.path)-2]
+		bit := up.discriminatorBit(n.addr)
+		up.compressChild(bit)
+		if up.children[bit] == n {
+			break
+		}
+		ptl.path = ptl.path[:len(ptl.path)-1]
+	}
+	ptl.trie.refreshV4()
+	return unempty(v)
+}
+
+// Entry is a network and the value associated with it.
+type Entry struct {
+	Network netip.Prefix
+	Value   any
+}
+
+// InsertAll inserts all of the given entries, sorting them by address first so that the cached node is beneficial
+// regardless of the order in which the entries are given. When multiple entries have the same network, the last one
+// given wins, the same as with successive calls to Insert. The given slice is not modified.
+func (ptl *TrieLoader) InsertAll(entries []Entry) {
+	type sortKey struct {
+		addr uint128
+		bits uint8
+		idx  int
+	}
+	keys := make([]sortKey, len(entries))
+	for i, entry := range entries {
+		addr, bits := prefix128(normalizePrefix(entry.Network))
+		keys[i] = sortKey{addr, bits, i}
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		if keys[i].addr != keys[j].addr {
+			return keys[i].addr.less(keys[j].addr)
+		}
+		return keys[i].bits < keys[j].bits
+	})
+
+	for _, key := range keys {
+		entry := entries[key.idx]
+		ptl.Insert(entry.Network, entry.Value)
 	}
-	ptl.lastInsert = parent.insert(pfx, v)
 }
 
 func normalizeAddr(addr netip.Addr) netip.Addr {
@@ -430,6 +1084,14 @@ func normalizePrefix(pfx netip.Prefix) netip.Prefix {
 	return pfx.Masked()
 }
 
+// denormalizePrefix is the inverse of normalizePrefix, returning IPv4-mapped networks in their IPv4 form.
+func denormalizePrefix(pfx netip.Prefix) netip.Prefix {
+	if pfx.Addr().Is4In6() && pfx.Bits() >= 96 {
+		pfx = netip.PrefixFrom(pfx.Addr().Unmap(), pfx.Bits()-96)
+	}
+	return pfx
+}
+
 // A lot of the code uses nil value tests to determine whether a node is explicit or implicitly created. Therefore
 // inserted values cannot be nil, and so `empty` is a placeholder to represent nil.
 type emptyStruct struct{}
@@ -449,16 +1111,29 @@ func unempty(v any) any {
 	return v
 }
 
-func addr128(addr netip.Addr) uint128 {
-	return *(*uint128)(unsafe.Pointer(&addr))
+// prefix128 returns the address and prefix length of a normalized prefix.
+func prefix128(pfx netip.Prefix) (uint128, uint8) {
+	return addr128(pfx.Addr()), uint8(pfx.Bits())
+}
+
+// v4Addr128 returns the IPv4-mapped IPv6 form of an IPv4 address.
+func v4Addr128(addr netip.Addr) uint128 {
+	b := addr.As4()
+	return uint128{0, 0xffff<<32 | uint64(binary.BigEndian.Uint32(b[:]))}
 }
-func init() {
-	// Accessing the underlying data of a `netip.Addr` relies upon the data being
-	// in a known format, which is not guaranteed to be stable. So this init()
-	// function is to detect if it ever changes.
-	ip := netip.AddrFrom16([16]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15})
-	i128 := addr128(ip)
-	if i128.hi != 0x0001020304050607 || i128.lo != 0x08090a0b0c0d0e0f {
-		panic("netip.Addr format mismatch")
+
+// lookupAddr128 returns the normalized form of an address being looked up, without the intermediate netip.Addr produced
+// by normalizeAddr.
+func lookupAddr128(addr netip.Addr) uint128 {
+	if addr.Is4() {
+		return v4Addr128(addr)
 	}
+	return addr128(addr)
+}
+
+func addrFrom128(u uint128) netip.Addr {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], u.hi)
+	binary.BigEndian.PutUint64(b[8:], u.lo)
+	return netip.AddrFrom16(b)
 }
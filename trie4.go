@@ -0,0 +1,333 @@
+package iptrie
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"net/netip"
+	"strings"
+)
+
+// Trie4 is a compressed IP radix trie specialized for IPv4 networks.
+//
+// Unlike Trie, addresses are stored natively as 32-bit values rather than being normalized to IPv6. This avoids the 96
+// leading bits shared by all IPv4 addresses, making nodes smaller and traversals shorter for IPv4-only workloads.
+//
+// IPv4-mapped IPv6 addresses (::ffff:0:0/96) are treated as their IPv4 equivalent. Any other IPv6 address is not
+// supported, and is ignored.
+type Trie4 struct {
+	parent   *Trie4
+	children [2]*Trie4
+
+	value any
+	addr  uint32
+	bits  uint8
+}
+
+// NewTrie4 creates a new Trie4.
+func NewTrie4() *Trie4 {
+	return &Trie4{}
+}
+
+// Insert inserts an entry into the trie. The entry is ignored if network is not an IPv4 network.
+func (pt *Trie4) Insert(network netip.Prefix, value any) {
+	addr, bits, ok := prefix32(network)
+	if !ok {
+		return
+	}
+	pt.insert(addr, bits, emptyize(value))
+}
+
+// Remove removes the entry identified by given network from trie.
+func (pt *Trie4) Remove(network netip.Prefix) any {
+	addr, bits, ok := prefix32(network)
+	if !ok {
+		return nil
+	}
+	return unempty(pt.remove(addr, bits))
+}
+
+// Find returns the value from the most specific network (largest prefix) containing the given address.
+func (pt *Trie4) Find(ip netip.Addr) any {
+	addr, ok := addr32(ip)
+	if !ok {
+		return nil
+	}
+	return unempty(pt.find(addr))
+}
+
+// FindLargest returns the value from the largest network (smallest prefix) containing the given address.
+func (pt *Trie4) FindLargest(ip netip.Addr) any {
+	addr, ok := addr32(ip)
+	if !ok {
+		return nil
+	}
+	return unempty(pt.findLargest(addr))
+}
+
+// Contains indicates whether the trie contains the given ip.
+func (pt *Trie4) Contains(ip netip.Addr) bool {
+	addr, ok := addr32(ip)
+	if !ok {
+		return false
+	}
+	return pt.findLargest(addr) != nil
+}
+
+// ContainingNetworks returns the list of networks containing the given ip in ascending prefix order (largest network to
+// smallest).
+func (pt *Trie4) ContainingNetworks(ip netip.Addr) []netip.Prefix {
+	addr, ok := addr32(ip)
+	if !ok {
+		return nil
+	}
+	var results []netip.Prefix
+	for node := pt; node != nil && node.contains(addr); {
+		if node.value != nil {
+			results = append(results, node.network())
+		}
+		if node.bits == 32 {
+			break
+		}
+		node = node.children[node.discriminatorBit(addr)]
+	}
+	return results
+}
+
+// CoveredNetworks returns the list of networks contained within the given network.
+func (pt *Trie4) CoveredNetworks(network netip.Prefix) []netip.Prefix {
+	addr, bits, ok := prefix32(network)
+	if !ok {
+		return nil
+	}
+	for node := pt; node != nil; {
+		if bits <= node.bits && net32Contains(addr, bits, node.addr) {
+			return node.appendEntries(nil)
+		}
+		if node.bits == 32 {
+			break
+		}
+		node = node.children[node.discriminatorBit(addr)]
+	}
+	return nil
+}
+
+// Walk calls fn for each entry in depth order, until fn returns false.
+func (pt *Trie4) Walk(fn func(network netip.Prefix, value any) bool) {
+	pt.walk(fn)
+}
+
+func (pt *Trie4) walk(fn func(network netip.Prefix, value any) bool) bool {
+	if pt.value != nil && !fn(pt.network(), unempty(pt.value)) {
+		return false
+	}
+	for _, child := range pt.children {
+		if child != nil && !child.walk(fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns string representation of trie.
+//
+// The result will contain implicit nodes which exist as parents for multiple entries, but can be distinguished by the
+// lack of a value.
+func (pt *Trie4) String() string {
+	return pt.string(0)
+}
+
+func (pt *Trie4) string(level int) string {
+	children := []string{}
+	padding := strings.Repeat("├ ", level+1)
+	for _, child := range pt.children {
+		if child == nil {
+			continue
+		}
+		childStr := fmt.Sprintf("\n%s%s", padding, child.string(level+1))
+		children = append(children, childStr)
+	}
+
+	var value string
+	if pt.value != nil {
+		value = fmt.Sprintf("%v", unempty(pt.value))
+		if len(value) > 32 {
+			value = value[0:31] + "…"
+		}
+		value = " • " + value
+	}
+
+	return fmt.Sprintf("%s%s%s", pt.network(),
+		value, strings.Join(children, ""))
+}
+
+// network returns the network of the node as a netip.Prefix.
+func (pt *Trie4) network() netip.Prefix {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], pt.addr)
+	return netip.PrefixFrom(netip.AddrFrom4(b), int(pt.bits))
+}
+
+func (pt *Trie4) find(ip uint32) any {
+	var value any
+	for node := pt; node != nil && node.contains(ip); {
+		if node.bits == 32 {
+			if node.value != nil {
+				value = node.value
+			}
+			break
+		}
+		// As with Trie, placeholders for nil values are skipped in favor of less specific networks, except on a full
+		// address match.
+		if node.value != nil && node.value != empty {
+			value = node.value
+		}
+		node = node.children[node.discriminatorBit(ip)]
+	}
+	return value
+}
+
+func (pt *Trie4) findLargest(ip uint32) any {
+	for node := pt; node != nil && node.contains(ip); {
+		if node.value != nil {
+			return node.value
+		}
+		if node.bits == 32 {
+			break
+		}
+		node = node.children[node.discriminatorBit(ip)]
+	}
+	return nil
+}
+
+func (pt *Trie4) insert(addr uint32, bits uint8, value any) {
+	if pt.bits == bits && pt.addr == addr {
+		pt.value = value
+		return
+	}
+
+	bit := pt.discriminatorBit(addr)
+	existingChild := pt.children[bit]
+
+	// No existing child, insert new leaf trie.
+	if existingChild == nil {
+		pt.setChild(bit, &Trie4{addr: addr, bits: bits, value: value})
+		return
+	}
+
+	// Check whether it is necessary to insert additional path prefix between current trie and existing child,
+	// in the case that inserted network diverges on its path to existing child.
+	divAddr, divBits := net32Divergence(existingChild.addr, existingChild.bits, addr, bits)
+	if divBits != existingChild.bits {
+		pathPrefix := &Trie4{addr: divAddr, bits: divBits}
+		pt.setChild(bit, pathPrefix)
+		pathPrefix.setChild(pathPrefix.discriminatorBit(existingChild.addr), existingChild)
+		existingChild = pathPrefix
+	}
+	existingChild.insert(addr, bits, value)
+}
+
+func (pt *Trie4) setChild(bit uint8, child *Trie4) {
+	pt.children[bit] = child
+	if child != nil {
+		child.parent = pt
+	}
+}
+
+func (pt *Trie4) remove(addr uint32, bits uint8) any {
+	node := pt
+	for node != nil && !(node.bits == bits && node.addr == addr) {
+		if node.bits >= bits || !node.contains(addr) {
+			return nil
+		}
+		node = node.children[node.discriminatorBit(addr)]
+	}
+	if node == nil || node.value == nil {
+		return nil
+	}
+
+	entry := node.value
+	node.value = nil
+	node.compressPathIfPossible()
+	return entry
+}
+
+func (pt *Trie4) compressPathIfPossible() {
+	// Current prefix trie can be path compressed if it records no entry, has single or no child, and is not the root.
+	for pt.value == nil && pt.parent != nil && (pt.children[0] == nil || pt.children[1] == nil) {
+		loneChild := pt.children[0]
+		if loneChild == nil {
+			loneChild = pt.children[1]
+		}
+		parent := pt.parent
+		parent.setChild(parent.discriminatorBit(pt.addr), loneChild)
+		pt = parent
+	}
+}
+
+func (pt *Trie4) appendEntries(dst []netip.Prefix) []netip.Prefix {
+	if pt.value != nil {
+		dst = append(dst, pt.network())
+	}
+	for _, child := range pt.children {
+		if child != nil {
+			dst = child.appendEntries(dst)
+		}
+	}
+	return dst
+}
+
+// discriminatorBit returns the bit of addr immediately following the network of pt, which determines the child addr
+// belongs under.
+func (pt *Trie4) discriminatorBit(addr uint32) uint8 {
+	return uint8(addr >> (31 - pt.bits) & 1)
+}
+
+// contains indicates whether the network of pt contains the given ip.
+func (pt *Trie4) contains(ip uint32) bool {
+	return net32Contains(pt.addr, pt.bits, ip)
+}
+
+func mask32(n uint8) uint32 {
+	return ^uint32(0) << (32 - n)
+}
+
+// net32Contains indicates whether the network given by addr and bits contains ip.
+func net32Contains(addr uint32, bits uint8, ip uint32) bool {
+	return (ip^addr)&mask32(bits) == 0
+}
+
+// net32Divergence returns the largest prefix shared by the provided 2 prefixes
+func net32Divergence(addr1 uint32, bits1 uint8, addr2 uint32, bits2 uint8) (uint32, uint8) {
+	if bits1 > bits2 {
+		addr1, bits1, addr2, bits2 = addr2, bits2, addr1, bits1
+	}
+	bit := uint8(bits.LeadingZeros32(addr1 ^ addr2))
+	if bit > bits1 {
+		bit = bits1
+	}
+	return addr1 & mask32(bit), bit
+}
+
+// addr32 returns the given IPv4 (or IPv4-mapped IPv6) address as a uint32.
+func addr32(ip netip.Addr) (uint32, bool) {
+	ip = ip.Unmap()
+	if !ip.Is4() {
+		return 0, false
+	}
+	b := ip.As4()
+	return binary.BigEndian.Uint32(b[:]), true
+}
+
+// prefix32 returns the masked address and prefix length of the given IPv4 (or IPv4-mapped IPv6) network.
+func prefix32(pfx netip.Prefix) (uint32, uint8, bool) {
+	bits := pfx.Bits()
+	if pfx.Addr().Is4In6() {
+		bits -= 96
+	}
+	addr, ok := addr32(pfx.Addr())
+	if !ok || bits < 0 {
+		return 0, 0, false
+	}
+	return addr & mask32(uint8(bits)), uint8(bits), true
+}
@@ -0,0 +1,99 @@
+package iptrie
+
+import (
+	"fmt"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func ExampleTrie4() {
+	ipt := NewTrie4()
+	ipt.Insert(netip.MustParsePrefix("10.0.0.0/8"), "foo")
+	ipt.Insert(netip.MustParsePrefix("10.1.0.0/24"), "bar")
+	ipt.Insert(netip.MustParsePrefix("10.1.1.0/24"), "baz")
+
+	fmt.Printf("10.2.0.1: %+v\n", ipt.Find(netip.MustParseAddr("10.2.0.1")))
+	fmt.Printf("10.1.0.1: %+v\n", ipt.Find(netip.MustParseAddr("10.1.0.1")))
+	fmt.Printf("11.0.0.1: %+v\n", ipt.Find(netip.MustParseAddr("11.0.0.1")))
+	fmt.Println(ipt.String())
+
+	// Output:
+	// 10.2.0.1: foo
+	// 10.1.0.1: bar
+	// 11.0.0.1: <nil>
+	// 0.0.0.0/0
+	// ├ 10.0.0.0/8 • foo
+	// ├ ├ 10.1.0.0/23
+	// ├ ├ ├ 10.1.0.0/24 • bar
+	// ├ ├ ├ 10.1.1.0/24 • baz
+}
+
+func TestTrie4MatchesTrie(t *testing.T) {
+	trie := NewTrie()
+	trie4 := NewTrie4()
+
+	var networks []netip.Prefix
+	for i := 0; i < 2000; i++ {
+		ip := GenIPV4()
+		network, _ := ip.Prefix(int(ip.As4()[3]%25) + 8)
+		networks = append(networks, network)
+		trie.Insert(network, i)
+		trie4.Insert(network, i)
+	}
+	for _, network := range networks[:1000] {
+		assert.Equal(t, trie.Remove(network), trie4.Remove(network))
+	}
+
+	unmap := func(networks []netip.Prefix) []netip.Prefix {
+		var results []netip.Prefix
+		for _, network := range networks {
+			results = append(results, netip.PrefixFrom(network.Addr().Unmap(), network.Bits()-96))
+		}
+		return results
+	}
+	for i := 0; i < 2000; i++ {
+		ip := GenIPV4()
+		assert.Equal(t, trie.Find(ip), trie4.Find(ip))
+		assert.Equal(t, trie.FindLargest(ip), trie4.FindLargest(ip))
+		assert.Equal(t, trie.Contains(ip), trie4.Contains(ip))
+		assert.Equal(t, unmap(trie.ContainingNetworks(ip)), trie4.ContainingNetworks(ip))
+	}
+	for _, network := range networks {
+		ip := network.Addr()
+		assert.Equal(t, trie.Find(ip), trie4.Find(ip))
+		assert.Equal(t, unmap(trie.ContainingNetworks(ip)), trie4.ContainingNetworks(ip))
+		supernet, _ := ip.Prefix(network.Bits() - 4)
+		assert.Equal(t, unmap(trie.CoveredNetworks(supernet)), trie4.CoveredNetworks(supernet))
+	}
+}
+
+func TestTrie4MatchesTrieNil(t *testing.T) {
+	trie, ips := genMatchTrie(true)
+	trie4 := NewTrie4()
+	trie.Walk(func(network netip.Prefix, value any) bool {
+		trie4.Insert(network, value)
+		return true
+	})
+	assertMatchesTrie(t, trie, trie4, ips)
+
+	trie4 = NewTrie4()
+	trie4.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	trie4.Insert(netip.MustParsePrefix("10.1.0.0/16"), nil)
+	trie4.Insert(netip.MustParsePrefix("10.2.0.1/32"), nil)
+	assert.Equal(t, "a", trie4.Find(netip.MustParseAddr("10.1.0.1")))
+	assert.Nil(t, trie4.Find(netip.MustParseAddr("10.2.0.1")))
+	assert.True(t, trie4.Contains(netip.MustParseAddr("10.2.0.1")))
+}
+
+func TestTrie4IPv6(t *testing.T) {
+	trie4 := NewTrie4()
+	trie4.Insert(netip.MustParsePrefix("::ffff:10.0.0.0/104"), "a")
+	trie4.Insert(netip.MustParsePrefix("2001:db8::/32"), "b")
+
+	assert.Equal(t, "a", trie4.Find(netip.MustParseAddr("10.0.0.1")))
+	assert.Equal(t, "a", trie4.Find(netip.MustParseAddr("::ffff:10.0.0.1")))
+	assert.Nil(t, trie4.Find(netip.MustParseAddr("2001:db8::1")))
+	assert.Equal(t, "0.0.0.0/0\n├ 10.0.0.0/8 • a", trie4.String())
+}
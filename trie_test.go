@@ -1,14 +1,20 @@
 package iptrie
 
 import (
+	"bytes"
+	"context"
 	"encoding/binary"
 	"fmt"
+	"io"
 	"math/rand"
 	"net/netip"
 	"runtime"
+	"sort"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func ExampleTrie() {
@@ -82,17 +88,11 @@ func TestTrieInsert(t *testing.T) {
 				trie.Insert(network, v)
 			}
 
-			walk := trie.walkDepth()
+			var expected []netip.Prefix
 			for _, network := range tc.expectedNetworksInDepthOrder {
-				expected := normalizePrefix(netip.MustParsePrefix(network))
-				actual := <-walk
-				assert.Equal(t, expected, actual)
-			}
-
-			// Ensure no unexpected elements in trie.
-			for network := range walk {
-				assert.Nil(t, network)
+				expected = append(expected, normalizePrefix(netip.MustParsePrefix(network)))
 			}
+			assert.Equal(t, expected, trie.appendEntries(nil))
 		})
 	}
 }
@@ -114,6 +114,48 @@ func ExampleTrie_String() {
 	// ├ ├ ├ ::ffff:192.168.1.0/126 • net=192.168.1.0/30
 }
 
+func ExampleTrie_StringWith() {
+	inserts := []string{"192.168.0.0/24", "192.168.1.0/24", "192.168.1.0/30"}
+	trie := NewTrie()
+	for _, insert := range inserts {
+		network := netip.MustParsePrefix(insert)
+		trie.Insert(network, "net="+insert)
+	}
+	fmt.Println(trie.StringWith(StringOpts{
+		HideImplicit:  true,
+		MaxValueWidth: 10,
+		Denormalize:   true,
+	}))
+
+	// Output:
+	// 192.168.0.0/24 • net=192.1…
+	// 192.168.1.0/24 • net=192.1…
+	// ├ 192.168.1.0/30 • net=192.1…
+}
+
+func TestTrieStringWith(t *testing.T) {
+	trie := NewTrie()
+	trie.Insert(netip.MustParsePrefix("::/0"), nil)
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), 1)
+	trie.Insert(netip.MustParsePrefix("2001:db8::/32"), 2)
+	assert.Equal(t, `::/0 • <<nil>>
+├ ::ffff:10.0.0.0/104 • <1>
+├ 2001:db8::/32 • <2>`, trie.StringWith(StringOpts{
+		HideImplicit: true,
+		FormatValue: func(value any) string {
+			return fmt.Sprintf("<%v>", value)
+		},
+	}))
+
+	assert.Equal(t, "", NewTrie().StringWith(StringOpts{HideImplicit: true}))
+	assert.Equal(t, "::/0", NewTrie().StringWith(StringOpts{}))
+
+	trie = NewTrie()
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), strings.Repeat("é", 40))
+	assert.Equal(t, "10.0.0.0/8 • "+strings.Repeat("é", 40), trie.StringWith(StringOpts{HideImplicit: true, Denormalize: true}))
+	assert.Equal(t, "10.0.0.0/8 • éé…", trie.StringWith(StringOpts{HideImplicit: true, Denormalize: true, MaxValueWidth: 3}))
+}
+
 func TestTrieRemove(t *testing.T) {
 	cases := []struct {
 		inserts                      []string
@@ -217,17 +259,11 @@ func TestTrieRemove(t *testing.T) {
 				}
 			}
 
-			walk := trie.walkDepth()
+			var expected []netip.Prefix
 			for _, network := range tc.expectedNetworksInDepthOrder {
-				expected := normalizePrefix(netip.MustParsePrefix(network))
-				actual := <-walk
-				assert.Equal(t, expected, actual, "tc=%d", tci)
-			}
-
-			// Ensure no unexpected elements in trie.
-			for network := range walk {
-				assert.Nil(t, network)
+				expected = append(expected, normalizePrefix(netip.MustParsePrefix(network)))
 			}
+			assert.Equal(t, expected, trie.appendEntries(nil), "tc=%d", tci)
 
 			assert.Equal(t, tc.expectedTrieString, trie.String(), "tc=%d", tci)
 		})
@@ -485,6 +521,162 @@ func TestTrieCoveredNetworks(t *testing.T) {
 	}
 }
 
+func TestTrieNetworksAppend(t *testing.T) {
+	trie := NewTrie()
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), 1)
+	trie.Insert(netip.MustParsePrefix("10.1.0.0/16"), 2)
+	trie.Insert(netip.MustParsePrefix("10.1.1.0/24"), 3)
+	trie.Insert(netip.MustParsePrefix("10.2.0.0/16"), 4)
+
+	prefix := netip.MustParsePrefix("192.168.0.0/16")
+	dst := trie.ContainingNetworksAppend([]netip.Prefix{prefix}, netip.MustParseAddr("10.1.1.1"))
+	assert.Equal(t, []netip.Prefix{
+		prefix,
+		netip.MustParsePrefix("::ffff:10.0.0.0/104"),
+		netip.MustParsePrefix("::ffff:10.1.0.0/112"),
+		netip.MustParsePrefix("::ffff:10.1.1.0/120"),
+	}, dst)
+
+	dst = trie.CoveredNetworksAppend(dst[:1], netip.MustParsePrefix("10.1.0.0/16"))
+	assert.Equal(t, []netip.Prefix{
+		prefix,
+		netip.MustParsePrefix("::ffff:10.1.0.0/112"),
+		netip.MustParsePrefix("::ffff:10.1.1.0/120"),
+	}, dst)
+
+	ip := netip.MustParseAddr("10.1.1.1")
+	network := netip.MustParsePrefix("10.1.0.0/16")
+	allocs := testing.AllocsPerRun(100, func() {
+		dst = trie.ContainingNetworksAppend(dst[:0], ip)
+		dst = trie.CoveredNetworksAppend(dst[:0], network)
+	})
+	assert.Zero(t, allocs)
+}
+
+func TestTrieWalk(t *testing.T) {
+	trie := NewTrie()
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	trie.Insert(netip.MustParsePrefix("10.1.0.0/16"), "b")
+	trie.Insert(netip.MustParsePrefix("192.168.0.0/16"), nil)
+
+	var networks []string
+	var values []any
+	trie.Walk(func(network netip.Prefix, value any) bool {
+		networks = append(networks, network.String())
+		values = append(values, value)
+		return true
+	})
+	assert.Equal(t, []string{"::ffff:10.0.0.0/104", "::ffff:10.1.0.0/112", "::ffff:192.168.0.0/112"}, networks)
+	assert.Equal(t, []any{"a", "b", nil}, values)
+
+	count := 0
+	trie.Walk(func(network netip.Prefix, value any) bool {
+		count++
+		return false
+	})
+	assert.Equal(t, 1, count)
+}
+
+func TestTrieWalkCtx(t *testing.T) {
+	trie := NewTrie()
+	for i := 0; i < 100; i++ {
+		trie.Insert(GenLeafIPNet(GenIPV4()), i)
+	}
+
+	count := 0
+	err := trie.WalkCtx(context.Background(), func(network netip.Prefix, value any) bool {
+		count++
+		return true
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, len(trie.appendEntries(nil)), count)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	count = 0
+	err = trie.WalkCtx(ctx, func(network netip.Prefix, value any) bool {
+		count++
+		if count == 10 {
+			cancel()
+		}
+		return true
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 10, count)
+}
+
+func TestTrieIsFullyCovered(t *testing.T) {
+	cases := []struct {
+		inserts  []string
+		search   string
+		expected bool
+		name     string
+	}{
+		{
+			[]string{"192.168.0.0/16"},
+			"192.168.1.0/24",
+			true,
+			"single covering entry",
+		},
+		{
+			[]string{"192.168.0.0/24"},
+			"192.168.0.0/24",
+			true,
+			"exact entry",
+		},
+		{
+			[]string{"192.168.0.0/25", "192.168.0.128/25"},
+			"192.168.0.0/24",
+			true,
+			"covered by halves",
+		},
+		{
+			[]string{"192.168.0.0/25", "192.168.0.128/26", "192.168.0.192/27", "192.168.0.224/27"},
+			"192.168.0.0/24",
+			true,
+			"covered by many entries",
+		},
+		{
+			[]string{"192.168.0.0/25", "192.168.0.128/26", "192.168.0.224/27"},
+			"192.168.0.0/24",
+			false,
+			"gap",
+		},
+		{
+			[]string{"192.168.0.0/25"},
+			"192.168.0.0/24",
+			false,
+			"half covered",
+		},
+		{
+			[]string{"192.168.0.0/24"},
+			"192.168.0.0/23",
+			false,
+			"smaller entry",
+		},
+		{
+			[]string{"10.0.0.0/8"},
+			"192.168.0.0/24",
+			false,
+			"disjoint",
+		},
+		{
+			[]string{"0.0.0.0/0"},
+			"::/0",
+			false,
+			"IPv4 does not cover IPv6",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			trie := NewTrie()
+			for _, insert := range tc.inserts {
+				trie.Insert(netip.MustParsePrefix(insert), nil)
+			}
+			assert.Equal(t, tc.expected, trie.IsFullyCovered(netip.MustParsePrefix(tc.search)))
+		})
+	}
+}
+
 func TestTrieMemUsage(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping memory test in `-short` mode")
@@ -549,6 +741,59 @@ func GenIPV4() netip.Addr {
 	return netip.AddrFrom4(ip)
 }
 
+// genMatchTrie returns a trie of random networks for checking lookup backends against, along with addresses to look
+// up. Every few entries have a nil value. Unless v4Only, it includes IPv6 networks and networks above the IPv4 portion.
+func genMatchTrie(v4Only bool) (*Trie, []netip.Addr) {
+	trie := NewTrie()
+	var ips []netip.Addr
+	insert := func(network netip.Prefix, i int) {
+		var value any = i
+		if i%5 == 0 {
+			value = nil
+		}
+		trie.Insert(network, value)
+		ips = append(ips, network.Addr(), network.Masked().Addr())
+	}
+	for i := 0; i < 2000; i++ {
+		ip := GenIPV4()
+		network, _ := ip.Prefix(int(ip.As4()[3]%25) + 8)
+		insert(network, i)
+		ips = append(ips, GenIPV4())
+	}
+	if v4Only {
+		return trie, ips
+	}
+	for i := 0; i < 500; i++ {
+		b := netip.MustParseAddr("2001:db8::").As16()
+		for j := 4; j < 16; j++ {
+			b[j] = byte(rng.Intn(4))
+		}
+		ip := netip.AddrFrom16(b)
+		network, _ := ip.Prefix(32 + rng.Intn(97))
+		insert(network, i)
+		ips = append(ips, ip)
+	}
+	for i := 0; i < 20; i++ {
+		network, _ := netip.AddrFrom16(GenIPV4().As16()).Prefix(rng.Intn(96))
+		insert(network, i)
+	}
+	return trie, ips
+}
+
+// assertMatchesTrie asserts that the lookups of f return the same results as trie for each of the addresses.
+func assertMatchesTrie(t *testing.T, trie *Trie, f interface {
+	Find(ip netip.Addr) any
+	FindLargest(ip netip.Addr) any
+	Contains(ip netip.Addr) bool
+}, ips []netip.Addr) {
+	t.Helper()
+	for _, ip := range ips {
+		assert.Equal(t, trie.Find(ip), f.Find(ip), "ip=%s", ip)
+		assert.Equal(t, trie.FindLargest(ip), f.FindLargest(ip), "ip=%s", ip)
+		assert.Equal(t, trie.Contains(ip), f.Contains(ip), "ip=%s", ip)
+	}
+}
+
 func GetHeapAllocation() uint64 {
 	var m runtime.MemStats
 	runtime.ReadMemStats(&m)
@@ -580,3 +825,364 @@ func ExampleTrieLoader() {
 	// ├ ├ ├ ::ffff:192.168.0.0/120 • net=192.168.0.0/24
 	// ├ ├ ├ ::ffff:192.168.1.1/128 • net=192.168.1.1/32
 }
+
+func TestTrieLoaderInsertAll(t *testing.T) {
+	var entries []Entry
+	for i := 0; i < 1000; i++ {
+		entries = append(entries, Entry{GenLeafIPNet(GenIPV4()), i})
+	}
+	entries = append(entries,
+		Entry{netip.MustParsePrefix("10.0.0.0/8"), "a"},
+		Entry{netip.MustParsePrefix("2001:db8::/32"), "b"},
+		Entry{netip.MustParsePrefix("10.0.0.0/8"), "c"},
+	)
+	orig := append([]Entry(nil), entries...)
+
+	expected := NewTrie()
+	for _, entry := range entries {
+		expected.Insert(entry.Network, entry.Value)
+	}
+
+	trie := NewTrie()
+	NewTrieLoader(trie).InsertAll(entries)
+
+	assert.Equal(t, expected.String(), trie.String())
+	assert.Equal(t, "c", trie.Find(netip.MustParseAddr("10.255.255.255")))
+	assert.Equal(t, orig, entries)
+}
+
+func TestTrieLoaderNil(t *testing.T) {
+	trie := NewTrie()
+	loader := NewTrieLoader(trie)
+	loader.Insert(netip.MustParsePrefix("10.0.0.0/8"), nil)
+	loader.InsertAll([]Entry{
+		{netip.MustParsePrefix("192.0.2.1/32"), nil},
+		{netip.MustParsePrefix("10.1.0.0/16"), "a"},
+	})
+
+	assert.True(t, trie.Contains(netip.MustParseAddr("10.2.0.1")))
+	assert.True(t, trie.Contains(netip.MustParseAddr("192.0.2.1")))
+	assert.Nil(t, trie.Find(netip.MustParseAddr("192.0.2.1")))
+	_, entries := trie.Count()
+	assert.Equal(t, 3, entries)
+}
+
+func TestTrieLoaderRemove(t *testing.T) {
+	var networks []netip.Prefix
+	for i := 0; i < 1000; i++ {
+		networks = append(networks, GenLeafIPNet(GenIPV4()))
+	}
+	networks = append(networks, netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("::/0"))
+
+	expected := NewTrie()
+	trie := NewTrie()
+	for i, network := range networks {
+		expected.Insert(network, i)
+		trie.Insert(network, i)
+	}
+	snap := trie.Snapshot()
+	snapStr := snap.String()
+
+	remove := append([]netip.Prefix(nil), networks[:600]...)
+	sort.Slice(remove, func(i, j int) bool { return remove[i].Addr().Less(remove[j].Addr()) })
+	remove = append(remove, networks[len(networks)-2:]...)
+
+	ptl := NewTrieLoader(trie)
+	for i, network := range remove {
+		assert.Equal(t, expected.Remove(network), ptl.Remove(network))
+		// Interleave inserts, which must still find their location correctly from the cached node.
+		if i%10 == 0 {
+			network := GenLeafIPNet(GenIPV4())
+			expected.Insert(network, i)
+			ptl.Insert(network, i)
+		}
+	}
+	assert.Nil(t, ptl.Remove(netip.MustParsePrefix("10.0.0.0/8")))
+
+	assert.Equal(t, expected.String(), trie.String())
+	assert.Equal(t, snapStr, snap.String())
+}
+
+func TestTrieLoaderStats(t *testing.T) {
+	trie := NewTrie()
+	ptl := NewTrieLoader(trie)
+	ptl.Insert(netip.MustParsePrefix("10.0.0.0/8"), 1)
+	ptl.Insert(netip.MustParsePrefix("10.1.0.0/16"), 2)
+	ptl.Insert(netip.MustParsePrefix("10.1.1.0/24"), 3)
+	stats := ptl.Stats()
+	assert.Equal(t, uint64(3), stats.Operations)
+	assert.Equal(t, uint64(0), stats.ParentHops)
+	assert.Equal(t, uint64(1), stats.RootRestarts)
+
+	// Back out to 10.0.0.0/8 from 10.1.1.0/24.
+	ptl.Insert(netip.MustParsePrefix("10.2.0.0/16"), 4)
+	stats = ptl.Stats()
+	assert.Equal(t, uint64(4), stats.Operations)
+	assert.Equal(t, uint64(2), stats.ParentHops)
+	assert.Equal(t, uint64(1), stats.RootRestarts)
+
+	ptl.Remove(netip.MustParsePrefix("192.168.0.0/16"))
+	trie.Snapshot()
+	ptl.Insert(netip.MustParsePrefix("10.2.1.0/24"), 5)
+	stats = ptl.Stats()
+	assert.Equal(t, uint64(6), stats.Operations)
+	assert.Equal(t, uint64(3), stats.RootRestarts)
+}
+
+func TestTrieSnapshot(t *testing.T) {
+	trie := NewTrie()
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	trie.Insert(netip.MustParsePrefix("10.1.0.0/16"), "b")
+	trie.Insert(netip.MustParsePrefix("10.2.0.0/16"), "c")
+
+	snap := trie.Snapshot()
+	snapStr := snap.String()
+
+	trie.Insert(netip.MustParsePrefix("10.1.1.0/24"), "d")
+	trie.Insert(netip.MustParsePrefix("10.1.0.0/16"), "e")
+	trie.Remove(netip.MustParsePrefix("10.2.0.0/16"))
+	ptl := NewTrieLoader(trie)
+	ptl.Insert(netip.MustParsePrefix("10.3.0.0/16"), "f")
+	snap2 := trie.Snapshot()
+	ptl.Insert(netip.MustParsePrefix("10.3.1.0/24"), "g")
+
+	assert.Equal(t, snapStr, snap.String())
+	assert.Equal(t, "b", snap.Find(netip.MustParseAddr("10.1.1.1")))
+	assert.Equal(t, "c", snap.Find(netip.MustParseAddr("10.2.0.1")))
+	assert.Equal(t, "a", snap.Find(netip.MustParseAddr("10.3.0.1")))
+
+	assert.Equal(t, "f", snap2.Find(netip.MustParseAddr("10.3.1.1")))
+
+	assert.Equal(t, "d", trie.Find(netip.MustParseAddr("10.1.1.1")))
+	assert.Equal(t, "e", trie.Find(netip.MustParseAddr("10.1.2.1")))
+	assert.Equal(t, "a", trie.Find(netip.MustParseAddr("10.2.0.1")))
+	assert.Equal(t, "g", trie.Find(netip.MustParseAddr("10.3.1.1")))
+}
+
+func TestTrieSnapshotConcurrent(t *testing.T) {
+	trie := NewTrie()
+	for i := 0; i < 1000; i++ {
+		trie.Insert(GenLeafIPNet(GenIPV4()), 1)
+	}
+	snap := trie.Snapshot()
+	expected := snap.CoveredNetworks(netip.MustParsePrefix("::/0"))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			assert.Equal(t, expected, snap.CoveredNetworks(netip.MustParsePrefix("::/0")))
+		}
+	}()
+	for _, network := range expected[:500] {
+		trie.Remove(network)
+	}
+	for i := 0; i < 1000; i++ {
+		trie.Insert(GenLeafIPNet(GenIPV4()), 2)
+	}
+	<-done
+}
+
+func TestTrieV4Shortcut(t *testing.T) {
+	networks := []string{
+		"::/0", "::/64", "::ffff:0:0/96", "::ffff:0:0/95", "0.0.0.0/1", "10.0.0.0/8", "10.1.0.0/16", "::1/128",
+	}
+	ips := []string{"10.1.0.1", "10.2.0.1", "11.0.0.1", "192.168.0.1"}
+	check := func(trie *Trie) {
+		for _, ip := range ips {
+			addr := netip.MustParseAddr(ip)
+			mapped := netip.AddrFrom16(addr.As16())
+			assert.Equal(t, trie.Find(mapped), trie.Find(addr), "ip=%s", ip)
+			assert.Equal(t, trie.FindLargest(mapped), trie.FindLargest(addr), "ip=%s", ip)
+			assert.Equal(t, trie.Contains(mapped), trie.Contains(addr), "ip=%s", ip)
+		}
+	}
+
+	// Insert and remove every permutation of starting offset so that the shortcut is exercised against different
+	// trie shapes.
+	for i := range networks {
+		trie := NewTrie()
+		check(trie)
+		for j := range networks {
+			network := networks[(i+j)%len(networks)]
+			trie.Insert(netip.MustParsePrefix(network), network)
+			check(trie)
+		}
+		snap := trie.Snapshot()
+		for j := range networks {
+			trie.Remove(netip.MustParsePrefix(networks[(i+j)%len(networks)]))
+			check(trie)
+		}
+		check(snap)
+		assert.Nil(t, trie.Find(netip.MustParseAddr("10.1.0.1")))
+		assert.Equal(t, "10.1.0.0/16", snap.Find(netip.MustParseAddr("10.1.0.1")))
+		assert.Equal(t, "::/0", snap.Find(netip.MustParseAddr("2001:db8::1")))
+	}
+}
+
+func TestTrieSetDenormalize(t *testing.T) {
+	trie := NewTrie()
+	trie.Insert(netip.MustParsePrefix("::/0"), "default")
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	trie.Insert(netip.MustParsePrefix("10.1.0.0/16"), "b")
+	trie.Insert(netip.MustParsePrefix("2001:db8::/32"), "c")
+	trie.SetDenormalize(true)
+
+	assert.Equal(t, []netip.Prefix{
+		netip.MustParsePrefix("::/0"),
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("10.1.0.0/16"),
+	}, trie.ContainingNetworks(netip.MustParseAddr("10.1.0.1")))
+	assert.Equal(t, []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("10.1.0.0/16"),
+	}, trie.CoveredNetworks(netip.MustParsePrefix("10.0.0.0/8")))
+
+	// Only the appended networks are denormalized.
+	dst := []netip.Prefix{netip.MustParsePrefix("::ffff:0.0.0.0/96")}
+	dst = trie.ContainingNetworksAppend(dst, netip.MustParseAddr("10.0.0.1"))
+	assert.Equal(t, []netip.Prefix{
+		netip.MustParsePrefix("::ffff:0.0.0.0/96"),
+		netip.MustParsePrefix("::/0"),
+		netip.MustParsePrefix("10.0.0.0/8"),
+	}, dst)
+
+	var walked []netip.Prefix
+	trie.Walk(func(network netip.Prefix, value any) bool {
+		walked = append(walked, network)
+		return true
+	})
+	assert.Equal(t, []netip.Prefix{
+		netip.MustParsePrefix("::/0"),
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("10.1.0.0/16"),
+		netip.MustParsePrefix("2001:db8::/32"),
+	}, walked)
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("::/0")}, trie.Aggregate())
+
+	// Inherited by snapshots.
+	assert.Equal(t, netip.MustParsePrefix("10.0.0.0/8"), trie.Snapshot().ContainingNetworks(netip.MustParseAddr("10.0.0.1"))[1])
+
+	trie.SetDenormalize(false)
+	assert.Equal(t, netip.MustParsePrefix("::ffff:10.0.0.0/104"), trie.ContainingNetworks(netip.MustParseAddr("10.0.0.1"))[1])
+}
+
+func TestTrieRender(t *testing.T) {
+	trie := NewTrie()
+	trie.Insert(netip.MustParsePrefix("192.168.0.0/24"), "a")
+	trie.Insert(netip.MustParsePrefix("192.168.1.0/24"), nil)
+	trie.SetDenormalize(true)
+
+	var buf bytes.Buffer
+	err := trie.Render(&buf, func(network netip.Prefix, value any, implicit bool) string {
+		if implicit {
+			return network.String() + " (implicit)"
+		}
+		return fmt.Sprintf("%s = %v", network, value)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, `::/0 (implicit)
+├ 192.168.0.0/23 (implicit)
+├ ├ 192.168.0.0/24 = a
+├ ├ 192.168.1.0/24 = <nil>
+`, buf.String())
+
+	pr, pw := io.Pipe()
+	pr.Close()
+	assert.ErrorIs(t, trie.Render(pw, func(network netip.Prefix, value any, implicit bool) string {
+		return ""
+	}), io.ErrClosedPipe)
+}
+
+func TestTrieSetZonePolicy(t *testing.T) {
+	trie := NewTrie()
+	trie.Insert(netip.MustParsePrefix("::/0"), "default")
+	trie.Insert(netip.MustParsePrefix("fe80::/10"), "link-local")
+	zoned := netip.MustParseAddr("fe80::1%eth0")
+
+	assert.Equal(t, "link-local", trie.Find(zoned))
+	assert.Equal(t, "default", trie.FindLargest(zoned))
+	assert.True(t, trie.Contains(zoned))
+	assert.Len(t, trie.ContainingNetworks(zoned), 2)
+
+	trie.SetZonePolicy(ZoneNoMatch)
+	assert.Nil(t, trie.Find(zoned))
+	assert.Nil(t, trie.FindLargest(zoned))
+	assert.False(t, trie.Contains(zoned))
+	assert.Empty(t, trie.ContainingNetworks(zoned))
+	assert.Equal(t, "link-local", trie.Find(zoned.WithZone("")))
+	assert.Equal(t, "default", trie.Find(netip.MustParseAddr("192.0.2.1")))
+	assert.Nil(t, trie.Snapshot().Find(zoned))
+}
+
+func TestTrieSetDefault(t *testing.T) {
+	trie := NewTrie()
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	trie.Insert(netip.MustParsePrefix("10.1.0.0/16"), "b")
+	assert.Nil(t, trie.DefaultRoute())
+	assert.Nil(t, trie.Find(netip.MustParseAddr("192.0.2.1")))
+
+	trie.SetDefault("default")
+	assert.Equal(t, "default", trie.DefaultRoute())
+	assert.Equal(t, "default", trie.Find(netip.MustParseAddr("192.0.2.1")))
+	assert.Equal(t, "default", trie.Find(netip.MustParseAddr("2001:db8::1")))
+	assert.Equal(t, "default", trie.FindLargest(netip.MustParseAddr("192.0.2.1")))
+	assert.Equal(t, "b", trie.Find(netip.MustParseAddr("10.1.0.1")))
+	// FindLargest still returns the largest entry, rather than the default.
+	assert.Equal(t, "a", trie.FindLargest(netip.MustParseAddr("10.1.0.1")))
+	assert.False(t, trie.Contains(netip.MustParseAddr("192.0.2.1")))
+	assert.Equal(t, "default", trie.Snapshot().Find(netip.MustParseAddr("192.0.2.1")))
+
+	trie.SetDefault(nil)
+	assert.Nil(t, trie.Find(netip.MustParseAddr("192.0.2.1")))
+}
+
+func TestNewTrieWithMerge(t *testing.T) {
+	trie := NewTrieWithMerge(func(old, new any) any {
+		return append(append([]string(nil), old.([]string)...), new.([]string)...)
+	})
+	var changes []Change
+	trie.SetChangeLog(func(c Change) { changes = append(changes, c) })
+
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), []string{"a"})
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), []string{"b"})
+	snap := trie.Snapshot()
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), []string{"c"})
+	trie.Insert(netip.MustParsePrefix("10.1.0.0/16"), []string{"d"})
+
+	assert.Equal(t, []string{"a", "b", "c"}, trie.Find(netip.MustParseAddr("10.0.0.1")))
+	assert.Equal(t, []string{"d"}, trie.Find(netip.MustParseAddr("10.1.0.1")))
+	assert.Equal(t, []string{"a", "b"}, snap.Find(netip.MustParseAddr("10.0.0.1")))
+
+	// Once removed, the next insert starts afresh.
+	trie.Remove(netip.MustParsePrefix("10.0.0.0/8"))
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), []string{"e"})
+	assert.Equal(t, []string{"e"}, trie.Find(netip.MustParseAddr("10.0.0.1")))
+
+	// Replaying the change log reproduces the merged values, rather than merging them again.
+	replayed := NewTrieWithMerge(trie.merge)
+	for _, c := range changes {
+		require.NoError(t, replayed.Apply(c))
+	}
+	assert.Equal(t, trie.String(), replayed.String())
+
+	largest := NewTrieWithMerge(func(old, new any) any { return max(old.(int), new.(int)) })
+	largest.Insert(netip.MustParsePrefix("2001:db8::/32"), 3)
+	largest.Insert(netip.MustParsePrefix("2001:db8::/32"), 1)
+	assert.Equal(t, 3, largest.Find(netip.MustParseAddr("2001:db8::1")))
+}
+
+func TestTrieCount(t *testing.T) {
+	trie := NewTrie()
+	nodes, entries := trie.Count()
+	assert.Equal(t, 1, nodes)
+	assert.Equal(t, 0, entries)
+
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	trie.Insert(netip.MustParsePrefix("10.1.0.0/16"), "b")
+	trie.Insert(netip.MustParsePrefix("10.2.0.0/16"), nil)
+	nodes, entries = trie.Count()
+	// The root, the 3 entries, and the implicit node joining 10.1.0.0/16 and 10.2.0.0/16.
+	assert.Equal(t, 5, nodes)
+	assert.Equal(t, 3, entries)
+}
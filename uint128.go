@@ -60,6 +60,25 @@ func (u uint128) addOne() uint128 {
 	return uint128{u.hi + carry, lo}
 }
 
+// add returns u + n.
+func (u uint128) add(n uint64) uint128 {
+	lo, carry := bits.Add64(u.lo, n, 0)
+	return uint128{u.hi + carry, lo}
+}
+
+// less reports whether u < m.
+func (u uint128) less(m uint128) bool {
+	return u.hi < m.hi || (u.hi == m.hi && u.lo < m.lo)
+}
+
+// bit returns the value (0 or 1) of the given bit.
+func (u uint128) bit(pos uint8) uint8 {
+	if pos < 64 {
+		return uint8(u.hi >> (63 - pos) & 1)
+	}
+	return uint8(u.lo >> (63 - (pos - 64)) & 1)
+}
+
 // halves returns the two uint64 halves of the uint128.
 //
 // Logically, think of it as returning two uint64s.
@@ -0,0 +1,116 @@
+package iptrie
+
+import (
+	"net/netip"
+	"sort"
+	"sync"
+)
+
+// VersionedTrie is a Trie container where each commit produces a numbered, immutable version of the trie. Lookups may
+// be performed against any version which has been retained.
+//
+// Modifications are made to a working trie, and become visible in a version once Commit is called. Versions share
+// unmodified nodes with each other (see Trie.Snapshot), so retaining many versions of a slowly changing trie is
+// inexpensive.
+//
+// All methods are safe for concurrent use.
+type VersionedTrie struct {
+	mu       sync.Mutex
+	head     *Trie
+	latest   uint64
+	versions map[uint64]*Trie
+}
+
+// NewVersionedTrie creates a new VersionedTrie. Version 0 is the initial empty trie.
+func NewVersionedTrie() *VersionedTrie {
+	vt := &VersionedTrie{
+		head:     NewTrie(),
+		versions: map[uint64]*Trie{},
+	}
+	vt.versions[0] = vt.head.Snapshot()
+	return vt
+}
+
+// NewVersionedTrieFrom creates a new VersionedTrie starting with the entries and settings of pt, such as its hooks and
+// default value, as version 0. pt is left unchanged, and may continue to be used independently.
+//
+// NewVersionedTrieFrom must be called on the root of a trie, and must not be called concurrently with modifications of
+// it.
+func NewVersionedTrieFrom(pt *Trie) *VersionedTrie {
+	vt := &VersionedTrie{
+		head:     pt.Snapshot(),
+		versions: map[uint64]*Trie{},
+	}
+	vt.versions[0] = vt.head.Snapshot()
+	return vt
+}
+
+// Insert inserts an entry into the working trie. The entry will become visible once Commit is called.
+func (vt *VersionedTrie) Insert(network netip.Prefix, value any) {
+	vt.mu.Lock()
+	defer vt.mu.Unlock()
+	vt.head.Insert(network, value)
+}
+
+// Remove removes the entry identified by given network from the working trie. The removal will become visible once
+// Commit is called.
+func (vt *VersionedTrie) Remove(network netip.Prefix) any {
+	vt.mu.Lock()
+	defer vt.mu.Unlock()
+	return vt.head.Remove(network)
+}
+
+// Commit creates a new version from the current state of the working trie, and returns its version number.
+func (vt *VersionedTrie) Commit() uint64 {
+	vt.mu.Lock()
+	defer vt.mu.Unlock()
+	vt.latest++
+	vt.versions[vt.latest] = vt.head.Snapshot()
+	return vt.latest
+}
+
+// Latest returns the number of the most recently committed version.
+func (vt *VersionedTrie) Latest() uint64 {
+	vt.mu.Lock()
+	defer vt.mu.Unlock()
+	return vt.latest
+}
+
+// At returns the trie for the given version, or nil if the version does not exist or has been released.
+//
+// The returned Trie must not be modified.
+func (vt *VersionedTrie) At(version uint64) *Trie {
+	vt.mu.Lock()
+	defer vt.mu.Unlock()
+	return vt.versions[version]
+}
+
+// Versions returns the list of retained versions in ascending order.
+func (vt *VersionedTrie) Versions() []uint64 {
+	vt.mu.Lock()
+	defer vt.mu.Unlock()
+	versions := make([]uint64, 0, len(vt.versions))
+	for v := range vt.versions {
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+	return versions
+}
+
+// Release discards the given version, allowing any nodes used only by that version to be garbage collected.
+func (vt *VersionedTrie) Release(version uint64) {
+	vt.mu.Lock()
+	defer vt.mu.Unlock()
+	delete(vt.versions, version)
+}
+
+// ReleaseBefore discards all versions older than the given version.
+func (vt *VersionedTrie) ReleaseBefore(version uint64) {
+	vt.mu.Lock()
+	defer vt.mu.Unlock()
+	for v := range vt.versions {
+		if v < version {
+			delete(vt.versions, v)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package iptrie
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVersionedTrie(t *testing.T) {
+	vt := NewVersionedTrie()
+	ip := netip.MustParseAddr("10.1.0.1")
+
+	vt.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	v1 := vt.Commit()
+	vt.Insert(netip.MustParsePrefix("10.1.0.0/16"), "b")
+	v2 := vt.Commit()
+	vt.Remove(netip.MustParsePrefix("10.0.0.0/8"))
+	vt.Insert(netip.MustParsePrefix("10.1.0.0/16"), "c")
+
+	// Uncommitted changes are not visible.
+	assert.Equal(t, v2, vt.Latest())
+	assert.Equal(t, "b", vt.At(vt.Latest()).Find(ip))
+
+	v3 := vt.Commit()
+	assert.Equal(t, []uint64{0, 1, 2, 3}, vt.Versions())
+
+	assert.Nil(t, vt.At(0).Find(ip))
+	assert.Equal(t, "a", vt.At(v1).Find(ip))
+	assert.Equal(t, "b", vt.At(v2).Find(ip))
+	assert.Equal(t, "c", vt.At(v3).Find(ip))
+	assert.False(t, vt.At(v3).Contains(netip.MustParseAddr("10.2.0.1")))
+
+	vt.Release(v2)
+	assert.Nil(t, vt.At(v2))
+	vt.ReleaseBefore(v3)
+	assert.Equal(t, []uint64{3}, vt.Versions())
+	assert.Equal(t, "c", vt.At(v3).Find(ip))
+}
+
+func TestNewVersionedTrieFrom(t *testing.T) {
+	trie := NewTrie()
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	trie.SetDefault("default")
+
+	vt := NewVersionedTrieFrom(trie)
+	vt.Insert(netip.MustParsePrefix("10.1.0.0/16"), "b")
+	v1 := vt.Commit()
+	assert.Equal(t, "a", vt.At(0).Find(netip.MustParseAddr("10.1.0.1")))
+	assert.Equal(t, "b", vt.At(v1).Find(netip.MustParseAddr("10.1.0.1")))
+	assert.Equal(t, "default", vt.At(v1).Find(netip.MustParseAddr("192.0.2.1")))
+
+	// The trie and the VersionedTrie are independent.
+	trie.Insert(netip.MustParsePrefix("10.2.0.0/16"), "c")
+	assert.Equal(t, "a", vt.At(v1).Find(netip.MustParseAddr("10.2.0.1")))
+	assert.Equal(t, "a", trie.Find(netip.MustParseAddr("10.1.0.1")))
+}
@@ -0,0 +1,87 @@
+package iptrie
+
+import "net/netip"
+
+// walkPruned is like walk, but skips the subtree of each node, including the node itself, for which skip returns true.
+// skip is called for implicit nodes as well as entries.
+func (pt *node) walkPruned(skip func(n *node) bool, fn func(n *node) bool) bool {
+	if skip(pt) {
+		return true
+	}
+	if pt.value != nil && !fn(pt) {
+		return false
+	}
+	for _, child := range pt.children {
+		if child != nil && !child.walkPruned(skip, fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// WalkMaxBits is like Walk, but only visits the entries with a prefix length of at most maxBits. Subtrees which can only
+// contain longer prefixes are skipped without being visited, so summarizing the shorter prefixes of a trie doesn't
+// require visiting every host entry.
+//
+// For IPv4 entries, maxBits is compared against the IPv4 prefix length, such that WalkMaxBits(24, fn) visits both
+// 192.0.2.0/24 and 2001:db8::/24, but not 192.0.2.0/25.
+func (pt *Trie) WalkMaxBits(maxBits int, fn func(network netip.Prefix, value any) bool) {
+	if pt.instr != nil {
+		var done func()
+		fn, done = pt.instrumentWalk(fn)
+		defer done()
+	}
+	pt.walkPruned(func(n *node) bool {
+		if isV4(n.addr, n.bits) {
+			return int(n.bits)-96 > maxBits
+		}
+		// A node containing the IPv4 networks must be descended into regardless of its own prefix length.
+		return int(n.bits) > maxBits && !(n.bits <= 96 && n.contains(v4Prefix))
+	}, func(n *node) bool {
+		if !isV4(n.addr, n.bits) && int(n.bits) > maxBits {
+			return true
+		}
+		return fn(pt.resultNetwork(n), unempty(n.value))
+	})
+}
+
+// WalkFilter is like Walk, but calls prefixFilter with the network of each node before visiting it, and skips the node
+// along with everything beneath it if prefixFilter returns false. This allows a scan to be limited to a region of the
+// trie without visiting the rest of it.
+//
+// prefixFilter is called for the implicit nodes joining entries as well as for the entries themselves, so it should
+// return true for any network which overlaps the region of interest, not only those within it. The networks passed to
+// prefixFilter are always normalized to IPv6, regardless of SetDenormalize, as the nodes above the IPv4 networks have
+// no IPv4 form. For example, to only visit the entries within 10.0.0.0/8:
+//
+//	region := netip.MustParsePrefix("::ffff:10.0.0.0/104")
+//	trie.WalkFilter(region.Overlaps, fn)
+func (pt *Trie) WalkFilter(prefixFilter func(network netip.Prefix) bool, fn func(network netip.Prefix, value any) bool) {
+	if pt.instr != nil {
+		var done func()
+		fn, done = pt.instrumentWalk(fn)
+		defer done()
+	}
+	pt.walkPruned(func(n *node) bool {
+		return !prefixFilter(n.network())
+	}, func(n *node) bool {
+		return fn(pt.resultNetwork(n), unempty(n.value))
+	})
+}
+
+// WalkLeaves is like Walk, but only visits the most specific entries: those which don't contain any other entry. This
+// is the set of networks needed for a flat list, such as an ACL, in which only the most specific match matters.
+func (pt *Trie) WalkLeaves(fn func(network netip.Prefix, value any) bool) {
+	if pt.instr != nil {
+		var done func()
+		fn, done = pt.instrumentWalk(fn)
+		defer done()
+	}
+	pt.walk(func(n *node) bool {
+		// Implicit nodes only exist to join entries, so a node with any children contains another entry.
+		if n.children[0] != nil || n.children[1] != nil {
+			return true
+		}
+		return fn(pt.resultNetwork(n), unempty(n.value))
+	})
+}
@@ -0,0 +1,124 @@
+package iptrie
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// walkNetworks returns the networks visited by walk as strings.
+func walkNetworks(walk func(fn func(network netip.Prefix, value any) bool)) []string {
+	var networks []string
+	walk(func(network netip.Prefix, value any) bool {
+		networks = append(networks, network.String())
+		return true
+	})
+	return networks
+}
+
+func TestTrieWalkMaxBits(t *testing.T) {
+	trie := NewTrie()
+	trie.SetDenormalize(true)
+	for _, network := range []string{
+		"::/0",
+		"10.0.0.0/8",
+		"10.1.0.0/16",
+		"10.1.2.0/24",
+		"10.1.2.3/32",
+		"192.0.2.0/25",
+		"2001:d00::/24",
+		"2001:db8::/32",
+		"2001:db8::1/128",
+	} {
+		trie.Insert(netip.MustParsePrefix(network), network)
+	}
+
+	assert.Equal(t, []string{"::/0", "10.0.0.0/8", "10.1.0.0/16", "10.1.2.0/24", "2001:d00::/24"},
+		walkNetworks(func(fn func(netip.Prefix, any) bool) { trie.WalkMaxBits(24, fn) }))
+	assert.Equal(t, []string{"::/0", "10.0.0.0/8"},
+		walkNetworks(func(fn func(netip.Prefix, any) bool) { trie.WalkMaxBits(8, fn) }))
+	assert.Equal(t, walkNetworks(trie.Walk),
+		walkNetworks(func(fn func(netip.Prefix, any) bool) { trie.WalkMaxBits(128, fn) }))
+
+	var visited int
+	trie.WalkMaxBits(32, func(netip.Prefix, any) bool {
+		visited++
+		return visited < 2
+	})
+	assert.Equal(t, 2, visited)
+}
+
+func TestTrieWalkFilter(t *testing.T) {
+	trie := NewTrie()
+	for _, network := range []string{
+		"10.0.0.0/8",
+		"10.1.0.0/16",
+		"10.1.2.0/24",
+		"10.200.0.0/16",
+		"10.200.1.0/24",
+		"192.0.2.0/24",
+		"192.0.2.128/25",
+		"2001:db8::/32",
+	} {
+		trie.Insert(netip.MustParsePrefix(network), network)
+	}
+
+	region := netip.MustParsePrefix("::ffff:10.1.0.0/112")
+	var filtered []string
+	trie.WalkFilter(func(network netip.Prefix) bool {
+		filtered = append(filtered, network.String())
+		return region.Overlaps(network)
+	}, func(network netip.Prefix, value any) bool {
+		return true
+	})
+	// The subtrees of 10.200.0.0/16 and 192.0.2.0/24 are not descended into.
+	assert.Equal(t, []string{
+		"::/0",
+		"::/2",
+		"::ffff:0.0.0.0/96",
+		"::ffff:10.0.0.0/104",
+		"::ffff:10.1.0.0/112",
+		"::ffff:10.1.2.0/120",
+		"::ffff:10.200.0.0/112",
+		"::ffff:192.0.2.0/120",
+		"2001:db8::/32",
+	}, filtered)
+
+	trie.SetDenormalize(true)
+	assert.Equal(t, []string{"10.0.0.0/8", "10.1.0.0/16", "10.1.2.0/24"},
+		walkNetworks(func(fn func(netip.Prefix, any) bool) { trie.WalkFilter(region.Overlaps, fn) }))
+	region = netip.MustParsePrefix("::ffff:10.1.2.0/120")
+	assert.Equal(t, []string{"10.0.0.0/8", "10.1.0.0/16", "10.1.2.0/24"},
+		walkNetworks(func(fn func(netip.Prefix, any) bool) { trie.WalkFilter(region.Overlaps, fn) }))
+	region = netip.MustParsePrefix("2001:db8::/48")
+	assert.Equal(t, []string{"2001:db8::/32"},
+		walkNetworks(func(fn func(netip.Prefix, any) bool) { trie.WalkFilter(region.Overlaps, fn) }))
+}
+
+func TestTrieWalkLeaves(t *testing.T) {
+	trie := NewTrie()
+	assert.Empty(t, walkNetworks(trie.WalkLeaves))
+
+	trie.Insert(netip.MustParsePrefix("::/0"), "default")
+	assert.Equal(t, []string{"::/0"}, walkNetworks(trie.WalkLeaves))
+
+	trie.SetDenormalize(true)
+	for _, network := range []string{
+		"10.0.0.0/8",
+		"10.1.0.0/16",
+		"10.1.2.0/24",
+		"10.2.0.0/16",
+		"192.0.2.0/24",
+		"2001:db8::/32",
+		"2001:db8::1/128",
+	} {
+		trie.Insert(netip.MustParsePrefix(network), network)
+	}
+	assert.Equal(t, []string{"10.1.2.0/24", "10.2.0.0/16", "192.0.2.0/24", "2001:db8::1/128"},
+		walkNetworks(trie.WalkLeaves))
+
+	trie.Remove(netip.MustParsePrefix("2001:db8::1/128"))
+	assert.Equal(t, []string{"10.1.2.0/24", "10.2.0.0/16", "192.0.2.0/24", "2001:db8::/32"},
+		walkNetworks(trie.WalkLeaves))
+}
@@ -0,0 +1,95 @@
+package iptrie
+
+import (
+	"context"
+	"io"
+	"os"
+	"time"
+)
+
+// Watcher keeps an AtomicTrie loaded with the contents of a file, such as a blocklist which is updated in place by
+// another process. When the file's modification time or size changes, a new trie is built from it in the background,
+// and swapped into the AtomicTrie once complete.
+//
+// The file is polled rather than watched with OS notifications, so changes are noticed within one interval. Files which
+// are replaced by renaming a new file over them, as is recommended to avoid reading a partially written file, are
+// handled the same as files modified in place.
+type Watcher struct {
+	// OnReload, if set, is called with the new trie after each successful reload.
+	OnReload func(pt *Trie)
+	// OnError, if set, is called with the error of each failed reload. The previous trie remains in place.
+	OnError func(err error)
+
+	path    string
+	target  *AtomicTrie
+	load    func(r io.Reader) (*Trie, error)
+	modTime time.Time
+	size    int64
+}
+
+// NewWatcher creates a Watcher which loads the file at path into target using load. If load is nil, the file is read
+// with LoadCIDRList, with a nil value for each entry.
+//
+// OnReload and OnError may be set on the returned Watcher before it is first used.
+func NewWatcher(path string, target *AtomicTrie, load func(r io.Reader) (*Trie, error)) *Watcher {
+	if load == nil {
+		load = func(r io.Reader) (*Trie, error) {
+			pt := NewTrie()
+			return pt, pt.LoadCIDRList(r, nil)
+		}
+	}
+	return &Watcher{
+		path:   path,
+		target: target,
+		load:   load,
+	}
+}
+
+// Check reloads the file if it has changed since it was last loaded, or if it hasn't been loaded yet, returning whether
+// it was reloaded. If an error occurs, the trie is left unchanged, and the file will be tried again by the next Check
+// even if it hasn't changed.
+//
+// Check must not be called concurrently with itself or Run.
+func (w *Watcher) Check() (bool, error) {
+	fi, err := os.Stat(w.path)
+	if err != nil {
+		return false, err
+	}
+	if fi.ModTime().Equal(w.modTime) && fi.Size() == w.size {
+		return false, nil
+	}
+
+	f, err := os.Open(w.path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	pt, err := w.load(f)
+	if err != nil {
+		return false, err
+	}
+	w.target.Store(pt)
+	w.modTime, w.size = fi.ModTime(), fi.Size()
+	return true, nil
+}
+
+// Run calls Check every interval, starting immediately, until ctx is done, reporting reloads and errors to OnReload and
+// OnError. It returns the context's error.
+func (w *Watcher) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		reloaded, err := w.Check()
+		switch {
+		case err != nil && w.OnError != nil:
+			w.OnError(err)
+		case reloaded && w.OnReload != nil:
+			w.OnReload(w.target.Load())
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package iptrie
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeWatched writes content to path, with a modification time of mtime so that changes are detected regardless of
+// the resolution of the filesystem's timestamps.
+func writeWatched(t *testing.T, path, content string, mtime time.Time) {
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	require.NoError(t, os.Chtimes(path, mtime, mtime))
+}
+
+func TestWatcherCheck(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "list.txt")
+	mtime := time.Now().Add(-time.Hour)
+	writeWatched(t, path, "10.0.0.0/8\n", mtime)
+
+	at := NewAtomicTrie(nil)
+	w := NewWatcher(path, at, nil)
+	reloaded, err := w.Check()
+	require.NoError(t, err)
+	assert.True(t, reloaded)
+	assert.True(t, at.Load().Contains(netip.MustParseAddr("10.0.0.1")))
+
+	reloaded, err = w.Check()
+	require.NoError(t, err)
+	assert.False(t, reloaded)
+
+	writeWatched(t, path, "192.0.2.0/24\n", mtime.Add(time.Second))
+	reloaded, err = w.Check()
+	require.NoError(t, err)
+	assert.True(t, reloaded)
+	assert.False(t, at.Load().Contains(netip.MustParseAddr("10.0.0.1")))
+	assert.True(t, at.Load().Contains(netip.MustParseAddr("192.0.2.1")))
+
+	// A failed reload leaves the previous trie in place, and is retried.
+	writeWatched(t, path, "bogus\n", mtime.Add(2*time.Second))
+	_, err = w.Check()
+	assert.ErrorContains(t, err, "line 1")
+	assert.True(t, at.Load().Contains(netip.MustParseAddr("192.0.2.1")))
+	_, err = w.Check()
+	assert.Error(t, err)
+
+	require.NoError(t, os.Remove(path))
+	_, err = w.Check()
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestWatcherRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "list.txt")
+	mtime := time.Now().Add(-time.Hour)
+	writeWatched(t, path, "10.0.0.0/8 a\n", mtime)
+
+	at := NewAtomicTrie(nil)
+	w := NewWatcher(path, at, func(r io.Reader) (*Trie, error) {
+		pt := NewTrie()
+		err := pt.LoadCIDRList(r, func(line string) (netip.Prefix, any, error) {
+			cidr, value, _ := strings.Cut(line, " ")
+			if value == "fail" {
+				return netip.Prefix{}, nil, errors.New("fail")
+			}
+			network, err := netip.ParsePrefix(cidr)
+			return network, value, err
+		})
+		return pt, err
+	})
+	reloads := make(chan *Trie, 10)
+	errs := make(chan error, 10)
+	w.OnReload = func(pt *Trie) { reloads <- pt }
+	w.OnError = func(err error) {
+		// The failing file is retried every interval, so drop the errors which aren't being waited for.
+		select {
+		case errs <- err:
+		default:
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- w.Run(ctx, time.Millisecond) }()
+
+	pt := <-reloads
+	assert.Equal(t, "a", pt.Find(netip.MustParseAddr("10.0.0.1")))
+
+	writeWatched(t, path, "10.0.0.0/8 fail\n", mtime.Add(time.Second))
+	assert.ErrorContains(t, <-errs, "fail")
+	assert.Equal(t, "a", at.Load().Find(netip.MustParseAddr("10.0.0.1")))
+
+	writeWatched(t, path, "10.0.0.0/8 b\n", mtime.Add(2*time.Second))
+	pt = <-reloads
+	assert.Equal(t, "b", pt.Find(netip.MustParseAddr("10.0.0.1")))
+	assert.Same(t, pt, at.Load())
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}